@@ -0,0 +1,235 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Command scan scans a local OCI image layout or image archive with the scanner adapter API,
+// e.g. in the CI pipelines before the image is pushed to Harbor.
+//
+//	scan -server http://127.0.0.1:8080 -mime "application/vnd.security.cis.report; version=1.0" oci-layout:./image
+//
+// The adapter must be able to read the local path, i.e. it runs on the same host or shares the
+// filesystem, `scanner.local.enabled` must be on and the path must be under `scanner.local.paths`.
+// The report is written to stdout by default.
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/cis"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// options of the command.
+type options struct {
+	server     string
+	token      string
+	mimetype   string
+	repository string
+	tag        string
+	output     string
+	timeout    time.Duration
+}
+
+func main() {
+	ops := &options{}
+	flag.StringVar(&ops.server, "server", "http://127.0.0.1:8080", "base URL of the scanner adapter API server")
+	flag.StringVar(&ops.token, "token", os.Getenv("SCANNER_ADAPTER_TOKEN"), "bearer token of the scanner adapter API, SCANNER_ADAPTER_TOKEN by default")
+	flag.StringVar(&ops.mimetype, "mime", cis.ReportMimeType, "mime type of the report to generate")
+	flag.StringVar(&ops.repository, "repository", "", "repository name recorded in the report, the base name of the path by default")
+	flag.StringVar(&ops.tag, "tag", "", "tag of the image in the layout or archive, required if it contains multiple images")
+	flag.StringVar(&ops.output, "o", "", "file to write the report, stdout by default")
+	flag.DurationVar(&ops.timeout, "timeout", 10*time.Minute, "timeout of the scan")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [options] oci-layout:<path> | docker-archive:<path>\n", os.Args[0])
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), ops.timeout)
+	defer cancel()
+
+	if err := run(ctx, ops, flag.Arg(0)); err != nil {
+		fmt.Fprintf(os.Stderr, "scan error: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, ops *options, reference string) error {
+	artifact, err := resolve(ctx, ops, reference)
+	if err != nil {
+		return err
+	}
+
+	id, err := accept(ctx, ops, artifact)
+	if err != nil {
+		return err
+	}
+
+	report, err := poll(ctx, ops, id)
+	if err != nil {
+		return err
+	}
+
+	if ops.output == "" {
+		_, err = os.Stdout.Write(report)
+		return err
+	}
+
+	return ioutil.WriteFile(ops.output, report, 0o644)
+}
+
+// resolve the artifact of the local reference.
+// The relative path is converted to the absolute one as the adapter requires.
+func resolve(ctx context.Context, ops *options, reference string) (*spec.Artifact, error) {
+	i := strings.Index(reference, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid reference %q: missing transport", reference)
+	}
+
+	p, err := filepath.Abs(reference[i+1:])
+	if err != nil {
+		return nil, err
+	}
+	reference = fmt.Sprintf("%s:%s", reference[:i], p)
+
+	src, err := oci.OpenLocal(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	content, mediaType, err := src.Manifest(ctx, ops.tag)
+	if err != nil {
+		return nil, err
+	}
+
+	repository := ops.repository
+	if repository == "" {
+		repository = strings.TrimSuffix(filepath.Base(p), filepath.Ext(p))
+	}
+
+	return &spec.Artifact{
+		Repository: repository,
+		Tag:        ops.tag,
+		Digest:     oci.Digest(content),
+		MimeType:   mediaType,
+		Reference:  reference,
+	}, nil
+}
+
+// accept submits the scan request and returns the request ID.
+func accept(ctx context.Context, ops *options, artifact *spec.Artifact) (string, error) {
+	// The registry is omitted for the local artifacts.
+	body, err := json.Marshal(map[string]interface{}{"artifact": artifact})
+	if err != nil {
+		return "", err
+	}
+
+	res, err := do(ctx, ops, http.MethodPost, "/api/v1/scan", bytes.NewReader(body), func(req *http.Request) {
+		req.Header.Set("Content-Type", "application/vnd.scanner.adapter.scan.request+json; version=1.0")
+	})
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusAccepted {
+		return "", unexpected(res)
+	}
+
+	sr := &spec.ScanResponse{}
+	if err := json.NewDecoder(res.Body).Decode(sr); err != nil {
+		return "", fmt.Errorf("decode scan response error: %w", err)
+	}
+
+	return sr.Id, nil
+}
+
+// poll the report until it's ready.
+func poll(ctx context.Context, ops *options, id string) ([]byte, error) {
+	for {
+		res, err := do(ctx, ops, http.MethodGet, fmt.Sprintf("/api/v1/scan/%s/report", id), nil, func(req *http.Request) {
+			req.Header.Set("Accept", ops.mimetype)
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		switch res.StatusCode {
+		case http.StatusOK:
+			defer res.Body.Close()
+			return ioutil.ReadAll(res.Body)
+		case http.StatusFound:
+			_ = res.Body.Close()
+
+			wait := 5 * time.Second
+			if s, err := strconv.Atoi(res.Header.Get("Refresh-After")); err == nil && s > 0 {
+				wait = time.Duration(s) * time.Second
+			}
+
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(wait):
+			}
+		default:
+			defer res.Body.Close()
+			return nil, unexpected(res)
+		}
+	}
+}
+
+// do sends the request to the adapter API.
+func do(ctx context.Context, ops *options, method, path string, body io.Reader, decorate func(req *http.Request)) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(ops.server, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if ops.token != "" {
+		req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", ops.token))
+	}
+	decorate(req)
+
+	// Do not follow the redirect of the report not ready yet.
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	return client.Do(req)
+}
+
+// unexpected returns the error of the unexpected response.
+func unexpected(res *http.Response) error {
+	msg, _ := ioutil.ReadAll(io.LimitReader(res.Body, 4096))
+	return fmt.Errorf("unexpected status code %d: %s", res.StatusCode, strings.TrimSpace(string(msg)))
+}
@@ -9,6 +9,9 @@ require (
 	github.com/spf13/viper v1.12.0
 	github.com/szlabs/goworker v0.5.1
 	go.uber.org/zap v1.19.1
+	google.golang.org/grpc v1.46.2
+	google.golang.org/protobuf v1.28.0
+	gopkg.in/yaml.v3 v3.0.0
 )
 
 require (
@@ -17,6 +20,7 @@ require (
 	github.com/fsnotify/fsnotify v1.5.4 // indirect
 	github.com/go-logr/logr v1.2.2 // indirect
 	github.com/go-logr/zapr v1.2.2 // indirect
+	github.com/golang/protobuf v1.5.2 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/magiconair/properties v1.8.6 // indirect
 	github.com/mitchellh/mapstructure v1.5.0 // indirect
@@ -32,11 +36,12 @@ require (
 	github.com/subosito/gotenv v1.3.0 // indirect
 	go.uber.org/atomic v1.7.0 // indirect
 	go.uber.org/multierr v1.6.0 // indirect
+	golang.org/x/net v0.0.0-20220520000938-2e3eb7b945c2 // indirect
 	golang.org/x/sys v0.0.0-20220520151302-bc2c85ada10a // indirect
 	golang.org/x/text v0.3.7 // indirect
+	google.golang.org/genproto v0.0.0-20220519153652-3a47de7e79bd // indirect
 	gopkg.in/ini.v1 v1.66.4 // indirect
 	gopkg.in/yaml.v2 v2.4.0 // indirect
-	gopkg.in/yaml.v3 v3.0.0 // indirect
 )
 
 replace github.com/szlabs/goworker v0.5.1 => github.com/szlabs/goworker v0.0.0-20220612152629-761bcc787c98
@@ -0,0 +1,311 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attest
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// MimeType is the base MIME type of the attestation reports and the artifact type of
+	// the attestations pushed as referrers. The report MIME type of the attestation is kept
+	// in the report parameter, see MimeTypeOf.
+	MimeType = "application/vnd.dsse.envelope.v1+json"
+	// PayloadType of the in-toto statements in the envelopes.
+	PayloadType = "application/vnd.in-toto+json"
+	// StatementType of the in-toto statements.
+	StatementType = "https://in-toto.io/Statement/v1"
+
+	// PredicateVulnerability is the cosign vulnerability predicate of the vulnerability reports.
+	PredicateVulnerability = "https://cosign.sigstore.dev/attestation/vuln/v1"
+	// PredicateCIS is the predicate of the CIS benchmark reports.
+	PredicateCIS = "https://github.com/szlabs/harbor-scanner-adapter/attestation/cis/v1"
+	// PredicateReport is the predicate of the other reports, e.g: the secret or license reports.
+	PredicateReport = "https://github.com/szlabs/harbor-scanner-adapter/attestation/report/v1"
+
+	// paramReport is the MIME type parameter of the attested report MIME type.
+	paramReport = "report"
+)
+
+// vulnerabilityReports are the report MIME types, without the parameters, attested with the vulnerability predicate.
+var vulnerabilityReports = []string{
+	"application/vnd.scanner.adapter.vuln.report.harbor+json",
+	"application/vnd.security.vulnerability.report",
+}
+
+// cisReport is the CIS report MIME type without the parameters.
+const cisReport = "application/vnd.security.cis.report"
+
+// Statement is the in-toto statement of the report.
+type Statement struct {
+	Type          string      `json:"_type"`
+	Subject       []Subject   `json:"subject"`
+	PredicateType string      `json:"predicateType"`
+	Predicate     interface{} `json:"predicate"`
+}
+
+// Subject of the statement, i.e. the scanned artifact.
+type Subject struct {
+	Name   string            `json:"name"`
+	Digest map[string]string `json:"digest"`
+}
+
+// VulnerabilityPredicate follows the cosign vulnerability attestation.
+// See https://github.com/sigstore/cosign/blob/main/specs/COSIGN_VULN_ATTESTATION_SPEC.md.
+type VulnerabilityPredicate struct {
+	Scanner  VulnerabilityScanner `json:"scanner"`
+	Metadata Metadata             `json:"metadata"`
+}
+
+// VulnerabilityScanner of the vulnerability predicate with the report as the result.
+type VulnerabilityScanner struct {
+	URI     string          `json:"uri"`
+	Version string          `json:"version,omitempty"`
+	Result  json.RawMessage `json:"result"`
+}
+
+// ReportPredicate wraps the CIS report or the other reports as they are.
+type ReportPredicate struct {
+	MimeType string          `json:"mimeType"`
+	Scanner  *spec.Scanner   `json:"scanner,omitempty"`
+	Metadata Metadata        `json:"metadata"`
+	Report   json.RawMessage `json:"report"`
+}
+
+// Metadata of the scan.
+type Metadata struct {
+	ScanStartedOn  *time.Time `json:"scanStartedOn,omitempty"`
+	ScanFinishedOn *time.Time `json:"scanFinishedOn,omitempty"`
+}
+
+// reportHeader is the common fields of the reports.
+type reportHeader struct {
+	GeneratedAt time.Time      `json:"generated_at"`
+	Artifact    *spec.Artifact `json:"artifact"`
+	Scanner     *spec.Scanner  `json:"scanner"`
+}
+
+// Enabled checks whether the attestations are enabled by `scanner.attestations.enabled`.
+func Enabled() bool {
+	return viper.GetBool("scanner.attestations.enabled")
+}
+
+// Attests checks whether the reports of the MIME type are attested.
+// All the reports are attested if `scanner.attestations.mimeTypes` is empty, the parameters of
+// the MIME type are ignored if they're not specified in the list.
+func Attests(reportMimeType string) bool {
+	if !Enabled() {
+		return false
+	}
+
+	mts := viper.GetStringSlice("scanner.attestations.mimeTypes")
+	if len(mts) == 0 {
+		return true
+	}
+
+	for _, mt := range mts {
+		if mt == reportMimeType || mt == baseType(reportMimeType) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// MimeTypeOf returns the MIME type of the attestation of the report MIME type,
+// e.g: application/vnd.dsse.envelope.v1+json; report="application/vnd.security.cis.report; version=1.0".
+func MimeTypeOf(reportMimeType string) string {
+	return mime.FormatMediaType(MimeType, map[string]string{paramReport: reportMimeType})
+}
+
+// ReportMimeTypeOf returns the report MIME type of the attestation MIME type.
+// False is returned if it's not an attestation MIME type.
+func ReportMimeTypeOf(mimeType string) (string, bool) {
+	mt, params, err := mime.ParseMediaType(mimeType)
+	if err != nil || mt != MimeType || params[paramReport] == "" {
+		return "", false
+	}
+
+	return params[paramReport], true
+}
+
+// PredicateTypeOf returns the predicate type of the report MIME type.
+func PredicateTypeOf(reportMimeType string) string {
+	mt := baseType(reportMimeType)
+	switch {
+	case mt == cisReport:
+		return PredicateCIS
+	case contains(vulnerabilityReports, mt):
+		return PredicateVulnerability
+	default:
+		return PredicateReport
+	}
+}
+
+// NewStatement creates the in-toto statement of the report with the scanned artifact as the subject.
+func NewStatement(reportMimeType string, report []byte) (*Statement, error) {
+	h := &reportHeader{}
+	if err := json.Unmarshal(report, h); err != nil {
+		return nil, fmt.Errorf("unmarshal report error: %w", err)
+	}
+
+	if h.Artifact == nil || h.Artifact.Digest == "" {
+		return nil, fmt.Errorf("report has no artifact digest")
+	}
+
+	algo, encoded := splitDigest(h.Artifact.Digest)
+	if _, err := hex.DecodeString(encoded); err != nil || algo == "" {
+		return nil, fmt.Errorf("invalid artifact digest %q", h.Artifact.Digest)
+	}
+
+	md := Metadata{}
+	if !h.GeneratedAt.IsZero() {
+		md.ScanFinishedOn = &h.GeneratedAt
+	}
+
+	st := &Statement{
+		Type: StatementType,
+		Subject: []Subject{
+			{
+				Name:   h.Artifact.Repository,
+				Digest: map[string]string{algo: encoded},
+			},
+		},
+		PredicateType: PredicateTypeOf(reportMimeType),
+	}
+
+	switch st.PredicateType {
+	case PredicateVulnerability:
+		p := &VulnerabilityPredicate{Metadata: md, Scanner: VulnerabilityScanner{Result: report}}
+		if h.Scanner != nil {
+			p.Scanner.URI = h.Scanner.Name
+			p.Scanner.Version = h.Scanner.Version
+		}
+		st.Predicate = p
+	default:
+		st.Predicate = &ReportPredicate{
+			MimeType: reportMimeType,
+			Scanner:  h.Scanner,
+			Metadata: md,
+			Report:   report,
+		}
+	}
+
+	return st, nil
+}
+
+// Attester signs the statements of the reports.
+type Attester struct {
+	signer Signer
+}
+
+var (
+	defaultAttester *Attester
+	defaultErr      error
+	once            sync.Once
+)
+
+// Default returns the attester with the signer configured by `scanner.attestations.signer`.
+// The error of loading the signer is kept and returned on each call.
+func Default() (*Attester, error) {
+	once.Do(func() {
+		var signer Signer
+		switch typ := viper.GetString("scanner.attestations.signer.type"); typ {
+		case "", SignerFile:
+			signer, defaultErr = LoadKeySigner(viper.GetString("scanner.attestations.signer.keyPath"))
+		case SignerCommand:
+			signer, defaultErr = NewCommandSigner(
+				viper.GetStringSlice("scanner.attestations.signer.command"),
+				viper.GetString("scanner.attestations.signer.publicKey"),
+				viper.GetString("scanner.attestations.signer.keyID"),
+			)
+		default:
+			defaultErr = fmt.Errorf("unknown signer type %q", typ)
+		}
+
+		if defaultErr != nil {
+			defaultErr = fmt.Errorf("load attestation signer error: %w", defaultErr)
+			return
+		}
+
+		defaultAttester = NewAttester(signer)
+	})
+
+	return defaultAttester, defaultErr
+}
+
+// NewAttester creates the attester with the signer.
+func NewAttester(signer Signer) *Attester {
+	return &Attester{signer: signer}
+}
+
+// Attest wraps the report in the in-toto statement and signs it as the DSSE envelope.
+func (a *Attester) Attest(reportMimeType string, report []byte) ([]byte, error) {
+	st, err := NewStatement(reportMimeType, report)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(st)
+	if err != nil {
+		return nil, fmt.Errorf("marshal statement error: %w", err)
+	}
+
+	env, err := Sign(a.signer, PayloadType, payload)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(env)
+}
+
+// baseType returns the MIME type without the parameters.
+func baseType(mimeType string) string {
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = mimeType[:i]
+	}
+
+	return strings.TrimSpace(mimeType)
+}
+
+// splitDigest splits the digest into the algorithm and the encoded value.
+func splitDigest(dgst string) (string, string) {
+	parts := strings.SplitN(dgst, ":", 2)
+	if len(parts) != 2 {
+		return "", ""
+	}
+
+	return parts[0], parts[1]
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,106 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+)
+
+// Envelope is the DSSE envelope of the signed payload.
+// See https://github.com/secure-systems-lab/dsse/blob/master/envelope.md.
+type Envelope struct {
+	PayloadType string      `json:"payloadType"`
+	Payload     string      `json:"payload"`
+	Signatures  []Signature `json:"signatures"`
+}
+
+// Signature of the envelope.
+type Signature struct {
+	KeyID string `json:"keyid,omitempty"`
+	Sig   string `json:"sig"`
+}
+
+// PAE returns the pre-authentication encoding of the payload, which is the content signed.
+func PAE(payloadType string, payload []byte) []byte {
+	var b bytes.Buffer
+	_, _ = fmt.Fprintf(&b, "DSSEv1 %d %s %d ", len(payloadType), payloadType, len(payload))
+	b.Write(payload)
+
+	return b.Bytes()
+}
+
+// Sign the payload with the signer and wrap it in the envelope.
+func Sign(signer Signer, payloadType string, payload []byte) (*Envelope, error) {
+	sig, err := signer.Sign(PAE(payloadType, payload))
+	if err != nil {
+		return nil, fmt.Errorf("sign payload error: %w", err)
+	}
+
+	return &Envelope{
+		PayloadType: payloadType,
+		Payload:     base64.StdEncoding.EncodeToString(payload),
+		Signatures: []Signature{
+			{
+				KeyID: signer.KeyID(),
+				Sig:   base64.StdEncoding.EncodeToString(sig),
+			},
+		},
+	}, nil
+}
+
+// DecodePayload decodes the payload of the envelope without verifying the signatures.
+func (e *Envelope) DecodePayload() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(e.Payload)
+}
+
+// Verify checks whether any signature of the envelope is verified by the verifier,
+// and returns the decoded payload.
+func (e *Envelope) Verify(verifier Verifier) ([]byte, error) {
+	payload, err := e.DecodePayload()
+	if err != nil {
+		return nil, fmt.Errorf("decode payload error: %w", err)
+	}
+
+	pae := PAE(e.PayloadType, payload)
+	for _, s := range e.Signatures {
+		sig, err := base64.StdEncoding.DecodeString(s.Sig)
+		if err != nil {
+			continue
+		}
+
+		if verifier.Verify(pae, sig) == nil {
+			return payload, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no signature of the envelope is verified")
+}
+
+// ParseEnvelope parses the JSON encoded envelope.
+func ParseEnvelope(content []byte) (*Envelope, error) {
+	e := &Envelope{}
+	if err := json.Unmarshal(content, e); err != nil {
+		return nil, fmt.Errorf("unmarshal DSSE envelope error: %w", err)
+	}
+
+	if e.PayloadType == "" || len(e.Signatures) == 0 {
+		return nil, fmt.Errorf("invalid DSSE envelope: missing payload type or signatures")
+	}
+
+	return e, nil
+}
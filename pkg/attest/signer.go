@@ -0,0 +1,294 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package attest
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const (
+	// SignerFile signs with the ECDSA or Ed25519 private key of the PEM file.
+	SignerFile = "file"
+	// SignerCommand signs with an external command, e.g: the wrapper of the PKCS#11 tools or the KMS CLI.
+	SignerCommand = "command"
+
+	// commandTimeout limits the run of the signing command.
+	commandTimeout = 30 * time.Second
+)
+
+// Signer signs the DSSE pre-authentication encoding of the payloads.
+type Signer interface {
+	// KeyID identifies the key verifying the signatures, it can be empty.
+	KeyID() string
+	// Sign the content and return the raw signature.
+	Sign(content []byte) ([]byte, error)
+}
+
+// Verifier verifies the signatures of the content.
+type Verifier interface {
+	// Verify the raw signature of the content.
+	Verify(content, sig []byte) error
+}
+
+// KeyID returns the hex encoded sha256 of the PKIX encoded public key.
+func KeyID(pub crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return "", err
+	}
+
+	sum := sha256.Sum256(der)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// keySigner signs with the ECDSA or Ed25519 private key in process.
+type keySigner struct {
+	key   crypto.Signer
+	keyID string
+}
+
+// LoadKeySigner loads the unencrypted ECDSA or Ed25519 private key in PKCS#8 or SEC 1 PEM format.
+func LoadKeySigner(path string) (Signer, error) {
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("read private key error: %w", err)
+	}
+
+	key, err := ParsePrivateKey(content)
+	if err != nil {
+		return nil, err
+	}
+
+	return NewKeySigner(key)
+}
+
+// NewKeySigner creates the signer of the ECDSA or Ed25519 private key.
+func NewKeySigner(key crypto.Signer) (Signer, error) {
+	switch key.(type) {
+	case *ecdsa.PrivateKey, ed25519.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T, only ECDSA and Ed25519 are supported", key)
+	}
+
+	id, err := KeyID(key.Public())
+	if err != nil {
+		return nil, err
+	}
+
+	return &keySigner{key: key, keyID: id}, nil
+}
+
+// ParsePrivateKey parses the first private key of the PEM content.
+func ParsePrivateKey(content []byte) (crypto.Signer, error) {
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			return nil, fmt.Errorf("no private key found in the PEM content")
+		}
+
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse PKCS#8 private key error: %w", err)
+			}
+
+			signer, ok := key.(crypto.Signer)
+			if !ok {
+				return nil, fmt.Errorf("unsupported private key type %T", key)
+			}
+
+			return signer, nil
+		case "EC PRIVATE KEY":
+			key, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse EC private key error: %w", err)
+			}
+
+			return key, nil
+		case "ENCRYPTED PRIVATE KEY":
+			return nil, fmt.Errorf("encrypted private keys are not supported")
+		}
+	}
+}
+
+// KeyID implements Signer.
+func (s *keySigner) KeyID() string {
+	return s.keyID
+}
+
+// Sign implements Signer.
+// ECDSA signs the digest of the content with the hash matching the curve size,
+// the signature is ASN.1 encoded. Ed25519 signs the content directly.
+func (s *keySigner) Sign(content []byte) ([]byte, error) {
+	switch k := s.key.(type) {
+	case *ecdsa.PrivateKey:
+		return ecdsa.SignASN1(rand.Reader, k, digestOf(k.Curve.Params().BitSize, content))
+	case ed25519.PrivateKey:
+		return ed25519.Sign(k, content), nil
+	default:
+		return nil, fmt.Errorf("unsupported private key type %T", s.key)
+	}
+}
+
+// keyVerifier verifies the signatures with the ECDSA or Ed25519 public key.
+type keyVerifier struct {
+	key crypto.PublicKey
+}
+
+// NewKeyVerifier creates the verifier of the ECDSA or Ed25519 public key.
+func NewKeyVerifier(key crypto.PublicKey) (Verifier, error) {
+	switch key.(type) {
+	case *ecdsa.PublicKey, ed25519.PublicKey:
+		return &keyVerifier{key: key}, nil
+	default:
+		return nil, fmt.Errorf("unsupported public key type %T, only ECDSA and Ed25519 are supported", key)
+	}
+}
+
+// ParsePublicKey parses the first PKIX public key of the PEM content.
+func ParsePublicKey(content []byte) (crypto.PublicKey, error) {
+	for {
+		var block *pem.Block
+		block, content = pem.Decode(content)
+		if block == nil {
+			return nil, fmt.Errorf("no public key found in the PEM content")
+		}
+
+		if block.Type == "PUBLIC KEY" {
+			return x509.ParsePKIXPublicKey(block.Bytes)
+		}
+	}
+}
+
+// Verify implements Verifier.
+func (v *keyVerifier) Verify(content, sig []byte) error {
+	switch k := v.key.(type) {
+	case *ecdsa.PublicKey:
+		if !ecdsa.VerifyASN1(k, digestOf(k.Curve.Params().BitSize, content), sig) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+	case ed25519.PublicKey:
+		if !ed25519.Verify(k, content, sig) {
+			return fmt.Errorf("invalid Ed25519 signature")
+		}
+	default:
+		return fmt.Errorf("unsupported public key type %T", v.key)
+	}
+
+	return nil
+}
+
+// digestOf hashes the content with the hash matching the curve size, e.g: sha256 for P-256.
+func digestOf(bits int, content []byte) []byte {
+	var h hash.Hash
+	switch {
+	case bits > 384:
+		h = sha512.New()
+	case bits > 256:
+		h = sha512.New384()
+	default:
+		h = sha256.New()
+	}
+	h.Write(content)
+
+	return h.Sum(nil)
+}
+
+// commandSigner signs with an external command.
+//
+// The content is written to the stdin of the command, and the base64 encoded signature
+// is read from the stdout. It stands in for the keys kept in the HSMs, e.g: a wrapper script
+// of pkcs11-tool, as the PKCS#11 libraries can not be loaded by the adapter.
+type commandSigner struct {
+	args  []string
+	keyID string
+}
+
+// NewCommandSigner creates the signer running the command with the arguments.
+// The key ID is derived from the PEM public key if it's set.
+func NewCommandSigner(args []string, publicKeyPath string, keyID string) (Signer, error) {
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty signing command")
+	}
+
+	if publicKeyPath != "" {
+		content, err := ioutil.ReadFile(publicKeyPath)
+		if err != nil {
+			return nil, fmt.Errorf("read public key error: %w", err)
+		}
+
+		pub, err := ParsePublicKey(content)
+		if err != nil {
+			return nil, err
+		}
+
+		if keyID, err = KeyID(pub); err != nil {
+			return nil, err
+		}
+	}
+
+	return &commandSigner{args: args, keyID: keyID}, nil
+}
+
+// KeyID implements Signer.
+func (s *commandSigner) KeyID() string {
+	return s.keyID
+}
+
+// Sign implements Signer.
+func (s *commandSigner) Sign(content []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.args[0], s.args[1:]...)
+	cmd.Stdin = bytes.NewReader(content)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("run signing command error: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(strings.TrimSpace(stdout.String()))
+	if err != nil {
+		return nil, fmt.Errorf("decode signature of signing command error: %w", err)
+	}
+
+	if len(sig) == 0 {
+		return nil, fmt.Errorf("empty signature of signing command")
+	}
+
+	return sig, nil
+}
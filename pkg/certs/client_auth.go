@@ -0,0 +1,92 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"path"
+)
+
+const (
+	// ClientAuthRequire requires and verifies the client certificates.
+	ClientAuthRequire = "require"
+	// ClientAuthVerifyIfGiven verifies the client certificates only if they're given.
+	ClientAuthVerifyIfGiven = "verifyIfGiven"
+)
+
+// ClientAuthType converts the configured client auth mode to tls.ClientAuthType.
+func ClientAuthType(mode string) (tls.ClientAuthType, error) {
+	switch mode {
+	case "", ClientAuthRequire:
+		return tls.RequireAndVerifyClientCert, nil
+	case ClientAuthVerifyIfGiven:
+		return tls.VerifyClientCertIfGiven, nil
+	default:
+		return tls.NoClientCert, fmt.Errorf("unsupported client auth mode: %s", mode)
+	}
+}
+
+// AllowList restricts the verified client certificates by matching their subject common name
+// and subject alternative names (DNS names, URIs and emails) against glob patterns.
+// An empty allow list accepts any verified client certificate.
+type AllowList []string
+
+// Allowed checks whether the certificate matches any pattern in the allow list.
+func (al AllowList) Allowed(cert *x509.Certificate) bool {
+	if len(al) == 0 {
+		return true
+	}
+
+	names := []string{cert.Subject.CommonName}
+	names = append(names, cert.DNSNames...)
+	names = append(names, cert.EmailAddresses...)
+	for _, u := range cert.URIs {
+		names = append(names, u.String())
+	}
+
+	for _, pattern := range al {
+		for _, n := range names {
+			if n == "" {
+				continue
+			}
+
+			if ok, err := path.Match(pattern, n); err == nil && ok {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// VerifyPeerCertificate returns a func to be used as tls.Config.VerifyPeerCertificate.
+// It's called after the chain verification, so only the leaf of the verified chains is checked.
+func (al AllowList) VerifyPeerCertificate() func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+	return func(_ [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(verifiedChains) == 0 || len(verifiedChains[0]) == 0 {
+			// No client certificate is given, it's handled by the client auth type.
+			return nil
+		}
+
+		leaf := verifiedChains[0][0]
+		if !al.Allowed(leaf) {
+			return fmt.Errorf("client certificate %q is not in the allow list", leaf.Subject.String())
+		}
+
+		return nil
+	}
+}
@@ -0,0 +1,202 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package certs
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/szlabs/goworker/pkg/errs"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+)
+
+// Reloader keeps the server keypair and the client CA bundle loaded from the disk.
+// The files are checked periodically and reloaded once any of them is changed,
+// so the rotated certificates take effect without restarting the process.
+type Reloader struct {
+	certFile string
+	keyFile  string
+	caFile   string
+
+	lock     sync.RWMutex
+	cert     *tls.Certificate
+	caPool   *x509.CertPool
+	versions map[string]fileVersion
+}
+
+// fileVersion identifies a version of a file on the disk.
+type fileVersion struct {
+	modTime time.Time
+	size    int64
+}
+
+// NewReloader creates a reloader and loads the files for the first time.
+// The CA file is optional.
+func NewReloader(certFile, keyFile, caFile string) (*Reloader, error) {
+	r := &Reloader{
+		certFile: certFile,
+		keyFile:  keyFile,
+		caFile:   caFile,
+		versions: map[string]fileVersion{},
+	}
+
+	if err := r.Reload(); err != nil {
+		return nil, err
+	}
+
+	return r, nil
+}
+
+// Reload loads the server keypair and the CA bundle from the disk.
+// The loaded ones are kept if any error occurs.
+func (r *Reloader) Reload() error {
+	errorf := errs.WithPrefix("reload certificates error")
+
+	versions := map[string]fileVersion{}
+	for _, f := range r.files() {
+		v, err := stat(f)
+		if err != nil {
+			return errorf.Wrap("check file %s error", err, f)
+		}
+		versions[f] = v
+	}
+
+	cert, err := tls.LoadX509KeyPair(r.certFile, r.keyFile)
+	if err != nil {
+		return errorf.Wrap("load X509 key pair error", err)
+	}
+
+	var pool *x509.CertPool
+	if r.caFile != "" {
+		pool, err = LoadCertPool(r.caFile)
+		if err != nil {
+			return errorf.Wrap("load CA bundle error", err)
+		}
+	}
+
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.cert = &cert
+	r.caPool = pool
+	r.versions = versions
+
+	return nil
+}
+
+// Watch checks the files with the specified interval and reloads them when changed.
+// It blocks until the context is done.
+func (r *Reloader) Watch(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if !r.changed() {
+				continue
+			}
+
+			if err := r.Reload(); err != nil {
+				zlog.Logger().Errorw("certificate files changed but reload failed", "error", err)
+				continue
+			}
+
+			zlog.Logger().Infow("certificates are reloaded", "cert", r.certFile, "ca", r.caFile)
+		}
+	}
+}
+
+// GetCertificate returns the current server certificate.
+// It can be used as tls.Config.GetCertificate.
+func (r *Reloader) GetCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.cert, nil
+}
+
+// ClientCAs returns the current client CA pool.
+// Nil is returned if no CA file is configured.
+func (r *Reloader) ClientCAs() *x509.CertPool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	return r.caPool
+}
+
+func (r *Reloader) changed() bool {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	for _, f := range r.files() {
+		v, err := stat(f)
+		if err != nil {
+			// Files may be missing temporarily during the rotation.
+			continue
+		}
+
+		if v != r.versions[f] {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (r *Reloader) files() []string {
+	files := []string{r.certFile, r.keyFile}
+	if r.caFile != "" {
+		files = append(files, r.caFile)
+	}
+
+	return files
+}
+
+func stat(file string) (fileVersion, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return fileVersion{}, err
+	}
+
+	return fileVersion{
+		modTime: fi.ModTime(),
+		size:    fi.Size(),
+	}, nil
+}
+
+// LoadCertPool loads the PEM encoded certificates in the bundle file into a cert pool.
+func LoadCertPool(caFile string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid PEM certificate found in %s", caFile)
+	}
+
+	return pool, nil
+}
@@ -41,7 +41,45 @@ func Load(configPath string) error {
 	viper.SetDefault("server.protocol", "http")
 	viper.SetDefault("server.host", "0.0.0.0")
 	viper.SetDefault("server.port", "8080")
+	viper.SetDefault("server.tls.reloadInterval", "30s")
+	viper.SetDefault("server.validation.responses", false)
+	viper.SetDefault("server.legacyPaths", true)
 	viper.SetDefault("scanner.workers", 5)
+	viper.SetDefault("scanner.cache.maxSize", 10737418240)
+	viper.SetDefault("scanner.local.enabled", false)
+	viper.SetDefault("scanner.layerCache.enabled", true)
+	viper.SetDefault("scanner.layerCache.ttl", "168h")
+	viper.SetDefault("scanner.layerCache.maxEntrySize", 1048576)
+	viper.SetDefault("scanner.referrers.enabled", false)
+	viper.SetDefault("scanner.referrers.timeout", "1m")
+	viper.SetDefault("scanner.attestations.enabled", false)
+	viper.SetDefault("scanner.attestations.push", false)
+	viper.SetDefault("scanner.attestations.signer.type", "file")
+	viper.SetDefault("scanner.vex.enabled", false)
+	viper.SetDefault("scanner.vex.mode", "drop")
+	viper.SetDefault("scanner.vex.referrers", false)
+	viper.SetDefault("scanner.waivers.enabled", false)
+	viper.SetDefault("scanner.waivers.auditSize", 10000)
+	viper.SetDefault("scanner.backends.cis.enabled", true)
+	viper.SetDefault("scanner.backends.cis.engine", "dockle")
+	viper.SetDefault("scanner.backends.cis.platformReport", "merged")
+	viper.SetDefault("scanner.backends.sbom.enabled", false)
+	viper.SetDefault("scanner.backends.sbom.engine", "syft")
+	viper.SetDefault("scanner.backends.secret.enabled", false)
+	viper.SetDefault("scanner.backends.secret.maxFileSize", 1048576)
+	viper.SetDefault("scanner.backends.license.enabled", false)
+	viper.SetDefault("scanner.backends.license.maxFileSize", 134217728)
+	viper.SetDefault("scanner.backends.malware.enabled", false)
+	viper.SetDefault("scanner.backends.malware.address", "tcp://127.0.0.1:3310")
+	viper.SetDefault("scanner.backends.malware.fileTimeout", "1m")
+	viper.SetDefault("scanner.backends.malware.maxFileSize", 26214400)
+	viper.SetDefault("scanner.backends.malware.maxImageSize", 2147483648)
+	viper.SetDefault("scanner.backends.signature.enabled", false)
+	viper.SetDefault("scanner.backends.composite.enabled", false)
+	viper.SetDefault("scanner.backends.composite.strategy", "max")
+	viper.SetDefault("scanner.backends.composite.allowPartial", false)
+	viper.SetDefault("log.level", "info")
+	viper.SetDefault("log.format", "json")
 
 	// Read from env.
 	viper.SetEnvPrefix("HSA")
@@ -0,0 +1,351 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"archive/tar"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+const (
+	// DockerLayer mime type of the uncompressed layer.
+	DockerLayer = "application/vnd.docker.image.rootfs.diff.tar"
+
+	// annotationImageName is the annotation of the image name in the OCI image layout archived by docker.
+	annotationImageName = "io.containerd.image.name"
+	// archiveManifest is the manifest file of the image archive created by `docker save`.
+	archiveManifest = "manifest.json"
+)
+
+// archiveImage is an image entry of the manifest.json of the image archive.
+type archiveImage struct {
+	Config   string   `json:"Config"`
+	RepoTags []string `json:"RepoTags"`
+	Layers   []string `json:"Layers"`
+}
+
+// section of a file in the tar archive.
+type section struct {
+	offset int64
+	size   int64
+}
+
+// Archive is a Source backed by an image archive created by `docker save`.
+//
+// Both the legacy archive format and the OCI image layout archived by the recent docker
+// versions are supported. The manifests of the legacy format are synthesized as the Docker
+// v2 manifests with the uncompressed layers.
+type Archive struct {
+	path string
+	// files are the sections of the files in the archive by name.
+	files map[string]section
+	// blobs map the digests to the file names.
+	blobs map[string]string
+	// manifests are the synthesized manifests of the legacy format.
+	manifests map[string][]byte
+	// tags map the repo tags to the digests of the synthesized manifests.
+	tags map[string]string
+	// layout is set if the archive is an OCI image layout.
+	layout bool
+}
+
+// NewArchive opens the image archive at the path.
+// The files of the archive are indexed and the blobs of the legacy format are digested.
+func NewArchive(p string) (*Archive, error) {
+	f, err := os.Open(p)
+	if err != nil {
+		return nil, fmt.Errorf("open image archive error: %w", err)
+	}
+	defer f.Close()
+
+	a := &Archive{
+		path:      p,
+		files:     make(map[string]section),
+		blobs:     make(map[string]string),
+		manifests: make(map[string][]byte),
+		tags:      make(map[string]string),
+	}
+
+	cr := &countingReader{r: f}
+	tr := tar.NewReader(cr)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("read image archive error: %w", err)
+		}
+
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+
+		// The reader stops at the beginning of the file content after reading the header.
+		a.files[path.Clean(hdr.Name)] = section{offset: cr.n, size: hdr.Size}
+	}
+
+	if _, ok := a.files[OCILayout]; ok {
+		if _, ok := a.files["index.json"]; ok {
+			a.layout = true
+			return a, nil
+		}
+	}
+
+	if err := a.synthesize(); err != nil {
+		return nil, err
+	}
+
+	return a, nil
+}
+
+// Manifest implements Source.
+// The reference can be a digest, a repo tag like nginx:1.25 or the tag only.
+// An empty reference selects the only image in the archive.
+func (a *Archive) Manifest(ctx context.Context, reference string) ([]byte, string, error) {
+	if a.layout {
+		return a.layoutManifest(ctx, reference)
+	}
+
+	dgst := reference
+	if !strings.HasPrefix(reference, "sha256:") {
+		var err error
+		if dgst, err = a.lookup(reference); err != nil {
+			return nil, "", err
+		}
+	}
+
+	content, ok := a.manifests[dgst]
+	if !ok {
+		return nil, "", fmt.Errorf("manifest %s is not found in the image archive", dgst)
+	}
+
+	return content, DockerV2Image, nil
+}
+
+// Blob implements Source.
+func (a *Archive) Blob(_ context.Context, digest string) (io.ReadCloser, error) {
+	name, ok := a.blobs[digest]
+	if !ok && a.layout {
+		name = path.Join("blobs", strings.Replace(digest, ":", "/", 1))
+	}
+
+	return a.open(name)
+}
+
+// lookup the manifest digest of the repo tag.
+func (a *Archive) lookup(reference string) (string, error) {
+	if reference == "" {
+		if len(a.manifests) != 1 {
+			return "", fmt.Errorf("image archive contains %d images, the tag is required", len(a.manifests))
+		}
+
+		for dgst := range a.manifests {
+			return dgst, nil
+		}
+	}
+
+	if dgst, ok := a.tags[reference]; ok {
+		return dgst, nil
+	}
+
+	// Match the tag only.
+	var matched []string
+	for t, dgst := range a.tags {
+		if strings.HasSuffix(t, ":"+reference) {
+			matched = append(matched, dgst)
+		}
+	}
+
+	if len(matched) != 1 {
+		return "", fmt.Errorf("tag %q matches %d images in the image archive", reference, len(matched))
+	}
+
+	return matched[0], nil
+}
+
+// layoutManifest reads the manifest of the archived OCI image layout.
+func (a *Archive) layoutManifest(ctx context.Context, reference string) ([]byte, string, error) {
+	var desc *Descriptor
+	if strings.HasPrefix(reference, "sha256:") {
+		desc = &Descriptor{Digest: reference}
+	} else {
+		content, err := a.read("index.json")
+		if err != nil {
+			return nil, "", err
+		}
+
+		idx := &Index{}
+		if err := json.Unmarshal(content, idx); err != nil {
+			return nil, "", fmt.Errorf("unmarshal index.json error: %w", err)
+		}
+
+		for i, m := range idx.Manifests {
+			if reference == "" && len(idx.Manifests) == 1 ||
+				m.Annotations[AnnotationRefName] == reference ||
+				m.Annotations[annotationImageName] == reference ||
+				strings.HasSuffix(m.Annotations[annotationImageName], ":"+reference) {
+				desc = &idx.Manifests[i]
+				break
+			}
+		}
+
+		if desc == nil {
+			return nil, "", fmt.Errorf("manifest %q is not found in the image archive", reference)
+		}
+	}
+
+	rc, err := a.Blob(ctx, desc.Digest)
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(rc, maxManifestSize))
+	if err != nil {
+		return nil, "", err
+	}
+
+	mediaType := desc.MediaType
+	if mediaType == "" {
+		mediaType = mediaTypeOf(content)
+	}
+
+	return content, mediaType, nil
+}
+
+// synthesize the Docker v2 manifests of the images in the legacy format.
+func (a *Archive) synthesize() error {
+	content, err := a.read(archiveManifest)
+	if err != nil {
+		return fmt.Errorf("invalid image archive: %w", err)
+	}
+
+	var images []archiveImage
+	if err := json.Unmarshal(content, &images); err != nil {
+		return fmt.Errorf("unmarshal %s error: %w", archiveManifest, err)
+	}
+
+	for _, img := range images {
+		cfg, err := a.digest(img.Config)
+		if err != nil {
+			return err
+		}
+
+		m := &Manifest{
+			SchemaVersion: 2,
+			MediaType:     DockerV2Image,
+			Config: Descriptor{
+				MediaType: DockerImageConfig,
+				Digest:    cfg,
+				Size:      a.files[path.Clean(img.Config)].size,
+			},
+		}
+
+		for _, l := range img.Layers {
+			dgst, err := a.digest(l)
+			if err != nil {
+				return err
+			}
+
+			m.Layers = append(m.Layers, Descriptor{
+				MediaType: DockerLayer,
+				Digest:    dgst,
+				Size:      a.files[path.Clean(l)].size,
+			})
+		}
+
+		content, err := json.Marshal(m)
+		if err != nil {
+			return fmt.Errorf("marshal manifest error: %w", err)
+		}
+
+		dgst := Digest(content)
+		a.manifests[dgst] = content
+		for _, t := range img.RepoTags {
+			a.tags[t] = dgst
+		}
+	}
+
+	return nil
+}
+
+// digest computes the digest of the file and indexes the file by it.
+func (a *Archive) digest(name string) (string, error) {
+	rc, err := a.open(name)
+	if err != nil {
+		return "", err
+	}
+	defer rc.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, rc); err != nil {
+		return "", fmt.Errorf("digest %s error: %w", name, err)
+	}
+
+	dgst := fmt.Sprintf("sha256:%s", hex.EncodeToString(h.Sum(nil)))
+	a.blobs[dgst] = path.Clean(name)
+
+	return dgst, nil
+}
+
+// open the file in the archive.
+func (a *Archive) open(name string) (io.ReadCloser, error) {
+	s, ok := a.files[path.Clean(name)]
+	if !ok {
+		return nil, fmt.Errorf("%s is not found in the image archive", name)
+	}
+
+	f, err := os.Open(a.path)
+	if err != nil {
+		return nil, fmt.Errorf("open image archive error: %w", err)
+	}
+
+	return &readCloser{Reader: io.NewSectionReader(f, s.offset, s.size), closer: f}, nil
+}
+
+// read the small file in the archive.
+func (a *Archive) read(name string) ([]byte, error) {
+	rc, err := a.open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return ioutil.ReadAll(io.LimitReader(rc, maxManifestSize))
+}
+
+// countingReader counts the bytes read.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+// Read implements io.Reader.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+
+	return n, err
+}
@@ -0,0 +1,240 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// defaultTokenExpiry is the token lifetime if the token service does not return it.
+	defaultTokenExpiry = 60 * time.Second
+	// tokenExpiryMargin is subtracted from the token lifetime to avoid using an expiring token.
+	tokenExpiryMargin = 10 * time.Second
+	// maxCachedTokens triggers the cleanup of the expired tokens.
+	maxCachedTokens = 512
+)
+
+// challenge of the registry authentication.
+type challenge struct {
+	scheme  string
+	realm   string
+	service string
+}
+
+// tokenKey identifies a cached token.
+type tokenKey struct {
+	realm      string
+	service    string
+	scope      string
+	credential string
+}
+
+// cachedToken is the token with the expiry.
+type cachedToken struct {
+	token     string
+	expiresAt time.Time
+}
+
+var (
+	// challenges are the last challenges by the registry host.
+	challenges     = make(map[string]*challenge)
+	challengesLock sync.RWMutex
+
+	// tokens are the bearer tokens shared by the clients.
+	tokens     = make(map[tokenKey]*cachedToken)
+	tokensLock sync.Mutex
+)
+
+// knownChallenge returns the last challenge of the registry host.
+func knownChallenge(host string) *challenge {
+	challengesLock.RLock()
+	defer challengesLock.RUnlock()
+
+	return challenges[host]
+}
+
+// rememberChallenge keeps the challenge of the registry host.
+func rememberChallenge(host string, c *challenge) {
+	challengesLock.Lock()
+	defer challengesLock.Unlock()
+
+	challenges[host] = c
+}
+
+// scopeOf returns the token scope of the repository with the actions, e.g: repository:library/nginx:pull.
+func scopeOf(repository string, actions string) string {
+	return fmt.Sprintf("repository:%s:%s", repository, actions)
+}
+
+// credentialKey identifies the credential without keeping the password.
+func credentialKey(username, password string) string {
+	if username == "" {
+		return ""
+	}
+
+	sum := sha256.Sum256([]byte(username + ":" + password))
+	return hex.EncodeToString(sum[:])
+}
+
+// cachedBearer returns the cached token of the scope if it's not expired.
+func cachedBearer(c *challenge, scope, username, password string) string {
+	key := tokenKey{realm: c.realm, service: c.service, scope: scope, credential: credentialKey(username, password)}
+
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+
+	t, ok := tokens[key]
+	if !ok {
+		return ""
+	}
+
+	if time.Now().After(t.expiresAt) {
+		delete(tokens, key)
+		return ""
+	}
+
+	return t.token
+}
+
+// fetchBearer requests the token of the scope from the token service and caches it.
+func fetchBearer(ctx context.Context, client *http.Client, c *challenge, scope, username, password string) (string, error) {
+	u, err := url.Parse(c.realm)
+	if err != nil || c.realm == "" {
+		return "", fmt.Errorf("invalid token realm in the challenge: %q", c.realm)
+	}
+
+	q := u.Query()
+	if c.service != "" {
+		q.Set("service", c.service)
+	}
+	q.Set("scope", scope)
+	u.RawQuery = q.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return "", err
+	}
+	if username != "" {
+		req.SetBasicAuth(username, password)
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("request token error: %w", err)
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("request token: unexpected status code %d", res.StatusCode)
+	}
+
+	tk := struct {
+		Token       string `json:"token"`
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}{}
+	if err := json.NewDecoder(res.Body).Decode(&tk); err != nil {
+		return "", fmt.Errorf("decode token error: %w", err)
+	}
+
+	token := tk.Token
+	if token == "" {
+		token = tk.AccessToken
+	}
+	if token == "" {
+		return "", fmt.Errorf("empty token returned from %s", u.Host)
+	}
+
+	expiry := defaultTokenExpiry
+	if tk.ExpiresIn > 0 {
+		expiry = time.Duration(tk.ExpiresIn) * time.Second
+	}
+	if expiry > tokenExpiryMargin {
+		expiry -= tokenExpiryMargin
+	}
+
+	tokensLock.Lock()
+	defer tokensLock.Unlock()
+
+	// Drop the expired tokens to keep the cache small.
+	if len(tokens) >= maxCachedTokens {
+		now := time.Now()
+		for k, t := range tokens {
+			if now.After(t.expiresAt) {
+				delete(tokens, k)
+			}
+		}
+	}
+
+	tokens[tokenKey{realm: c.realm, service: c.service, scope: scope, credential: credentialKey(username, password)}] = &cachedToken{
+		token:     token,
+		expiresAt: time.Now().Add(expiry),
+	}
+
+	return token, nil
+}
+
+// parseChallenge parses the WWW-Authenticate header,
+// e.g. Bearer realm="https://auth.docker.io/token",service="registry.docker.io".
+func parseChallenge(challenge string) (string, map[string]string) {
+	params := make(map[string]string)
+
+	challenge = strings.TrimSpace(challenge)
+	i := strings.Index(challenge, " ")
+	if i < 0 {
+		return challenge, params
+	}
+	scheme, rest := challenge[:i], challenge[i+1:]
+
+	for len(rest) > 0 {
+		rest = strings.TrimLeft(rest, " ,")
+		eq := strings.Index(rest, "=")
+		if eq < 0 {
+			break
+		}
+		key := strings.ToLower(strings.TrimSpace(rest[:eq]))
+		rest = rest[eq+1:]
+
+		var value string
+		if strings.HasPrefix(rest, `"`) {
+			end := strings.Index(rest[1:], `"`)
+			if end < 0 {
+				value, rest = rest[1:], ""
+			} else {
+				value, rest = rest[1:end+1], rest[end+2:]
+			}
+		} else {
+			end := strings.Index(rest, ",")
+			if end < 0 {
+				value, rest = rest, ""
+			} else {
+				value, rest = rest[:end], rest[end:]
+			}
+		}
+		params[key] = value
+	}
+
+	return scheme, params
+}
@@ -0,0 +1,572 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bytes"
+	"container/list"
+	"context"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cacheMetrics of the blob cache, served by the /metrics API.
+var cacheMetrics = expvar.NewMap("blob_cache")
+
+const (
+	// metricHits counts the blobs served from the cache.
+	metricHits = "hits"
+	// metricMisses counts the blobs downloaded into the cache.
+	metricMisses = "misses"
+	// metricShared counts the requests waiting for the download of the same blob by another request.
+	metricShared = "shared"
+	// metricEvictions counts the blobs evicted from the cache.
+	metricEvictions = "evictions"
+	// metricCorrupted counts the cached blobs failed with the digest verification.
+	metricCorrupted = "corrupted"
+	// metricSize is the size of the cached blobs in bytes.
+	metricSize = "size_bytes"
+)
+
+// cache is the blob cache shared by the sources of all the providers.
+var (
+	cache     *BlobCache
+	cacheLock sync.RWMutex
+)
+
+// BlobCache is a size bounded cache of the blobs keyed by the digest.
+//
+// The cache directory is an OCI image layout, the blobs are stored under blobs/<alg>/<hex>
+// and the cached manifests are listed in the index.json with the ref name annotations.
+// The least recently used blobs are evicted once the total size exceeds the limit.
+// The concurrent requests of the same blob share one download.
+type BlobCache struct {
+	root    string
+	maxSize int64
+
+	lock     sync.Mutex
+	size     int64
+	lru      *list.List
+	entries  map[string]*list.Element
+	inflight map[string]*download
+
+	// indexLock guards the index, it's acquired after the lock if both are required.
+	indexLock sync.Mutex
+	index     *Index
+}
+
+// entry of the cached blob.
+type entry struct {
+	digest string
+	size   int64
+}
+
+// download of the blob in progress.
+type download struct {
+	done chan struct{}
+	err  error
+}
+
+// ConfigureCache enables the blob cache under the directory with the size limit in bytes.
+// The blobs cached by the previous runs are kept. An empty directory disables the cache.
+func ConfigureCache(root string, maxSize int64) error {
+	var c *BlobCache
+	if root != "" {
+		var err error
+		if c, err = OpenBlobCache(root, maxSize); err != nil {
+			return err
+		}
+	}
+
+	cacheLock.Lock()
+	defer cacheLock.Unlock()
+
+	cache = c
+
+	return nil
+}
+
+// Cached wraps the source with the blob cache if the cache is enabled.
+func Cached(src Source) Source {
+	cacheLock.RLock()
+	defer cacheLock.RUnlock()
+
+	if cache == nil {
+		return src
+	}
+
+	cs := &cachedSource{Source: src, cache: cache}
+	if r, ok := src.(*Remote); ok {
+		cs.name = fmt.Sprintf("%s/%s", r.host, r.repository)
+	}
+
+	return cs
+}
+
+// OpenBlobCache opens the blob cache under the directory and loads the cached blobs.
+func OpenBlobCache(root string, maxSize int64) (*BlobCache, error) {
+	if maxSize <= 0 {
+		return nil, fmt.Errorf("invalid blob cache size: %d", maxSize)
+	}
+
+	// Drop the partial downloads of the previous runs.
+	if err := os.RemoveAll(filepath.Join(root, "ingest")); err != nil {
+		return nil, fmt.Errorf("clean blob cache error: %w", err)
+	}
+
+	for _, dir := range []string{"ingest", filepath.Join("blobs", "sha256")} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0o700); err != nil {
+			return nil, fmt.Errorf("create blob cache error: %w", err)
+		}
+	}
+
+	if err := writeIfAbsent(filepath.Join(root, OCILayout), []byte(`{"imageLayoutVersion":"1.0.0"}`)); err != nil {
+		return nil, err
+	}
+	if err := writeIfAbsent(filepath.Join(root, "index.json"), []byte(`{"schemaVersion":2,"manifests":[]}`)); err != nil {
+		return nil, err
+	}
+
+	c := &BlobCache{
+		root:     root,
+		maxSize:  maxSize,
+		lru:      list.New(),
+		entries:  make(map[string]*list.Element),
+		inflight: make(map[string]*download),
+	}
+
+	layout, err := NewLayout(root)
+	if err != nil {
+		return nil, err
+	}
+	if c.index, err = layout.index(); err != nil {
+		return nil, err
+	}
+
+	files, err := ioutil.ReadDir(filepath.Join(root, "blobs", "sha256"))
+	if err != nil {
+		return nil, fmt.Errorf("load blob cache error: %w", err)
+	}
+
+	// Restore the usage order by the modification time, it's updated on each hit.
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].ModTime().Before(files[j].ModTime())
+	})
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+
+		dgst := fmt.Sprintf("sha256:%s", f.Name())
+		c.entries[dgst] = c.lru.PushFront(&entry{digest: dgst, size: f.Size()})
+		c.size += f.Size()
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.evict("")
+
+	// Drop the manifests removed out of the cache.
+	if err := c.unindex(func(digest string) bool {
+		_, ok := c.entries[digest]
+		return !ok
+	}); err != nil {
+		return nil, err
+	}
+
+	return c, nil
+}
+
+// Root of the OCI image layout of the cache.
+func (c *BlobCache) Root() string {
+	return c.root
+}
+
+// Open the blob from the cache, it's fetched with the function if it's not cached.
+// The digest of the cached blob is verified when it's read to the end,
+// the blob is evicted if the verification fails.
+func (c *BlobCache) Open(ctx context.Context, digest string, fetch func(ctx context.Context) (io.ReadCloser, error)) (io.ReadCloser, error) {
+	if !strings.HasPrefix(digest, "sha256:") {
+		// The blobs of the unsupported algorithms are not cached.
+		return fetch(ctx)
+	}
+
+	for {
+		if f, ok := c.hit(digest); ok {
+			vr, err := NewVerifyReader(f, digest)
+			if err != nil {
+				_ = f.Close()
+				return nil, err
+			}
+
+			return &cachedBlob{VerifyReader: vr, file: f, cache: c, digest: digest}, nil
+		}
+
+		c.lock.Lock()
+		d, ok := c.inflight[digest]
+		if !ok {
+			d = &download{done: make(chan struct{})}
+			c.inflight[digest] = d
+			c.lock.Unlock()
+
+			cacheMetrics.Add(metricMisses, 1)
+			d.err = c.download(ctx, digest, fetch)
+
+			c.lock.Lock()
+			delete(c.inflight, digest)
+			c.lock.Unlock()
+			close(d.done)
+
+			if d.err != nil {
+				return nil, d.err
+			}
+
+			continue
+		}
+		c.lock.Unlock()
+
+		cacheMetrics.Add(metricShared, 1)
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-d.done:
+		}
+
+		if d.err != nil {
+			// The download may be failed by the context of the other request, retry it.
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+
+			continue
+		}
+	}
+}
+
+// hit opens the cached blob and marks it recently used.
+func (c *BlobCache) hit(digest string) (*os.File, bool) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	e, ok := c.entries[digest]
+	if !ok {
+		return nil, false
+	}
+
+	f, err := os.Open(c.path(digest))
+	if err != nil {
+		// The blob is removed out of the cache.
+		c.remove(e)
+		return nil, false
+	}
+
+	c.lru.MoveToFront(e)
+	now := time.Now()
+	_ = os.Chtimes(f.Name(), now, now)
+	cacheMetrics.Add(metricHits, 1)
+
+	return f, true
+}
+
+// download the blob into the cache.
+// The content is written to the ingest directory and moved into the layout after it's verified.
+func (c *BlobCache) download(ctx context.Context, digest string, fetch func(ctx context.Context) (io.ReadCloser, error)) error {
+	rc, err := fetch(ctx)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	vr, err := NewVerifyReader(rc, digest)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Join(c.root, "ingest"), "blob-")
+	if err != nil {
+		return fmt.Errorf("create cached blob error: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	size, err := io.Copy(tmp, vr)
+	if err != nil {
+		return fmt.Errorf("download blob %s error: %w", digest, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write cached blob error: %w", err)
+	}
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if err := os.Rename(tmp.Name(), c.path(digest)); err != nil {
+		return fmt.Errorf("store cached blob error: %w", err)
+	}
+
+	if e, ok := c.entries[digest]; ok {
+		c.remove(e)
+	}
+	c.entries[digest] = c.lru.PushFront(&entry{digest: digest, size: size})
+	c.size += size
+	c.evict(digest)
+
+	return nil
+}
+
+// evict the least recently used blobs until the size is in the limit.
+// The blob being added is kept even if it exceeds the limit alone,
+// the following downloads will evict it.
+func (c *BlobCache) evict(keep string) {
+	for c.size > c.maxSize {
+		el := c.lru.Back()
+		if el == nil || el.Value.(*entry).digest == keep {
+			break
+		}
+
+		c.remove(el)
+		cacheMetrics.Add(metricEvictions, 1)
+	}
+
+	c.updateSize()
+}
+
+// remove the blob from the cache. The opened readers of the blob are not affected.
+func (c *BlobCache) remove(el *list.Element) {
+	e := el.Value.(*entry)
+
+	c.lru.Remove(el)
+	delete(c.entries, e.digest)
+	c.size -= e.size
+	_ = os.Remove(c.path(e.digest))
+
+	// The blob may be a manifest listed in the index.json.
+	_ = c.unindex(func(digest string) bool {
+		return digest == e.digest
+	})
+
+	c.updateSize()
+}
+
+// addManifest lists the cached manifest in the index.json with the ref name annotation.
+func (c *BlobCache) addManifest(desc Descriptor, refName string) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if _, ok := c.entries[desc.Digest]; !ok {
+		// Evicted already.
+		return nil
+	}
+
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	for _, m := range c.index.Manifests {
+		if m.Digest == desc.Digest && m.Annotations[AnnotationRefName] == refName {
+			return nil
+		}
+	}
+
+	desc.Annotations = map[string]string{AnnotationRefName: refName}
+	c.index.Manifests = append(c.index.Manifests, desc)
+
+	return c.writeIndex()
+}
+
+// unindex removes the manifests matched by the digest from the index.json.
+func (c *BlobCache) unindex(match func(digest string) bool) error {
+	c.indexLock.Lock()
+	defer c.indexLock.Unlock()
+
+	manifests := c.index.Manifests[:0]
+	for _, m := range c.index.Manifests {
+		if !match(m.Digest) {
+			manifests = append(manifests, m)
+		}
+	}
+
+	if len(manifests) == len(c.index.Manifests) {
+		return nil
+	}
+	c.index.Manifests = manifests
+
+	return c.writeIndex()
+}
+
+// writeIndex replaces the index.json atomically, the index lock should be held.
+func (c *BlobCache) writeIndex() error {
+	if c.index.Manifests == nil {
+		c.index.Manifests = []Descriptor{}
+	}
+
+	content, err := json.Marshal(c.index)
+	if err != nil {
+		return fmt.Errorf("marshal index.json error: %w", err)
+	}
+
+	tmp, err := ioutil.TempFile(filepath.Join(c.root, "ingest"), "index-")
+	if err != nil {
+		return fmt.Errorf("create index.json error: %w", err)
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if _, err := tmp.Write(content); err != nil {
+		return fmt.Errorf("write index.json error: %w", err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write index.json error: %w", err)
+	}
+
+	if err := os.Rename(tmp.Name(), filepath.Join(c.root, "index.json")); err != nil {
+		return fmt.Errorf("replace index.json error: %w", err)
+	}
+
+	return nil
+}
+
+// corrupted evicts the blob failed with the digest verification.
+func (c *BlobCache) corrupted(digest string) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if el, ok := c.entries[digest]; ok {
+		c.remove(el)
+		cacheMetrics.Add(metricCorrupted, 1)
+	}
+}
+
+func (c *BlobCache) updateSize() {
+	size := &expvar.Int{}
+	size.Set(c.size)
+	cacheMetrics.Set(metricSize, size)
+}
+
+func (c *BlobCache) path(digest string) string {
+	return filepath.Join(c.root, "blobs", "sha256", filepath.Base(strings.TrimPrefix(digest, "sha256:")))
+}
+
+// cachedBlob is the reader of the cached blob.
+type cachedBlob struct {
+	*VerifyReader
+	file   *os.File
+	cache  *BlobCache
+	digest string
+}
+
+// Read implements io.Reader.
+func (b *cachedBlob) Read(p []byte) (int, error) {
+	n, err := b.VerifyReader.Read(p)
+	if err != nil && err != io.EOF {
+		b.cache.corrupted(b.digest)
+	}
+
+	return n, err
+}
+
+// Close implements io.Closer.
+func (b *cachedBlob) Close() error {
+	return b.file.Close()
+}
+
+// cachedSource serves the blobs and the manifests referenced by digest from the blob cache.
+// The manifests referenced by tag are always fetched from the source as the tags are mutable.
+type cachedSource struct {
+	Source
+	cache *BlobCache
+	// name of the repository, e.g: core.harbor.domain/library/nginx. It's empty if unknown.
+	name string
+}
+
+// Manifest implements Source.
+func (s *cachedSource) Manifest(ctx context.Context, reference string) ([]byte, string, error) {
+	if !strings.HasPrefix(reference, "sha256:") {
+		return s.Source.Manifest(ctx, reference)
+	}
+
+	var mediaType string
+	rc, err := s.cache.Open(ctx, reference, func(ctx context.Context) (io.ReadCloser, error) {
+		content, mt, err := s.Source.Manifest(ctx, reference)
+		if err != nil {
+			return nil, err
+		}
+		mediaType = mt
+
+		return ioutil.NopCloser(bytes.NewReader(content)), nil
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(rc, maxManifestSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("read cached manifest error: %w", err)
+	}
+
+	if mediaType == "" || mediaType == "application/json" {
+		mediaType = mediaTypeOf(content)
+	}
+
+	refName := reference
+	if s.name != "" {
+		refName = fmt.Sprintf("%s@%s", s.name, reference)
+	}
+
+	// The manifest is still cached if the index.json is failed to update.
+	_ = s.cache.addManifest(Descriptor{
+		MediaType: mediaType,
+		Digest:    reference,
+		Size:      int64(len(content)),
+	}, refName)
+
+	return content, mediaType, nil
+}
+
+// Blob implements Source.
+func (s *cachedSource) Blob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	return s.cache.Open(ctx, digest, func(ctx context.Context) (io.ReadCloser, error) {
+		return s.Source.Blob(ctx, digest)
+	})
+}
+
+// writeIfAbsent writes the file if it does not exist.
+func writeIfAbsent(path string, content []byte) error {
+	if _, err := os.Stat(path); err == nil {
+		return nil
+	}
+
+	if err := ioutil.WriteFile(path, content, 0o600); err != nil {
+		return fmt.Errorf("write %s error: %w", filepath.Base(path), err)
+	}
+
+	return nil
+}
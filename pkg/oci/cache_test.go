@@ -0,0 +1,82 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCacheIndexesManifests(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		serveManifest(w)
+	}))
+	defer srv.Close()
+
+	root := t.TempDir()
+	if err := ConfigureCache(root, 1<<20); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ConfigureCache("", 0) }()
+
+	r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{})
+	src := Cached(r)
+
+	dgst := Digest([]byte(testManifest))
+	for i := 0; i < 2; i++ {
+		if _, _, err := src.Manifest(context.Background(), dgst); err != nil {
+			t.Fatalf("fetch manifest error: %v", err)
+		}
+	}
+
+	layout, err := NewLayout(root)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	idx, err := layout.index()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(idx.Manifests) != 1 {
+		t.Fatalf("expect 1 manifest in index.json, got %d", len(idx.Manifests))
+	}
+
+	refName := r.host + "/library/app@" + dgst
+	if m := idx.Manifests[0]; m.Digest != dgst || m.MediaType != OCIImage || m.Annotations[AnnotationRefName] != refName {
+		t.Errorf("unexpected index entry: %+v", m)
+	}
+
+	// The cache directory is an image layout readable by the ref name.
+	if _, _, err := layout.Manifest(context.Background(), refName); err != nil {
+		t.Errorf("read manifest by ref name error: %v", err)
+	}
+
+	// The evicted manifests are removed from the index.json.
+	if _, err := OpenBlobCache(root, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	if idx, err = layout.index(); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(idx.Manifests) != 0 {
+		t.Errorf("expect no manifest in index.json after eviction, got %d", len(idx.Manifests))
+	}
+}
@@ -0,0 +1,374 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"path"
+	"runtime"
+	"strings"
+)
+
+const (
+	// maxManifestSize limits the size of the manifest and config contents read into the memory.
+	maxManifestSize = 8 << 20
+
+	// whiteoutPrefix is the prefix of the whiteout files removing the files of the lower layers.
+	whiteoutPrefix = ".wh."
+	// whiteoutOpaque is the whiteout file removing the children of its directory in the lower layers.
+	whiteoutOpaque = ".wh..wh..opq"
+)
+
+// ErrUnsupportedCompression is returned if the layer compression is not supported.
+var ErrUnsupportedCompression = errors.New("unsupported layer compression")
+
+// Source is a storage of the image contents, e.g. the registry or the OCI image layout.
+type Source interface {
+	// Manifest fetches the manifest content and its media type by tag or digest.
+	Manifest(ctx context.Context, reference string) ([]byte, string, error)
+	// Blob opens the blob with the digest.
+	Blob(ctx context.Context, digest string) (io.ReadCloser, error)
+}
+
+// Image is an image manifest resolved from the source.
+type Image struct {
+	// Digest of the image manifest.
+	Digest string
+	// Manifest of the image.
+	Manifest *Manifest
+	// Platform of the image declared in the index, nil if the image is not resolved from an index.
+	Platform *Platform
+
+	source Source
+}
+
+// ResolveImage resolves the image manifest with the reference from the source.
+// If the reference points to an image index, the manifest matching the platform is selected.
+// The platform of the running process is used if the platform is not specified.
+func ResolveImage(ctx context.Context, src Source, reference string, platform *Platform) (*Image, error) {
+	content, mediaType, err := src.Manifest(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest %s error: %w", reference, err)
+	}
+
+	dgst, err := manifestDigest(reference, content)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsIndex(mediaType) {
+		return newImage(src, dgst, content, mediaType, nil)
+	}
+
+	idx := &Index{}
+	if err := json.Unmarshal(content, idx); err != nil {
+		return nil, fmt.Errorf("unmarshal image index error: %w", err)
+	}
+
+	desc, err := selectPlatform(idx, platform)
+	if err != nil {
+		return nil, err
+	}
+
+	return resolveChild(ctx, src, desc)
+}
+
+// ResolveImages resolves the image manifests of all the platforms with the reference from the source.
+// If the reference points to an image manifest, the image is returned as it is. If it points to an
+// image index, the manifests of the platforms accepted by the filter are returned in the index order.
+// The manifests without platforms or with the unknown platform, e.g. the attestations, are skipped.
+// A nil filter accepts all the platforms.
+func ResolveImages(ctx context.Context, src Source, reference string, filter func(*Platform) bool) ([]*Image, error) {
+	content, mediaType, err := src.Manifest(ctx, reference)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest %s error: %w", reference, err)
+	}
+
+	dgst, err := manifestDigest(reference, content)
+	if err != nil {
+		return nil, err
+	}
+
+	if !IsIndex(mediaType) {
+		img, err := newImage(src, dgst, content, mediaType, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		return []*Image{img}, nil
+	}
+
+	idx := &Index{}
+	if err := json.Unmarshal(content, idx); err != nil {
+		return nil, fmt.Errorf("unmarshal image index error: %w", err)
+	}
+
+	var images []*Image
+	for i := range idx.Manifests {
+		desc := &idx.Manifests[i]
+		if desc.Platform == nil || desc.Platform.OS == "unknown" || IsIndex(desc.MediaType) {
+			continue
+		}
+
+		if filter != nil && !filter(desc.Platform) {
+			continue
+		}
+
+		img, err := resolveChild(ctx, src, desc)
+		if err != nil {
+			return nil, err
+		}
+		images = append(images, img)
+	}
+
+	if len(images) == 0 {
+		return nil, fmt.Errorf("no platform is selected in the index")
+	}
+
+	return images, nil
+}
+
+// resolveChild resolves the image manifest of the descriptor in the index.
+func resolveChild(ctx context.Context, src Source, desc *Descriptor) (*Image, error) {
+	content, mediaType, err := src.Manifest(ctx, desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("fetch manifest %s error: %w", desc.Digest, err)
+	}
+
+	return newImage(src, desc.Digest, content, mediaType, desc.Platform)
+}
+
+// newImage verifies and parses the image manifest content.
+func newImage(src Source, dgst string, content []byte, mediaType string, platform *Platform) (*Image, error) {
+	if mediaType != OCIImage && mediaType != DockerV2Image {
+		return nil, fmt.Errorf("unsupported manifest media type: %s", mediaType)
+	}
+
+	if err := verify(dgst, content); err != nil {
+		return nil, err
+	}
+
+	m := &Manifest{}
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("unmarshal image manifest error: %w", err)
+	}
+
+	return &Image{
+		Digest:   dgst,
+		Manifest: m,
+		Platform: platform,
+		source:   src,
+	}, nil
+}
+
+// Config fetches the image config blob.
+func (img *Image) Config(ctx context.Context) (*ImageConfig, error) {
+	rc, err := img.source.Blob(ctx, img.Manifest.Config.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("fetch image config error: %w", err)
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(rc, maxManifestSize))
+	if err != nil {
+		return nil, fmt.Errorf("read image config error: %w", err)
+	}
+
+	if err := verify(img.Manifest.Config.Digest, content); err != nil {
+		return nil, err
+	}
+
+	cfg := &ImageConfig{}
+	if err := json.Unmarshal(content, cfg); err != nil {
+		return nil, fmt.Errorf("unmarshal image config error: %w", err)
+	}
+
+	return cfg, nil
+}
+
+// Layer opens the uncompressed tar stream of the layer.
+// The digest of the compressed blob is verified when the stream is read to the end.
+func (img *Image) Layer(ctx context.Context, desc Descriptor) (io.ReadCloser, error) {
+	rc, err := img.source.Blob(ctx, desc.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("fetch layer %s error: %w", desc.Digest, err)
+	}
+
+	vr, err := NewVerifyReader(rc, desc.Digest)
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	tr, err := Decompress(vr)
+	if err != nil {
+		_ = rc.Close()
+		return nil, fmt.Errorf("decompress layer %s error: %w", desc.Digest, err)
+	}
+
+	return &readCloser{Reader: tr, closer: rc}, nil
+}
+
+// Decompress detects the compression of the layer stream and returns the uncompressed tar stream.
+// Gzip and uncompressed layers are supported.
+func Decompress(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	magic, err := br.Peek(4)
+	if err != nil && err != io.EOF {
+		return nil, err
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		return gzip.NewReader(br)
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		return nil, fmt.Errorf("zstd: %w", ErrUnsupportedCompression)
+	default:
+		return br, nil
+	}
+}
+
+// Whiteout parses the whiteout file of the layer, e.g. /etc/.wh.passwd removes /etc/passwd of the lower
+// layers. The target is the removed path, opaque indicates the children of the target directory are
+// removed, e.g. by /etc/.wh..wh..opq. ok is false if the file is not a whiteout.
+func Whiteout(name string) (target string, opaque bool, ok bool) {
+	name = path.Clean("/" + name)
+	base := path.Base(name)
+
+	switch {
+	case base == whiteoutOpaque:
+		return path.Dir(name), true, true
+	case strings.HasPrefix(base, whiteoutPrefix):
+		return path.Join(path.Dir(name), strings.TrimPrefix(base, whiteoutPrefix)), false, true
+	}
+
+	return "", false, false
+}
+
+// Digest computes the sha256 digest of the content.
+func Digest(content []byte) string {
+	sum := sha256.Sum256(content)
+	return fmt.Sprintf("sha256:%s", hex.EncodeToString(sum[:]))
+}
+
+// isDigest checks whether the manifest reference is a digest instead of a tag.
+func isDigest(reference string) bool {
+	// The tags can not contain colons.
+	return strings.Contains(reference, ":")
+}
+
+// manifestDigest returns the digest of the manifest content fetched with the reference.
+// The content is verified against the reference if it's a digest.
+func manifestDigest(reference string, content []byte) (string, error) {
+	if !isDigest(reference) {
+		return Digest(content), nil
+	}
+
+	if err := verify(reference, content); err != nil {
+		return "", fmt.Errorf("verify manifest %s error: %w", reference, err)
+	}
+
+	return reference, nil
+}
+
+// verify the content with the digest.
+func verify(dgst string, content []byte) error {
+	if !strings.HasPrefix(dgst, "sha256:") {
+		// Skip the unsupported algorithms.
+		return nil
+	}
+
+	if actual := Digest(content); actual != dgst {
+		return fmt.Errorf("digest mismatch: expected=%s actual=%s", dgst, actual)
+	}
+
+	return nil
+}
+
+// VerifyReader computes the digest of the content read through it
+// and returns an error at EOF if the digest does not match.
+type VerifyReader struct {
+	r        io.Reader
+	h        hash.Hash
+	expected string
+}
+
+// NewVerifyReader creates a VerifyReader for the expected digest.
+func NewVerifyReader(r io.Reader, dgst string) (*VerifyReader, error) {
+	if !strings.HasPrefix(dgst, "sha256:") {
+		return nil, fmt.Errorf("unsupported digest algorithm: %s", dgst)
+	}
+
+	return &VerifyReader{
+		r:        r,
+		h:        sha256.New(),
+		expected: strings.TrimPrefix(dgst, "sha256:"),
+	}, nil
+}
+
+// Read implements io.Reader.
+func (vr *VerifyReader) Read(p []byte) (int, error) {
+	n, err := vr.r.Read(p)
+	vr.h.Write(p[:n])
+
+	if err == io.EOF {
+		if actual := hex.EncodeToString(vr.h.Sum(nil)); actual != vr.expected {
+			return n, fmt.Errorf("digest mismatch: expected=sha256:%s actual=sha256:%s", vr.expected, actual)
+		}
+	}
+
+	return n, err
+}
+
+// readCloser combines the reader with the closer of the underlying stream.
+type readCloser struct {
+	io.Reader
+	closer io.Closer
+}
+
+// Close implements io.Closer.
+func (rc *readCloser) Close() error {
+	return rc.closer.Close()
+}
+
+// selectPlatform selects the manifest matching the platform from the index.
+func selectPlatform(idx *Index, platform *Platform) (*Descriptor, error) {
+	if platform == nil {
+		platform = &Platform{OS: runtime.GOOS, Architecture: runtime.GOARCH}
+	}
+
+	for i, m := range idx.Manifests {
+		if m.Platform == nil {
+			continue
+		}
+
+		if m.Platform.Matches(platform) {
+			return &idx.Manifests[i], nil
+		}
+	}
+
+	return nil, fmt.Errorf("no manifest matches the platform %s/%s in the index", platform.OS, platform.Architecture)
+}
@@ -0,0 +1,97 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+)
+
+// memorySource serves the manifests by reference from the memory.
+type memorySource map[string][]byte
+
+// Manifest implements Source.
+func (s memorySource) Manifest(_ context.Context, reference string) ([]byte, string, error) {
+	content, ok := s[reference]
+	if !ok {
+		return nil, "", ErrNotFound
+	}
+
+	return content, mediaTypeOf(content), nil
+}
+
+// Blob implements Source.
+func (s memorySource) Blob(_ context.Context, _ string) (io.ReadCloser, error) {
+	return nil, ErrNotFound
+}
+
+func TestResolveImageVerifiesDigest(t *testing.T) {
+	manifest := []byte(testManifest)
+	dgst := Digest(manifest)
+
+	idx, err := json.Marshal(&Index{
+		SchemaVersion: 2,
+		MediaType:     OCIImageIndex,
+		Manifests: []Descriptor{
+			{MediaType: OCIImage, Digest: dgst, Size: int64(len(manifest)), Platform: &Platform{OS: "linux", Architecture: "amd64"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	idxDigest := Digest(idx)
+
+	tampered := []byte(strings.Replace(testManifest, `"layers":[]`, `"layers":null`, 1))
+
+	cases := []struct {
+		name      string
+		src       memorySource
+		reference string
+		wantErr   bool
+	}{
+		{name: "tag", src: memorySource{"latest": manifest, dgst: manifest}, reference: "latest"},
+		{name: "digest", src: memorySource{dgst: manifest}, reference: dgst},
+		{name: "tampered manifest", src: memorySource{dgst: tampered}, reference: dgst, wantErr: true},
+		{name: "index digest", src: memorySource{idxDigest: idx, dgst: manifest}, reference: idxDigest},
+		{name: "tampered index", src: memorySource{idxDigest: append(idx, ' '), dgst: manifest}, reference: idxDigest, wantErr: true},
+		{name: "tampered child", src: memorySource{idxDigest: idx, dgst: tampered}, reference: idxDigest, wantErr: true},
+	}
+
+	platform := &Platform{OS: "linux", Architecture: "amd64"}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			img, err := ResolveImage(context.Background(), tc.src, tc.reference, platform)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ResolveImage: expect error %v, got %v", tc.wantErr, err)
+			}
+
+			if err == nil && img.Digest != dgst {
+				t.Errorf("ResolveImage: expect digest %s, got %s", dgst, img.Digest)
+			}
+
+			images, err := ResolveImages(context.Background(), tc.src, tc.reference, nil)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ResolveImages: expect error %v, got %v", tc.wantErr, err)
+			}
+
+			if err == nil && (len(images) != 1 || images[0].Digest != dgst) {
+				t.Errorf("ResolveImages: expect the image %s, got %d images", dgst, len(images))
+			}
+		})
+	}
+}
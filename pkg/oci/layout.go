@@ -0,0 +1,111 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// AnnotationRefName is the annotation of the reference name in the OCI image layout index.
+	AnnotationRefName = "org.opencontainers.image.ref.name"
+)
+
+// Layout is a Source backed by an OCI image layout directory.
+type Layout struct {
+	root string
+}
+
+// NewLayout opens the OCI image layout at the directory.
+func NewLayout(root string) (*Layout, error) {
+	if _, err := os.Stat(filepath.Join(root, OCILayout)); err != nil {
+		return nil, fmt.Errorf("invalid OCI image layout %s: %w", root, err)
+	}
+
+	return &Layout{root: root}, nil
+}
+
+// Manifest implements Source.
+// The reference can be a digest or the ref name annotated in the index.json.
+// An empty reference selects the only manifest in the index.json.
+func (l *Layout) Manifest(_ context.Context, reference string) ([]byte, string, error) {
+	var desc *Descriptor
+	if strings.HasPrefix(reference, "sha256:") {
+		desc = &Descriptor{Digest: reference}
+	} else {
+		idx, err := l.index()
+		if err != nil {
+			return nil, "", err
+		}
+
+		for i, m := range idx.Manifests {
+			if reference == "" && len(idx.Manifests) == 1 || m.Annotations[AnnotationRefName] == reference {
+				desc = &idx.Manifests[i]
+				break
+			}
+		}
+
+		if desc == nil {
+			return nil, "", fmt.Errorf("manifest %q is not found in the OCI image layout", reference)
+		}
+	}
+
+	content, err := ioutil.ReadFile(l.blobPath(desc.Digest))
+	if err != nil {
+		return nil, "", err
+	}
+
+	mediaType := desc.MediaType
+	if mediaType == "" {
+		mediaType = mediaTypeOf(content)
+	}
+
+	return content, mediaType, nil
+}
+
+// Blob implements Source.
+func (l *Layout) Blob(_ context.Context, digest string) (io.ReadCloser, error) {
+	return os.Open(l.blobPath(digest))
+}
+
+func (l *Layout) index() (*Index, error) {
+	content, err := ioutil.ReadFile(filepath.Join(l.root, "index.json"))
+	if err != nil {
+		return nil, fmt.Errorf("read index.json error: %w", err)
+	}
+
+	idx := &Index{}
+	if err := json.Unmarshal(content, idx); err != nil {
+		return nil, fmt.Errorf("unmarshal index.json error: %w", err)
+	}
+
+	return idx, nil
+}
+
+func (l *Layout) blobPath(digest string) string {
+	segments := strings.SplitN(digest, ":", 2)
+	if len(segments) != 2 {
+		return filepath.Join(l.root, "blobs", filepath.Base(digest))
+	}
+
+	return filepath.Join(l.root, "blobs", segments[0], filepath.Base(segments[1]))
+}
@@ -0,0 +1,118 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	// TransportOCILayout is the transport of the local OCI image layout directory, e.g: oci-layout:/path.
+	TransportOCILayout = "oci-layout"
+	// TransportDockerArchive is the transport of the image archive created by `docker save`,
+	// e.g: docker-archive:/path.tar.
+	TransportDockerArchive = "docker-archive"
+)
+
+// LocalReference is the reference of an image on the local filesystem.
+type LocalReference struct {
+	// Transport of the reference, oci-layout or docker-archive.
+	Transport string
+	// Path of the OCI image layout directory or the image archive.
+	Path string
+}
+
+// String format of the reference.
+func (r *LocalReference) String() string {
+	return fmt.Sprintf("%s:%s", r.Transport, r.Path)
+}
+
+// IsLocal checks whether the reference refers to an image on the local filesystem.
+func IsLocal(reference string) bool {
+	_, err := ParseLocal(reference)
+	return err == nil
+}
+
+// ParseLocal parses the local reference like oci-layout:/path or docker-archive:/path.tar.
+// The path must be absolute.
+func ParseLocal(reference string) (*LocalReference, error) {
+	i := strings.Index(reference, ":")
+	if i < 0 {
+		return nil, fmt.Errorf("invalid local reference %q: missing transport", reference)
+	}
+
+	r := &LocalReference{
+		Transport: reference[:i],
+		Path:      reference[i+1:],
+	}
+
+	switch r.Transport {
+	case TransportOCILayout, TransportDockerArchive:
+	default:
+		return nil, fmt.Errorf("invalid local reference %q: unknown transport %q", reference, r.Transport)
+	}
+
+	if !filepath.IsAbs(r.Path) {
+		return nil, fmt.Errorf("invalid local reference %q: path must be absolute", reference)
+	}
+	r.Path = filepath.Clean(r.Path)
+
+	return r, nil
+}
+
+// OpenLocal opens the source of the local reference.
+func OpenLocal(reference string) (Source, error) {
+	r, err := ParseLocal(reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Transport == TransportDockerArchive {
+		return NewArchive(r.Path)
+	}
+
+	return NewLayout(r.Path)
+}
+
+// OpenSource opens the source of the artifact. The local reference is opened from the filesystem if
+// it's set, otherwise the registry client of the repository is created with the options and the
+// blob cache.
+func OpenSource(reference string, registryURL, repository string, options RemoteOptions) (Source, error) {
+	if reference != "" {
+		return OpenLocal(reference)
+	}
+
+	r, err := NewRemote(registryURL, repository, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return Cached(r), nil
+}
+
+// LocalImageRef builds the reference of the image in the local OCI image layout or archive with the
+// tag or digest, e.g: oci-layout:/path@sha256:... . Digest is used if it's set, otherwise tag is used.
+func LocalImageRef(reference, tag, digest string) string {
+	switch {
+	case digest != "":
+		return fmt.Sprintf("%s@%s", reference, digest)
+	case tag != "":
+		return fmt.Sprintf("%s:%s", reference, tag)
+	default:
+		return reference
+	}
+}
@@ -0,0 +1,151 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Descriptor describes the content addressed by digest.
+type Descriptor struct {
+	MediaType   string            `json:"mediaType"`
+	Digest      string            `json:"digest"`
+	Size        int64             `json:"size"`
+	URLs        []string          `json:"urls,omitempty"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	Platform    *Platform         `json:"platform,omitempty"`
+	// ArtifactType of the manifest listed in the referrers.
+	ArtifactType string `json:"artifactType,omitempty"`
+}
+
+// Platform of the image in the index.
+type Platform struct {
+	Architecture string `json:"architecture"`
+	OS           string `json:"os"`
+	Variant      string `json:"variant,omitempty"`
+}
+
+// String returns the platform in the os/arch[/variant] format.
+func (p *Platform) String() string {
+	if p.Variant != "" {
+		return fmt.Sprintf("%s/%s/%s", p.OS, p.Architecture, p.Variant)
+	}
+
+	return fmt.Sprintf("%s/%s", p.OS, p.Architecture)
+}
+
+// Matches checks whether the platform matches the pattern platform.
+// The variant is ignored if the pattern does not specify it.
+func (p *Platform) Matches(pattern *Platform) bool {
+	return p.OS == pattern.OS && p.Architecture == pattern.Architecture &&
+		(pattern.Variant == "" || p.Variant == pattern.Variant)
+}
+
+// ParsePlatform parses the platform in the os/arch[/variant] format.
+func ParsePlatform(s string) (*Platform, error) {
+	parts := strings.Split(strings.TrimSpace(s), "/")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid platform %q, expect os/arch[/variant]", s)
+	}
+
+	p := &Platform{OS: parts[0], Architecture: parts[1]}
+	if len(parts) == 3 {
+		p.Variant = parts[2]
+	}
+
+	return p, nil
+}
+
+// Manifest of the OCI image or the docker v2 image.
+type Manifest struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	ArtifactType  string            `json:"artifactType,omitempty"`
+	Config        Descriptor        `json:"config"`
+	Layers        []Descriptor      `json:"layers"`
+	Subject       *Descriptor       `json:"subject,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// Index of the OCI image index or the docker manifest list.
+type Index struct {
+	SchemaVersion int               `json:"schemaVersion"`
+	MediaType     string            `json:"mediaType,omitempty"`
+	Manifests     []Descriptor      `json:"manifests"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+}
+
+// ImageConfig is the image configuration blob.
+// Only the fields used by the scanners are kept.
+type ImageConfig struct {
+	Architecture string    `json:"architecture,omitempty"`
+	OS           string    `json:"os,omitempty"`
+	Created      time.Time `json:"created,omitempty"`
+	Config       struct {
+		User        string            `json:"User,omitempty"`
+		Env         []string          `json:"Env,omitempty"`
+		Entrypoint  []string          `json:"Entrypoint,omitempty"`
+		Cmd         []string          `json:"Cmd,omitempty"`
+		Labels      map[string]string `json:"Labels,omitempty"`
+		Healthcheck *Healthcheck      `json:"Healthcheck,omitempty"`
+	} `json:"config"`
+	RootFS struct {
+		Type    string   `json:"type"`
+		DiffIDs []string `json:"diff_ids"`
+	} `json:"rootfs"`
+	History []History `json:"history,omitempty"`
+}
+
+// Healthcheck of the image config.
+type Healthcheck struct {
+	Test []string `json:"Test,omitempty"`
+}
+
+// History of the image layers.
+type History struct {
+	Created    time.Time `json:"created,omitempty"`
+	CreatedBy  string    `json:"created_by,omitempty"`
+	EmptyLayer bool      `json:"empty_layer,omitempty"`
+}
+
+// IsIndex checks whether the media type is an image index or manifest list.
+func IsIndex(mediaType string) bool {
+	return mediaType == OCIImageIndex || mediaType == DockerManifestList
+}
+
+// mediaTypeOf detects the media type of the manifest content.
+func mediaTypeOf(content []byte) string {
+	var probe struct {
+		MediaType string            `json:"mediaType"`
+		Manifests []json.RawMessage `json:"manifests"`
+	}
+	if err := json.Unmarshal(content, &probe); err != nil {
+		return ""
+	}
+
+	if probe.MediaType != "" {
+		return probe.MediaType
+	}
+
+	// The media type is optional in the OCI manifests.
+	if probe.Manifests != nil {
+		return OCIImageIndex
+	}
+
+	return OCIImage
+}
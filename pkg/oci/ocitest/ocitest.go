@@ -0,0 +1,179 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ocitest builds the OCI image layouts for the tests of the scanners.
+package ocitest
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+)
+
+// RefName of the image written in the layout.
+const RefName = "latest"
+
+// File of a test layer.
+type File struct {
+	Name    string
+	Content []byte
+	// Mode of the file, 0644 if it's not set.
+	Mode int64
+	// Typeflag of the tar entry, a regular file if it's not set.
+	Typeflag byte
+}
+
+// Layer creates the uncompressed tar layer of the files.
+func Layer(t testing.TB, files ...File) []byte {
+	t.Helper()
+
+	buf := &bytes.Buffer{}
+	tw := tar.NewWriter(buf)
+	for _, f := range files {
+		hdr := &tar.Header{
+			Name:     f.Name,
+			Mode:     f.Mode,
+			Size:     int64(len(f.Content)),
+			Typeflag: f.Typeflag,
+		}
+		if hdr.Mode == 0 {
+			hdr.Mode = 0o644
+		}
+		if hdr.Typeflag == 0 {
+			hdr.Typeflag = tar.TypeReg
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+
+		if err := tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := tw.Write(f.Content[:hdr.Size]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	return buf.Bytes()
+}
+
+// WriteLayout writes the image of the config and the layers into the OCI image layout at the
+// directory, the image is annotated with RefName. The diffIDs of the config are filled with
+// the layers. The digest of the image manifest is returned.
+func WriteLayout(t testing.TB, dir string, config *oci.ImageConfig, layers ...[]byte) string {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Join(dir, "blobs", "sha256"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	writeBlob := func(content []byte) string {
+		dgst := oci.Digest(content)
+		if err := ioutil.WriteFile(filepath.Join(dir, "blobs", "sha256", strings.TrimPrefix(dgst, "sha256:")), content, 0o644); err != nil {
+			t.Fatal(err)
+		}
+
+		return dgst
+	}
+
+	if config == nil {
+		config = &oci.ImageConfig{}
+	}
+	cfg := *config
+	if cfg.OS == "" {
+		cfg.OS, cfg.Architecture = "linux", "amd64"
+	}
+	cfg.RootFS.Type = "layers"
+	cfg.RootFS.DiffIDs = nil
+
+	m := &oci.Manifest{SchemaVersion: 2, MediaType: oci.OCIImage, Layers: []oci.Descriptor{}}
+	for _, l := range layers {
+		dgst := writeBlob(l)
+		// The layers are not compressed.
+		cfg.RootFS.DiffIDs = append(cfg.RootFS.DiffIDs, dgst)
+		m.Layers = append(m.Layers, oci.Descriptor{
+			MediaType: "application/vnd.oci.image.layer.v1.tar",
+			Digest:    dgst,
+			Size:      int64(len(l)),
+		})
+	}
+
+	content := marshal(t, &cfg)
+	m.Config = oci.Descriptor{MediaType: oci.OCIImageConfig, Digest: writeBlob(content), Size: int64(len(content))}
+
+	content = marshal(t, m)
+	dgst := writeBlob(content)
+
+	idx := &oci.Index{
+		SchemaVersion: 2,
+		MediaType:     oci.OCIImageIndex,
+		Manifests: []oci.Descriptor{{
+			MediaType:   oci.OCIImage,
+			Digest:      dgst,
+			Size:        int64(len(content)),
+			Annotations: map[string]string{oci.AnnotationRefName: RefName},
+		}},
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.json"), marshal(t, idx), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := ioutil.WriteFile(filepath.Join(dir, oci.OCILayout), []byte(`{"imageLayoutVersion":"1.0.0"}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	return dgst
+}
+
+// Image writes the image into a temporary OCI image layout and resolves it.
+func Image(t testing.TB, config *oci.ImageConfig, layers ...[]byte) *oci.Image {
+	t.Helper()
+
+	dir := t.TempDir()
+	dgst := WriteLayout(t, dir, config, layers...)
+
+	layout, err := oci.NewLayout(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := oci.ResolveImage(context.Background(), layout, dgst, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return img
+}
+
+func marshal(t testing.TB, v interface{}) []byte {
+	t.Helper()
+
+	content, err := json.Marshal(v)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return content
+}
@@ -0,0 +1,225 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+const (
+	// actionPush is the token scope action of writing the repository.
+	actionPush = "pull,push"
+)
+
+// emptyConfig is the content of the empty config blob of the artifacts.
+var emptyConfig = []byte("{}")
+
+// PushBlob uploads the content as a blob of the repository with the monolithic upload.
+// The upload is skipped if the blob already exists.
+func (r *Remote) PushBlob(ctx context.Context, mediaType string, content []byte) (*Descriptor, error) {
+	desc := &Descriptor{
+		MediaType: mediaType,
+		Digest:    Digest(content),
+		Size:      int64(len(content)),
+	}
+
+	res, err := r.do(ctx, &request{
+		method:  http.MethodHead,
+		path:    fmt.Sprintf("/v2/%s/blobs/%s", r.repository, desc.Digest),
+		actions: actionPush,
+	})
+	if err == nil {
+		_ = res.Body.Close()
+		return desc, nil
+	}
+	if !errors.Is(err, ErrNotFound) {
+		return nil, err
+	}
+
+	res, err = r.do(ctx, &request{
+		method:   http.MethodPost,
+		path:     fmt.Sprintf("/v2/%s/blobs/uploads/", r.repository),
+		actions:  actionPush,
+		expected: []int{http.StatusAccepted},
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = res.Body.Close()
+
+	location, err := r.location(res)
+	if err != nil {
+		return nil, err
+	}
+
+	q := location.Query()
+	q.Set("digest", desc.Digest)
+	location.RawQuery = q.Encode()
+
+	res, err = r.do(ctx, &request{
+		method: http.MethodPut,
+		path:   location.String(),
+		header: http.Header{
+			"Content-Type": []string{"application/octet-stream"},
+		},
+		body:     content,
+		actions:  actionPush,
+		expected: []int{http.StatusCreated},
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = res.Body.Close()
+
+	return desc, nil
+}
+
+// PushManifest uploads the manifest content with the tag or digest reference.
+// It reports whether the registry has processed the subject of the manifest, i.e. the referrers API
+// is supported. The referrers tag schema should be maintained by the client if it's not supported.
+func (r *Remote) PushManifest(ctx context.Context, reference string, mediaType string, content []byte) (*Descriptor, bool, error) {
+	res, err := r.do(ctx, &request{
+		method: http.MethodPut,
+		path:   fmt.Sprintf("/v2/%s/manifests/%s", r.repository, reference),
+		header: http.Header{
+			"Content-Type": []string{mediaType},
+		},
+		body:     content,
+		actions:  actionPush,
+		expected: []int{http.StatusCreated},
+		// Putting the same manifest again has the same effect.
+		idempotent: true,
+	})
+	if err != nil {
+		return nil, false, err
+	}
+	_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxManifestSize))
+	_ = res.Body.Close()
+
+	return &Descriptor{
+		MediaType: mediaType,
+		Digest:    Digest(content),
+		Size:      int64(len(content)),
+	}, res.Header.Get("OCI-Subject") != "", nil
+}
+
+// PushReferrer uploads the content as an artifact referring to the subject manifest.
+// The artifact is an image manifest with the empty config and the content as its only layer.
+// The referrers tag of the subject, e.g: sha256-<hex>, is updated if the registry does not support
+// the referrers API.
+func (r *Remote) PushReferrer(ctx context.Context, subject *Descriptor, artifactType string, content []byte, annotations map[string]string) (*Descriptor, error) {
+	config, err := r.PushBlob(ctx, OCIEmpty, emptyConfig)
+	if err != nil {
+		return nil, fmt.Errorf("push config blob error: %w", err)
+	}
+
+	layer, err := r.PushBlob(ctx, artifactType, content)
+	if err != nil {
+		return nil, fmt.Errorf("push content blob error: %w", err)
+	}
+
+	m := &Manifest{
+		SchemaVersion: 2,
+		MediaType:     OCIImage,
+		ArtifactType:  artifactType,
+		Config:        *config,
+		Layers:        []Descriptor{*layer},
+		Subject: &Descriptor{
+			MediaType: subject.MediaType,
+			Digest:    subject.Digest,
+			Size:      subject.Size,
+		},
+		Annotations: annotations,
+	}
+
+	b, err := json.Marshal(m)
+	if err != nil {
+		return nil, fmt.Errorf("marshal artifact manifest error: %w", err)
+	}
+
+	desc, processed, err := r.PushManifest(ctx, Digest(b), OCIImage, b)
+	if err != nil {
+		return nil, fmt.Errorf("push artifact manifest error: %w", err)
+	}
+	desc.ArtifactType = artifactType
+	desc.Annotations = annotations
+
+	if !processed {
+		if err := r.appendReferrersTag(ctx, subject.Digest, *desc); err != nil {
+			return nil, fmt.Errorf("update referrers tag error: %w", err)
+		}
+	}
+
+	return desc, nil
+}
+
+// appendReferrersTag adds the descriptor to the index tagged with the referrers tag schema of the subject.
+func (r *Remote) appendReferrersTag(ctx context.Context, subject string, desc Descriptor) error {
+	tag := strings.Replace(subject, ":", "-", 1)
+
+	idx := &Index{SchemaVersion: 2, MediaType: OCIImageIndex}
+	content, _, err := r.Manifest(ctx, tag)
+	switch {
+	case err == nil:
+		if err := json.Unmarshal(content, idx); err != nil {
+			return fmt.Errorf("decode referrers tag error: %w", err)
+		}
+	case !errors.Is(err, ErrNotFound):
+		return err
+	}
+
+	for _, m := range idx.Manifests {
+		if m.Digest == desc.Digest {
+			return nil
+		}
+	}
+	idx.Manifests = append(idx.Manifests, desc)
+
+	b, err := json.Marshal(idx)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = r.PushManifest(ctx, tag, OCIImageIndex, b)
+	return err
+}
+
+// location parses the upload location of the response, relative to the registry base URL.
+func (r *Remote) location(res *http.Response) (*url.URL, error) {
+	loc := res.Header.Get("Location")
+	if loc == "" {
+		return nil, fmt.Errorf("missing upload location in the registry response")
+	}
+
+	base, err := url.Parse(r.base)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := base.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("invalid upload location %q: %w", loc, err)
+	}
+
+	return u, nil
+}
@@ -0,0 +1,42 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"fmt"
+	"strings"
+)
+
+// RegistryHost returns the host part of the registry URL.
+// The scheme and the trailing slashes are removed, e.g. https://core.harbor.domain/ -> core.harbor.domain.
+func RegistryHost(registryURL string) string {
+	host := registryURL
+	if i := strings.Index(host, "://"); i >= 0 {
+		host = host[i+3:]
+	}
+
+	return strings.TrimRight(host, "/")
+}
+
+// ImageRef builds the image reference with the registry URL, repository and tag or digest.
+// Tag is used if it's set, otherwise digest is used.
+func ImageRef(registryURL, repository, tag, digest string) string {
+	ref := fmt.Sprintf("%s/%s", RegistryHost(registryURL), repository)
+	if tag != "" {
+		return fmt.Sprintf("%s:%s", ref, tag)
+	}
+
+	return fmt.Sprintf("%s@%s", ref, digest)
+}
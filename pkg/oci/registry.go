@@ -0,0 +1,342 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// mirrorProbeTimeout is the timeout of probing the availability of a mirror.
+	mirrorProbeTimeout = 5 * time.Second
+	// mirrorProbeTTL is how long the availability of a mirror is reused by the following requests.
+	mirrorProbeTTL = 30 * time.Second
+)
+
+// RegistryConfig is the settings of a registry host, which override the options of the clients.
+type RegistryConfig struct {
+	// Host of the registry with the port if it's not the default one, e.g: core.harbor.domain:8443.
+	Host string `mapstructure:"host"`
+	// Insecure skips the TLS verification.
+	Insecure bool `mapstructure:"insecure"`
+	// CertPath is the CA bundle used to verify the registry certificate.
+	CertPath string `mapstructure:"certPath"`
+	// ClientCert is the client certificate presented to the registry, ClientKey is required with it.
+	ClientCert string `mapstructure:"clientCert"`
+	// ClientKey is the private key of the client certificate.
+	ClientKey string `mapstructure:"clientKey"`
+	// Rewrite rules of the registry URL, the first matched rule is applied.
+	Rewrite []RewriteRule `mapstructure:"rewrite"`
+	// Mirrors are the endpoints serving the same content as the registry, e.g: the in-cluster service.
+	// The first available mirror replaces the registry URL.
+	Mirrors []string `mapstructure:"mirrors"`
+}
+
+// RewriteRule rewrites the registry URL matched the pattern with the replacement.
+type RewriteRule struct {
+	// Pattern is the regular expression matching the registry URL.
+	Pattern string `mapstructure:"pattern"`
+	// Replacement of the matched URL, the submatches can be referenced like $1.
+	Replacement string `mapstructure:"replacement"`
+
+	re *regexp.Regexp
+}
+
+// tlsOptions of the registry connections.
+type tlsOptions struct {
+	insecure   bool
+	certPath   string
+	clientCert string
+	clientKey  string
+}
+
+var (
+	registries     = make(map[string]RegistryConfig)
+	registriesLock sync.RWMutex
+
+	// transports are cached by the TLS settings to reuse the connections.
+	transports     = make(map[tlsOptions]*http.Transport)
+	transportsLock sync.Mutex
+
+	// probes are the availability of the mirrors keyed by the URL.
+	probes     = make(map[string]probe)
+	probesLock sync.Mutex
+)
+
+// probe is the availability of a mirror checked at the time.
+type probe struct {
+	available bool
+	at        time.Time
+}
+
+// ConfigureRegistries sets the settings of the registries.
+func ConfigureRegistries(configs []RegistryConfig) error {
+	m := make(map[string]RegistryConfig, len(configs))
+	for _, c := range configs {
+		host := RegistryHost(c.Host)
+		if host == "" {
+			return fmt.Errorf("registry host is required")
+		}
+
+		if _, ok := m[host]; ok {
+			return fmt.Errorf("duplicated registry settings: %s", host)
+		}
+
+		if c.CertPath != "" {
+			// Fail early if the CA bundle is invalid.
+			if _, err := certPool(c.CertPath); err != nil {
+				return err
+			}
+		}
+
+		if c.ClientCert != "" || c.ClientKey != "" {
+			if _, err := tls.LoadX509KeyPair(c.ClientCert, c.ClientKey); err != nil {
+				return fmt.Errorf("load client certificate of registry %s error: %w", host, err)
+			}
+		}
+
+		for i, r := range c.Rewrite {
+			re, err := regexp.Compile(r.Pattern)
+			if err != nil {
+				return fmt.Errorf("invalid rewrite pattern of registry %s: %w", host, err)
+			}
+			c.Rewrite[i].re = re
+		}
+
+		for i, mirror := range c.Mirrors {
+			if mirror == "" {
+				return fmt.Errorf("empty mirror of registry %s", host)
+			}
+			c.Mirrors[i] = normalizeURL(mirror)
+		}
+
+		c.Host = host
+		m[host] = c
+	}
+
+	registriesLock.Lock()
+	defer registriesLock.Unlock()
+
+	registries = m
+
+	// The mirrors may be changed, probe them again.
+	probesLock.Lock()
+	defer probesLock.Unlock()
+
+	probes = make(map[string]probe)
+
+	return nil
+}
+
+// LookupRegistry returns the settings of the registry, e.g: for the scanner engines
+// accessing the registry by themselves.
+func LookupRegistry(registryURL string) (RegistryConfig, bool) {
+	return registryConfig(RegistryHost(registryURL))
+}
+
+// ResolveRegistry returns the URL to access the registry with the rewrite rules and mirrors applied.
+// The first available mirror is used, otherwise the URL rewritten by the first matched rule is used.
+// The URL is returned as it is if the registry has no settings.
+func ResolveRegistry(ctx context.Context, registryURL string) string {
+	c, ok := LookupRegistry(registryURL)
+	if !ok {
+		return registryURL
+	}
+
+	for _, mirror := range c.Mirrors {
+		if available(ctx, mirror) {
+			return mirror
+		}
+	}
+
+	for _, r := range c.Rewrite {
+		if r.re.MatchString(registryURL) {
+			return r.re.ReplaceAllString(registryURL, r.Replacement)
+		}
+	}
+
+	return registryURL
+}
+
+// OriginRegistry returns the URL of the registry mirrored by the URL resolved by ResolveRegistry,
+// with the rewrite rules of the registry applied. It's used to write to the registry, as the mirrors
+// may be read only. The URL is returned as it is if it's not a mirror.
+func OriginRegistry(registryURL string) string {
+	u := normalizeURL(registryURL)
+
+	registriesLock.RLock()
+	defer registriesLock.RUnlock()
+
+	for host, c := range registries {
+		for _, mirror := range c.Mirrors {
+			if mirror != u {
+				continue
+			}
+
+			origin := normalizeURL(host)
+			for _, r := range c.Rewrite {
+				if r.re.MatchString(origin) {
+					return r.re.ReplaceAllString(origin, r.Replacement)
+				}
+			}
+
+			return origin
+		}
+	}
+
+	return registryURL
+}
+
+// available checks whether the registry serves the distribution API.
+// The result is reused for mirrorProbeTTL, so the scan requests don't wait for the probes.
+func available(ctx context.Context, registryURL string) bool {
+	probesLock.Lock()
+	p, ok := probes[registryURL]
+	probesLock.Unlock()
+
+	if ok && time.Since(p.at) < mirrorProbeTTL {
+		return p.available
+	}
+
+	res := probeRegistry(ctx, registryURL)
+
+	// The probe canceled with the request tells nothing about the registry.
+	if ctx.Err() == nil {
+		probesLock.Lock()
+		probes[registryURL] = probe{available: res, at: time.Now()}
+		probesLock.Unlock()
+	}
+
+	return res
+}
+
+// probeRegistry requests the API version check of the registry.
+// Any response other than the server errors means it's available.
+func probeRegistry(ctx context.Context, registryURL string) bool {
+	ops := tlsOptions{}
+	if c, ok := LookupRegistry(registryURL); ok {
+		ops = c.tlsOptions()
+	}
+
+	t, err := transport(ops)
+	if err != nil {
+		return false
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, mirrorProbeTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf("%s/v2/", registryURL), nil)
+	if err != nil {
+		return false
+	}
+
+	res, err := (&http.Client{Transport: t}).Do(req)
+	if err != nil {
+		return false
+	}
+	_ = res.Body.Close()
+
+	return res.StatusCode < http.StatusInternalServerError
+}
+
+// registryConfig returns the settings of the registry host.
+func registryConfig(host string) (RegistryConfig, bool) {
+	registriesLock.RLock()
+	defer registriesLock.RUnlock()
+
+	c, ok := registries[host]
+	return c, ok
+}
+
+func (c RegistryConfig) tlsOptions() tlsOptions {
+	return tlsOptions{
+		insecure:   c.Insecure,
+		certPath:   c.CertPath,
+		clientCert: c.ClientCert,
+		clientKey:  c.ClientKey,
+	}
+}
+
+// transport returns the shared transport with the TLS settings.
+func transport(ops tlsOptions) (*http.Transport, error) {
+	transportsLock.Lock()
+	defer transportsLock.Unlock()
+
+	if t, ok := transports[ops]; ok {
+		return t, nil
+	}
+
+	tlsConfig := &tls.Config{InsecureSkipVerify: ops.insecure} // nolint:gosec
+	if ops.certPath != "" {
+		pool, err := certPool(ops.certPath)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if ops.clientCert != "" {
+		cert, err := tls.LoadX509KeyPair(ops.clientCert, ops.clientKey)
+		if err != nil {
+			return nil, fmt.Errorf("load registry client certificate error: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.TLSClientConfig = tlsConfig
+	transports[ops] = t
+
+	return t, nil
+}
+
+// certPool loads the CA bundle together with the system CAs.
+func certPool(certPath string) (*x509.CertPool, error) {
+	pem, err := ioutil.ReadFile(certPath)
+	if err != nil {
+		return nil, fmt.Errorf("read registry CA error: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no valid certificate found in %s", certPath)
+	}
+
+	return pool, nil
+}
+
+// normalizeURL adds the https scheme to the registry URL if it's missing and removes the trailing slashes.
+func normalizeURL(registryURL string) string {
+	u := strings.TrimRight(registryURL, "/")
+	if !strings.Contains(u, "://") {
+		u = fmt.Sprintf("https://%s", u)
+	}
+
+	return u
+}
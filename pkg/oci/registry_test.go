@@ -0,0 +1,103 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveRegistryMirrorProbe(t *testing.T) {
+	c := &counter{}
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.inc("up")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer up.Close()
+
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.inc("down")
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+
+	err := ConfigureRegistries([]RegistryConfig{
+		{
+			Host:    "harbor.example.com",
+			Mirrors: []string{down.URL, up.URL},
+		},
+		{
+			Host:    "other.example.com",
+			Mirrors: []string{down.URL},
+			Rewrite: []RewriteRule{{Pattern: `^https://other\.example\.com`, Replacement: "http://other.svc"}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ConfigureRegistries(nil) })
+
+	for i := 0; i < 3; i++ {
+		if u := ResolveRegistry(context.Background(), "https://harbor.example.com"); u != up.URL {
+			t.Errorf("expect the available mirror %s, got %s", up.URL, u)
+		}
+		if u := ResolveRegistry(context.Background(), "https://other.example.com"); u != "http://other.svc" {
+			t.Errorf("expect the rewritten URL, got %s", u)
+		}
+	}
+
+	// The probes are reused within the TTL.
+	if n := c.get("up"); n != 1 {
+		t.Errorf("expect the available mirror to be probed once, got %d", n)
+	}
+	if n := c.get("down"); n != 1 {
+		t.Errorf("expect the unavailable mirror to be probed once, got %d", n)
+	}
+
+	// The probes are dropped once the registries are configured again.
+	if err := ConfigureRegistries([]RegistryConfig{{Host: "harbor.example.com", Mirrors: []string{up.URL}}}); err != nil {
+		t.Fatal(err)
+	}
+	ResolveRegistry(context.Background(), "https://harbor.example.com")
+	if n := c.get("up"); n != 2 {
+		t.Errorf("expect the mirror to be probed again, got %d", n)
+	}
+}
+
+func TestResolveRegistryCanceledProbe(t *testing.T) {
+	c := &counter{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		c.inc("hits")
+	}))
+	defer srv.Close()
+
+	if err := ConfigureRegistries([]RegistryConfig{{Host: "harbor.example.com", Mirrors: []string{srv.URL}}}); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = ConfigureRegistries(nil) })
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if u := ResolveRegistry(ctx, "https://harbor.example.com"); u != "https://harbor.example.com" {
+		t.Errorf("expect the registry URL as the probe is canceled, got %s", u)
+	}
+
+	// The canceled probe is not reused.
+	if u := ResolveRegistry(context.Background(), "https://harbor.example.com"); u != srv.URL {
+		t.Errorf("expect the mirror %s, got %s", srv.URL, u)
+	}
+}
@@ -0,0 +1,468 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// defaultRetries is the retry times of the failed requests if not specified.
+	defaultRetries = 3
+	// retryBackoff is the initial delay of the retries, doubled on each retry.
+	retryBackoff = 200 * time.Millisecond
+	// maxRetryDelay caps the delay of the retries including the Retry-After of the registry.
+	maxRetryDelay = 10 * time.Second
+	// maxRedirects of a request, e.g: the blob storage redirects.
+	maxRedirects = 10
+
+	// actionPull is the token scope action of reading the repository.
+	actionPull = "pull"
+)
+
+// acceptedManifests are the manifest media types accepted when fetching manifests.
+var acceptedManifests = []string{
+	OCIImage,
+	DockerV2Image,
+	OCIImageIndex,
+	DockerManifestList,
+}
+
+// ErrNotFound is returned if the manifest or blob does not exist in the registry.
+var ErrNotFound = errors.New("not found")
+
+// RemoteOptions of the registry client.
+type RemoteOptions struct {
+	// Username for the registry authentication.
+	Username string
+	// Password for the registry authentication.
+	Password string
+	// Insecure skips the TLS verification.
+	// It's overridden by the registry settings, see ConfigureRegistries.
+	Insecure bool
+	// CertPath is the CA bundle used to verify the registry certificate.
+	// It's overridden by the registry settings, see ConfigureRegistries.
+	CertPath string
+	// Timeout of each registry request, including reading the response body.
+	Timeout time.Duration
+	// Retries of the idempotent requests failed with the network errors or the retryable status codes,
+	// 3 by default. Use a negative value to disable the retries.
+	Retries int
+}
+
+// Remote is a Source backed by a repository of the OCI distribution registry.
+//
+// The client handles the basic and bearer authentication challenges. The bearer tokens are
+// cached by the scope and shared by the clients of the same credential. The idempotent requests
+// are retried on the network errors, 429 and 5xx responses.
+type Remote struct {
+	base       string
+	host       string
+	repository string
+	options    RemoteOptions
+	client     *http.Client
+}
+
+// NewRemote creates a registry source of the repository.
+// The registry URL should include the scheme, https is used if it's missing.
+func NewRemote(registryURL, repository string, options RemoteOptions) (*Remote, error) {
+	base := normalizeURL(registryURL)
+	host := RegistryHost(base)
+
+	ops := tlsOptions{insecure: options.Insecure, certPath: options.CertPath}
+	if c, ok := registryConfig(host); ok {
+		ops = c.tlsOptions()
+	}
+
+	if options.Retries == 0 {
+		options.Retries = defaultRetries
+	}
+
+	t, err := transport(ops)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Remote{
+		base:       base,
+		host:       host,
+		repository: repository,
+		options:    options,
+		client: &http.Client{
+			Transport: t,
+			Timeout:   options.Timeout,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				if len(via) >= maxRedirects {
+					return fmt.Errorf("stopped after %d redirects", maxRedirects)
+				}
+
+				// The authorization is only kept for the registry itself,
+				// the blob storage accepts the signed URL only.
+				if req.URL.Host != via[0].URL.Host {
+					req.Header.Del("Authorization")
+				}
+
+				return nil
+			},
+		},
+	}, nil
+}
+
+// Manifest implements Source.
+func (r *Remote) Manifest(ctx context.Context, reference string) ([]byte, string, error) {
+	res, err := r.do(ctx, &request{
+		method: http.MethodGet,
+		path:   fmt.Sprintf("/v2/%s/manifests/%s", r.repository, reference),
+		accept: strings.Join(acceptedManifests, ", "),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(res.Body, maxManifestSize))
+	if err != nil {
+		return nil, "", fmt.Errorf("read manifest error: %w", err)
+	}
+
+	// The digest returned by the registry should match the content as well.
+	if dgst := res.Header.Get("Docker-Content-Digest"); dgst != "" {
+		if err := verify(dgst, content); err != nil {
+			return nil, "", fmt.Errorf("verify manifest %s error: %w", reference, err)
+		}
+	}
+
+	mediaType := contentType(res)
+	if mediaType == "" || mediaType == "application/json" {
+		mediaType = mediaTypeOf(content)
+	}
+
+	return content, mediaType, nil
+}
+
+// Resolve the manifest descriptor of the tag or digest without fetching the content.
+// The content is fetched to calculate the digest if the registry does not return it.
+func (r *Remote) Resolve(ctx context.Context, reference string) (*Descriptor, error) {
+	res, err := r.do(ctx, &request{
+		method: http.MethodHead,
+		path:   fmt.Sprintf("/v2/%s/manifests/%s", r.repository, reference),
+		accept: strings.Join(acceptedManifests, ", "),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_ = res.Body.Close()
+
+	desc := &Descriptor{
+		MediaType: contentType(res),
+		Digest:    res.Header.Get("Docker-Content-Digest"),
+		Size:      res.ContentLength,
+	}
+	if desc.Digest != "" && desc.MediaType != "" && desc.MediaType != "application/json" {
+		return desc, nil
+	}
+
+	content, mediaType, err := r.Manifest(ctx, reference)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Descriptor{
+		MediaType: mediaType,
+		Digest:    Digest(content),
+		Size:      int64(len(content)),
+	}, nil
+}
+
+// Blob implements Source.
+func (r *Remote) Blob(ctx context.Context, digest string) (io.ReadCloser, error) {
+	res, err := r.do(ctx, &request{
+		method: http.MethodGet,
+		path:   fmt.Sprintf("/v2/%s/blobs/%s", r.repository, digest),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Body, nil
+}
+
+// Referrers lists the manifests referring to the digest, filtered by the artifact type if it's set.
+// The referrers tag schema is used if the registry does not support the referrers API.
+func (r *Remote) Referrers(ctx context.Context, digest string, artifactType string) (*Index, error) {
+	path := fmt.Sprintf("/v2/%s/referrers/%s", r.repository, digest)
+	if artifactType != "" {
+		path = fmt.Sprintf("%s?artifactType=%s", path, artifactType)
+	}
+
+	idx := &Index{}
+	res, err := r.do(ctx, &request{
+		method: http.MethodGet,
+		path:   path,
+		accept: OCIImageIndex,
+	})
+	switch {
+	case err == nil:
+		defer res.Body.Close()
+
+		if err := json.NewDecoder(io.LimitReader(res.Body, maxManifestSize)).Decode(idx); err != nil {
+			return nil, fmt.Errorf("decode referrers error: %w", err)
+		}
+	case errors.Is(err, ErrNotFound):
+		// Fall back to the referrers tag schema, e.g: sha256-<hex>.
+		content, _, err := r.Manifest(ctx, strings.Replace(digest, ":", "-", 1))
+		if err != nil {
+			if errors.Is(err, ErrNotFound) {
+				return &Index{SchemaVersion: 2, MediaType: OCIImageIndex}, nil
+			}
+			return nil, err
+		}
+
+		if err := json.Unmarshal(content, idx); err != nil {
+			return nil, fmt.Errorf("decode referrers tag error: %w", err)
+		}
+	default:
+		return nil, err
+	}
+
+	// The registry may not apply the filter.
+	if artifactType != "" && (res == nil || res.Header.Get("OCI-Filters-Applied") == "") {
+		var filtered []Descriptor
+		for _, m := range idx.Manifests {
+			if m.ArtifactType == artifactType {
+				filtered = append(filtered, m)
+			}
+		}
+		idx.Manifests = filtered
+	}
+
+	return idx, nil
+}
+
+// request to the registry API.
+type request struct {
+	method string
+	// path of the API or the absolute URL, e.g: the upload location.
+	path   string
+	accept string
+	header http.Header
+	body   []byte
+	// actions of the token scope, pull by default.
+	actions string
+	// expected status codes, 200 by default.
+	expected []int
+	// idempotent marks the request safe to resend on failures, the GET and HEAD requests always are.
+	idempotent bool
+}
+
+// do sends the request with the authorization and retries.
+func (r *Remote) do(ctx context.Context, req *request) (*http.Response, error) {
+	if req.actions == "" {
+		req.actions = actionPull
+	}
+	if len(req.expected) == 0 {
+		req.expected = []int{http.StatusOK}
+	}
+
+	// The other requests may have taken effect even if they failed, e.g. the upload session is
+	// consumed by the failed upload, so they're not resent.
+	idempotent := req.idempotent || req.method == http.MethodGet || req.method == http.MethodHead
+
+	for attempt := 0; ; attempt++ {
+		res, err := r.send(ctx, req)
+		if err == nil && !retryable(res.StatusCode) {
+			return r.check(req, res)
+		}
+
+		if ctx.Err() != nil || !idempotent || attempt >= r.options.Retries {
+			if err != nil {
+				return nil, err
+			}
+			return r.check(req, res)
+		}
+
+		delay := retryBackoff << uint(attempt)
+		if res != nil {
+			if ra, e := strconv.Atoi(res.Header.Get("Retry-After")); e == nil && ra > 0 {
+				delay = time.Duration(ra) * time.Second
+			}
+			_, _ = io.Copy(ioutil.Discard, io.LimitReader(res.Body, maxManifestSize))
+			_ = res.Body.Close()
+		}
+		if delay > maxRetryDelay {
+			delay = maxRetryDelay
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+// send the request once, it's sent again with the credential if it's challenged.
+func (r *Remote) send(ctx context.Context, req *request) (*http.Response, error) {
+	scope := scopeOf(r.repository, req.actions)
+
+	build := func() (*http.Request, error) {
+		u := req.path
+		if !strings.Contains(u, "://") {
+			u = r.base + req.path
+		}
+
+		var body io.Reader
+		if req.body != nil {
+			body = bytes.NewReader(req.body)
+		}
+
+		hr, err := http.NewRequestWithContext(ctx, req.method, u, body)
+		if err != nil {
+			return nil, err
+		}
+
+		for k, vs := range req.header {
+			for _, v := range vs {
+				hr.Header.Add(k, v)
+			}
+		}
+		if req.accept != "" {
+			hr.Header.Set("Accept", req.accept)
+		}
+
+		return hr, nil
+	}
+
+	hr, err := build()
+	if err != nil {
+		return nil, err
+	}
+	r.authorize(hr, knownChallenge(r.host), scope)
+
+	res, err := r.client.Do(hr)
+	if err != nil || res.StatusCode != http.StatusUnauthorized {
+		return res, err
+	}
+
+	header := res.Header.Get("WWW-Authenticate")
+	_ = res.Body.Close()
+
+	c, err := r.login(ctx, header, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	if hr, err = build(); err != nil {
+		return nil, err
+	}
+	r.authorize(hr, c, scope)
+
+	return r.client.Do(hr)
+}
+
+// authorize sets the authorization header of the request per the challenge.
+// Nothing is set if the registry has not challenged yet.
+func (r *Remote) authorize(req *http.Request, c *challenge, scope string) {
+	if c == nil {
+		return
+	}
+
+	switch c.scheme {
+	case "basic":
+		if r.options.Username != "" {
+			req.SetBasicAuth(r.options.Username, r.options.Password)
+		}
+	case "bearer":
+		if token := cachedBearer(c, scope, r.options.Username, r.options.Password); token != "" {
+			req.Header.Set("Authorization", fmt.Sprintf("Bearer %s", token))
+		}
+	}
+}
+
+// login handles the authentication challenge of the registry.
+// Basic challenge is handled by sending the credential directly, bearer challenge
+// by requesting the token of the scope from the token service.
+func (r *Remote) login(ctx context.Context, header string, scope string) (*challenge, error) {
+	scheme, params := parseChallenge(header)
+	c := &challenge{
+		scheme:  strings.ToLower(scheme),
+		realm:   params["realm"],
+		service: params["service"],
+	}
+
+	switch c.scheme {
+	case "basic":
+		if r.options.Username == "" {
+			return nil, fmt.Errorf("registry requires basic authorization but no credential is provided")
+		}
+	case "bearer":
+		if _, err := fetchBearer(ctx, r.client, c, scope, r.options.Username, r.options.Password); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported authentication challenge: %q", header)
+	}
+
+	rememberChallenge(r.host, c)
+
+	return c, nil
+}
+
+// check the status code of the response.
+func (r *Remote) check(req *request, res *http.Response) (*http.Response, error) {
+	for _, code := range req.expected {
+		if res.StatusCode == code {
+			return res, nil
+		}
+	}
+
+	_ = res.Body.Close()
+
+	if res.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("%s %s: %w", req.method, req.path, ErrNotFound)
+	}
+
+	return nil, fmt.Errorf("%s %s: unexpected status code %d", req.method, req.path, res.StatusCode)
+}
+
+// retryable checks whether the request failed with the status code can be retried.
+func retryable(code int) bool {
+	switch code {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// contentType returns the media type of the response without the parameters.
+func contentType(res *http.Response) string {
+	mediaType := res.Header.Get("Content-Type")
+	if i := strings.Index(mediaType, ";"); i >= 0 {
+		mediaType = mediaType[:i]
+	}
+
+	return strings.TrimSpace(mediaType)
+}
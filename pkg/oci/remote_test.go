@@ -0,0 +1,485 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package oci
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const testManifest = `{"schemaVersion":2,"mediaType":"application/vnd.oci.image.manifest.v1+json","config":{"mediaType":"application/vnd.oci.image.config.v1+json","digest":"sha256:44136fa355b3678a1146ad16f7e8649e94fb4fc21fe77e8310c060f61caaff8a","size":2},"layers":[]}`
+
+// counter counts the requests by key.
+type counter struct {
+	lock   sync.Mutex
+	counts map[string]int
+}
+
+func (c *counter) inc(key string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.counts == nil {
+		c.counts = make(map[string]int)
+	}
+	c.counts[key]++
+
+	return c.counts[key]
+}
+
+func (c *counter) get(key string) int {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	return c.counts[key]
+}
+
+// serveManifest writes the test manifest.
+func serveManifest(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", OCIImage)
+	w.Header().Set("Docker-Content-Digest", Digest([]byte(testManifest)))
+	_, _ = w.Write([]byte(testManifest))
+}
+
+func newTestRemote(t *testing.T, registryURL, repository string, options RemoteOptions) *Remote {
+	t.Helper()
+
+	r, err := NewRemote(registryURL, repository, options)
+	if err != nil {
+		t.Fatalf("create remote error: %v", err)
+	}
+
+	return r
+}
+
+func TestRemoteBasicChallenge(t *testing.T) {
+	c := &counter{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if u, p, ok := req.BasicAuth(); !ok || u != "robot" || p != "secret" {
+			c.inc("challenge")
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		serveManifest(w)
+	}))
+	defer srv.Close()
+
+	r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{Username: "robot", Password: "secret"})
+	for i := 0; i < 2; i++ {
+		content, mediaType, err := r.Manifest(context.Background(), "latest")
+		if err != nil {
+			t.Fatalf("fetch manifest error: %v", err)
+		}
+
+		if string(content) != testManifest || mediaType != OCIImage {
+			t.Errorf("unexpected manifest: %s %s", mediaType, content)
+		}
+	}
+
+	// The challenge is remembered, the credential is sent directly later.
+	if n := c.get("challenge"); n != 1 {
+		t.Errorf("expect 1 challenge, got %d", n)
+	}
+
+	anonymous := newTestRemote(t, srv.URL, "library/app", RemoteOptions{})
+	if _, _, err := anonymous.Manifest(context.Background(), "latest"); err == nil {
+		t.Error("expect error without the credential")
+	}
+}
+
+func TestRemoteBearerChallenge(t *testing.T) {
+	c := &counter{}
+
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.URL.Query().Get("service") != "registry.test" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		scope := req.URL.Query().Get("scope")
+		u, _, _ := req.BasicAuth()
+		c.inc(fmt.Sprintf("token:%s:%s", u, scope))
+
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"token":      fmt.Sprintf("token-%s-%s", u, scope),
+			"expires_in": 300,
+		})
+	}))
+	defer auth.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// e.g: /v2/library/app/manifests/latest
+		repository := strings.TrimPrefix(req.URL.Path[:strings.Index(req.URL.Path, "/manifests/")], "/v2/")
+
+		// Only the token of robot is accepted.
+		want := fmt.Sprintf("Bearer token-robot-%s", scopeOf(repository, actionPull))
+		if req.Header.Get("Authorization") != want {
+			w.Header().Set("WWW-Authenticate", fmt.Sprintf(`Bearer realm="%s/token",service="registry.test",scope="%s"`, auth.URL, scopeOf(repository, actionPull)))
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		serveManifest(w)
+	}))
+	defer srv.Close()
+
+	options := RemoteOptions{Username: "robot", Password: "secret"}
+	app := newTestRemote(t, srv.URL, "library/app", options)
+	for i := 0; i < 3; i++ {
+		if _, _, err := app.Manifest(context.Background(), "latest"); err != nil {
+			t.Fatalf("fetch manifest error: %v", err)
+		}
+	}
+
+	// The token is cached by the scope and shared by the clients of the same credential.
+	shared := newTestRemote(t, srv.URL, "library/app", options)
+	if _, _, err := shared.Manifest(context.Background(), "latest"); err != nil {
+		t.Fatalf("fetch manifest error: %v", err)
+	}
+
+	if n := c.get("token:robot:repository:library/app:pull"); n != 1 {
+		t.Errorf("expect 1 token request of the scope, got %d", n)
+	}
+
+	// Another scope requires another token.
+	db := newTestRemote(t, srv.URL, "library/db", options)
+	if _, _, err := db.Manifest(context.Background(), "latest"); err != nil {
+		t.Fatalf("fetch manifest error: %v", err)
+	}
+
+	if n := c.get("token:robot:repository:library/db:pull"); n != 1 {
+		t.Errorf("expect 1 token request of the other scope, got %d", n)
+	}
+
+	// The token of another credential is not shared.
+	other := newTestRemote(t, srv.URL, "library/app", RemoteOptions{Username: "other", Password: "secret"})
+	if _, _, err := other.Manifest(context.Background(), "latest"); err == nil {
+		t.Error("expect error with the token of the other credential")
+	}
+
+	if n := c.get("token:other:repository:library/app:pull"); n == 0 {
+		t.Error("expect token request of the other credential")
+	}
+}
+
+func TestRemoteBlobRedirect(t *testing.T) {
+	blob := []byte("layer content")
+	dgst := Digest(blob)
+
+	storage := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		// The registry authorization must not be leaked to the storage.
+		if req.Header.Get("Authorization") != "" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		if req.URL.Query().Get("signature") != "signed" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		_, _ = w.Write(blob)
+	}))
+	defer storage.Close()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if _, _, ok := req.BasicAuth(); !ok {
+			w.Header().Set("WWW-Authenticate", `Basic realm="registry"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		if req.URL.Path != fmt.Sprintf("/v2/library/app/blobs/%s", dgst) {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+
+		http.Redirect(w, req, storage.URL+"/blob?signature=signed", http.StatusTemporaryRedirect)
+	}))
+	defer srv.Close()
+
+	r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{Username: "robot", Password: "secret"})
+	rc, err := r.Blob(context.Background(), dgst)
+	if err != nil {
+		t.Fatalf("fetch blob error: %v", err)
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatalf("read blob error: %v", err)
+	}
+
+	if string(content) != string(blob) {
+		t.Errorf("unexpected blob content: %s", content)
+	}
+}
+
+func TestRemoteRetries(t *testing.T) {
+	cases := []struct {
+		name     string
+		retries  int
+		failures int
+		code     int
+		wantErr  bool
+		wantHits int
+	}{
+		{name: "retry on 503", retries: 3, failures: 2, code: http.StatusServiceUnavailable, wantHits: 3},
+		{name: "retry on 429", retries: 3, failures: 1, code: http.StatusTooManyRequests, wantHits: 2},
+		{name: "give up after retries", retries: 1, failures: 5, code: http.StatusBadGateway, wantErr: true, wantHits: 2},
+		{name: "retries disabled", retries: -1, failures: 1, code: http.StatusServiceUnavailable, wantErr: true, wantHits: 1},
+		{name: "no retry on 500", retries: 3, failures: 1, code: http.StatusInternalServerError, wantErr: true, wantHits: 1},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &counter{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if c.inc("hits") <= tc.failures {
+					w.WriteHeader(tc.code)
+					return
+				}
+
+				serveManifest(w)
+			}))
+			defer srv.Close()
+
+			r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{Retries: tc.retries})
+			_, _, err := r.Manifest(context.Background(), "latest")
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expect error %v, got %v", tc.wantErr, err)
+			}
+
+			if n := c.get("hits"); n != tc.wantHits {
+				t.Errorf("expect %d requests, got %d", tc.wantHits, n)
+			}
+		})
+	}
+}
+
+func TestRemotePushRetries(t *testing.T) {
+	cases := []struct {
+		name string
+		// method failing with 503 once.
+		method   string
+		wantErr  bool
+		wantHits map[string]int
+	}{
+		{name: "retry HEAD", method: http.MethodHead, wantHits: map[string]int{http.MethodHead: 2, http.MethodPost: 1, http.MethodPut: 1}},
+		{name: "no retry of POST", method: http.MethodPost, wantErr: true, wantHits: map[string]int{http.MethodHead: 1, http.MethodPost: 1}},
+		{name: "no retry of upload PUT", method: http.MethodPut, wantErr: true, wantHits: map[string]int{http.MethodHead: 1, http.MethodPost: 1, http.MethodPut: 1}},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			c := &counter{}
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+				if c.inc(req.Method) == 1 && req.Method == tc.method {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+
+				switch req.Method {
+				case http.MethodHead:
+					w.WriteHeader(http.StatusNotFound)
+				case http.MethodPost:
+					w.Header().Set("Location", "/v2/library/app/blobs/uploads/session")
+					w.WriteHeader(http.StatusAccepted)
+				case http.MethodPut:
+					w.WriteHeader(http.StatusCreated)
+				}
+			}))
+			defer srv.Close()
+
+			r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{Retries: 3})
+			_, err := r.PushBlob(context.Background(), "application/octet-stream", []byte("blob"))
+			if (err != nil) != tc.wantErr {
+				t.Errorf("expect error %v, got %v", tc.wantErr, err)
+			}
+
+			for _, m := range []string{http.MethodHead, http.MethodPost, http.MethodPut} {
+				if n := c.get(m); n != tc.wantHits[m] {
+					t.Errorf("expect %d %s requests, got %d", tc.wantHits[m], m, n)
+				}
+			}
+		})
+	}
+}
+
+func TestRemotePushManifestRetries(t *testing.T) {
+	c := &counter{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if c.inc("hits") == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer srv.Close()
+
+	r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{Retries: 3})
+	if _, _, err := r.PushManifest(context.Background(), "latest", OCIImage, []byte(testManifest)); err != nil {
+		t.Fatalf("push manifest error: %v", err)
+	}
+
+	if n := c.get("hits"); n != 2 {
+		t.Errorf("expect the manifest to be put again, got %d requests", n)
+	}
+}
+
+func TestRemoteManifestDigestMismatch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", OCIImage)
+		w.Header().Set("Docker-Content-Digest", Digest([]byte("another manifest")))
+		_, _ = w.Write([]byte(testManifest))
+	}))
+	defer srv.Close()
+
+	r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{})
+	if _, _, err := r.Manifest(context.Background(), "latest"); err == nil || !strings.Contains(err.Error(), "digest mismatch") {
+		t.Errorf("expect digest mismatch error, got %v", err)
+	}
+}
+
+func TestRemoteReferrers(t *testing.T) {
+	subject := Digest([]byte(testManifest))
+	referrers := &Index{
+		SchemaVersion: 2,
+		MediaType:     OCIImageIndex,
+		Manifests: []Descriptor{
+			{MediaType: OCIImage, Digest: "sha256:aaaa", ArtifactType: "application/vnd.example.sbom"},
+			{MediaType: OCIImage, Digest: "sha256:bbbb", ArtifactType: "application/vnd.example.signature"},
+		},
+	}
+	content, err := json.Marshal(referrers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name         string
+		handler      http.HandlerFunc
+		artifactType string
+		want         []string
+	}{
+		{
+			name: "referrers API",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/v2/library/app/referrers/"+subject {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Header().Set("Content-Type", OCIImageIndex)
+				_, _ = w.Write(content)
+			},
+			want: []string{"sha256:aaaa", "sha256:bbbb"},
+		},
+		{
+			name: "filter not applied by the registry",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/v2/library/app/referrers/"+subject {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Header().Set("Content-Type", OCIImageIndex)
+				_, _ = w.Write(content)
+			},
+			artifactType: "application/vnd.example.sbom",
+			want:         []string{"sha256:aaaa"},
+		},
+		{
+			name: "filter applied by the registry",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Query().Get("artifactType") != "application/vnd.example.signature" {
+					w.WriteHeader(http.StatusBadRequest)
+					return
+				}
+
+				filtered := *referrers
+				filtered.Manifests = referrers.Manifests[1:]
+				w.Header().Set("Content-Type", OCIImageIndex)
+				w.Header().Set("OCI-Filters-Applied", "artifactType")
+				_ = json.NewEncoder(w).Encode(&filtered)
+			},
+			artifactType: "application/vnd.example.signature",
+			want:         []string{"sha256:bbbb"},
+		},
+		{
+			name: "referrers tag schema",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				if req.URL.Path != "/v2/library/app/manifests/"+strings.Replace(subject, ":", "-", 1) {
+					w.WriteHeader(http.StatusNotFound)
+					return
+				}
+
+				w.Header().Set("Content-Type", OCIImageIndex)
+				_, _ = w.Write(content)
+			},
+			artifactType: "application/vnd.example.signature",
+			want:         []string{"sha256:bbbb"},
+		},
+		{
+			name: "no referrers",
+			handler: func(w http.ResponseWriter, req *http.Request) {
+				w.WriteHeader(http.StatusNotFound)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			srv := httptest.NewServer(tc.handler)
+			defer srv.Close()
+
+			r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{})
+			idx, err := r.Referrers(context.Background(), subject, tc.artifactType)
+			if err != nil {
+				t.Fatalf("list referrers error: %v", err)
+			}
+
+			var got []string
+			for _, m := range idx.Manifests {
+				got = append(got, m.Digest)
+			}
+
+			if strings.Join(got, ",") != strings.Join(tc.want, ",") {
+				t.Errorf("expect referrers %v, got %v", tc.want, got)
+			}
+		})
+	}
+}
+
+func TestRemoteNotFound(t *testing.T) {
+	srv := httptest.NewServer(http.NotFoundHandler())
+	defer srv.Close()
+
+	r := newTestRemote(t, srv.URL, "library/app", RemoteOptions{})
+	if _, _, err := r.Manifest(context.Background(), "latest"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expect ErrNotFound, got %v", err)
+	}
+}
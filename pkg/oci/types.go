@@ -19,4 +19,16 @@ const (
 	OCIImage = "application/vnd.oci.image.manifest.v1+json"
 	// DockerV2Image mime type.
 	DockerV2Image = "application/vnd.docker.distribution.manifest.v2+json"
+	// OCIImageIndex mime type.
+	OCIImageIndex = "application/vnd.oci.image.index.v1+json"
+	// DockerManifestList mime type.
+	DockerManifestList = "application/vnd.docker.distribution.manifest.list.v2+json"
+	// OCIImageConfig mime type.
+	OCIImageConfig = "application/vnd.oci.image.config.v1+json"
+	// DockerImageConfig mime type.
+	DockerImageConfig = "application/vnd.docker.container.image.v1+json"
+	// OCIEmpty is the mime type of the empty config of the artifacts.
+	OCIEmpty = "application/vnd.oci.empty.v1+json"
+	// OCILayout is the version file name of the OCI image layout.
+	OCILayout = "oci-layout"
 )
@@ -18,6 +18,12 @@ import (
 	"context"
 
 	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/cis"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/license"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/malware"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/plugin"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/sbom"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/secret"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/signature"
 
 	"github.com/spf13/viper"
 	"github.com/szlabs/goworker/pkg/backend"
@@ -29,10 +35,30 @@ import (
 // buildKnownList builds internal known list of scanning jobs.
 // Till now, the following jobs are registered:
 //   - CIS
+//   - SBOM
+//   - Secret
+//   - License
+//   - Malware
+//   - Signature
+//   - Plugins (exec and resident)
 func buildKnownList() (*job.KnownList, error) {
 	klb := job.NewKnownListBuilder(
 		// CIS scan job.
 		cis.AddToKnownList,
+		// SBOM generation job.
+		sbom.AddToKnownList,
+		// Secret scan job.
+		secret.AddToKnownList,
+		// License scan job.
+		license.AddToKnownList,
+		// Malware scan job.
+		malware.AddToKnownList,
+		// Signature verification job.
+		signature.AddToKnownList,
+		// Exec plugin scan job.
+		plugin.AddToKnownList,
+		// Resident plugin scan job.
+		plugin.AddResidentToKnownList,
 	)
 
 	kl := job.NewKnownList()
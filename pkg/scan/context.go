@@ -0,0 +1,35 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import "context"
+
+// mediaTypeCtxKey is the key of the report media type injected into the context.Context.
+type mediaTypeCtxKey struct{}
+
+// WithReportMediaType injects the media type of the requested report content into the context,
+// e.g. the SBOM format specified by the sbom_media_type query parameter.
+func WithReportMediaType(ctx context.Context, mediaType string) context.Context {
+	return context.WithValue(ctx, mediaTypeCtxKey{}, mediaType)
+}
+
+// ReportMediaTypeFromContext extracts the media type of the requested report content from the context.
+func ReportMediaTypeFromContext(ctx context.Context) string {
+	if v, ok := ctx.Value(mediaTypeCtxKey{}).(string); ok {
+		return v
+	}
+
+	return ""
+}
@@ -0,0 +1,111 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import (
+	"fmt"
+	"reflect"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+)
+
+// JSONResult is a generic Result holding the JSON content of the specified mime type.
+type JSONResult struct {
+	mimetype string
+	rawJSON  string
+	options  *ResultOptions
+}
+
+// NewJSONResult creates a JSON result of the mime type.
+func NewJSONResult(mimetype string) *JSONResult {
+	return &JSONResult{
+		mimetype: mimetype,
+	}
+}
+
+// MimeType implements Result.
+func (r *JSONResult) MimeType() string {
+	return r.mimetype
+}
+
+// JSON implements Result.
+func (r *JSONResult) JSON() string {
+	return r.rawJSON
+}
+
+// Write implements Result.
+func (r *JSONResult) Write(content interface{}, options ...ResultOption) error {
+	ops := &ResultOptions{}
+	for _, op := range options {
+		op(ops)
+	}
+	r.options = ops
+
+	if content == nil {
+		// Accept but do nothing
+		return nil
+	}
+
+	s, ok := content.(string)
+	if !ok {
+		return fmt.Errorf("json result error: invalid type of result content: accepted=string actual=%v", reflect.TypeOf(content).Kind())
+	}
+
+	r.rawJSON = s
+	return nil
+}
+
+// Phase implements Result.
+func (r *JSONResult) Phase() ResultPhase {
+	if r.options != nil {
+		return r.options.Phase
+	}
+
+	// By default.
+	return ResultPhaseNotFound
+}
+
+// NextTry implements Result.
+func (r *JSONResult) NextTry() int64 {
+	if r.options != nil {
+		return r.options.NextTry
+	}
+
+	return DefaultRetry
+}
+
+// ResultOf converts the data item kept in the store to the result of the mime type.
+// A nil item means the result is not found.
+func ResultOf(mimetype string, item *data.Item) (Result, error) {
+	res := NewJSONResult(mimetype)
+	if item == nil {
+		return res, nil
+	}
+
+	switch item.Status {
+	case data.Pending, data.Ongoing:
+		return res, res.Write(nil, Phase(ResultPhaseNotReady), NextTry(DefaultRetry))
+	case data.Error:
+		return nil, fmt.Errorf("%s", item.Error)
+	case data.Success:
+		return res, res.Write(
+			item.JSON,
+			Phase(ResultPhaseReady),
+			NextTry(0), // skip retry
+		)
+	default:
+		return nil, fmt.Errorf("unknown status: status=%v", item.Status)
+	}
+}
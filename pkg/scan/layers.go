@@ -0,0 +1,148 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	rs "github.com/szlabs/harbor-scanner-adapter/pkg/store/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+)
+
+// layerMetrics counts the reused and analyzed layers by provider, served by the /metrics API.
+var layerMetrics = expvar.NewMap("layer_results")
+
+// Layers reuses the per-layer analysis results of a provider across the scans.
+//
+// The results are keyed by the diffID of the layer and the fingerprint of the engine,
+// so the layers shared by the images, e.g. the base image layers, are analyzed once.
+// The fingerprint should change once the engine version or the settings affecting the
+// results change.
+type Layers struct {
+	provider    string
+	fingerprint string
+	// diffIDs maps the layer digests to the diffIDs.
+	diffIDs map[string]string
+	enabled bool
+}
+
+// NewLayers creates the per-layer results of the image analyzed by the provider.
+// The layers are always analyzed if `scanner.layerCache.enabled` is off.
+func NewLayers(ctx context.Context, img *oci.Image, provider, fingerprint string) *Layers {
+	l := &Layers{
+		provider:    provider,
+		fingerprint: fingerprint,
+		diffIDs:     make(map[string]string),
+		enabled:     viper.GetBool("scanner.layerCache.enabled"),
+	}
+
+	if !l.enabled {
+		return l
+	}
+
+	cfg, err := img.Config(ctx)
+	if err != nil {
+		// Fall back to the layer digests.
+		zlog.FromContext(ctx).Warnw("layer diffIDs are not resolved", "error", err)
+		return l
+	}
+
+	if len(cfg.RootFS.DiffIDs) == len(img.Manifest.Layers) {
+		for i, desc := range img.Manifest.Layers {
+			l.diffIDs[desc.Digest] = cfg.RootFS.DiffIDs[i]
+		}
+	}
+
+	return l
+}
+
+// Analyze loads the analysis result of the layer into the result, the layer is analyzed
+// with the function if the result is not found. The result should be a pointer of the
+// JSON serializable data, and the function should fill it.
+// The store errors are logged only, they fail the analysis never.
+func (l *Layers) Analyze(ctx context.Context, desc oci.Descriptor, result interface{}, analyze func() error) error {
+	if !l.enabled {
+		return analyze()
+	}
+
+	lg := zlog.FromContext(ctx)
+	key := l.key(desc)
+
+	content, err := store.Layers().GetLayer(key)
+	if err == nil {
+		if err := json.Unmarshal(content, result); err == nil {
+			layerMetrics.Add(fmt.Sprintf("%s_hits", l.provider), 1)
+			return nil
+		}
+
+		lg.Warnw("invalid layer result is dropped", "layer", desc.Digest)
+	} else if !errors.Is(err, rs.NotFoundErr) {
+		lg.Warnw("get layer result error", "layer", desc.Digest, "error", err)
+	}
+
+	layerMetrics.Add(fmt.Sprintf("%s_misses", l.provider), 1)
+
+	if err := analyze(); err != nil {
+		return err
+	}
+
+	if content, err = json.Marshal(result); err != nil {
+		return fmt.Errorf("marshal layer result error: %w", err)
+	}
+
+	if err := store.Layers().SaveLayer(key, content); err != nil {
+		lg.Warnw("save layer result error", "layer", desc.Digest, "error", err)
+	}
+
+	return nil
+}
+
+// key of the layer result, the layer digest is used if the diffID is unknown.
+func (l *Layers) key(desc oci.Descriptor) *data.LayerKey {
+	diffID, ok := l.diffIDs[desc.Digest]
+	if !ok {
+		diffID = desc.Digest
+	}
+
+	return (&data.LayerKey{
+		Provider:    l.provider,
+		Fingerprint: l.fingerprint,
+		DiffID:      diffID,
+	}).AppendPrefix(rds.Namespace)
+}
+
+// Fingerprint computes the fingerprint of the engine version and the settings.
+func Fingerprint(version string, settings interface{}) (string, error) {
+	content, err := json.Marshal(settings)
+	if err != nil {
+		return "", fmt.Errorf("marshal settings error: %w", err)
+	}
+
+	sum := sha256.Sum256(append([]byte(version+"\n"), content...))
+
+	return hex.EncodeToString(sum[:8]), nil
+}
@@ -0,0 +1,162 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scan
+
+import (
+	"context"
+	"expvar"
+	"mime"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/attest"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// AnnotationReportMimeType is the annotation of the full report MIME type, including the parameters,
+	// of the report artifacts pushed to the registry.
+	AnnotationReportMimeType = "io.goharbor.scanner.report.mime-type"
+	// AnnotationPredicateType is the annotation of the in-toto predicate type of the attestation artifacts.
+	AnnotationPredicateType = "in-toto.io/predicate-type"
+	// annotationCreated is the OCI annotation of the creation time.
+	annotationCreated = "org.opencontainers.image.created"
+)
+
+// referrerMetrics counts the pushed and failed report artifacts, served by the /metrics API.
+var referrerMetrics = expvar.NewMap("referrers")
+
+// ArtifactType returns the artifact type of the report artifact with the report MIME type,
+// i.e. the MIME type without the parameters.
+func ArtifactType(mimeType string) string {
+	mt, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return mimeType
+	}
+
+	return mt
+}
+
+// PushReport pushes the report of the scanned artifact back to the registry as an OCI artifact
+// whose subject is the scanned manifest if `scanner.referrers.enabled` is on.
+//
+// The artifact type is the report MIME type. The reports of the local artifacts and the MIME types
+// not listed in `scanner.referrers.mimeTypes`, if it's set, are not pushed. The credential of the
+// scan request is used unless `scanner.referrers.username` is set, as the robot account created by
+// Harbor for the scan usually has the pull permission only.
+// The signed attestation of the report is pushed too if `scanner.attestations.push` is on.
+// Pushing the report is best effort, the failure is logged and does not fail the scan.
+func PushReport(ctx context.Context, registryURL string, artifact *spec.Artifact, mimeType string, report []byte, options oci.RemoteOptions) {
+	if !viper.GetBool("scanner.referrers.enabled") || registryURL == "" || artifact.Digest == "" {
+		return
+	}
+
+	if mts := viper.GetStringSlice("scanner.referrers.mimeTypes"); len(mts) > 0 && !containsType(mts, mimeType) {
+		return
+	}
+
+	lg := zlog.FromContext(ctx)
+
+	if username := viper.GetString("scanner.referrers.username"); username != "" {
+		options.Username = username
+		options.Password = viper.GetString("scanner.referrers.password")
+	}
+
+	if timeout := viper.GetDuration("scanner.referrers.timeout"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// The mirror the artifact was pulled from may be read only.
+	r, err := oci.NewRemote(oci.OriginRegistry(registryURL), artifact.Repository, options)
+	if err != nil {
+		referrerMetrics.Add("failures", 1)
+		lg.Errorw("create registry client to push report error", "error", err)
+		return
+	}
+
+	subject, err := r.Resolve(ctx, artifact.Digest)
+	if err != nil {
+		referrerMetrics.Add("failures", 1)
+		lg.Errorw("resolve subject of report error", "error", err)
+		return
+	}
+
+	desc, err := r.PushReferrer(ctx, subject, ArtifactType(mimeType), report, map[string]string{
+		AnnotationReportMimeType: mimeType,
+		annotationCreated:        time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		referrerMetrics.Add("failures", 1)
+		lg.Errorw("push report to registry error", "error", err)
+		return
+	}
+
+	referrerMetrics.Add("pushed", 1)
+	lg.Infow("report is pushed to registry", "referrer", desc.Digest, "mimeType", mimeType)
+
+	if viper.GetBool("scanner.attestations.push") && attest.Attests(mimeType) {
+		pushAttestation(ctx, r, subject, mimeType, report)
+	}
+}
+
+// pushAttestation pushes the signed attestation of the report as the referrer of the subject.
+func pushAttestation(ctx context.Context, r *oci.Remote, subject *oci.Descriptor, mimeType string, report []byte) {
+	lg := zlog.FromContext(ctx)
+
+	attester, err := attest.Default()
+	if err != nil {
+		referrerMetrics.Add("failures", 1)
+		lg.Errorw("get attester error", "error", err)
+		return
+	}
+
+	env, err := attester.Attest(mimeType, report)
+	if err != nil {
+		referrerMetrics.Add("failures", 1)
+		lg.Errorw("attest report error", "error", err)
+		return
+	}
+
+	desc, err := r.PushReferrer(ctx, subject, attest.MimeType, env, map[string]string{
+		AnnotationReportMimeType: mimeType,
+		AnnotationPredicateType:  attest.PredicateTypeOf(mimeType),
+		annotationCreated:        time.Now().UTC().Format(time.RFC3339),
+	})
+	if err != nil {
+		referrerMetrics.Add("failures", 1)
+		lg.Errorw("push attestation to registry error", "error", err)
+		return
+	}
+
+	referrerMetrics.Add("attestations", 1)
+	lg.Infow("attestation is pushed to registry", "referrer", desc.Digest, "mimeType", mimeType)
+}
+
+// containsType checks whether the MIME type is in the list, the parameters are ignored if they're
+// not specified in the list item.
+func containsType(list []string, mimeType string) bool {
+	for _, mt := range list {
+		if mt == mimeType || mt == ArtifactType(mimeType) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,117 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+
+	"github.com/szlabs/goworker/pkg/errs"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/attest"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// attested wraps the provider to produce the signed attestations of its reports.
+// The attestation is signed when it's retrieved from the report kept by the provider.
+type attested struct {
+	Provider
+	// reports maps the attestation mimetypes to the report mimetypes.
+	reports map[string]string
+}
+
+// withAttestations adds the attestation mimetypes of the attested reports to the registrations.
+func withAttestations(regs []registration) []registration {
+	res := make([]registration, 0, len(regs))
+	for _, r := range regs {
+		reports := make(map[string]string)
+		mimetypes := append([]string{}, r.mimetypes...)
+		for _, m := range r.mimetypes {
+			if attest.Attests(m) {
+				am := attest.MimeTypeOf(m)
+				reports[am] = m
+				mimetypes = append(mimetypes, am)
+			}
+		}
+
+		if len(reports) == 0 {
+			res = append(res, r)
+			continue
+		}
+
+		inner := r.new
+		res = append(res, registration{
+			key:       r.key,
+			mimetypes: mimetypes,
+			new:       func() Provider { return &attested{Provider: inner(), reports: reports} },
+		})
+	}
+
+	return res
+}
+
+// Metadata implements Provider.
+// The attestation mimetypes are added to the capabilities producing the attested reports.
+func (a *attested) Metadata() *spec.ScannerAdapterMetadata {
+	md := *a.Provider.Metadata()
+
+	caps := make([]spec.ScannerCapability, 0, len(md.Capabilities))
+	for _, c := range md.Capabilities {
+		produces := append([]string{}, c.ProducesMimeTypes...)
+		for _, m := range c.ProducesMimeTypes {
+			if attest.Attests(m) {
+				produces = append(produces, attest.MimeTypeOf(m))
+			}
+		}
+		c.ProducesMimeTypes = produces
+		caps = append(caps, c)
+	}
+	md.Capabilities = caps
+
+	return &md
+}
+
+// RetrieveScanResult implements Provider.
+// The report is wrapped in the signed in-toto statement if the attestation mimetype is requested.
+func (a *attested) RetrieveScanResult(ctx context.Context, reqID string, mimetype string) (scan.Result, error) {
+	report, ok := a.reports[mimetype]
+	if !ok {
+		return a.Provider.RetrieveScanResult(ctx, reqID, mimetype)
+	}
+
+	errorf := errs.WithPrefix("retrieve attestation error")
+
+	res, err := a.Provider.RetrieveScanResult(ctx, reqID, report)
+	if err != nil {
+		return nil, err
+	}
+
+	att := scan.NewJSONResult(mimetype)
+	if res.Phase() != scan.ResultPhaseReady {
+		return att, att.Write(nil, scan.Phase(res.Phase()), scan.NextTry(res.NextTry()))
+	}
+
+	attester, err := attest.Default()
+	if err != nil {
+		return nil, errorf.Wrap("get attester error", err)
+	}
+
+	env, err := attester.Attest(report, []byte(res.JSON()))
+	if err != nil {
+		return nil, errorf.Wrap("attest report error", err)
+	}
+
+	return att, att.Write(string(env), scan.Phase(scan.ResultPhaseReady), scan.NextTry(0))
+}
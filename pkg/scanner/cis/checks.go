@@ -0,0 +1,311 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cis
+
+import (
+	"archive/tar"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// checkpointLink is the link of the checkpoint details.
+	checkpointLink = "https://github.com/goodwithtech/dockle/blob/master/CHECKPOINT.md#%s"
+)
+
+// inspection is the image content inspected by the checkpoints.
+type inspection struct {
+	config *oci.ImageConfig
+	// setuid/setgid files found in the layers.
+	setIDFiles []string
+	// credential files found in the layers.
+	credentialFiles []string
+	// alerts of the layers which can not be inspected.
+	layerAlerts []string
+
+	// files are the relevant files of the lower layers, keyed by the path.
+	files map[string]fileMark
+	// layer is the relevant files of the layer being inspected.
+	layer map[string]fileMark
+	// whiteouts of the layer being inspected, applied to the lower layers when the layer is done.
+	whiteouts []whiteout
+}
+
+// fileMark marks why the file is relevant to the checkpoints.
+type fileMark struct {
+	setID      bool
+	credential bool
+}
+
+// whiteout removes the path, or its children only if it's opaque, from the lower layers.
+type whiteout struct {
+	path   string
+	opaque bool
+}
+
+// checkpoint of the CIS docker benchmarks.
+type checkpoint struct {
+	code  string
+	title string
+	level spec.CISLevel
+	// check returns the alerts of the violations, no alerts means pass.
+	check func(in *inspection) []string
+}
+
+// checkpoints evaluated by the native engine.
+var checkpoints = []checkpoint{
+	{"CIS-DI-0001", "Create a user for the container", spec.WARN, checkUser},
+	{"CIS-DI-0006", "Add HEALTHCHECK instruction to the container image", spec.INFO, checkHealthcheck},
+	{"CIS-DI-0007", "Do not use update instructions alone in the Dockerfile", spec.FATAL, checkUpdateAlone},
+	{"CIS-DI-0008", "Confirm safety of setuid/setgid files", spec.INFO, checkSetID},
+	{"CIS-DI-0009", "Use COPY instead of ADD in Dockerfile", spec.FATAL, checkAdd},
+	{"CIS-DI-0010", "Do not store credential in environment variables/files", spec.FATAL, checkCredentials},
+	{"DKL-DI-0001", "Avoid sudo command", spec.FATAL, checkSudo},
+}
+
+var (
+	// secretEnvPattern matches the env keys which look like credentials.
+	secretEnvPattern = regexp.MustCompile(`(?i)(password|passwd|secret|token|api_?key|access_?key|private_?key|credential)`)
+	// updatePattern matches the package index update commands.
+	updatePattern = regexp.MustCompile(`\b(apt-get|apt|apk|yum|dnf|zypper)\s+(update|upgrade)\b`)
+	// installPattern matches the package install commands.
+	installPattern = regexp.MustCompile(`\b(apt-get|apt|apk|yum|dnf|zypper)\s+(\S+\s+)*(install|add)\b`)
+	// sudoPattern matches the sudo command.
+	sudoPattern = regexp.MustCompile(`(^|[\s;&|(])sudo\s`)
+)
+
+// credentialFiles are the well-known files keeping credentials.
+var credentialFiles = []string{
+	".aws/credentials",
+	".docker/config.json",
+	".git-credentials",
+	".npmrc",
+	".pgpass",
+	".pypirc",
+	".ssh/id_dsa",
+	".ssh/id_ecdsa",
+	".ssh/id_ed25519",
+	".ssh/id_rsa",
+	".vault-token",
+	"credentials.json",
+}
+
+func checkUser(in *inspection) []string {
+	user := in.config.Config.User
+	if i := strings.Index(user, ":"); i >= 0 {
+		user = user[:i]
+	}
+
+	if user == "" || user == "root" || user == "0" {
+		return []string{"Last user should not be root"}
+	}
+
+	return nil
+}
+
+func checkHealthcheck(in *inspection) []string {
+	hc := in.config.Config.Healthcheck
+	if hc == nil || len(hc.Test) == 0 || hc.Test[0] == "NONE" {
+		return []string{"not found HEALTHCHECK statement"}
+	}
+
+	return nil
+}
+
+func checkUpdateAlone(in *inspection) []string {
+	var alerts []string
+	for _, h := range in.config.History {
+		// Check each command chain separately.
+		for _, cmd := range strings.Split(h.CreatedBy, ";") {
+			if updatePattern.MatchString(cmd) && !installPattern.MatchString(cmd) {
+				alerts = append(alerts, fmt.Sprintf("Use 'update' and 'install' in the same RUN instruction: %s", strings.TrimSpace(h.CreatedBy)))
+				break
+			}
+		}
+	}
+
+	return alerts
+}
+
+func checkSetID(in *inspection) []string {
+	alerts := append([]string{}, in.layerAlerts...)
+	for _, f := range in.setIDFiles {
+		alerts = append(alerts, fmt.Sprintf("setuid/setgid file: %s", f))
+	}
+
+	return alerts
+}
+
+func checkAdd(in *inspection) []string {
+	var alerts []string
+	for _, h := range in.config.History {
+		cmd := strings.TrimSpace(strings.TrimPrefix(h.CreatedBy, "/bin/sh -c #(nop)"))
+		if !strings.HasPrefix(cmd, "ADD ") {
+			continue
+		}
+
+		// Local files and directories added by the build tools are fine.
+		if strings.HasPrefix(cmd, "ADD file:") || strings.HasPrefix(cmd, "ADD dir:") {
+			continue
+		}
+
+		alerts = append(alerts, fmt.Sprintf("Use COPY : %s", cmd))
+	}
+
+	return alerts
+}
+
+func checkCredentials(in *inspection) []string {
+	var alerts []string
+	for _, env := range in.config.Config.Env {
+		kv := strings.SplitN(env, "=", 2)
+		if len(kv) == 2 && kv[1] != "" && secretEnvPattern.MatchString(kv[0]) {
+			alerts = append(alerts, fmt.Sprintf("Suspicious ENV key found : %s", kv[0]))
+		}
+	}
+
+	for _, f := range in.credentialFiles {
+		alerts = append(alerts, fmt.Sprintf("Suspicious filename found : %s", f))
+	}
+
+	return alerts
+}
+
+func checkSudo(in *inspection) []string {
+	var alerts []string
+	for _, h := range in.config.History {
+		if sudoPattern.MatchString(h.CreatedBy) {
+			alerts = append(alerts, fmt.Sprintf("Avoid sudo in command : %s", strings.TrimSpace(h.CreatedBy)))
+		}
+	}
+
+	return alerts
+}
+
+// inspectFile records the file entry of the layer if it's relevant to the checkpoints.
+// The entry replaces the file of the same path in the lower layers.
+func (in *inspection) inspectFile(hdr *tar.Header) {
+	if target, opaque, ok := oci.Whiteout(hdr.Name); ok {
+		in.whiteouts = append(in.whiteouts, whiteout{path: target, opaque: opaque})
+		return
+	}
+
+	name := path.Clean("/" + hdr.Name)
+	delete(in.files, name)
+
+	mark := fileMark{setID: hdr.Typeflag == tar.TypeReg && hdr.Mode&(04000|02000) != 0}
+	for _, cf := range credentialFiles {
+		if strings.HasSuffix(name, "/"+cf) {
+			mark.credential = true
+			break
+		}
+	}
+
+	if mark.setID || mark.credential {
+		if in.layer == nil {
+			in.layer = make(map[string]fileMark)
+		}
+		in.layer[name] = mark
+	}
+}
+
+// commitLayer applies the whiteouts of the inspected layer to the lower layers
+// and merges the relevant files of the layer.
+func (in *inspection) commitLayer() {
+	if in.files == nil {
+		in.files = make(map[string]fileMark)
+	}
+
+	for _, w := range in.whiteouts {
+		for name := range in.files {
+			if (!w.opaque && name == w.path) || strings.HasPrefix(name, w.path+"/") {
+				delete(in.files, name)
+			}
+		}
+	}
+
+	for name, mark := range in.layer {
+		in.files[name] = mark
+	}
+
+	in.layer, in.whiteouts = nil, nil
+}
+
+// flatten lists the relevant files of the flattened filesystem.
+func (in *inspection) flatten() {
+	names := make([]string, 0, len(in.files))
+	for name := range in.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	in.setIDFiles, in.credentialFiles = nil, nil
+	for _, name := range names {
+		if in.files[name].setID {
+			in.setIDFiles = append(in.setIDFiles, name)
+		}
+		if in.files[name].credential {
+			in.credentialFiles = append(in.credentialFiles, name)
+		}
+	}
+}
+
+// evaluate the checkpoints except the ignored ones.
+func evaluate(in *inspection, ignored map[string]bool) *spec.CISReportBody {
+	body := &spec.CISReportBody{
+		Summary: make(map[string]uint),
+	}
+
+	for _, cp := range checkpoints {
+		if ignored[cp.code] {
+			continue
+		}
+
+		level := cp.level
+		alerts := cp.check(in)
+		if len(alerts) == 0 {
+			level = spec.PASS
+		}
+
+		body.Details = append(body.Details, spec.CISBenchmarkItem{
+			Code:   cp.code,
+			Title:  cp.title,
+			Link:   fmt.Sprintf(checkpointLink, strings.ToLower(cp.code)),
+			Level:  &level,
+			Alerts: alerts,
+		})
+		body.Summary[strings.ToLower(string(level))]++
+	}
+
+	return body
+}
+
+// ignoredCodes parses the ignored checkpoint codes, e.g: "CIS-DI-0001, DKL-DI-0006".
+func ignoredCodes(ignore string) map[string]bool {
+	ignored := make(map[string]bool)
+	for _, code := range strings.Split(ignore, ",") {
+		if code = strings.TrimSpace(code); code != "" {
+			ignored[code] = true
+		}
+	}
+
+	return ignored
+}
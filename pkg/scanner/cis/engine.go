@@ -0,0 +1,309 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cis
+
+import (
+	"archive/tar"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// EngineDockle runs the dockle binary.
+	EngineDockle = "dockle"
+	// EngineNative evaluates the checkpoints in process.
+	EngineNative = "native"
+)
+
+// engine returns the configured CIS engine.
+func engine() string {
+	if e := viper.GetString("scanner.backends.cis.engine"); e != "" {
+		return e
+	}
+
+	return EngineDockle
+}
+
+var (
+	dockleVersion     string
+	dockleVersionOnce sync.Once
+)
+
+// engineVersion returns the version of the configured engine.
+// The dockle version is detected once from the binary.
+func engineVersion() string {
+	if engine() == EngineNative {
+		return Version
+	}
+
+	dockleVersionOnce.Do(func() {
+		dockleVersion = "unknown"
+
+		// e.g: dockle version 0.4.5
+		out, err := exec.Command("dockle", "--version").Output()
+		if err != nil {
+			zlog.Logger().Warnw("detect dockle version error", "error", err)
+			return
+		}
+
+		if fields := strings.Fields(string(out)); len(fields) > 0 {
+			dockleVersion = fields[len(fields)-1]
+		}
+	})
+
+	return dockleVersion
+}
+
+// scanImage scans the image with the configured engine.
+func scanImage(ctx context.Context, t *native.Target) (*spec.CISReportBody, error) {
+	switch engine() {
+	case EngineDockle:
+		target, err := dockleTarget(t.Artifact, oci.ImageRef(t.Registry, t.Artifact.Repository, "", t.Artifact.Digest))
+		if err != nil {
+			return nil, err
+		}
+
+		return scanWithDockle(ctx, t, target...)
+	case EngineNative:
+		src, err := t.Source()
+		if err != nil {
+			return nil, err
+		}
+
+		return Inspect(ctx, src, reference(t.Artifact), viper.GetString("scanner.backends.cis.ignore"))
+	default:
+		return nil, fmt.Errorf("unknown CIS engine: %s", engine())
+	}
+}
+
+// dockleTarget returns the dockle arguments of the image to scan.
+// The image archive is scanned with the input option, dockle can't scan the OCI image layout.
+// The image in the registry is pulled by dockle, so the blob cache is not used.
+func dockleTarget(artifact *spec.Artifact, image string) ([]string, error) {
+	if artifact.Reference == "" {
+		return []string{image}, nil
+	}
+
+	r, err := oci.ParseLocal(artifact.Reference)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.Transport != oci.TransportDockerArchive {
+		return nil, fmt.Errorf("dockle engine does not support %s, use the native engine", r.Transport)
+	}
+
+	return []string{"--input", r.Path}, nil
+}
+
+// reference of the artifact, the digest is preferred.
+func reference(artifact *spec.Artifact) string {
+	if artifact.Digest != "" {
+		return artifact.Digest
+	}
+
+	return artifact.Tag
+}
+
+// Inspect evaluates the CIS checkpoints against the image in the source.
+// The image config and the file entries of the layers are inspected. Layers with
+// unsupported compressions are skipped and reported in the alerts of CIS-DI-0008.
+func Inspect(ctx context.Context, src oci.Source, reference string, ignore string) (*spec.CISReportBody, error) {
+	errorf := errs.WithPrefix("")
+
+	img, err := oci.ResolveImage(ctx, src, reference, nil)
+	if err != nil {
+		return nil, errorf.Wrap("resolve image error", err)
+	}
+
+	return InspectImage(ctx, img, ignore)
+}
+
+// InspectImage evaluates the CIS checkpoints against the resolved image.
+func InspectImage(ctx context.Context, img *oci.Image, ignore string) (*spec.CISReportBody, error) {
+	errorf := errs.WithPrefix("")
+
+	cfg, err := img.Config(ctx)
+	if err != nil {
+		return nil, errorf.Wrap("get image config error", err)
+	}
+
+	in := &inspection{config: cfg}
+	for _, l := range img.Manifest.Layers {
+		if err := inspectLayer(ctx, img, l, in); err != nil {
+			return nil, errorf.Wrap("inspect layer %s error", err, l.Digest)
+		}
+	}
+
+	in.flatten()
+
+	return evaluate(in, ignoredCodes(ignore)), nil
+}
+
+// inspectLayer walks the file entries of the layer.
+func inspectLayer(ctx context.Context, img *oci.Image, desc oci.Descriptor, in *inspection) error {
+	rc, err := img.Layer(ctx, desc)
+	if err != nil {
+		if errors.Is(err, oci.ErrUnsupportedCompression) {
+			in.layerAlerts = append(in.layerAlerts, fmt.Sprintf("layer %s is skipped: %s", desc.Digest, err))
+			return nil
+		}
+		return err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		in.inspectFile(hdr)
+	}
+
+	// Drain the stream to verify the layer digest.
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		return err
+	}
+
+	in.commitLayer()
+	return nil
+}
+
+// dockleReport is the JSON output of dockle.
+type dockleReport struct {
+	Summary map[string]uint `json:"summary"`
+	Details []struct {
+		Code   string   `json:"code"`
+		Title  string   `json:"title"`
+		Level  string   `json:"level"`
+		Alerts []string `json:"alerts"`
+	} `json:"details"`
+}
+
+// scanWithDockle runs dockle against the image and converts its output to the CIS report body.
+func scanWithDockle(ctx context.Context, t *native.Target, target ...string) (*spec.CISReportBody, error) {
+	errorf := errs.WithPrefix("")
+	lg := zlog.FromContext(ctx)
+
+	args := buildOptions(t)
+
+	// Specify a temp file for keeping scan output.
+	f, err := ioutil.TempFile("", uuid.FromContext(ctx))
+	if err != nil {
+		return nil, errorf.Wrap("create temp scan result file error", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, errorf.Wrap("close temp scan result file error", err)
+	}
+	defer func() {
+		if err := os.Remove(f.Name()); err != nil {
+			lg.Error(err)
+		}
+	}()
+
+	// Append the scan target.
+	args = append(args, "--output", f.Name())
+	args = append(args, target...)
+
+	dt, err := exec.CommandContext(ctx, "dockle", args...).CombinedOutput()
+	if err != nil {
+		return nil, errorf.Wrap("run backend engine command error: details=%s", err, dt)
+	}
+
+	raw, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, errorf.Wrap("read result temp file error", err)
+	}
+
+	dr := &dockleReport{}
+	if err := json.Unmarshal(raw, dr); err != nil {
+		return nil, errorf.Wrap("parse scan result error", err)
+	}
+
+	body := &spec.CISReportBody{
+		Summary: dr.Summary,
+	}
+	for _, d := range dr.Details {
+		// Dockle levels are in upper case, e.g: FATAL.
+		level := spec.CISLevel(strings.Title(strings.ToLower(d.Level)))
+		body.Details = append(body.Details, spec.CISBenchmarkItem{
+			Code:   d.Code,
+			Title:  d.Title,
+			Link:   fmt.Sprintf(checkpointLink, strings.ToLower(d.Code)),
+			Level:  &level,
+			Alerts: d.Alerts,
+		})
+	}
+
+	return body, nil
+}
+
+func buildOptions(t *native.Target) []string {
+	// Build arguments.
+	args := []string{"-f", "json"}
+
+	if t.Options.Username != "" {
+		args = append(args, "--username", t.Options.Username, "--password", t.Options.Password)
+	}
+
+	// Get configurations.
+	timeout := viper.GetString("scanner.backends.cis.timeout")
+	insecure := viper.GetBool("scanner.backends.cis.insecure")
+	ignores := viper.GetString("scanner.backends.cis.ignore")
+	certPath := viper.GetString("scanner.backends.cis.certPath")
+
+	// The registry settings replace the engine settings.
+	if c, ok := oci.LookupRegistry(t.Registry); ok {
+		insecure, certPath = c.Insecure, c.CertPath
+	}
+
+	// Append corresponding options if related configurations are set.
+	if len(timeout) > 0 {
+		args = append(args, "-t", timeout)
+	}
+	if insecure {
+		args = append(args, "--insecure")
+	}
+	if len(ignores) > 0 {
+		args = append(args, "--ignore", ignores)
+	}
+	if len(certPath) > 0 {
+		args = append(args, "--cert-path", certPath)
+	}
+
+	return args
+}
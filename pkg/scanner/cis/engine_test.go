@@ -0,0 +1,250 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cis
+
+import (
+	"archive/tar"
+	"context"
+	"reflect"
+	"testing"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci/ocitest"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// config returns the image config passing all the checkpoints.
+func config() *oci.ImageConfig {
+	cfg := &oci.ImageConfig{}
+	cfg.Config.User = "app"
+	cfg.Config.Healthcheck = &oci.Healthcheck{Test: []string{"CMD", "true"}}
+
+	return cfg
+}
+
+// inspect writes the image to a temporary OCI layout and inspects it.
+func inspect(t *testing.T, cfg *oci.ImageConfig, ignore string, layers ...[]byte) *spec.CISReportBody {
+	t.Helper()
+
+	dir := t.TempDir()
+	ocitest.WriteLayout(t, dir, cfg, layers...)
+	src, err := oci.OpenLocal("oci-layout:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, err := Inspect(context.Background(), src, ocitest.RefName, ignore)
+	if err != nil {
+		t.Fatalf("inspect error: %v", err)
+	}
+
+	return body
+}
+
+// item returns the benchmark item of the code.
+func item(t *testing.T, body *spec.CISReportBody, code string) spec.CISBenchmarkItem {
+	t.Helper()
+
+	for _, d := range body.Details {
+		if d.Code == code {
+			return d
+		}
+	}
+
+	t.Fatalf("benchmark %s is not found", code)
+	return spec.CISBenchmarkItem{}
+}
+
+func TestInspect(t *testing.T) {
+	clean := ocitest.Layer(t, ocitest.File{Name: "etc/hostname", Content: []byte("host")})
+
+	cases := []struct {
+		name   string
+		config func(cfg *oci.ImageConfig)
+		layers [][]byte
+		code   string
+		level  spec.CISLevel
+		alerts []string
+	}{
+		{
+			name:   "non-root user",
+			layers: [][]byte{clean},
+			code:   "CIS-DI-0001",
+			level:  spec.PASS,
+		},
+		{
+			name:   "unset user",
+			config: func(cfg *oci.ImageConfig) { cfg.Config.User = "" },
+			layers: [][]byte{clean},
+			code:   "CIS-DI-0001",
+			level:  spec.WARN,
+			alerts: []string{"Last user should not be root"},
+		},
+		{
+			name:   "root user",
+			config: func(cfg *oci.ImageConfig) { cfg.Config.User = "root" },
+			layers: [][]byte{clean},
+			code:   "CIS-DI-0001",
+			level:  spec.WARN,
+			alerts: []string{"Last user should not be root"},
+		},
+		{
+			name:   "root uid with group",
+			config: func(cfg *oci.ImageConfig) { cfg.Config.User = "0:1000" },
+			layers: [][]byte{clean},
+			code:   "CIS-DI-0001",
+			level:  spec.WARN,
+			alerts: []string{"Last user should not be root"},
+		},
+		{
+			name: "setuid file",
+			layers: [][]byte{ocitest.Layer(t,
+				ocitest.File{Name: "usr/bin/su", Content: []byte("su"), Mode: 0o4755},
+				ocitest.File{Name: "usr/bin/ls", Content: []byte("ls"), Mode: 0o755},
+			)},
+			code:   "CIS-DI-0008",
+			level:  spec.INFO,
+			alerts: []string{"setuid/setgid file: /usr/bin/su"},
+		},
+		{
+			name: "setgid file",
+			layers: [][]byte{ocitest.Layer(t,
+				ocitest.File{Name: "usr/bin/wall", Content: []byte("wall"), Mode: 0o2755},
+			)},
+			code:   "CIS-DI-0008",
+			level:  spec.INFO,
+			alerts: []string{"setuid/setgid file: /usr/bin/wall"},
+		},
+		{
+			name:   "credential in env",
+			config: func(cfg *oci.ImageConfig) { cfg.Config.Env = []string{"PATH=/usr/bin", "DB_PASSWORD=secret"} },
+			layers: [][]byte{clean},
+			code:   "CIS-DI-0010",
+			level:  spec.FATAL,
+			alerts: []string{"Suspicious ENV key found : DB_PASSWORD"},
+		},
+		{
+			name:   "empty credential in env",
+			config: func(cfg *oci.ImageConfig) { cfg.Config.Env = []string{"API_KEY="} },
+			layers: [][]byte{clean},
+			code:   "CIS-DI-0010",
+			level:  spec.PASS,
+		},
+		{
+			name: "credential file",
+			layers: [][]byte{ocitest.Layer(t,
+				ocitest.File{Name: "root/.aws/credentials", Content: []byte("[default]")},
+			)},
+			code:   "CIS-DI-0010",
+			level:  spec.FATAL,
+			alerts: []string{"Suspicious filename found : /root/.aws/credentials"},
+		},
+		{
+			name: "setuid file removed by whiteout",
+			layers: [][]byte{
+				ocitest.Layer(t,
+					ocitest.File{Name: "usr/bin/su", Content: []byte("su"), Mode: 0o4755},
+					ocitest.File{Name: "usr/bin/passwd", Content: []byte("passwd"), Mode: 0o4755},
+				),
+				ocitest.Layer(t, ocitest.File{Name: "usr/bin/.wh.su"}),
+			},
+			code:   "CIS-DI-0008",
+			level:  spec.INFO,
+			alerts: []string{"setuid/setgid file: /usr/bin/passwd"},
+		},
+		{
+			name: "credential file removed by opaque whiteout",
+			layers: [][]byte{
+				ocitest.Layer(t, ocitest.File{Name: "root/.aws/credentials", Content: []byte("[default]")}),
+				ocitest.Layer(t, ocitest.File{Name: "root/.aws/.wh..wh..opq"}),
+			},
+			code:  "CIS-DI-0010",
+			level: spec.PASS,
+		},
+		{
+			name: "setuid bit dropped in upper layer",
+			layers: [][]byte{
+				ocitest.Layer(t, ocitest.File{Name: "usr/bin/su", Content: []byte("su"), Mode: 0o4755}),
+				ocitest.Layer(t, ocitest.File{Name: "usr/bin/su", Content: []byte("su"), Mode: 0o755}),
+			},
+			code:  "CIS-DI-0008",
+			level: spec.PASS,
+		},
+		{
+			name: "credential file added back after whiteout",
+			layers: [][]byte{
+				ocitest.Layer(t, ocitest.File{Name: "root/.npmrc", Content: []byte("token")}),
+				ocitest.Layer(t,
+					ocitest.File{Name: "root/.wh..npmrc"},
+					ocitest.File{Name: "root/.npmrc", Content: []byte("token")},
+				),
+			},
+			code:   "CIS-DI-0010",
+			level:  spec.FATAL,
+			alerts: []string{"Suspicious filename found : /root/.npmrc"},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			cfg := config()
+			if c.config != nil {
+				c.config(cfg)
+			}
+
+			d := item(t, inspect(t, cfg, "", c.layers...), c.code)
+			if d.Level == nil || *d.Level != c.level {
+				t.Errorf("expect level %s, got %v", c.level, d.Level)
+			}
+			if (len(d.Alerts) > 0 || len(c.alerts) > 0) && !reflect.DeepEqual(d.Alerts, c.alerts) {
+				t.Errorf("expect alerts %q, got %q", c.alerts, d.Alerts)
+			}
+		})
+	}
+}
+
+func TestInspectSummary(t *testing.T) {
+	layer := ocitest.Layer(t,
+		ocitest.File{Name: "usr/", Typeflag: tar.TypeDir},
+		ocitest.File{Name: "usr/bin/su", Content: []byte("su"), Mode: 0o4755},
+	)
+
+	cfg := config()
+	body := inspect(t, cfg, "", layer)
+	if want := map[string]uint{"pass": 6, "info": 1}; !reflect.DeepEqual(body.Summary, want) {
+		t.Errorf("expect summary %v, got %v", want, body.Summary)
+	}
+
+	cfg.Config.User = ""
+	cfg.Config.Env = []string{"GITHUB_TOKEN=ghp"}
+	body = inspect(t, cfg, "", layer)
+	if want := map[string]uint{"pass": 4, "info": 1, "warn": 1, "fatal": 1}; !reflect.DeepEqual(body.Summary, want) {
+		t.Errorf("expect summary %v, got %v", want, body.Summary)
+	}
+	if len(body.Details) != len(checkpoints) {
+		t.Errorf("expect %d benchmarks, got %d", len(checkpoints), len(body.Details))
+	}
+
+	// The ignored checkpoints are neither reported nor counted.
+	body = inspect(t, cfg, "CIS-DI-0001, CIS-DI-0010", layer)
+	if want := map[string]uint{"pass": 4, "info": 1}; !reflect.DeepEqual(body.Summary, want) {
+		t.Errorf("expect summary %v, got %v", want, body.Summary)
+	}
+	for _, d := range body.Details {
+		if d.Code == "CIS-DI-0001" || d.Code == "CIS-DI-0010" {
+			t.Errorf("expect %s to be ignored", d.Code)
+		}
+	}
+}
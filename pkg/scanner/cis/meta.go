@@ -14,6 +14,8 @@
 
 package cis
 
+import "github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+
 const (
 	// Name of CIS provider.
 	Name = "CIS"
@@ -25,7 +27,16 @@ const (
 	ReportMimeType = "application/vnd.security.cis.report; version=1.0"
 )
 
-// ExtraMeta is the CIS extra properties.
+// SupportedMimeTypes are the mimetypes of the artifacts can be scanned.
+// Each platform of the image index is scanned.
+var SupportedMimeTypes = []string{
+	oci.OCIImage,
+	oci.DockerV2Image,
+	oci.OCIImageIndex,
+	oci.DockerManifestList,
+}
+
+// ExtraMeta is the CIS extra properties of the dockle engine.
 var ExtraMeta *map[string]string = &map[string]string{
 	"maintainer": "steven-zou",
 	"engine":     "dockle",
@@ -33,3 +44,19 @@ var ExtraMeta *map[string]string = &map[string]string{
 	"license":    "Apache-2.0",
 	"repository": "github.com/goodwithtech/dockle",
 }
+
+// properties returns the extra properties of the configured engine.
+func properties() map[string]string {
+	props := make(map[string]string, len(*ExtraMeta))
+	for k, v := range *ExtraMeta {
+		props[k] = v
+	}
+
+	if engine() == EngineNative {
+		props["repository"] = "github.com/szlabs/harbor-scanner-adapter"
+	}
+	props["engine"] = engine()
+	props["version"] = engineVersion()
+
+	return props
+}
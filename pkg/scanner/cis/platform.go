@@ -0,0 +1,177 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cis
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// PlatformReportMerged only reports the benchmarks merged from all the platforms.
+	PlatformReportMerged = "merged"
+	// PlatformReportSectioned reports the benchmarks of each platform besides the merged ones.
+	PlatformReportSectioned = "sectioned"
+)
+
+// levelOrder ranks the CIS levels from low to high.
+var levelOrder = map[spec.CISLevel]int{
+	spec.PASS:  0,
+	spec.SKIP:  1,
+	spec.INFO:  2,
+	spec.WARN:  3,
+	spec.FATAL: 4,
+}
+
+// platformReport returns the configured report mode of the image index.
+func platformReport() string {
+	if m := viper.GetString("scanner.backends.cis.platformReport"); m != "" {
+		return m
+	}
+
+	return PlatformReportMerged
+}
+
+// scanIndex scans each platform of the image index with the configured engine.
+// The merged benchmarks and the benchmarks of each platform are returned.
+func scanIndex(ctx context.Context, t *native.Target) (*spec.CISReportBody, []spec.CISPlatformReport, error) {
+	filter, err := platformFilter(viper.GetStringSlice("scanner.backends.cis.platforms"))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	src, err := t.Source()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	images, err := oci.ResolveImages(ctx, src, reference(t.Artifact), filter)
+	if err != nil {
+		return nil, nil, fmt.Errorf("resolve image index error: %w", err)
+	}
+
+	var reports []spec.CISPlatformReport
+	for _, img := range images {
+		platform := "default"
+		if img.Platform != nil {
+			platform = img.Platform.String()
+		}
+
+		var body *spec.CISReportBody
+		switch engine() {
+		case EngineDockle:
+			if t.Artifact.Reference != "" {
+				return nil, nil, fmt.Errorf("dockle engine can't scan the platforms of the local image index, use the native engine")
+			}
+
+			// Scan the platform image by digest.
+			image := oci.ImageRef(t.Registry, t.Artifact.Repository, "", img.Digest)
+			body, err = scanWithDockle(ctx, t, image)
+		case EngineNative:
+			body, err = InspectImage(ctx, img, viper.GetString("scanner.backends.cis.ignore"))
+		default:
+			err = fmt.Errorf("unknown CIS engine: %s", engine())
+		}
+		if err != nil {
+			return nil, nil, fmt.Errorf("scan platform %s error: %w", platform, err)
+		}
+
+		reports = append(reports, spec.CISPlatformReport{
+			Platform:   platform,
+			Digest:     img.Digest,
+			Benchmarks: body,
+		})
+	}
+
+	return mergePlatforms(reports), reports, nil
+}
+
+// platformFilter returns the filter of the platforms in the os/arch[/variant] format.
+// All the platforms are accepted if no platform is specified.
+func platformFilter(platforms []string) (func(*oci.Platform) bool, error) {
+	var patterns []*oci.Platform
+	for _, s := range platforms {
+		p, err := oci.ParsePlatform(s)
+		if err != nil {
+			return nil, err
+		}
+		patterns = append(patterns, p)
+	}
+
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+
+	return func(p *oci.Platform) bool {
+		for _, pattern := range patterns {
+			if p.Matches(pattern) {
+				return true
+			}
+		}
+
+		return false
+	}, nil
+}
+
+// mergePlatforms merges the benchmarks of the platforms.
+// The highest level of the checkpoint is kept and the alerts are prefixed with the platform.
+func mergePlatforms(reports []spec.CISPlatformReport) *spec.CISReportBody {
+	merged := &spec.CISReportBody{
+		Summary: make(map[string]uint),
+	}
+
+	index := make(map[string]int)
+	for _, r := range reports {
+		if r.Benchmarks == nil {
+			continue
+		}
+
+		for _, d := range r.Benchmarks.Details {
+			i, ok := index[d.Code]
+			if !ok {
+				item := d
+				item.Alerts = nil
+				merged.Details = append(merged.Details, item)
+				i = len(merged.Details) - 1
+				index[d.Code] = i
+			}
+
+			item := &merged.Details[i]
+			if d.Level != nil && (item.Level == nil || levelOrder[*d.Level] > levelOrder[*item.Level]) {
+				level := *d.Level
+				item.Level = &level
+			}
+
+			for _, a := range d.Alerts {
+				item.Alerts = append(item.Alerts, fmt.Sprintf("[%s] %s", r.Platform, a))
+			}
+		}
+	}
+
+	for _, d := range merged.Details {
+		if d.Level != nil {
+			merged.Summary[strings.ToLower(string(*d.Level))]++
+		}
+	}
+
+	return merged
+}
@@ -0,0 +1,229 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// AttrEngines is the vendor attribute of the engines reporting the vulnerability.
+	AttrEngines = "engines"
+	// AttrSeverities is the vendor attribute of the severities reported by each engine.
+	AttrSeverities = "engine_severities"
+)
+
+// severityOrder ranks the severities from low to high.
+var severityOrder = map[spec.Severity]int{
+	spec.UNKNOWN:    0,
+	spec.NEGLIGIBLE: 1,
+	spec.LOW:        2,
+	spec.MEDIUM:     3,
+	spec.HIGH:       4,
+	spec.CRITICAL:   5,
+}
+
+// vulnerability mirrors spec.VulnerabilityItem but keeps the vendor attributes of any types,
+// e.g. some engines report the CVSS vectors as objects.
+type vulnerability struct {
+	spec.VulnerabilityItem
+	VendorAttributes map[string]interface{} `json:"vendor_attributes,omitempty"`
+}
+
+// report mirrors spec.HarborVulnerabilityReport with the vulnerability above.
+type report struct {
+	spec.HarborVulnerabilityReport
+	Vulnerabilities []vulnerability `json:"vulnerabilities,omitempty"`
+}
+
+// engineReport is the report of an engine.
+type engineReport struct {
+	engine string
+	report *report
+}
+
+// finding is a merged vulnerability.
+type finding struct {
+	vulnerability
+	engines    []string
+	severities []string
+	// rank of the engine whose severity is applied, lower is preferred.
+	rank int
+}
+
+// merger merges the reports of the engines.
+type merger struct {
+	strategy string
+	// preferred ranks the engines by the index.
+	preferred map[string]int
+}
+
+func newMerger(strategy string, preferred []string) (*merger, error) {
+	switch strategy {
+	case StrategyMax, StrategyPreferred:
+	case "":
+		strategy = StrategyMax
+	default:
+		return nil, fmt.Errorf("unknown severity strategy: %s", strategy)
+	}
+
+	m := &merger{
+		strategy:  strategy,
+		preferred: make(map[string]int, len(preferred)),
+	}
+	for i, e := range preferred {
+		if _, ok := m.preferred[e]; !ok {
+			m.preferred[e] = i
+		}
+	}
+
+	return m, nil
+}
+
+// rank of the engine, the engines not preferred are ranked last.
+func (m *merger) rank(engine string) int {
+	if r, ok := m.preferred[engine]; ok {
+		return r
+	}
+
+	return len(m.preferred)
+}
+
+// merge the engine reports into one, the vulnerabilities are deduplicated by ID, package and version.
+func (m *merger) merge(reports []engineReport) *report {
+	res := &report{
+		HarborVulnerabilityReport: spec.HarborVulnerabilityReport{
+			GeneratedAt: time.Now().UTC(),
+			Scanner: &spec.Scanner{
+				Name:    Name,
+				Vendor:  Vendor,
+				Version: Version,
+			},
+		},
+	}
+
+	var findings []*finding
+	index := make(map[string]*finding)
+	for _, er := range reports {
+		if res.Artifact == nil {
+			res.Artifact = er.report.Artifact
+		}
+
+		for _, v := range er.report.Vulnerabilities {
+			key := strings.Join([]string{v.Id, v.Package_, v.Version}, "|")
+			f, ok := index[key]
+			if !ok {
+				f = &finding{vulnerability: v, rank: m.rank(er.engine)}
+				// Copy the attributes as they are updated later.
+				f.VendorAttributes = make(map[string]interface{}, len(v.VendorAttributes)+2)
+				for k, a := range v.VendorAttributes {
+					f.VendorAttributes[k] = a
+				}
+
+				index[key] = f
+				findings = append(findings, f)
+			} else {
+				m.combine(f, er.engine, &v)
+			}
+
+			if !contains(f.engines, er.engine) {
+				f.engines = append(f.engines, er.engine)
+				if v.Severity != nil {
+					f.severities = append(f.severities, fmt.Sprintf("%s=%s", er.engine, *v.Severity))
+				}
+			}
+		}
+	}
+
+	for _, f := range findings {
+		f.VendorAttributes[AttrEngines] = f.engines
+		f.VendorAttributes[AttrSeverities] = strings.Join(f.severities, ",")
+		res.Vulnerabilities = append(res.Vulnerabilities, f.vulnerability)
+
+		if f.Severity != nil && (res.Severity == nil || severityOrder[*f.Severity] > severityOrder[*res.Severity]) {
+			sev := *f.Severity
+			res.Severity = &sev
+		}
+	}
+
+	return res
+}
+
+// combine the vulnerability reported by another engine into the finding.
+func (m *merger) combine(f *finding, engine string, v *vulnerability) {
+	if v.Severity != nil {
+		switch {
+		case f.Severity == nil:
+			f.Severity, f.rank = v.Severity, m.rank(engine)
+		case m.strategy == StrategyPreferred && m.rank(engine) < f.rank:
+			f.Severity, f.rank = v.Severity, m.rank(engine)
+		case (m.strategy == StrategyMax || m.rank(engine) == f.rank) && severityOrder[*v.Severity] > severityOrder[*f.Severity]:
+			f.Severity = v.Severity
+		}
+	}
+
+	// Fill the missing details.
+	if f.FixVersion == "" {
+		f.FixVersion = v.FixVersion
+	}
+	if f.Description == "" {
+		f.Description = v.Description
+	}
+	if f.PreferredCvss == nil {
+		f.PreferredCvss = v.PreferredCvss
+	}
+	f.Links = union(f.Links, v.Links)
+	f.CweIds = union(f.CweIds, v.CweIds)
+
+	for k, a := range v.VendorAttributes {
+		if _, ok := f.VendorAttributes[k]; !ok {
+			f.VendorAttributes[k] = a
+		}
+	}
+}
+
+// union returns the sorted distinct values of both lists.
+func union(a []string, b []string) []string {
+	if len(b) == 0 {
+		return a
+	}
+
+	var res []string
+	seen := make(map[string]bool, len(a)+len(b))
+	for _, s := range append(append([]string{}, a...), b...) {
+		if !seen[s] {
+			seen[s] = true
+			res = append(res, s)
+		}
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
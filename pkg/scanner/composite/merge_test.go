@@ -0,0 +1,231 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// vuln builds the vulnerability reported by an engine, the severity is not set if it's empty.
+func vuln(id, pkg, version string, severity spec.Severity, attrs map[string]interface{}) vulnerability {
+	v := vulnerability{
+		VulnerabilityItem: spec.VulnerabilityItem{
+			Id:       id,
+			Package_: pkg,
+			Version:  version,
+		},
+		VendorAttributes: attrs,
+	}
+	if severity != "" {
+		v.Severity = &severity
+	}
+
+	return v
+}
+
+// reportOf builds the report of the engine with the vulnerabilities.
+func reportOf(engine string, vulns ...vulnerability) engineReport {
+	return engineReport{engine: engine, report: &report{Vulnerabilities: vulns}}
+}
+
+func TestMergeDedup(t *testing.T) {
+	m, err := newMerger(StrategyMax, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := m.merge([]engineReport{
+		reportOf("trivy",
+			vuln("CVE-2021-0001", "openssl", "1.1.1", spec.HIGH, nil),
+			vuln("CVE-2021-0001", "openssl", "3.0.0", spec.LOW, nil),
+		),
+		reportOf("grype",
+			vuln("CVE-2021-0001", "openssl", "1.1.1", spec.MEDIUM, nil),
+			vuln("CVE-2021-0001", "libssl", "1.1.1", spec.MEDIUM, nil),
+			vuln("CVE-2021-0002", "zlib", "1.2.11", spec.CRITICAL, nil),
+		),
+	})
+
+	cases := []struct {
+		key        string
+		severity   spec.Severity
+		engines    []string
+		severities string
+	}{
+		{"CVE-2021-0001|openssl|1.1.1", spec.HIGH, []string{"trivy", "grype"}, "trivy=High,grype=Medium"},
+		{"CVE-2021-0001|openssl|3.0.0", spec.LOW, []string{"trivy"}, "trivy=Low"},
+		{"CVE-2021-0001|libssl|1.1.1", spec.MEDIUM, []string{"grype"}, "grype=Medium"},
+		{"CVE-2021-0002|zlib|1.2.11", spec.CRITICAL, []string{"grype"}, "grype=Critical"},
+	}
+
+	if len(res.Vulnerabilities) != len(cases) {
+		t.Fatalf("expect %d vulnerabilities, got %d", len(cases), len(res.Vulnerabilities))
+	}
+
+	for i, c := range cases {
+		v := res.Vulnerabilities[i]
+		if key := v.Id + "|" + v.Package_ + "|" + v.Version; key != c.key {
+			t.Errorf("expect vulnerability %d to be %s, got %s", i, c.key, key)
+			continue
+		}
+		if v.Severity == nil || *v.Severity != c.severity {
+			t.Errorf("%s: expect severity %s, got %v", c.key, c.severity, v.Severity)
+		}
+		if !reflect.DeepEqual(v.VendorAttributes[AttrEngines], c.engines) {
+			t.Errorf("%s: expect engines %v, got %v", c.key, c.engines, v.VendorAttributes[AttrEngines])
+		}
+		if v.VendorAttributes[AttrSeverities] != c.severities {
+			t.Errorf("%s: expect engine severities %q, got %v", c.key, c.severities, v.VendorAttributes[AttrSeverities])
+		}
+	}
+
+	if res.Severity == nil || *res.Severity != spec.CRITICAL {
+		t.Errorf("expect report severity %s, got %v", spec.CRITICAL, res.Severity)
+	}
+}
+
+func TestMergeStrategy(t *testing.T) {
+	cases := []struct {
+		name      string
+		strategy  string
+		preferred []string
+		reports   []engineReport
+		severity  spec.Severity
+	}{
+		{
+			name:     "max",
+			strategy: StrategyMax,
+			reports: []engineReport{
+				reportOf("trivy", vuln("CVE-1", "a", "1", spec.LOW, nil)),
+				reportOf("grype", vuln("CVE-1", "a", "1", spec.HIGH, nil)),
+			},
+			severity: spec.HIGH,
+		},
+		{
+			name:     "max ignores preferred engines",
+			strategy: StrategyMax,
+			reports: []engineReport{
+				reportOf("trivy", vuln("CVE-1", "a", "1", spec.HIGH, nil)),
+				reportOf("grype", vuln("CVE-1", "a", "1", spec.LOW, nil)),
+			},
+			preferred: []string{"grype"},
+			severity:  spec.HIGH,
+		},
+		{
+			name:      "preferred engine wins",
+			strategy:  StrategyPreferred,
+			preferred: []string{"grype", "trivy"},
+			reports: []engineReport{
+				reportOf("trivy", vuln("CVE-1", "a", "1", spec.HIGH, nil)),
+				reportOf("grype", vuln("CVE-1", "a", "1", spec.LOW, nil)),
+			},
+			severity: spec.LOW,
+		},
+		{
+			name:      "listed engine wins over unlisted one",
+			strategy:  StrategyPreferred,
+			preferred: []string{"trivy"},
+			reports: []engineReport{
+				reportOf("grype", vuln("CVE-1", "a", "1", spec.CRITICAL, nil)),
+				reportOf("trivy", vuln("CVE-1", "a", "1", spec.MEDIUM, nil)),
+			},
+			severity: spec.MEDIUM,
+		},
+		{
+			name:     "unlisted engines fall back to max",
+			strategy: StrategyPreferred,
+			reports: []engineReport{
+				reportOf("trivy", vuln("CVE-1", "a", "1", spec.LOW, nil)),
+				reportOf("grype", vuln("CVE-1", "a", "1", spec.HIGH, nil)),
+			},
+			severity: spec.HIGH,
+		},
+		{
+			name:      "missing severity of preferred engine",
+			strategy:  StrategyPreferred,
+			preferred: []string{"trivy", "grype"},
+			reports: []engineReport{
+				reportOf("trivy", vuln("CVE-1", "a", "1", "", nil)),
+				reportOf("grype", vuln("CVE-1", "a", "1", spec.MEDIUM, nil)),
+			},
+			severity: spec.MEDIUM,
+		},
+		{
+			name:     "default strategy is max",
+			strategy: "",
+			reports: []engineReport{
+				reportOf("trivy", vuln("CVE-1", "a", "1", spec.MEDIUM, nil)),
+				reportOf("grype", vuln("CVE-1", "a", "1", spec.CRITICAL, nil)),
+			},
+			severity: spec.CRITICAL,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			m, err := newMerger(c.strategy, c.preferred)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			res := m.merge(c.reports)
+			if len(res.Vulnerabilities) != 1 {
+				t.Fatalf("expect 1 vulnerability, got %d", len(res.Vulnerabilities))
+			}
+			if sev := res.Vulnerabilities[0].Severity; sev == nil || *sev != c.severity {
+				t.Errorf("expect severity %s, got %v", c.severity, sev)
+			}
+		})
+	}
+}
+
+func TestNewMergerUnknownStrategy(t *testing.T) {
+	if _, err := newMerger("min", nil); err == nil {
+		t.Error("expect error of the unknown strategy")
+	}
+}
+
+func TestMergeVendorAttributes(t *testing.T) {
+	trivyAttrs := map[string]interface{}{"cvss": map[string]interface{}{"nvd": 7.5}}
+	grypeAttrs := map[string]interface{}{"cvss": "ignored", "namespace": "debian"}
+
+	m, err := newMerger(StrategyMax, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := m.merge([]engineReport{
+		reportOf("trivy", vuln("CVE-1", "a", "1", spec.LOW, trivyAttrs)),
+		reportOf("grype", vuln("CVE-1", "a", "1", "", grypeAttrs)),
+	})
+
+	want := map[string]interface{}{
+		"cvss":         map[string]interface{}{"nvd": 7.5},
+		"namespace":    "debian",
+		AttrEngines:    []string{"trivy", "grype"},
+		AttrSeverities: "trivy=Low",
+	}
+	if got := res.Vulnerabilities[0].VendorAttributes; !reflect.DeepEqual(got, want) {
+		t.Errorf("expect vendor attributes %v, got %v", want, got)
+	}
+
+	// The attributes of the engine reports are not modified.
+	if _, ok := trivyAttrs[AttrEngines]; ok || len(trivyAttrs) != 1 {
+		t.Errorf("expect the engine attributes to be untouched, got %v", trivyAttrs)
+	}
+}
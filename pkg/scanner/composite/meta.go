@@ -0,0 +1,39 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite
+
+const (
+	// Name of composite provider.
+	Name = "Composite"
+	// Vendor of composite provider.
+	Vendor = "Harbor"
+	// Version of composite provider.
+	Version = "0.1.0"
+	// ReportMimeType is mimetype of the merged Harbor vulnerability report.
+	ReportMimeType = "application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0"
+	// CapabilityType is the capability type of scanning vulnerabilities.
+	CapabilityType = "vulnerability"
+
+	// StrategyMax resolves the conflicting severities with the highest one.
+	StrategyMax = "max"
+	// StrategyPreferred resolves the conflicting severities with the one reported by the most preferred engine.
+	StrategyPreferred = "preferred"
+)
+
+// ExtraMeta is the composite extra properties.
+var ExtraMeta = map[string]string{
+	"maintainer": "steven-zou",
+	"license":    "Apache-2.0",
+}
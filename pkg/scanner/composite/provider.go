@@ -0,0 +1,253 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// Engine is a vulnerability scanner wrapped by the composite provider.
+// It mirrors scanner.Provider to avoid the import cycle.
+type Engine interface {
+	// Metadata of the engine.
+	Metadata() *spec.ScannerAdapterMetadata
+	// AcceptScanRequest accepts the scan request.
+	AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error)
+	// RetrieveScanResult retrieves the scan result of the mimetype.
+	RetrieveScanResult(ctx context.Context, reqID string, mimetype string) (scan.Result, error)
+}
+
+// NamedEngine is the engine with the name used in the configurations and reports.
+type NamedEngine struct {
+	Name   string
+	Engine Engine
+}
+
+// Provider fans the scan request out to the vulnerability engines and merges their reports.
+//
+// The engines scan in parallel in their own jobs, the reports are merged once all of them
+// are completed, so the composite provider does not keep any data itself.
+type Provider struct {
+	engines []NamedEngine
+	name    string
+}
+
+// New a composite provider of the engines in order.
+func New(engines []NamedEngine) *Provider {
+	return &Provider{
+		engines: engines,
+		name:    Name,
+	}
+}
+
+// Metadata implements scanner.Provider.
+func (p *Provider) Metadata() *spec.ScannerAdapterMetadata {
+	props := make(map[string]string, len(ExtraMeta)+2)
+	for k, v := range ExtraMeta {
+		props[k] = v
+	}
+
+	var names, consumes []string
+	for _, e := range p.engines {
+		names = append(names, e.Name)
+
+		for _, c := range e.Engine.Metadata().Capabilities {
+			if !contains(c.ProducesMimeTypes, ReportMimeType) {
+				continue
+			}
+
+			for _, m := range c.ConsumesMimeTypes {
+				if !contains(consumes, m) {
+					consumes = append(consumes, m)
+				}
+			}
+		}
+	}
+	props["engines"] = strings.Join(names, ",")
+	props["strategy"] = strategy()
+
+	return &spec.ScannerAdapterMetadata{
+		Scanner: &spec.Scanner{
+			Name:    Name,
+			Version: Version,
+			Vendor:  Vendor,
+		},
+		Capabilities: []spec.ScannerCapability{
+			{
+				Type:              CapabilityType,
+				ConsumesMimeTypes: consumes,
+				ProducesMimeTypes: []string{
+					ReportMimeType,
+				},
+			},
+		},
+		Properties: &props,
+	}
+}
+
+// AcceptScanRequest implements scanner.Provider.
+// The request is accepted if any of the engines accepts it.
+func (p *Provider) AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error) {
+	errorf := errs.WithPrefix("accept scan request error")
+
+	reqID := uuid.FromContext(ctx)
+	if reqID == "" {
+		return nil, errorf.Error("missing request ID in the context")
+	}
+
+	// The local artifacts have no registry, the engines check whether they support them.
+	if req == nil || req.Artifact == nil || (req.Registry == nil && req.Artifact.Reference == "") {
+		return nil, errorf.Error("registry or local reference and artifact are required in the scan request")
+	}
+
+	lg := zlog.FromContext(ctx)
+
+	var (
+		wg       sync.WaitGroup
+		lock     sync.Mutex
+		accepted []string
+		failures []string
+	)
+	for _, e := range p.engines {
+		if !consumes(e.Engine, req.Artifact.MimeType) {
+			continue
+		}
+
+		wg.Add(1)
+		go func(e NamedEngine) {
+			defer wg.Done()
+
+			_, err := e.Engine.AcceptScanRequest(ctx, req)
+
+			lock.Lock()
+			defer lock.Unlock()
+
+			if err != nil {
+				lg.Errorw("engine accept scan request error", "engine", e.Name, "error", err)
+				failures = append(failures, fmt.Sprintf("%s: %s", e.Name, err))
+				return
+			}
+			accepted = append(accepted, e.Name)
+		}(e)
+	}
+	wg.Wait()
+
+	if len(accepted) == 0 {
+		if len(failures) == 0 {
+			return nil, errorf.Error("no engine supports mimetype: %s", req.Artifact.MimeType)
+		}
+		return nil, errorf.Error("no engine accepts the scan request: %s", strings.Join(failures, "; "))
+	}
+
+	lg.Infow("Composite scan request is accepted", "engines", accepted)
+
+	return &spec.ScanResponse{
+		Id: reqID,
+	}, nil
+}
+
+// RetrieveScanResult implements scanner.Provider.
+// The result is not ready until all the engines accepting the request are completed.
+func (p *Provider) RetrieveScanResult(ctx context.Context, reqID string, mimetype string) (scan.Result, error) {
+	errorf := errs.WithPrefix("retrieve scan request error")
+
+	m, err := newMerger(strategy(), viper.GetStringSlice("scanner.backends.composite.preferred"))
+	if err != nil {
+		return nil, errorf.Wrap("create report merger error", err)
+	}
+
+	var (
+		reports  []engineReport
+		failures []string
+	)
+	for _, e := range p.engines {
+		res, err := e.Engine.RetrieveScanResult(ctx, reqID, ReportMimeType)
+		if err != nil {
+			failures = append(failures, fmt.Sprintf("%s: %s", e.Name, err))
+			continue
+		}
+
+		switch res.Phase() {
+		case scan.ResultPhaseNotFound:
+			// The engine did not accept the request.
+			continue
+		case scan.ResultPhaseNotReady:
+			return res, nil
+		}
+
+		r := &report{}
+		if err := json.Unmarshal([]byte(res.JSON()), r); err != nil {
+			failures = append(failures, fmt.Sprintf("%s: invalid report: %s", e.Name, err))
+			continue
+		}
+		reports = append(reports, engineReport{engine: e.Name, report: r})
+	}
+
+	if len(failures) > 0 {
+		if len(reports) == 0 || !viper.GetBool("scanner.backends.composite.allowPartial") {
+			return nil, errorf.Error("engines failed: %s", strings.Join(failures, "; "))
+		}
+
+		zlog.FromContext(ctx).Warnw("merge partial reports as some engines failed", "failures", failures)
+	}
+
+	res := scan.NewJSONResult(mimetype)
+	if len(reports) == 0 {
+		return res, nil
+	}
+
+	bytes, err := json.Marshal(m.merge(reports))
+	if err != nil {
+		return nil, errorf.Wrap("marshal merged report error", err)
+	}
+
+	return res, res.Write(
+		string(bytes),
+		scan.Phase(scan.ResultPhaseReady),
+		scan.NextTry(0), // skip retry
+	)
+}
+
+// strategy of resolving the conflicting severities.
+func strategy() string {
+	if s := viper.GetString("scanner.backends.composite.strategy"); s != "" {
+		return s
+	}
+
+	return StrategyMax
+}
+
+// consumes checks whether the engine produces the vulnerability report of the artifact mimetype.
+func consumes(e Engine, mimetype string) bool {
+	for _, c := range e.Metadata().Capabilities {
+		if contains(c.ProducesMimeTypes, ReportMimeType) && contains(c.ConsumesMimeTypes, mimetype) {
+			return true
+		}
+	}
+
+	return false
+}
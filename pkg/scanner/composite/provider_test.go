@@ -0,0 +1,130 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package composite
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// fakeEngine records the accepted requests.
+type fakeEngine struct {
+	local bool
+
+	lock     sync.Mutex
+	accepted []*spec.ScanRequest
+}
+
+func (e *fakeEngine) Metadata() *spec.ScannerAdapterMetadata {
+	return &spec.ScannerAdapterMetadata{
+		Capabilities: []spec.ScannerCapability{
+			{
+				ConsumesMimeTypes: []string{oci.OCIImage},
+				ProducesMimeTypes: []string{ReportMimeType},
+			},
+		},
+	}
+}
+
+func (e *fakeEngine) AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error) {
+	if req.Artifact.Reference != "" && !e.local {
+		return nil, errors.New("local artifacts are not supported")
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+	e.accepted = append(e.accepted, req)
+
+	return &spec.ScanResponse{Id: uuid.FromContext(ctx)}, nil
+}
+
+func (e *fakeEngine) RetrieveScanResult(context.Context, string, string) (scan.Result, error) {
+	return scan.NewJSONResult(ReportMimeType), nil
+}
+
+func TestAcceptScanRequest(t *testing.T) {
+	ctx := uuid.WithRequestID(context.Background(), "req")
+	local := &spec.Artifact{Reference: "oci-layout:/images/app", MimeType: oci.OCIImage}
+	remote := &spec.Artifact{Repository: "library/app", Digest: "sha256:abc", MimeType: oci.OCIImage}
+
+	cases := []struct {
+		name     string
+		req      *spec.ScanRequest
+		accepted []int
+		fail     bool
+	}{
+		{
+			name:     "remote artifact",
+			req:      &spec.ScanRequest{Registry: &spec.Registry{Url: "https://registry.example.com"}, Artifact: remote},
+			accepted: []int{1, 1},
+		},
+		{
+			name:     "local artifact without registry",
+			req:      &spec.ScanRequest{Artifact: local},
+			accepted: []int{1, 0},
+		},
+		{
+			name: "remote artifact without registry",
+			req:  &spec.ScanRequest{Artifact: remote},
+			fail: true,
+		},
+		{
+			name: "no artifact",
+			req:  &spec.ScanRequest{Registry: &spec.Registry{Url: "https://registry.example.com"}},
+			fail: true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			engines := []*fakeEngine{{local: true}, {}}
+			p := New([]NamedEngine{{Name: "native", Engine: engines[0]}, {Name: "remote", Engine: engines[1]}})
+
+			_, err := p.AcceptScanRequest(ctx, c.req)
+			if c.fail {
+				if err == nil {
+					t.Fatal("expect error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("accept error: %v", err)
+			}
+
+			for i, e := range engines {
+				if len(e.accepted) != c.accepted[i] {
+					t.Errorf("expect engine %d to accept %d requests, got %d", i, c.accepted[i], len(e.accepted))
+				}
+			}
+		})
+	}
+}
+
+func TestAcceptLocalArtifactUnsupported(t *testing.T) {
+	ctx := uuid.WithRequestID(context.Background(), "req")
+	p := New([]NamedEngine{{Name: "remote", Engine: &fakeEngine{}}})
+
+	req := &spec.ScanRequest{Artifact: &spec.Artifact{Reference: "oci-layout:/images/app", MimeType: oci.OCIImage}}
+	if _, err := p.AcceptScanRequest(ctx, req); err == nil {
+		t.Error("expect error as no engine supports the local artifact")
+	}
+}
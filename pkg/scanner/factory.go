@@ -14,14 +14,280 @@
 
 package scanner
 
-import "github.com/szlabs/harbor-scanner-adapter/pkg/scanner/cis"
+import (
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/attest"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/cis"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/composite"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/license"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/malware"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/plugin"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/sbom"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/secret"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/signature"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/vex"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/waiver"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// AdapterName is the scanner name reported when multiple providers are enabled.
+	AdapterName = "Harbor Scanner Adapter"
+	// AdapterVendor is the scanner vendor reported when multiple providers are enabled.
+	AdapterVendor = "Harbor"
+	// AdapterVersion is the scanner version reported when multiple providers are enabled.
+	AdapterVersion = "0.1.0"
+
+	// CapabilityVulnerability is the default capability type requested by Harbor.
+	CapabilityVulnerability = "vulnerability"
+)
+
+// builtin is a provider built in the adapter.
+type builtin struct {
+	// key of the provider configurations under `scanner.backends`.
+	key string
+	// mimetypes of the reports produced by the provider.
+	mimetypes []string
+	// new returns the provider.
+	new func() Provider
+}
+
+// builtins are the providers built in the adapter.
+var builtins = []builtin{
+	{
+		key:       "cis",
+		mimetypes: []string{cis.ReportMimeType},
+		new:       func() Provider { return cis.New() },
+	},
+	{
+		key:       "sbom",
+		mimetypes: []string{sbom.ReportMimeType},
+		new:       func() Provider { return sbom.New() },
+	},
+	{
+		key:       "secret",
+		mimetypes: []string{secret.ReportMimeType},
+		new:       func() Provider { return secret.New() },
+	},
+	{
+		key:       "license",
+		mimetypes: []string{license.ReportMimeType},
+		new:       func() Provider { return license.New() },
+	},
+	{
+		key:       "malware",
+		mimetypes: []string{malware.ReportMimeType},
+		new:       func() Provider { return malware.New() },
+	},
+	{
+		key:       "signature",
+		mimetypes: []string{signature.ReportMimeType},
+		new:       func() Provider { return signature.New() },
+	},
+}
+
+// registration is an enabled provider.
+type registration struct {
+	// key of the builtin provider or name of the plugin.
+	key string
+	// mimetypes of the reports produced by the provider.
+	mimetypes []string
+	// new returns the provider.
+	new func() Provider
+}
+
+// registered returns the enabled builtin providers followed by the loaded plugins.
+// If the composite provider is enabled, it replaces the vulnerability engines it wraps.
+// If the VEX statements are enabled, they're applied to the vulnerability reports.
+// If the waivers are enabled, they're applied to the vulnerability and CIS reports.
+// If the attestations are enabled, the providers also produce the attestations of their reports.
+func registered() []registration {
+	var res []registration
+	for _, b := range builtins {
+		if Enabled(b.key) {
+			res = append(res, registration{key: b.key, mimetypes: b.mimetypes, new: b.new})
+		}
+	}
+
+	for _, p := range plugin.Loaded() {
+		p := p
+		res = append(res, registration{
+			key:       p.Name(),
+			mimetypes: p.MimeTypes(),
+			new:       func() Provider { return p },
+		})
+	}
+
+	if Enabled("composite") {
+		res = withComposite(res)
+	}
+
+	if vex.Enabled() {
+		res = withVEX(res)
+	}
+
+	if waiver.Enabled() {
+		res = withWaivers(res)
+	}
+
+	if attest.Enabled() {
+		res = withAttestations(res)
+	}
+
+	return res
+}
+
+// withComposite replaces the engines of the composite provider with it.
+// The engines are the providers producing the Harbor vulnerability report, limited to the ones
+// listed in `scanner.backends.composite.engines` in order if it's set.
+func withComposite(regs []registration) []registration {
+	candidates := make(map[string]registration)
+	var order []string
+	for _, r := range regs {
+		if contains(r.mimetypes, composite.ReportMimeType) {
+			candidates[r.key] = r
+			order = append(order, r.key)
+		}
+	}
+
+	if names := viper.GetStringSlice("scanner.backends.composite.engines"); len(names) > 0 {
+		order = names
+	}
+
+	var engines []composite.NamedEngine
+	wrapped := make(map[string]bool)
+	for _, name := range order {
+		if r, ok := candidates[name]; ok && !wrapped[name] {
+			engines = append(engines, composite.NamedEngine{Name: name, Engine: r.new()})
+			wrapped[name] = true
+		}
+	}
+
+	if len(engines) == 0 {
+		return regs
+	}
+
+	res := []registration{
+		{
+			key:       "composite",
+			mimetypes: []string{composite.ReportMimeType},
+			new:       func() Provider { return composite.New(engines) },
+		},
+	}
+	for _, r := range regs {
+		if !wrapped[r.key] {
+			res = append(res, r)
+		}
+	}
+
+	return res
+}
+
+// Enabled checks whether the provider with the key is enabled by `scanner.backends.<key>.enabled`.
+func Enabled(key string) bool {
+	return viper.GetBool(fmt.Sprintf("scanner.backends.%s.enabled", key))
+}
 
 // Get provider by mimetype.
+// Nil is returned if no enabled provider produces the mimetype.
 func Get(mimetype string) Provider {
-	switch mimetype {
-	case cis.ReportMimeType:
-		return cis.New()
-	default:
-		return nil
+	for _, r := range registered() {
+		for _, m := range r.mimetypes {
+			if m == mimetype {
+				return r.new()
+			}
+		}
 	}
+
+	return nil
+}
+
+// All returns all the enabled providers, including the plugins.
+func All() []Provider {
+	var providers []Provider
+	for _, r := range registered() {
+		providers = append(providers, r.new())
+	}
+
+	return providers
+}
+
+// Metadata merges the metadata of all the enabled providers.
+// The metadata of the provider is returned as it is if only one provider is enabled.
+func Metadata() *spec.ScannerAdapterMetadata {
+	providers := All()
+	if len(providers) == 1 {
+		return providers[0].Metadata()
+	}
+
+	md := &spec.ScannerAdapterMetadata{
+		Scanner: &spec.Scanner{
+			Name:    AdapterName,
+			Vendor:  AdapterVendor,
+			Version: AdapterVersion,
+		},
+		Capabilities: []spec.ScannerCapability{},
+	}
+
+	props := make(map[string]string)
+	for _, p := range providers {
+		pmd := p.Metadata()
+		md.Capabilities = append(md.Capabilities, pmd.Capabilities...)
+
+		if pmd.Properties != nil {
+			for k, v := range *pmd.Properties {
+				// Prefix with the provider name to avoid conflicts.
+				props[pmd.Scanner.Name+"."+k] = v
+			}
+		}
+	}
+	md.Properties = &props
+
+	return md
+}
+
+// Accepts returns the enabled providers which can handle the scan request.
+//
+// A provider is selected if it consumes the artifact mimetype with a capability enabled in the request.
+// All the capabilities are enabled if the request does not specify any. The capabilities without
+// type are treated as the default vulnerability capability.
+func Accepts(req *spec.ScanRequest) []Provider {
+	enabled := make(map[string]bool)
+	for _, c := range req.EnabledCapabilities {
+		enabled[c.Type] = true
+	}
+
+	var providers []Provider
+	for _, p := range All() {
+		for _, c := range p.Metadata().Capabilities {
+			typ := c.Type
+			if typ == "" {
+				typ = CapabilityVulnerability
+			}
+
+			if len(enabled) > 0 && !enabled[typ] {
+				continue
+			}
+
+			if contains(c.ConsumesMimeTypes, req.Artifact.MimeType) {
+				providers = append(providers, p)
+				break
+			}
+		}
+	}
+
+	return providers
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
 }
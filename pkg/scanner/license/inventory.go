@@ -0,0 +1,400 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	dpkgStatus    = "/var/lib/dpkg/status"
+	dpkgStatusDir = "/var/lib/dpkg/status.d/"
+	apkInstalled  = "/lib/apk/db/installed"
+	rpmBerkeleyDB = "/var/lib/rpm/Packages"
+)
+
+// unsupportedDBs are the package databases can not be parsed.
+var unsupportedDBs = []string{
+	"/var/lib/rpm/rpmdb.sqlite",
+	"/usr/lib/sysimage/rpm/rpmdb.sqlite",
+	"/var/lib/rpm/Packages.db",
+	"/usr/lib/sysimage/rpm/Packages.db",
+}
+
+// layerFormat is the version of the per-layer results, it's changed once the layerFiles is changed.
+const layerFormat = 3
+
+// file kept from the layers.
+type file struct {
+	layer string
+	*parsedFile
+}
+
+// parsedFile is the packages and licenses parsed from a relevant file.
+type parsedFile struct {
+	Packages []record `json:"packages,omitempty"`
+	// Licenses declared by the debian copyright file.
+	Licenses []string `json:"licenses,omitempty"`
+	// Warning of the file, e.g. the package database is not supported.
+	Warning string `json:"warning,omitempty"`
+}
+
+// record of the package parsed from the file.
+type record struct {
+	Name     string     `json:"name"`
+	Version  string     `json:"version,omitempty"`
+	Type     string     `json:"type"`
+	Declared string     `json:"declared,omitempty"`
+	Expr     Expression `json:"expr,omitempty"`
+}
+
+// Inventory collects the packages of the image filesystem.
+type Inventory struct {
+	// files are the relevant files of the flattened filesystem.
+	files map[string]*file
+	// maxFileSize is the size limit of the files to collect.
+	maxFileSize int64
+	warnings    []string
+}
+
+// NewInventory creates an inventory with the file size limit.
+func NewInventory(maxFileSize int64) *Inventory {
+	return &Inventory{
+		files:       make(map[string]*file),
+		maxFileSize: maxFileSize,
+	}
+}
+
+// layerFiles are the changes of a layer to the relevant files.
+// The files are parsed to keep the per-layer results small.
+type layerFiles struct {
+	// Whiteouts are the files removed from the lower layers, the opaque directories end with "/".
+	// The relevant files replaced by the entries not collected are removed as well.
+	Whiteouts []string `json:"whiteouts,omitempty"`
+	// Files are the relevant files added by the layer.
+	Files    map[string]*parsedFile `json:"files,omitempty"`
+	Warnings []string               `json:"warnings,omitempty"`
+}
+
+// Collect walks the image layers in order and keeps the relevant files of the final filesystem.
+// The whiteout files of the later layers remove the files of the previous layers.
+// The relevant files of the layers collected by the previous scans are reused.
+func (inv *Inventory) Collect(ctx context.Context, img *oci.Image) error {
+	fingerprint, err := scan.Fingerprint(Version, []interface{}{layerFormat, inv.maxFileSize})
+	if err != nil {
+		return err
+	}
+	layers := scan.NewLayers(ctx, img, Name, fingerprint)
+
+	for _, l := range img.Manifest.Layers {
+		l := l
+
+		lf := &layerFiles{}
+		err := layers.Analyze(ctx, l, lf, func() error {
+			return inv.collectLayer(ctx, img, l, lf)
+		})
+		if err != nil {
+			if errors.Is(err, oci.ErrUnsupportedCompression) {
+				inv.warnings = append(inv.warnings, fmt.Sprintf("layer %s is skipped: %s", l.Digest, err))
+				continue
+			}
+			return fmt.Errorf("collect layer %s error: %w", l.Digest, err)
+		}
+
+		inv.apply(l.Digest, lf)
+	}
+
+	return nil
+}
+
+func (inv *Inventory) collectLayer(ctx context.Context, img *oci.Image, desc oci.Descriptor, lf *layerFiles) error {
+	rc, err := img.Layer(ctx, desc)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		base := path.Base(name)
+
+		// Collect the whiteouts.
+		if base == ".wh..wh..opq" {
+			lf.Whiteouts = append(lf.Whiteouts, path.Dir(name)+"/")
+			continue
+		}
+		if strings.HasPrefix(base, ".wh.") {
+			lf.Whiteouts = append(lf.Whiteouts, path.Join(path.Dir(name), strings.TrimPrefix(base, ".wh.")))
+			continue
+		}
+
+		if !relevant(name) {
+			continue
+		}
+
+		// The entry replaces the file of the lower layers even if it's not collected,
+		// so the stale copy is removed like a whiteout.
+		if hdr.Typeflag != tar.TypeReg {
+			if hdr.Typeflag != tar.TypeDir {
+				lf.Whiteouts = append(lf.Whiteouts, name)
+			}
+			continue
+		}
+
+		if hdr.Size > inv.maxFileSize {
+			lf.Whiteouts = append(lf.Whiteouts, name)
+			lf.Warnings = append(lf.Warnings, fmt.Sprintf("%s is skipped: size %d exceeds the limit", name, hdr.Size))
+			continue
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return err
+		}
+
+		if lf.Files == nil {
+			lf.Files = make(map[string]*parsedFile)
+		}
+		lf.Files[name] = parseFile(name, content)
+	}
+
+	// Drain the stream to verify the layer digest.
+	_, err = io.Copy(ioutil.Discard, rc)
+	return err
+}
+
+// apply the changes of the layer to the files of the lower layers.
+func (inv *Inventory) apply(layer string, lf *layerFiles) {
+	for _, w := range lf.Whiteouts {
+		if strings.HasSuffix(w, "/") {
+			inv.remove(strings.TrimSuffix(w, "/"), false)
+		} else {
+			inv.remove(w, true)
+		}
+	}
+
+	for name, pf := range lf.Files {
+		inv.files[name] = &file{layer: layer, parsedFile: pf}
+	}
+
+	inv.warnings = append(inv.warnings, lf.Warnings...)
+}
+
+// remove the files under the directory and the directory itself if self is true.
+func (inv *Inventory) remove(dir string, self bool) {
+	for name := range inv.files {
+		if (self && name == dir) || strings.HasPrefix(name, dir+"/") {
+			delete(inv.files, name)
+		}
+	}
+}
+
+// relevant checks whether the file declares the packages or licenses.
+func relevant(name string) bool {
+	switch {
+	case name == dpkgStatus, strings.HasPrefix(name, dpkgStatusDir):
+		return true
+	case strings.HasPrefix(name, "/usr/share/doc/") && path.Base(name) == "copyright" &&
+		path.Dir(path.Dir(name)) == "/usr/share/doc":
+		return true
+	case name == apkInstalled, name == rpmBerkeleyDB:
+		return true
+	case isNodeModule(name), isPythonMetadata(name):
+		return true
+	}
+
+	for _, db := range unsupportedDBs {
+		if name == db {
+			return true
+		}
+	}
+
+	return false
+}
+
+// parseFile parses the packages or licenses declared by the relevant file.
+func parseFile(name string, content []byte) *parsedFile {
+	pf := &parsedFile{}
+
+	var pkgs []*pkg
+	switch {
+	case name == dpkgStatus, strings.HasPrefix(name, dpkgStatusDir):
+		pkgs = parseDpkgStatus(content)
+	case strings.HasPrefix(name, "/usr/share/doc/"):
+		pf.Licenses = parseDebianCopyright(content)
+	case name == apkInstalled:
+		pkgs = parseAPKInstalled(content)
+	case name == rpmBerkeleyDB:
+		rpms, err := parseRPMBerkeleyDB(content)
+		if err != nil {
+			pf.Warning = fmt.Sprintf("parse %s error: %s", name, err)
+			break
+		}
+
+		for _, r := range rpms {
+			pkgs = append(pkgs, &pkg{
+				name:     r.name,
+				version:  r.version,
+				typ:      TypeRPM,
+				declared: r.license,
+				expr:     ParseExpression(r.license),
+			})
+		}
+	case isNodeModule(name):
+		if p, err := parseNPMPackage(content); err == nil {
+			pkgs = append(pkgs, p)
+		}
+	case isPythonMetadata(name):
+		if p, err := parsePythonMetadata(content); err == nil {
+			pkgs = append(pkgs, p)
+		}
+	default:
+		for _, db := range unsupportedDBs {
+			if name == db {
+				pf.Warning = fmt.Sprintf("package database %s is not supported", name)
+			}
+		}
+	}
+
+	for _, p := range pkgs {
+		pf.Packages = append(pf.Packages, record{
+			Name:     p.name,
+			Version:  p.version,
+			Type:     p.typ,
+			Declared: p.declared,
+			Expr:     p.expr,
+		})
+	}
+
+	return pf
+}
+
+// packages returns the packages of the collected files.
+func (inv *Inventory) packages() []*pkg {
+	names := make([]string, 0, len(inv.files))
+	for name := range inv.files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var pkgs []*pkg
+	for _, name := range names {
+		f := inv.files[name]
+		if f.Warning != "" {
+			inv.warnings = append(inv.warnings, f.Warning)
+		}
+
+		for _, r := range f.Packages {
+			p := &pkg{
+				name:     r.Name,
+				version:  r.Version,
+				typ:      r.Type,
+				path:     name,
+				layer:    f.layer,
+				declared: r.Declared,
+				expr:     r.Expr,
+			}
+			if p.typ == TypeDeb {
+				inv.debianLicenses(p)
+			}
+			pkgs = append(pkgs, p)
+		}
+	}
+
+	return pkgs
+}
+
+// debianLicenses resolves the licenses of the debian package with its copyright file.
+func (inv *Inventory) debianLicenses(p *pkg) {
+	f, ok := inv.files[path.Join("/usr/share/doc", p.name, "copyright")]
+	if !ok {
+		return
+	}
+
+	var declared []string
+	for _, l := range f.Licenses {
+		p.expr = and(p.expr, ParseExpression(l))
+		if strings.Contains(strings.ToLower(l), " or ") {
+			l = fmt.Sprintf("(%s)", l)
+		}
+		declared = append(declared, l)
+	}
+	p.declared = strings.Join(declared, " AND ")
+}
+
+// Warnings returns the issues met when collecting the packages.
+func (inv *Inventory) Warnings() []string {
+	return inv.warnings
+}
+
+// classify the packages with the policy and summarize them.
+func classify(pkgs []*pkg, policy *Policy) ([]spec.LicensePackage, *spec.LicenseSummary) {
+	summary := &spec.LicenseSummary{
+		Total:    uint(len(pkgs)),
+		Licenses: make(map[string]uint),
+	}
+
+	res := make([]spec.LicensePackage, 0, len(pkgs))
+	for _, p := range pkgs {
+		c := policy.ClassifyExpression(p.expr)
+		switch c {
+		case Allowed:
+			summary.Allowed++
+		case Denied:
+			summary.Denied++
+		default:
+			summary.Unknown++
+		}
+
+		ids := p.expr.IDs()
+		for _, id := range ids {
+			summary.Licenses[id]++
+		}
+
+		res = append(res, spec.LicensePackage{
+			Name:           p.name,
+			Version:        p.version,
+			Type:           p.typ,
+			Path:           p.path,
+			Layer:          p.layer,
+			Declared:       p.declared,
+			Licenses:       ids,
+			Classification: string(c),
+		})
+	}
+
+	return res, summary
+}
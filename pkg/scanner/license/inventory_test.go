@@ -0,0 +1,107 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci/ocitest"
+)
+
+// apkDB is the apk installed database of the packages, e.g: musl=1.2.3=MIT.
+func apkDB(packages ...string) []byte {
+	buf := &bytes.Buffer{}
+	for _, p := range packages {
+		f := strings.Split(p, "=")
+		buf.WriteString("P:" + f[0] + "\nV:" + f[1] + "\nL:" + f[2] + "\n\n")
+	}
+
+	return buf.Bytes()
+}
+
+func TestInventoryCollect(t *testing.T) {
+	base := ocitest.Layer(t,
+		ocitest.File{Name: "lib/apk/db/installed", Content: apkDB("musl=1.2.3=MIT", "busybox=1.36=GPL-2.0-only")},
+	)
+
+	cases := []struct {
+		name     string
+		upper    []byte
+		packages []string
+		warning  string
+	}{
+		{
+			name:     "lower database",
+			upper:    ocitest.Layer(t, ocitest.File{Name: "etc/hostname", Content: []byte("host")}),
+			packages: []string{"musl@1.2.3", "busybox@1.36"},
+		},
+		{
+			name:     "database replaced",
+			upper:    ocitest.Layer(t, ocitest.File{Name: "lib/apk/db/installed", Content: apkDB("musl=1.2.4=MIT")}),
+			packages: []string{"musl@1.2.4"},
+		},
+		{
+			name:  "database removed by whiteout",
+			upper: ocitest.Layer(t, ocitest.File{Name: "lib/apk/db/.wh.installed"}),
+		},
+		{
+			name:  "database removed by opaque whiteout",
+			upper: ocitest.Layer(t, ocitest.File{Name: "lib/apk/.wh..wh..opq"}),
+		},
+		{
+			name: "oversized database replaces the lower one",
+			upper: ocitest.Layer(t, ocitest.File{
+				Name:    "lib/apk/db/installed",
+				Content: bytes.Repeat(apkDB("musl=1.2.4=MIT"), 64),
+			}),
+			warning: "/lib/apk/db/installed is skipped",
+		},
+		{
+			name: "database replaced by symlink",
+			upper: ocitest.Layer(t, ocitest.File{
+				Name:     "lib/apk/db/installed",
+				Typeflag: tar.TypeSymlink,
+			}),
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			img := ocitest.Image(t, nil, base, c.upper)
+
+			inv := NewInventory(512)
+			if err := inv.Collect(context.Background(), img); err != nil {
+				t.Fatalf("collect error: %v", err)
+			}
+
+			var got []string
+			for _, p := range inv.packages() {
+				got = append(got, p.name+"@"+p.version)
+			}
+			if strings.Join(got, ",") != strings.Join(c.packages, ",") {
+				t.Errorf("expect packages %v, got %v", c.packages, got)
+			}
+
+			warnings := strings.Join(inv.Warnings(), "\n")
+			if c.warning != "" && !strings.Contains(warnings, c.warning) {
+				t.Errorf("expect warning %q, got %q", c.warning, warnings)
+			}
+		})
+	}
+}
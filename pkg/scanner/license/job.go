@@ -0,0 +1,95 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// JobName is the name of the license scan job.
+	JobName = "LICENSE_SCAN"
+
+	// defaultMaxFileSize is the default size limit of the package databases and manifests.
+	defaultMaxFileSize = 128 << 20
+)
+
+// AddToKnownList adds the license scan job to the known list.
+func AddToKnownList(l *job.KnownList) error {
+	return licenseEngine.AddToKnownList(l)
+}
+
+// scanLicenses inventories the packages of the image and classifies their licenses with the policy.
+func scanLicenses(ctx context.Context, t *native.Target) ([]byte, error) {
+	errorf := errs.WithPrefix("")
+
+	img, err := t.Image(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := Scan(ctx, img, LoadPolicy())
+	if err != nil {
+		return nil, errorf.Wrap("collect packages error", err)
+	}
+	report.Artifact = t.Artifact
+
+	zlog.FromContext(ctx).Infow("licenses are classified", "packages", report.Summary.Total, "denied", report.Summary.Denied)
+
+	result, err := json.Marshal(report)
+	if err != nil {
+		return nil, errorf.Wrap("marshal license report error", err)
+	}
+
+	return result, nil
+}
+
+// Scan inventories the packages of the image and classifies their licenses with the policy.
+func Scan(ctx context.Context, img *oci.Image, policy *Policy) (*spec.HarborLicenseReport, error) {
+	maxFileSize := viper.GetInt64("scanner.backends.license.maxFileSize")
+	if maxFileSize <= 0 {
+		maxFileSize = defaultMaxFileSize
+	}
+
+	inv := NewInventory(maxFileSize)
+	if err := inv.Collect(ctx, img); err != nil {
+		return nil, err
+	}
+
+	pkgs, summary := classify(inv.packages(), policy)
+
+	return &spec.HarborLicenseReport{
+		GeneratedAt: time.Now().UTC(),
+		Scanner: &spec.Scanner{
+			Name:    Name,
+			Vendor:  Vendor,
+			Version: Version,
+		},
+		Summary:  summary,
+		Packages: pkgs,
+		Warnings: inv.Warnings(),
+	}, nil
+}
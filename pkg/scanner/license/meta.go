@@ -0,0 +1,35 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+const (
+	// Name of license provider.
+	Name = "License"
+	// Vendor of license provider.
+	Vendor = "Harbor"
+	// Version of license provider.
+	Version = "0.1.0"
+	// ReportMimeType is mimetype of license report.
+	ReportMimeType = "application/vnd.security.license.report; version=1.0"
+	// CapabilityType is the capability type of license compliance.
+	CapabilityType = "license"
+)
+
+// ExtraMeta is the license extra properties.
+var ExtraMeta = map[string]string{
+	"maintainer": "steven-zou",
+	"engine":     "native",
+	"license":    "Apache-2.0",
+}
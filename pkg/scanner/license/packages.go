@@ -0,0 +1,319 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+)
+
+const (
+	// TypeDeb is the type of the debian packages.
+	TypeDeb = "deb"
+	// TypeAPK is the type of the alpine packages.
+	TypeAPK = "apk"
+	// TypeRPM is the type of the rpm packages.
+	TypeRPM = "rpm"
+	// TypeNPM is the type of the node packages.
+	TypeNPM = "npm"
+	// TypePython is the type of the python packages.
+	TypePython = "python"
+)
+
+// pkg is a package found in the image.
+type pkg struct {
+	name     string
+	version  string
+	typ      string
+	path     string
+	layer    string
+	declared string
+	expr     Expression
+}
+
+// stanzas splits the content into the RFC822 like stanzas separated by blank lines.
+// The continuation lines starting with the spaces are folded into the previous field.
+func stanzas(content []byte) []map[string]string {
+	var (
+		res   []map[string]string
+		cur   map[string]string
+		field string
+	)
+
+	sc := bufio.NewScanner(bytes.NewReader(content))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.TrimSpace(line) == "" {
+			if cur != nil {
+				res = append(res, cur)
+				cur = nil
+			}
+			continue
+		}
+
+		if cur == nil {
+			cur = make(map[string]string)
+		}
+
+		if line[0] == ' ' || line[0] == '\t' {
+			if field != "" {
+				cur[field] += "\n" + strings.TrimSpace(line)
+			}
+			continue
+		}
+
+		if i := strings.Index(line, ":"); i > 0 {
+			field = line[:i]
+			value := strings.TrimSpace(line[i+1:])
+			if prev, ok := cur[field]; ok {
+				// Keep the repeated fields, e.g. the classifiers of python packages.
+				value = prev + "\n" + value
+			}
+			cur[field] = value
+		}
+	}
+
+	if cur != nil {
+		res = append(res, cur)
+	}
+
+	return res
+}
+
+// parseDpkgStatus parses the packages in the dpkg status file.
+func parseDpkgStatus(content []byte) []*pkg {
+	var pkgs []*pkg
+	for _, s := range stanzas(content) {
+		name := s["Package"]
+		if name == "" || (s["Status"] != "" && !strings.HasSuffix(s["Status"], "installed")) {
+			continue
+		}
+
+		pkgs = append(pkgs, &pkg{
+			name:    name,
+			version: s["Version"],
+			typ:     TypeDeb,
+		})
+	}
+
+	return pkgs
+}
+
+// commonLicensePattern matches the references to the licenses installed in the debian systems.
+var commonLicensePattern = regexp.MustCompile(`/usr/share/common-licenses/([A-Za-z0-9.+\-]+)`)
+
+// parseDebianCopyright extracts the licenses from the debian copyright file.
+// The machine-readable format is parsed with the License fields, otherwise the
+// references to /usr/share/common-licenses are used.
+func parseDebianCopyright(content []byte) []string {
+	seen := make(map[string]bool)
+	var licenses []string
+	add := func(l string) {
+		l = strings.TrimSpace(l)
+		if l != "" && !seen[l] {
+			seen[l] = true
+			licenses = append(licenses, l)
+		}
+	}
+
+	sc := bufio.NewScanner(bytes.NewReader(content))
+	sc.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for sc.Scan() {
+		line := sc.Text()
+		if strings.HasPrefix(line, "License:") {
+			add(strings.TrimPrefix(line, "License:"))
+		}
+	}
+
+	if len(licenses) == 0 {
+		for _, m := range commonLicensePattern.FindAllSubmatch(content, -1) {
+			add(strings.TrimSuffix(string(m[1]), "."))
+		}
+	}
+
+	return licenses
+}
+
+// parseAPKInstalled parses the packages in the apk installed database.
+func parseAPKInstalled(content []byte) []*pkg {
+	var pkgs []*pkg
+	for _, s := range stanzas(content) {
+		if s["P"] == "" {
+			continue
+		}
+
+		pkgs = append(pkgs, &pkg{
+			name:     s["P"],
+			version:  s["V"],
+			typ:      TypeAPK,
+			declared: s["L"],
+			expr:     ParseExpression(s["L"]),
+		})
+	}
+
+	return pkgs
+}
+
+// parseNPMPackage parses the package.json of the node module.
+func parseNPMPackage(content []byte) (*pkg, error) {
+	var manifest struct {
+		Name     string          `json:"name"`
+		Version  string          `json:"version"`
+		License  json.RawMessage `json:"license"`
+		Licenses []interface{}   `json:"licenses"`
+	}
+	if err := json.Unmarshal(content, &manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.Name == "" {
+		return nil, fmt.Errorf("missing package name")
+	}
+
+	var declared []string
+	if len(manifest.License) > 0 {
+		var s string
+		var obj struct {
+			Type string `json:"type"`
+		}
+		if json.Unmarshal(manifest.License, &s) == nil {
+			declared = append(declared, s)
+		} else if json.Unmarshal(manifest.License, &obj) == nil && obj.Type != "" {
+			declared = append(declared, obj.Type)
+		}
+	}
+
+	// The deprecated licenses field is a list of alternatives.
+	for _, l := range manifest.Licenses {
+		switch v := l.(type) {
+		case string:
+			declared = append(declared, v)
+		case map[string]interface{}:
+			if t, ok := v["type"].(string); ok {
+				declared = append(declared, t)
+			}
+		}
+	}
+
+	p := &pkg{
+		name:     manifest.Name,
+		version:  manifest.Version,
+		typ:      TypeNPM,
+		declared: strings.Join(declared, " OR "),
+	}
+	for _, d := range declared {
+		p.expr = append(p.expr, ParseExpression(d)...)
+	}
+
+	return p, nil
+}
+
+// parsePythonMetadata parses the METADATA or PKG-INFO of the python distribution.
+// License-Expression is preferred, then the License field and the license classifiers.
+func parsePythonMetadata(content []byte) (*pkg, error) {
+	ss := stanzas(content)
+	if len(ss) == 0 || ss[0]["Name"] == "" {
+		return nil, fmt.Errorf("missing package name")
+	}
+	s := ss[0]
+
+	declared := s["License-Expression"]
+	if declared == "" {
+		if l := strings.TrimSpace(s["License"]); l != "" && !strings.EqualFold(l, "UNKNOWN") && !strings.Contains(l, "\n") && len(l) <= 64 {
+			declared = l
+		}
+	}
+
+	if declared == "" {
+		var classifiers []string
+		for _, c := range strings.Split(s["Classifier"], "\n") {
+			if !strings.HasPrefix(c, "License ::") {
+				continue
+			}
+
+			segments := strings.Split(c, "::")
+			l := strings.TrimSpace(segments[len(segments)-1])
+			// e.g. License :: OSI Approved :: GNU General Public License v2 (GPLv2)
+			if i := strings.LastIndex(l, "("); i >= 0 && strings.HasSuffix(l, ")") {
+				l = l[i+1 : len(l)-1]
+			}
+			classifiers = append(classifiers, l)
+		}
+		declared = strings.Join(classifiers, " OR ")
+	}
+
+	return &pkg{
+		name:     s["Name"],
+		version:  s["Version"],
+		typ:      TypePython,
+		declared: declared,
+		expr:     ParseExpression(declared),
+	}, nil
+}
+
+// maxAlternatives limits the alternatives when combining the expressions.
+const maxAlternatives = 64
+
+// and combines the expressions with the AND operator.
+// The alternatives exceeding the limit are dropped.
+func and(a, b Expression) Expression {
+	if len(a) == 0 {
+		return b
+	}
+	if len(b) == 0 {
+		return a
+	}
+
+	var res Expression
+	for _, x := range a {
+		for _, y := range b {
+			if len(res) >= maxAlternatives {
+				return res
+			}
+			res = append(res, append(append([]string{}, x...), y...))
+		}
+	}
+
+	return res
+}
+
+// isNodeModule checks whether the file is the package.json of a node module,
+// e.g: /app/node_modules/lodash/package.json or /app/node_modules/@types/node/package.json.
+func isNodeModule(file string) bool {
+	if path.Base(file) != "package.json" {
+		return false
+	}
+
+	dir := path.Dir(file)
+	parent := path.Dir(dir)
+	if strings.HasPrefix(path.Base(parent), "@") {
+		parent = path.Dir(parent)
+	}
+
+	return path.Base(parent) == "node_modules"
+}
+
+// isPythonMetadata checks whether the file is the metadata of a python distribution.
+func isPythonMetadata(file string) bool {
+	dir := path.Base(path.Dir(file))
+	return (strings.HasSuffix(dir, ".dist-info") && path.Base(file) == "METADATA") ||
+		(strings.HasSuffix(dir, ".egg-info") && path.Base(file) == "PKG-INFO")
+}
@@ -0,0 +1,103 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"path"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+// Classification of the licenses against the policy.
+type Classification string
+
+const (
+	// Allowed by the policy.
+	Allowed Classification = "allowed"
+	// Denied by the policy.
+	Denied Classification = "denied"
+	// Unknown means the license is not covered by the policy or can not be resolved.
+	Unknown Classification = "unknown"
+)
+
+// rank of the classifications when choosing among the alternatives.
+var rank = map[Classification]int{
+	Denied:  0,
+	Unknown: 1,
+	Allowed: 2,
+}
+
+// Policy classifies the licenses with the allow and deny lists.
+// The entries are SPDX identifiers or glob patterns, e.g: GPL-*. Deny takes precedence.
+type Policy struct {
+	Allow []string
+	Deny  []string
+}
+
+// LoadPolicy loads the policy from `scanner.backends.license.policy`.
+func LoadPolicy() *Policy {
+	return &Policy{
+		Allow: viper.GetStringSlice("scanner.backends.license.policy.allow"),
+		Deny:  viper.GetStringSlice("scanner.backends.license.policy.deny"),
+	}
+}
+
+// Classify the license identifier.
+func (p *Policy) Classify(id string) Classification {
+	if matchAny(p.Deny, id) {
+		return Denied
+	}
+
+	if matchAny(p.Allow, id) {
+		return Allowed
+	}
+
+	return Unknown
+}
+
+// ClassifyExpression classifies the license expression. An alternative is denied if any of
+// its licenses is denied and the best alternative is chosen. Empty expression is unknown.
+func (p *Policy) ClassifyExpression(expr Expression) Classification {
+	if len(expr) == 0 {
+		return Unknown
+	}
+
+	best := Denied
+	for _, alt := range expr {
+		c := Allowed
+		for _, id := range alt {
+			if lc := p.Classify(id); rank[lc] < rank[c] {
+				c = lc
+			}
+		}
+
+		if rank[c] > rank[best] {
+			best = c
+		}
+	}
+
+	return best
+}
+
+func matchAny(patterns []string, id string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), strings.ToLower(id)); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,74 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import "testing"
+
+func TestClassify(t *testing.T) {
+	p := &Policy{
+		Allow: []string{"MIT", "Apache-2.0", "BSD-*", "GPL-*"},
+		Deny:  []string{"AGPL-*", "GPL-3.0-*"},
+	}
+
+	cases := []struct {
+		id   string
+		want Classification
+	}{
+		{"MIT", Allowed},
+		{"mit", Allowed},
+		{"BSD-3-Clause", Allowed},
+		{"GPL-2.0-only", Allowed},
+		{"GPL-3.0-or-later", Denied},
+		{"AGPL-3.0-only", Denied},
+		{"ISC", Unknown},
+		{"LicenseRef-Proprietary", Unknown},
+	}
+
+	for _, c := range cases {
+		if got := p.Classify(c.id); got != c.want {
+			t.Errorf("Classify(%q): expect %s, got %s", c.id, c.want, got)
+		}
+	}
+}
+
+func TestClassifyExpression(t *testing.T) {
+	p := &Policy{
+		Allow: []string{"MIT", "Apache-2.0"},
+		Deny:  []string{"GPL-3.0-*"},
+	}
+
+	cases := []struct {
+		name string
+		expr Expression
+		want Classification
+	}{
+		{"empty", nil, Unknown},
+		{"allowed", Expression{{"MIT"}}, Allowed},
+		{"denied", Expression{{"GPL-3.0-only"}}, Denied},
+		{"unknown", Expression{{"ISC"}}, Unknown},
+		{"conjunction with denied", Expression{{"MIT", "GPL-3.0-only"}}, Denied},
+		{"conjunction with unknown", Expression{{"MIT", "ISC"}}, Unknown},
+		{"conjunction of allowed", Expression{{"MIT", "Apache-2.0"}}, Allowed},
+		{"allowed alternative", Expression{{"GPL-3.0-only"}, {"MIT"}}, Allowed},
+		{"unknown alternative", Expression{{"GPL-3.0-only"}, {"ISC"}}, Unknown},
+		{"denied alternatives", Expression{{"GPL-3.0-only"}, {"MIT", "GPL-3.0-or-later"}}, Denied},
+	}
+
+	for _, c := range cases {
+		if got := p.ClassifyExpression(c.expr); got != c.want {
+			t.Errorf("%s: expect %s, got %s", c.name, c.want, got)
+		}
+	}
+}
@@ -0,0 +1,217 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+const (
+	// bdbHashMagic is the magic number of the Berkeley DB hash database.
+	bdbHashMagic = 0x061561
+	// bdbPageHeaderSize is the size of the Berkeley DB page header.
+	bdbPageHeaderSize = 26
+
+	bdbPageHash         = 13
+	bdbPageHashUnsorted = 2
+	bdbPageOverflow     = 7
+
+	bdbItemKeyData = 1
+	bdbItemOffPage = 3
+
+	rpmTagName    = 1000
+	rpmTagVersion = 1001
+	rpmTagRelease = 1002
+	rpmTagEpoch   = 1003
+	rpmTagLicense = 1014
+
+	rpmTypeInt32      = 4
+	rpmTypeString     = 6
+	rpmTypeI18NString = 9
+)
+
+// rpmPackage is the package info extracted from the rpm header.
+type rpmPackage struct {
+	name    string
+	version string
+	license string
+}
+
+// parseRPMBerkeleyDB extracts the packages from the rpm Packages database in the Berkeley DB hash format.
+// The database is read as it is without the Berkeley DB library: the header blobs are collected from
+// the hash pages and the overflow page chains.
+func parseRPMBerkeleyDB(db []byte) ([]rpmPackage, error) {
+	if len(db) < 512 {
+		return nil, errors.New("invalid rpm database: too small")
+	}
+
+	// The byte order of the database is the one of the host creating it.
+	var order binary.ByteOrder = binary.LittleEndian
+	if order.Uint32(db[12:]) != bdbHashMagic {
+		order = binary.BigEndian
+		if order.Uint32(db[12:]) != bdbHashMagic {
+			return nil, errors.New("invalid rpm database: not a Berkeley DB hash database")
+		}
+	}
+
+	pageSize := int(order.Uint32(db[20:]))
+	if pageSize < 512 || pageSize > 64*1024 {
+		return nil, fmt.Errorf("invalid rpm database: unexpected page size %d", pageSize)
+	}
+	pages := len(db) / pageSize
+
+	page := func(n uint32) []byte {
+		if int(n) >= pages {
+			return nil
+		}
+		return db[int(n)*pageSize : int(n+1)*pageSize]
+	}
+
+	// overflow reads the data of the overflow page chain.
+	overflow := func(n uint32, size uint32) ([]byte, error) {
+		data := make([]byte, 0, size)
+		for visited := 0; n != 0 && visited < pages; visited++ {
+			p := page(n)
+			if p == nil || p[25] != bdbPageOverflow {
+				return nil, fmt.Errorf("invalid overflow page %d", n)
+			}
+
+			l := int(order.Uint16(p[22:]))
+			if bdbPageHeaderSize+l > len(p) {
+				return nil, fmt.Errorf("invalid overflow page %d: length %d", n, l)
+			}
+			data = append(data, p[bdbPageHeaderSize:bdbPageHeaderSize+l]...)
+			n = order.Uint32(p[16:])
+		}
+
+		if uint32(len(data)) != size {
+			return nil, fmt.Errorf("overflow data size mismatch: expected=%d actual=%d", size, len(data))
+		}
+
+		return data, nil
+	}
+
+	var pkgs []rpmPackage
+	for n := 1; n < pages; n++ {
+		p := page(uint32(n))
+		if p[25] != bdbPageHash && p[25] != bdbPageHashUnsorted {
+			continue
+		}
+
+		entries := int(order.Uint16(p[20:]))
+		if bdbPageHeaderSize+entries*2 > len(p) {
+			continue
+		}
+
+		// The items are key/data pairs, only the data items are headers.
+		for i := 1; i < entries; i += 2 {
+			off := int(order.Uint16(p[bdbPageHeaderSize+i*2:]))
+			end := int(order.Uint16(p[bdbPageHeaderSize+(i-1)*2:]))
+			if off >= end || end > len(p) {
+				continue
+			}
+
+			var blob []byte
+			switch p[off] {
+			case bdbItemKeyData:
+				blob = p[off+1 : end]
+			case bdbItemOffPage:
+				if off+12 > len(p) {
+					continue
+				}
+				data, err := overflow(order.Uint32(p[off+4:]), order.Uint32(p[off+8:]))
+				if err != nil {
+					return nil, err
+				}
+				blob = data
+			default:
+				continue
+			}
+
+			// Skip the records which are not headers, e.g. the instance counter.
+			if pkg, err := parseRPMHeader(blob); err == nil && pkg.name != "" {
+				pkgs = append(pkgs, *pkg)
+			}
+		}
+	}
+
+	return pkgs, nil
+}
+
+// parseRPMHeader parses the rpm header blob stored in the database.
+// The blob is in the big endian order and without the header magic.
+func parseRPMHeader(blob []byte) (*rpmPackage, error) {
+	if len(blob) < 8 {
+		return nil, errors.New("invalid rpm header: too small")
+	}
+
+	il := int(binary.BigEndian.Uint32(blob))
+	dl := int(binary.BigEndian.Uint32(blob[4:]))
+	if il <= 0 || il > 0xffff || dl < 0 || 8+il*16+dl > len(blob) {
+		return nil, errors.New("invalid rpm header: bad index or data length")
+	}
+	store := blob[8+il*16 : 8+il*16+dl]
+
+	str := func(off int) string {
+		if off < 0 || off >= len(store) {
+			return ""
+		}
+		end := off
+		for end < len(store) && store[end] != 0 {
+			end++
+		}
+		return string(store[off:end])
+	}
+
+	pkg := &rpmPackage{}
+	var release, epoch string
+	for i := 0; i < il; i++ {
+		entry := blob[8+i*16:]
+		tag := binary.BigEndian.Uint32(entry)
+		typ := binary.BigEndian.Uint32(entry[4:])
+		off := int(int32(binary.BigEndian.Uint32(entry[8:])))
+
+		if typ == rpmTypeInt32 && tag == rpmTagEpoch && off >= 0 && off+4 <= len(store) {
+			epoch = fmt.Sprintf("%d", binary.BigEndian.Uint32(store[off:]))
+			continue
+		}
+
+		if typ != rpmTypeString && typ != rpmTypeI18NString {
+			continue
+		}
+
+		switch tag {
+		case rpmTagName:
+			pkg.name = str(off)
+		case rpmTagVersion:
+			pkg.version = str(off)
+		case rpmTagRelease:
+			release = str(off)
+		case rpmTagLicense:
+			pkg.license = str(off)
+		}
+	}
+
+	if release != "" {
+		pkg.version = fmt.Sprintf("%s-%s", pkg.version, release)
+	}
+	if epoch != "" && epoch != "0" {
+		pkg.version = fmt.Sprintf("%s:%s", epoch, pkg.version)
+	}
+
+	return pkg, nil
+}
@@ -0,0 +1,212 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"regexp"
+	"strings"
+)
+
+// spdxIDs are the well-known SPDX license identifiers, keyed by the lower case.
+var spdxIDs = map[string]string{}
+
+func init() {
+	for _, id := range []string{
+		"0BSD", "AFL-2.1", "AFL-3.0", "AGPL-3.0-only", "AGPL-3.0-or-later", "Apache-1.1", "Apache-2.0",
+		"Artistic-1.0", "Artistic-1.0-Perl", "Artistic-2.0", "BSD-1-Clause", "BSD-2-Clause", "BSD-3-Clause",
+		"BSD-4-Clause", "BSL-1.0", "CC-BY-3.0", "CC-BY-4.0", "CC-BY-SA-3.0", "CC-BY-SA-4.0", "CC0-1.0",
+		"CDDL-1.0", "CDDL-1.1", "EPL-1.0", "EPL-2.0", "EUPL-1.1", "EUPL-1.2", "GFDL-1.2-only", "GFDL-1.2-or-later",
+		"GFDL-1.3-only", "GFDL-1.3-or-later", "GPL-1.0-only", "GPL-1.0-or-later", "GPL-2.0-only",
+		"GPL-2.0-or-later", "GPL-3.0-only", "GPL-3.0-or-later", "ISC", "LGPL-2.0-only", "LGPL-2.0-or-later",
+		"LGPL-2.1-only", "LGPL-2.1-or-later", "LGPL-3.0-only", "LGPL-3.0-or-later", "MIT", "MIT-0", "MPL-1.1",
+		"MPL-2.0", "MS-PL", "NCSA", "OFL-1.1", "OpenSSL", "PHP-3.01", "PostgreSQL", "PSF-2.0", "Python-2.0",
+		"Ruby", "SSPL-1.0", "Unicode-DFS-2016", "Unlicense", "Vim", "W3C", "WTFPL", "X11", "Zlib", "ZPL-2.1",
+	} {
+		spdxIDs[strings.ToLower(id)] = id
+	}
+}
+
+// aliases map the license names commonly used by the package managers to the SPDX identifiers.
+var aliases = map[string]string{
+	"mit license":                        "MIT",
+	"expat":                              "MIT",
+	"the mit license":                    "MIT",
+	"apache":                             "Apache-2.0",
+	"apache 2":                           "Apache-2.0",
+	"apache 2.0":                         "Apache-2.0",
+	"apache-2":                           "Apache-2.0",
+	"apache2":                            "Apache-2.0",
+	"apache license 2.0":                 "Apache-2.0",
+	"apache license, version 2.0":        "Apache-2.0",
+	"apache software license":            "Apache-2.0",
+	"asl 2.0":                            "Apache-2.0",
+	"bsd":                                "BSD-3-Clause",
+	"bsd license":                        "BSD-3-Clause",
+	"bsd-2":                              "BSD-2-Clause",
+	"bsd-3":                              "BSD-3-Clause",
+	"new bsd":                            "BSD-3-Clause",
+	"simplified bsd":                     "BSD-2-Clause",
+	"boost":                              "BSL-1.0",
+	"isc license":                        "ISC",
+	"mpl-2":                              "MPL-2.0",
+	"mpl 2.0":                            "MPL-2.0",
+	"mozilla public license 2.0":         "MPL-2.0",
+	"psf":                                "PSF-2.0",
+	"python software foundation license": "PSF-2.0",
+	"zlib license":                       "Zlib",
+	"public domain":                      "LicenseRef-public-domain",
+	"public-domain":                      "LicenseRef-public-domain",
+	"gpl":                                "GPL-2.0-or-later",
+	"gpl+":                               "GPL-1.0-or-later",
+	"lgpl":                               "LGPL-2.1-or-later",
+	"artistic":                           "Artistic-1.0-Perl",
+}
+
+// gnuPattern matches the GNU license names, e.g: GPLv2+, GPL-2+, LGPL-2.1, GPL-3.0-only.
+var gnuPattern = regexp.MustCompile(`(?i)^(a?gpl|lgpl|gfdl)[ -]?v?(\d)(?:\.(\d))?(\+|-or-later|-only)?$`)
+
+// Normalize resolves the declared license name to the SPDX identifier.
+// The unresolved name is converted to a LicenseRef identifier and false is returned.
+func Normalize(name string) (string, bool) {
+	name = strings.TrimSpace(name)
+	lower := strings.ToLower(name)
+
+	if id, ok := spdxIDs[lower]; ok {
+		return id, true
+	}
+
+	if id, ok := aliases[lower]; ok {
+		return id, !strings.HasPrefix(id, "LicenseRef-")
+	}
+
+	if m := gnuPattern.FindStringSubmatch(name); m != nil {
+		family := strings.ToUpper(m[1])
+		minor := m[3]
+		if minor == "" {
+			minor = "0"
+		}
+
+		suffix := "-only"
+		if m[4] == "+" || strings.EqualFold(m[4], "-or-later") {
+			suffix = "-or-later"
+		}
+
+		id := family + "-" + m[2] + "." + minor + suffix
+		if known, ok := spdxIDs[strings.ToLower(id)]; ok {
+			return known, true
+		}
+	}
+
+	return "LicenseRef-" + sanitize(name), false
+}
+
+// Expression is the parsed license expression in the disjunctive normal form,
+// e.g: "MIT OR (Apache-2.0 AND BSD-3-Clause)" -> [[MIT] [Apache-2.0 BSD-3-Clause]].
+type Expression [][]string
+
+// ParseExpression parses the declared license expression and normalizes the licenses.
+// Parentheses are flattened, which is accurate enough for the package metadata.
+func ParseExpression(declared string) Expression {
+	if id, ok := Normalize(declared); ok {
+		return Expression{{id}}
+	}
+
+	declared = strings.NewReplacer("(", " ", ")", " ").Replace(declared)
+
+	var expr Expression
+	for _, alt := range splitWords(declared, " or ", "/", " | ") {
+		if id, ok := Normalize(alt); ok {
+			expr = append(expr, []string{id})
+			continue
+		}
+
+		var all []string
+		for _, l := range splitWords(alt, " and ", ",", ";", " & ") {
+			// Resolve the license without the exception, e.g: GPL-2.0-or-later WITH Classpath-exception-2.0.
+			l = strings.TrimSpace(splitWords(l, " with ")[0])
+			if l == "" {
+				continue
+			}
+
+			id, _ := Normalize(l)
+			all = append(all, id)
+		}
+
+		if len(all) > 0 {
+			expr = append(expr, all)
+		}
+	}
+
+	return expr
+}
+
+// IDs returns the distinct license identifiers in the expression.
+func (e Expression) IDs() []string {
+	seen := make(map[string]bool)
+	var ids []string
+	for _, alt := range e {
+		for _, id := range alt {
+			if !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+
+	return ids
+}
+
+// splitWords splits the string with the case-insensitive separators.
+func splitWords(s string, seps ...string) []string {
+	parts := []string{s}
+	for _, sep := range seps {
+		var next []string
+		for _, p := range parts {
+			lower := strings.ToLower(p)
+			for {
+				i := strings.Index(lower, sep)
+				if i < 0 {
+					break
+				}
+				next = append(next, p[:i])
+				p, lower = p[i+len(sep):], lower[i+len(sep):]
+			}
+			next = append(next, p)
+		}
+		parts = next
+	}
+
+	return parts
+}
+
+var invalidRef = regexp.MustCompile(`[^A-Za-z0-9.\-]+`)
+
+// maxRefLen limits the length of the LicenseRef suffix.
+const maxRefLen = 64
+
+// sanitize converts the name to a valid LicenseRef suffix.
+func sanitize(name string) string {
+	s := invalidRef.ReplaceAllString(name, "-")
+	if len(s) > maxRefLen {
+		s = s[:maxRefLen]
+	}
+
+	s = strings.Trim(s, "-")
+	if s == "" {
+		return "unknown"
+	}
+
+	return s
+}
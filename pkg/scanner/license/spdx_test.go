@@ -0,0 +1,80 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package license
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	cases := []struct {
+		name  string
+		id    string
+		known bool
+	}{
+		{"MIT", "MIT", true},
+		{" apache-2.0 ", "Apache-2.0", true},
+		{"Expat", "MIT", true},
+		{"Apache License, Version 2.0", "Apache-2.0", true},
+		{"GPLv2+", "GPL-2.0-or-later", true},
+		{"GPL-3", "GPL-3.0-only", true},
+		{"LGPL-2.1", "LGPL-2.1-only", true},
+		{"lgpl v3+", "LGPL-3.0-or-later", true},
+		{"AGPL-3.0-or-later", "AGPL-3.0-or-later", true},
+		{"GPLv9", "LicenseRef-GPLv9", false},
+		{"public domain", "LicenseRef-public-domain", false},
+		{"Custom (internal) license", "LicenseRef-Custom-internal-license", false},
+		{"???", "LicenseRef-unknown", false},
+	}
+
+	for _, c := range cases {
+		id, known := Normalize(c.name)
+		if id != c.id || known != c.known {
+			t.Errorf("Normalize(%q): expect %s %v, got %s %v", c.name, c.id, c.known, id, known)
+		}
+	}
+}
+
+func TestParseExpression(t *testing.T) {
+	cases := []struct {
+		declared string
+		want     Expression
+	}{
+		{"MIT", Expression{{"MIT"}}},
+		{"Apache License 2.0", Expression{{"Apache-2.0"}}},
+		{"MIT OR Apache-2.0", Expression{{"MIT"}, {"Apache-2.0"}}},
+		{"MIT or (Apache-2.0 AND BSD-3-Clause)", Expression{{"MIT"}, {"Apache-2.0", "BSD-3-Clause"}}},
+		{"GPLv2+ and LGPLv2+", Expression{{"GPL-2.0-or-later", "LGPL-2.0-or-later"}}},
+		{"GPL-2.0-or-later WITH Classpath-exception-2.0", Expression{{"GPL-2.0-or-later"}}},
+		{"BSD / MIT", Expression{{"BSD-3-Clause"}, {"MIT"}}},
+		{"MIT, ISC; Zlib", Expression{{"MIT", "ISC", "Zlib"}}},
+		{"Proprietary", Expression{{"LicenseRef-Proprietary"}}},
+		{"", nil},
+	}
+
+	for _, c := range cases {
+		if got := ParseExpression(c.declared); !reflect.DeepEqual(got, c.want) {
+			t.Errorf("ParseExpression(%q): expect %v, got %v", c.declared, c.want, got)
+		}
+	}
+}
+
+func TestExpressionIDs(t *testing.T) {
+	expr := Expression{{"MIT"}, {"Apache-2.0", "MIT"}, {"BSD-3-Clause"}}
+	if got, want := expr.IDs(), []string{"MIT", "Apache-2.0", "BSD-3-Clause"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("expect %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package malware
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strings"
+	"time"
+)
+
+const (
+	// chunkSize is the size of the chunks streamed to clamd.
+	chunkSize = 64 * 1024
+)
+
+// Clamd is the client of the clamd daemon.
+// The commands are sent with the z prefix so the responses are null terminated.
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// NewClamd creates a clamd client with the address,
+// e.g: tcp://127.0.0.1:3310, unix:///var/run/clamav/clamd.ctl or 127.0.0.1:3310.
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	c := &Clamd{
+		network: "tcp",
+		address: address,
+		timeout: timeout,
+	}
+
+	switch {
+	case strings.HasPrefix(address, "unix://"):
+		c.network, c.address = "unix", strings.TrimPrefix(address, "unix://")
+	case strings.HasPrefix(address, "tcp://"):
+		c.address = strings.TrimPrefix(address, "tcp://")
+	}
+
+	if c.address == "" {
+		return nil, fmt.Errorf("empty clamd address")
+	}
+
+	return c, nil
+}
+
+// Ping checks whether clamd is alive.
+func (c *Clamd) Ping(ctx context.Context) error {
+	res, err := c.command(ctx, "PING", nil)
+	if err != nil {
+		return err
+	}
+
+	if res != "PONG" {
+		return fmt.Errorf("unexpected clamd response: %s", res)
+	}
+
+	return nil
+}
+
+// Version returns the version of clamd and its signature database.
+func (c *Clamd) Version(ctx context.Context) (string, error) {
+	return c.command(ctx, "VERSION", nil)
+}
+
+// Scan streams the content to clamd with the INSTREAM command.
+// The signature name is returned if malware is found, otherwise empty string is returned.
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (string, error) {
+	res, err := c.command(ctx, "INSTREAM", r)
+	if err != nil {
+		return "", err
+	}
+
+	// e.g: stream: OK, stream: Eicar-Signature FOUND or INSTREAM size limit exceeded. ERROR
+	res = strings.TrimPrefix(res, "stream: ")
+	switch {
+	case res == "OK":
+		return "", nil
+	case strings.HasSuffix(res, " FOUND"):
+		return strings.TrimSuffix(res, " FOUND"), nil
+	default:
+		return "", fmt.Errorf("clamd scan error: %s", strings.TrimSuffix(res, " ERROR"))
+	}
+}
+
+// command sends the command with the optional stream and reads the response.
+func (c *Clamd) command(ctx context.Context, cmd string, stream io.Reader) (string, error) {
+	d := &net.Dialer{Timeout: c.timeout}
+	conn, err := d.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		return "", fmt.Errorf("connect clamd error: %w", err)
+	}
+	defer conn.Close()
+
+	// Use the earlier one of the context deadline and the timeout.
+	deadline, ok := ctx.Deadline()
+	if c.timeout > 0 && (!ok || time.Now().Add(c.timeout).Before(deadline)) {
+		deadline, ok = time.Now().Add(c.timeout), true
+	}
+	if ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if _, err := fmt.Fprintf(conn, "z%s\x00", cmd); err != nil {
+		return "", fmt.Errorf("send clamd command error: %w", err)
+	}
+
+	var werr error
+	if stream != nil {
+		var rerr error
+		if rerr, werr = writeChunks(conn, stream); rerr != nil {
+			return "", rerr
+		}
+	}
+
+	// Clamd may reply and close the connection before the stream is completed,
+	// e.g: the size limit is exceeded, so the response is read anyway.
+	res, err := bufio.NewReader(conn).ReadString(0)
+	if err != nil && !(err == io.EOF && len(res) > 0) {
+		if werr != nil {
+			return "", werr
+		}
+		return "", fmt.Errorf("read clamd response error: %w", err)
+	}
+
+	return strings.TrimSpace(strings.TrimRight(res, "\x00")), nil
+}
+
+// writeChunks writes the stream as the length prefixed chunks terminated by a zero length chunk.
+// The errors of reading the stream and writing to clamd are returned separately.
+func writeChunks(w io.Writer, r io.Reader) (error, error) {
+	buf := make([]byte, 4+chunkSize)
+	for {
+		n, err := io.ReadFull(r, buf[4:])
+		if n > 0 {
+			binary.BigEndian.PutUint32(buf, uint32(n))
+			if _, werr := w.Write(buf[:4+n]); werr != nil {
+				return nil, fmt.Errorf("stream to clamd error: %w", werr)
+			}
+		}
+
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("read stream error: %w", err), nil
+		}
+	}
+
+	if _, err := w.Write([]byte{0, 0, 0, 0}); err != nil {
+		return nil, fmt.Errorf("stream to clamd error: %w", err)
+	}
+
+	return nil, nil
+}
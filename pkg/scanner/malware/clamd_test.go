@@ -0,0 +1,223 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package malware
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net"
+	"strings"
+	"testing"
+	"time"
+)
+
+// eicar is the EICAR anti-malware test string, split so the source file itself is not flagged.
+var eicar = []byte(`X5O!P%@AP[4\PZX54(P^)7CC)7}$` + `EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`)
+
+// fakeClamd speaks the z-prefixed clamd commands. It flags the EICAR test string, fails the
+// streams containing "clamd-error" and rejects the streams exceeding maxStream bytes.
+type fakeClamd struct {
+	l         net.Listener
+	maxStream int
+}
+
+func newFakeClamd(t *testing.T, maxStream int) *fakeClamd {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	f := &fakeClamd{l: l, maxStream: maxStream}
+	go f.serve()
+	t.Cleanup(func() { _ = l.Close() })
+
+	return f
+}
+
+func (f *fakeClamd) address() string {
+	return "tcp://" + f.l.Addr().String()
+}
+
+func (f *fakeClamd) serve() {
+	for {
+		conn, err := f.l.Accept()
+		if err != nil {
+			return
+		}
+
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	cmd, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	reply := func(s string) {
+		_, _ = conn.Write([]byte(s + "\x00"))
+	}
+
+	switch strings.TrimSuffix(cmd, "\x00") {
+	case "zPING":
+		reply("PONG")
+	case "zVERSION":
+		reply("ClamAV 1.0.0/26000/Mon Jan  1 00:00:00 2024")
+	case "zINSTREAM":
+		stream := &bytes.Buffer{}
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+
+			if stream.Len()+int(size) > f.maxStream {
+				// Clamd replies and closes the connection before the stream is completed.
+				reply("INSTREAM size limit exceeded. ERROR")
+				return
+			}
+
+			if _, err := io.CopyN(stream, r, int64(size)); err != nil {
+				return
+			}
+		}
+
+		switch {
+		case bytes.Contains(stream.Bytes(), eicar):
+			reply("stream: Eicar-Signature FOUND")
+		case bytes.Contains(stream.Bytes(), []byte("clamd-error")):
+			reply("stream: Can't allocate memory ERROR")
+		default:
+			reply("stream: OK")
+		}
+	default:
+		reply("UNKNOWN COMMAND")
+	}
+}
+
+func newTestClamd(t *testing.T, maxStream int) *Clamd {
+	t.Helper()
+
+	c, err := NewClamd(newFakeClamd(t, maxStream).address(), 5*time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return c
+}
+
+func TestNewClamd(t *testing.T) {
+	cases := []struct {
+		address string
+		network string
+		addr    string
+		wantErr bool
+	}{
+		{address: "tcp://127.0.0.1:3310", network: "tcp", addr: "127.0.0.1:3310"},
+		{address: "127.0.0.1:3310", network: "tcp", addr: "127.0.0.1:3310"},
+		{address: "unix:///var/run/clamav/clamd.ctl", network: "unix", addr: "/var/run/clamav/clamd.ctl"},
+		{address: "unix://", wantErr: true},
+		{address: "", wantErr: true},
+	}
+
+	for _, tc := range cases {
+		c, err := NewClamd(tc.address, time.Second)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("%q: expect error %v, got %v", tc.address, tc.wantErr, err)
+			continue
+		}
+
+		if err == nil && (c.network != tc.network || c.address != tc.addr) {
+			t.Errorf("%q: expect %s %s, got %s %s", tc.address, tc.network, tc.addr, c.network, c.address)
+		}
+	}
+}
+
+func TestClamd(t *testing.T) {
+	ctx := context.Background()
+	c := newTestClamd(t, 1<<20)
+
+	if err := c.Ping(ctx); err != nil {
+		t.Fatalf("ping error: %v", err)
+	}
+
+	if v, err := c.Version(ctx); err != nil || !strings.HasPrefix(v, "ClamAV") {
+		t.Fatalf("unexpected version %q: %v", v, err)
+	}
+
+	cases := []struct {
+		name      string
+		content   []byte
+		signature string
+		wantErr   string
+	}{
+		{name: "clean", content: []byte("hello")},
+		{name: "empty", content: nil},
+		{name: "eicar", content: eicar, signature: "Eicar-Signature"},
+		{name: "eicar after chunks", content: append(bytes.Repeat([]byte{'a'}, 3*chunkSize), eicar...), signature: "Eicar-Signature"},
+		{name: "clamd error", content: []byte("clamd-error"), wantErr: "Can't allocate memory"},
+		{name: "size limit", content: bytes.Repeat([]byte{'a'}, 2<<20), wantErr: "size limit exceeded"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			sig, err := c.Scan(ctx, bytes.NewReader(tc.content))
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expect error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("scan error: %v", err)
+			}
+
+			if sig != tc.signature {
+				t.Errorf("expect signature %q, got %q", tc.signature, sig)
+			}
+		})
+	}
+}
+
+func TestClamdUnavailable(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := l.Addr().String()
+	_ = l.Close()
+
+	c, err := NewClamd(address, time.Second)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := c.Ping(context.Background()); err == nil || !strings.Contains(err.Error(), "connect clamd error") {
+		t.Errorf("expect connect error, got %v", err)
+	}
+}
@@ -0,0 +1,97 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package malware
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// JobName is the name of the malware scan job.
+	JobName = "MALWARE_SCAN"
+)
+
+// AddToKnownList adds the malware scan job to the known list.
+func AddToKnownList(l *job.KnownList) error {
+	return malwareEngine.AddToKnownList(l)
+}
+
+// scanMalware streams the files of the image layers to clamd.
+func scanMalware(ctx context.Context, t *native.Target) ([]byte, error) {
+	errorf := errs.WithPrefix("")
+
+	clamd, err := NewClamd(
+		viper.GetString("scanner.backends.malware.address"),
+		viper.GetDuration("scanner.backends.malware.fileTimeout"),
+	)
+	if err != nil {
+		return nil, errorf.Wrap("create clamd client error", err)
+	}
+
+	// Fail fast if clamd is not available.
+	if err := clamd.Ping(ctx); err != nil {
+		return nil, errorf.Wrap("ping clamd error", err)
+	}
+
+	vendorAttrs := spec.ModelMap{}
+	if v, err := clamd.Version(ctx); err == nil {
+		vendorAttrs["clamd_version"] = v
+	}
+
+	img, err := t.Image(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	scanner := NewScanner(clamd, Limits{
+		MaxFileSize:  viper.GetInt64("scanner.backends.malware.maxFileSize"),
+		MaxImageSize: viper.GetInt64("scanner.backends.malware.maxImageSize"),
+	})
+	summary, detections, warnings, err := scanner.Scan(ctx, img)
+	if err != nil {
+		return nil, errorf.Wrap("scan image error", err)
+	}
+
+	zlog.FromContext(ctx).Infow("image is scanned", "scanned_files", summary.ScannedFiles, "detections", summary.Detections)
+
+	result, err := json.Marshal(&spec.HarborMalwareReport{
+		GeneratedAt: time.Now().UTC(),
+		Artifact:    t.Artifact,
+		Scanner: &spec.Scanner{
+			Name:    Name,
+			Vendor:  Vendor,
+			Version: Version,
+		},
+		Summary:          summary,
+		Detections:       detections,
+		Warnings:         warnings,
+		VendorAttributes: vendorAttrs,
+	})
+	if err != nil {
+		return nil, errorf.Wrap("marshal malware report error", err)
+	}
+
+	return result, nil
+}
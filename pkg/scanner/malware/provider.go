@@ -0,0 +1,48 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package malware
+
+import (
+	"sync"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+)
+
+// Use singleton provider.
+var provider *native.Provider
+var once sync.Once
+
+// malwareEngine scans the files of the image layers with clamd.
+var malwareEngine = &native.Engine{
+	Name:           Name,
+	Vendor:         Vendor,
+	Version:        Version,
+	CapabilityType: CapabilityType,
+	ReportMimeType: ReportMimeType,
+	ExtraMeta:      ExtraMeta,
+	JobName:        JobName,
+	Backend:        "malware",
+	Local:          true,
+	Scan:           scanMalware,
+}
+
+// New a malware provider.
+func New() *native.Provider {
+	once.Do(func() {
+		provider = native.NewProvider(malwareEngine)
+	})
+
+	return provider
+}
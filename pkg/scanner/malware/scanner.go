@@ -0,0 +1,175 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package malware
+
+import (
+	"archive/tar"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// errImageLimit is returned when the image size limit is reached.
+var errImageLimit = errors.New("image size limit is reached")
+
+// Limits of the malware scanning.
+type Limits struct {
+	// MaxFileSize is the size limit of each file, larger files are skipped.
+	MaxFileSize int64
+	// MaxImageSize is the limit of the total bytes scanned in the image,
+	// the remaining files are not scanned once it's reached.
+	MaxImageSize int64
+}
+
+// Scanner streams the files of the image layers to clamd.
+type Scanner struct {
+	clamd  *Clamd
+	limits Limits
+}
+
+// NewScanner creates a scanner with the clamd client and the limits.
+func NewScanner(clamd *Clamd, limits Limits) *Scanner {
+	return &Scanner{
+		clamd:  clamd,
+		limits: limits,
+	}
+}
+
+// Scan the regular files in the image layers.
+// Each layer is scanned as it is, so the files removed by the later layers are scanned too.
+func (s *Scanner) Scan(ctx context.Context, img *oci.Image) (*spec.MalwareSummary, []spec.MalwareDetection, []string, error) {
+	summary := &spec.MalwareSummary{Complete: true}
+	detections := []spec.MalwareDetection{}
+	var warnings []string
+
+	for _, l := range img.Manifest.Layers {
+		ds, ws, err := s.scanLayer(ctx, img, l, summary)
+		warnings = append(warnings, ws...)
+		detections = append(detections, ds...)
+		if err != nil {
+			if errors.Is(err, errImageLimit) {
+				summary.Complete = false
+				warnings = append(warnings, fmt.Sprintf("image size limit %d bytes is reached, the remaining files are not scanned", s.limits.MaxImageSize))
+				break
+			}
+
+			if errors.Is(err, oci.ErrUnsupportedCompression) {
+				summary.Complete = false
+				warnings = append(warnings, fmt.Sprintf("layer %s is skipped: %s", l.Digest, err))
+				continue
+			}
+
+			return nil, nil, nil, fmt.Errorf("scan layer %s error: %w", l.Digest, err)
+		}
+	}
+
+	if summary.SkippedFiles > 0 {
+		warnings = append(warnings, fmt.Sprintf("%d files larger than %d bytes are skipped", summary.SkippedFiles, s.limits.MaxFileSize))
+	}
+	summary.Detections = uint(len(detections))
+
+	return summary, detections, warnings, nil
+}
+
+// scanLayer scans the files of the layer and returns the detections and the warnings of the files
+// failed to scan by clamd. The error is returned only if the layer itself can't be read.
+func (s *Scanner) scanLayer(ctx context.Context, img *oci.Image, desc oci.Descriptor, summary *spec.MalwareSummary) ([]spec.MalwareDetection, []string, error) {
+	rc, err := img.Layer(ctx, desc)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rc.Close()
+
+	var (
+		detections []spec.MalwareDetection
+		warnings   []string
+	)
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return detections, warnings, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg || hdr.Size == 0 {
+			continue
+		}
+
+		if s.limits.MaxFileSize > 0 && hdr.Size > s.limits.MaxFileSize {
+			summary.SkippedFiles++
+			continue
+		}
+
+		if s.limits.MaxImageSize > 0 && summary.ScannedBytes+hdr.Size > s.limits.MaxImageSize {
+			return detections, warnings, errImageLimit
+		}
+
+		name := path.Clean("/" + hdr.Name)
+		fr := &fileReader{r: tr}
+		sig, err := s.clamd.Scan(ctx, fr)
+		if err != nil {
+			// The layer is broken if the file can't be read.
+			if fr.err != nil {
+				return detections, warnings, fr.err
+			}
+
+			// The other files are still scanned if clamd fails on this one.
+			summary.Complete = false
+			warnings = append(warnings, fmt.Sprintf("%s of layer %s is not scanned: %s", name, desc.Digest, err))
+			continue
+		}
+
+		summary.ScannedFiles++
+		summary.ScannedBytes += hdr.Size
+
+		if sig != "" {
+			detections = append(detections, spec.MalwareDetection{
+				Path:      name,
+				Layer:     desc.Digest,
+				Signature: sig,
+			})
+		}
+	}
+
+	// Drain the stream to verify the layer digest.
+	_, err = io.Copy(ioutil.Discard, rc)
+	return detections, warnings, err
+}
+
+// fileReader keeps the error of reading the file from the layer.
+type fileReader struct {
+	r   io.Reader
+	err error
+}
+
+// Read implements io.Reader.
+func (fr *fileReader) Read(p []byte) (int, error) {
+	n, err := fr.r.Read(p)
+	if err != nil && err != io.EOF {
+		fr.err = err
+	}
+
+	return n, err
+}
@@ -0,0 +1,112 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package malware
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci/ocitest"
+)
+
+func TestScannerScan(t *testing.T) {
+	base := ocitest.Layer(t,
+		ocitest.File{Name: "bin/", Typeflag: tar.TypeDir},
+		ocitest.File{Name: "bin/tool", Content: []byte("clean binary")},
+		ocitest.File{Name: "tmp/eicar.com", Content: eicar},
+	)
+	upper := ocitest.Layer(t,
+		// The removed file is still scanned in the lower layer.
+		ocitest.File{Name: "tmp/.wh.eicar.com"},
+		ocitest.File{Name: "etc/broken", Content: []byte("clamd-error")},
+		ocitest.File{Name: "var/big", Content: bytes.Repeat([]byte{'a'}, 2048)},
+		ocitest.File{Name: "etc/empty"},
+	)
+	img := ocitest.Image(t, nil, base, upper)
+
+	s := NewScanner(newTestClamd(t, 1<<20), Limits{MaxFileSize: 1024})
+	summary, detections, warnings, err := s.Scan(context.Background(), img)
+	if err != nil {
+		t.Fatalf("scan error: %v", err)
+	}
+
+	if len(detections) != 1 {
+		t.Fatalf("expect 1 detection, got %+v", detections)
+	}
+
+	d := detections[0]
+	if d.Path != "/tmp/eicar.com" || d.Signature != "Eicar-Signature" || d.Layer != img.Manifest.Layers[0].Digest {
+		t.Errorf("unexpected detection: %+v", d)
+	}
+
+	// The empty files are not scanned.
+	if summary.ScannedFiles != 2 || summary.SkippedFiles != 1 || summary.Detections != 1 {
+		t.Errorf("unexpected summary: %+v", summary)
+	}
+
+	if summary.Complete {
+		t.Errorf("expect incomplete scan as /etc/broken is not scanned")
+	}
+
+	joined := strings.Join(warnings, "\n")
+	for _, w := range []string{"/etc/broken of layer " + img.Manifest.Layers[1].Digest + " is not scanned", "1 files larger than 1024 bytes are skipped"} {
+		if !strings.Contains(joined, w) {
+			t.Errorf("expect warning %q, got %q", w, joined)
+		}
+	}
+}
+
+func TestScannerLimits(t *testing.T) {
+	layer := ocitest.Layer(t,
+		ocitest.File{Name: "a", Content: bytes.Repeat([]byte{'a'}, 600)},
+		ocitest.File{Name: "b", Content: bytes.Repeat([]byte{'b'}, 600)},
+		ocitest.File{Name: "c", Content: eicar},
+	)
+	img := ocitest.Image(t, nil, layer)
+
+	cases := []struct {
+		name      string
+		limits    Limits
+		maxStream int
+		scanned   uint
+		complete  bool
+		warning   string
+	}{
+		{name: "no limits", maxStream: 1 << 20, scanned: 3, complete: true},
+		{name: "image size limit", limits: Limits{MaxImageSize: 1000}, maxStream: 1 << 20, scanned: 1, warning: "image size limit 1000 bytes is reached"},
+		{name: "clamd stream limit", maxStream: 500, scanned: 1, warning: "/a of layer"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s := NewScanner(newTestClamd(t, tc.maxStream), tc.limits)
+			summary, _, warnings, err := s.Scan(context.Background(), img)
+			if err != nil {
+				t.Fatalf("scan error: %v", err)
+			}
+
+			if summary.ScannedFiles != tc.scanned || summary.Complete != tc.complete {
+				t.Errorf("unexpected summary: %+v", summary)
+			}
+
+			if tc.warning != "" && !strings.Contains(strings.Join(warnings, "\n"), tc.warning) {
+				t.Errorf("expect warning %q, got %q", tc.warning, warnings)
+			}
+		})
+	}
+}
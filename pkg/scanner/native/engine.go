@@ -0,0 +1,139 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// Engine is a native scanning engine. The provider and the scan job of the engine share the plumbing
+// of accepting the scan requests, running the scan jobs and keeping the reports, the engine only
+// supplies its metadata and the scan function.
+type Engine struct {
+	// Name of the provider, e.g: Secret.
+	Name string
+	// Vendor of the provider.
+	Vendor string
+	// Version of the provider.
+	Version string
+	// CapabilityType is the capability type of the provider, e.g: secret.
+	CapabilityType string
+	// ReportMimeType is the mimetype of the report produced by the engine.
+	ReportMimeType string
+	// MimeTypes are the artifact mimetypes consumed by the engine, the image manifests if it's empty.
+	MimeTypes []string
+	// ExtraMeta is the extra properties of the provider.
+	ExtraMeta map[string]string
+	// JobName is the name of the scan job.
+	JobName string
+	// Backend is the key of the engine settings under scanner.backends, e.g: secret.
+	// The settings timeout, insecure and certPath are honored by the scan job.
+	Backend string
+	// Local indicates the local artifacts like oci-layout:/path are supported.
+	Local bool
+	// Scan the target and return the report.
+	Scan func(ctx context.Context, t *Target) ([]byte, error)
+}
+
+// Target is the artifact scanned by the scan job.
+type Target struct {
+	// Artifact to scan, the digest is set.
+	Artifact *spec.Artifact
+	// Registry is the registry URL, empty for the local artifacts.
+	Registry string
+	// Options of the registry client with the credential of the scan request.
+	Options oci.RemoteOptions
+}
+
+// Source opens the source of the target, the local artifact or the repository in the registry.
+func (t *Target) Source() (oci.Source, error) {
+	return oci.OpenSource(t.Artifact.Reference, t.Registry, t.Artifact.Repository, t.Options)
+}
+
+// Image resolves the image manifest of the target, the digest of the artifact is set to the resolved one
+// if it's not set.
+func (t *Target) Image(ctx context.Context) (*oci.Image, error) {
+	src, err := t.Source()
+	if err != nil {
+		return nil, fmt.Errorf("open image source error: %w", err)
+	}
+
+	img, err := oci.ResolveImage(ctx, src, reference(t.Artifact), nil)
+	if err != nil {
+		return nil, fmt.Errorf("resolve image error: %w", err)
+	}
+
+	// Record the digest of the local image resolved by tag.
+	if t.Artifact.Digest == "" {
+		t.Artifact.Digest = img.Digest
+	}
+
+	return img, nil
+}
+
+// mimeTypes returns the artifact mimetypes consumed by the engine.
+func (e *Engine) mimeTypes() []string {
+	if len(e.MimeTypes) > 0 {
+		return e.MimeTypes
+	}
+
+	return []string{oci.OCIImage, oci.DockerV2Image}
+}
+
+// supported checks whether the artifact mimetype is consumed by the engine.
+func (e *Engine) supported(mimetype string) bool {
+	for _, m := range e.mimeTypes() {
+		if m == mimetype {
+			return true
+		}
+	}
+
+	return false
+}
+
+// dataPrefix is the prefix of the result keys, e.g: {secret-result-store}.
+func (e *Engine) dataPrefix() string {
+	return fmt.Sprintf("{%s-result-store}", e.Backend)
+}
+
+// setting returns the key of the engine setting.
+func (e *Engine) setting(name string) string {
+	return fmt.Sprintf("scanner.backends.%s.%s", e.Backend, name)
+}
+
+// remoteOptions returns the options of the registry client with the credential.
+func (e *Engine) remoteOptions(username, password string) oci.RemoteOptions {
+	return oci.RemoteOptions{
+		Username: username,
+		Password: password,
+		Insecure: viper.GetBool(e.setting("insecure")),
+		CertPath: viper.GetString(e.setting("certPath")),
+	}
+}
+
+// reference of the artifact, the digest is preferred.
+func reference(artifact *spec.Artifact) string {
+	if artifact.Digest != "" {
+		return artifact.Digest
+	}
+
+	return artifact.Tag
+}
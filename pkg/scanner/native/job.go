@@ -0,0 +1,141 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/auth"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	concurrency = 10
+)
+
+// AddToKnownList adds the scan job of the engine to the known list.
+func (e *Engine) AddToKnownList(l *job.KnownList) error {
+	return l.AddKnownJob(e.JobName, job.RunnableFunc(e.run), job.Concurrency(concurrency))
+}
+
+// run the scan job of the engine.
+func (e *Engine) run(ctx context.Context, parameters job.Parameters) (err error) {
+	// Skip parameter validation as the parameter should be validated in the API layer.
+
+	prefix := fmt.Sprintf("%s scan job error", e.Backend)
+	errorf := errs.WithPrefix(prefix)
+	resStore := store.Default()
+
+	// Extract key parameters.
+	reqID := parameters[ParamReqID].(string)
+	imagePath := parameters[ParamKeyImage].(string)
+
+	// Correlate the job logs with the scan request.
+	ctx = uuid.WithRequestID(ctx, reqID)
+	ctx = zlog.WithFields(ctx,
+		zlog.FieldProvider, e.Name,
+		zlog.FieldJobID, parameters[ParamJobID],
+		zlog.FieldArtifact, imagePath,
+	)
+	lg := zlog.FromContext(ctx)
+
+	artifact := &spec.Artifact{}
+	if err := json.Unmarshal([]byte(parameters[ParamArtifact].(string)), artifact); err != nil {
+		return errorf.Wrap("unmarshal artifact error", err)
+	}
+
+	uk := fmt.Sprintf("%s:%s:%s", e.dataPrefix(), e.Name, imagePath)
+	if err := resStore.Unique(uk); err != nil {
+		return errorf.Wrap("last scan is still not finished yet, skip job run", err)
+	}
+
+	defer func() {
+		// Defer to de-unique.
+		if er := resStore.DeUnique(uk); er != nil {
+			// Just log.
+			lg.Error(er)
+		}
+
+		// Check if error is occurred.
+		if err != nil {
+			if er := resStore.SaveResult(e.dataKey(reqID), &data.Item{
+				Timestamp: time.Now().UTC().Unix(),
+				Status:    data.Error,
+				Error:     err.Error(),
+			}); er != nil {
+				lg.Error(er)
+			}
+		}
+	}()
+
+	// Mark status to start.
+	if err := resStore.SaveResult(e.dataKey(reqID), &data.Item{
+		Timestamp: time.Now().UTC().Unix(),
+		Status:    data.Ongoing,
+	}); err != nil {
+		// Just need to log
+		lg.Error(err)
+	}
+
+	if timeout := viper.GetDuration(e.setting("timeout")); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	username, _ := parameters[auth.ParamKeyUsername].(string)
+	password, _ := parameters[auth.ParamKeyPassword].(string)
+	t := &Target{
+		Artifact: artifact,
+		Registry: parameters[ParamRegistry].(string),
+		Options:  e.remoteOptions(username, password),
+	}
+
+	lg.Infof("%s scan is started", e.Backend)
+
+	result, err := e.Scan(ctx, t)
+	if err != nil {
+		// The engine describes the failure, e.g: detect secrets error.
+		return fmt.Errorf("%s: %w", prefix, err)
+	}
+
+	// Save data.
+	if err := resStore.SaveResult(e.dataKey(reqID), &data.Item{
+		Status:    data.Success,
+		JSON:      string(result),
+		Timestamp: time.Now().UTC().Unix(),
+	}); err != nil {
+		lg.Error(err)
+	} else {
+		lg.Infof("%s.job is completed", e.Backend)
+	}
+
+	// Push the report back to the registry along with the image.
+	scan.PushReport(ctx, t.Registry, artifact, e.ReportMimeType, result, t.Options)
+
+	return nil
+}
@@ -0,0 +1,107 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/auth"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// resolveTimeout is the timeout of resolving the tag digest at accepting.
+	resolveTimeout = 30 * time.Second
+)
+
+// PinDigest returns the scan request with the artifact digest set, so the job scans the requested
+// image even if the tag is moved after accepting. The digest of the tag is resolved from the local
+// artifact, or from the registry with the options and the credential of the scan request.
+func PinDigest(ctx context.Context, req *spec.ScanRequest, options oci.RemoteOptions) (*spec.ScanRequest, error) {
+	if req.Artifact.Digest != "" {
+		return req, nil
+	}
+
+	var (
+		dgst string
+		err  error
+	)
+	if req.Artifact.Reference != "" {
+		dgst, err = resolveLocal(ctx, req.Artifact)
+	} else {
+		dgst, err = resolveRemote(ctx, req, options)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	artifact := *req.Artifact
+	artifact.Digest = dgst
+
+	pinned := *req
+	pinned.Artifact = &artifact
+
+	return &pinned, nil
+}
+
+// resolveRemote resolves the digest of the tag from the registry.
+func resolveRemote(ctx context.Context, req *spec.ScanRequest, options oci.RemoteOptions) (string, error) {
+	if req.Artifact.Tag == "" {
+		return "", fmt.Errorf("either digest or tag of the artifact is required")
+	}
+
+	ap, err := auth.Parse(req.Registry.Authorization)
+	if err != nil {
+		return "", fmt.Errorf("parse registry authorization error: %w", err)
+	}
+
+	if ba, ok := ap.(*auth.BasicAuth); ok {
+		options.Username, options.Password = ba.Username, ba.Password
+	}
+
+	r, err := oci.NewRemote(req.Registry.Url, req.Artifact.Repository, options)
+	if err != nil {
+		return "", err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, resolveTimeout)
+	defer cancel()
+
+	desc, err := r.Resolve(ctx, req.Artifact.Tag)
+	if err != nil {
+		return "", err
+	}
+
+	return desc.Digest, nil
+}
+
+// resolveLocal resolves the digest of the tag in the local artifact.
+// The only image in the local artifact is selected if the tag is not set.
+func resolveLocal(ctx context.Context, artifact *spec.Artifact) (string, error) {
+	src, err := oci.OpenLocal(artifact.Reference)
+	if err != nil {
+		return "", err
+	}
+
+	content, _, err := src.Manifest(ctx, artifact.Tag)
+	if err != nil {
+		return "", err
+	}
+
+	return oci.Digest(content), nil
+}
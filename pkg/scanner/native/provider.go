@@ -0,0 +1,231 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package native
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/auth"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/client"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// ParamKeyImage is parameter key of image path.
+	ParamKeyImage = "image"
+	// ParamReqID is parameter key of request ID.
+	ParamReqID = "reqID"
+	// ParamJobID is parameter key of the job ID used to correlate logs.
+	ParamJobID = "jobID"
+	// ParamArtifact is parameter key of the JSON encoded artifact.
+	ParamArtifact = "artifact"
+	// ParamRegistry is parameter key of the registry URL, empty for the local artifacts.
+	ParamRegistry = "registry"
+)
+
+// Provider of the engine, it implements scanner.Provider.
+type Provider struct {
+	engine *Engine
+	store  store.Provider
+}
+
+// NewProvider creates the provider of the engine.
+func NewProvider(e *Engine) *Provider {
+	return &Provider{
+		engine: e,
+		store:  store.Default(),
+	}
+}
+
+// Metadata implements scanner.Provider.
+func (p *Provider) Metadata() *spec.ScannerAdapterMetadata {
+	props := make(map[string]string, len(p.engine.ExtraMeta))
+	for k, v := range p.engine.ExtraMeta {
+		props[k] = v
+	}
+
+	return &spec.ScannerAdapterMetadata{
+		Scanner: &spec.Scanner{
+			Name:    p.engine.Name,
+			Version: p.engine.Version,
+			Vendor:  p.engine.Vendor,
+		},
+		Capabilities: []spec.ScannerCapability{
+			{
+				Type:              p.engine.CapabilityType,
+				ConsumesMimeTypes: p.engine.mimeTypes(),
+				ProducesMimeTypes: []string{
+					p.engine.ReportMimeType,
+				},
+			},
+		},
+		Properties: &props,
+	}
+}
+
+// AcceptScanRequest implements scanner.Provider.
+func (p *Provider) AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error) {
+	errorf := errs.WithPrefix("accept scan request error")
+
+	// Extract request ID first.
+	reqID := uuid.FromContext(ctx)
+	if reqID == "" {
+		return nil, errorf.Error("missing request ID in the context")
+	}
+
+	if req == nil || req.Artifact == nil {
+		return nil, errorf.Error("artifact is required in the scan request")
+	}
+
+	if req.Artifact.Reference != "" {
+		if !p.engine.Local {
+			return nil, errorf.Error("local artifacts are not supported")
+		}
+	} else if req.Registry == nil {
+		return nil, errorf.Error("registry or local reference is required in the scan request")
+	}
+
+	// Validate artifact mimetype.
+	if !p.engine.supported(req.Artifact.MimeType) {
+		return nil, errorf.Error("only support mimetypes: %s", strings.Join(p.engine.mimeTypes(), ","))
+	}
+
+	// Pin the artifact to the digest, so the job scans the requested image even if the tag is moved.
+	req, err := PinDigest(ctx, req, p.engine.remoteOptions("", ""))
+	if err != nil {
+		return nil, errorf.Wrap("resolve artifact digest error", err)
+	}
+
+	// Convert job parameters.
+	jp, err := toJobParams(req)
+	if err != nil {
+		return nil, errorf.Wrap("parse job parameters error", err)
+	}
+
+	jobID := uuid.Random()
+	jp[ParamReqID] = reqID
+	jp[ParamJobID] = jobID
+
+	ctx = zlog.WithFields(ctx,
+		zlog.FieldProvider, p.engine.Name,
+		zlog.FieldJobID, jobID,
+		zlog.FieldArtifact, jp[ParamKeyImage],
+	)
+	lg := zlog.FromContext(ctx)
+
+	// Enqueue scan job.
+	enq, err := client.Enqueuer()
+	if err != nil {
+		return nil, errorf.Wrap("get job enqueuer error", err)
+	}
+
+	j, err := enq.EnqueueUnique(p.engine.JobName, jp)
+	if err != nil {
+		return nil, errorf.Wrap("enqueue %s job error", err, p.engine.Backend)
+	}
+
+	// Log the backend job info for potential debug.
+	lg.Infow("Backend scan job is enqueued", "job", j.Name, "id", j.ID)
+
+	// Create result placeholder and set the status to pending.
+	if err := p.store.SaveResult(p.engine.dataKey(reqID), &data.Item{
+		Timestamp: time.Now().UTC().Unix(),
+		Status:    data.Pending,
+	}); err != nil {
+		// Not a panic case, just logged it.
+		// Once the scan job is started, it can be recovered again.
+		lg.Errorw("save result placeholder failed", "error", err)
+	}
+
+	return &spec.ScanResponse{
+		Id: reqID,
+	}, nil
+}
+
+// RetrieveScanResult implements scanner.Provider.
+func (p *Provider) RetrieveScanResult(_ context.Context, reqID string, mimetype string) (scan.Result, error) {
+	errorf := errs.WithPrefix("retrieve scan request error")
+
+	dt, err := p.store.GetResult(p.engine.dataKey(reqID))
+	if err != nil {
+		if errors.Is(err, rds.NotFoundErr) {
+			return scan.ResultOf(mimetype, nil)
+		}
+		return nil, errorf.Wrap("store get result error", err)
+	}
+
+	return scan.ResultOf(mimetype, dt)
+}
+
+// dataKey returns the data key of the report.
+func (e *Engine) dataKey(reqID string) *data.Key {
+	dk := &data.Key{
+		Provider: e.Name,
+		ReqID:    reqID,
+		Mimetype: e.ReportMimeType,
+	}
+	dk.AppendPrefix(e.dataPrefix())
+
+	return dk
+}
+
+func toJobParams(req *spec.ScanRequest) (job.Parameters, error) {
+	errorf := errs.WithPrefix("")
+
+	jp := make(job.Parameters)
+
+	// Always scan the image by digest, the digest is pinned at accepting.
+	if req.Artifact.Reference != "" {
+		// The local artifact is read from the filesystem.
+		jp[ParamKeyImage] = oci.LocalImageRef(req.Artifact.Reference, "", req.Artifact.Digest)
+		jp[ParamRegistry] = ""
+	} else {
+		// Parse authorization credential.
+		ap, err := auth.Parse(req.Registry.Authorization)
+		if err != nil {
+			return nil, errorf.Wrap("parse registry authorization error", err)
+		}
+
+		if err := ap.Inject(jp); err != nil {
+			return nil, errorf.Wrap("inject auth params error", err)
+		}
+
+		jp[ParamKeyImage] = oci.ImageRef(req.Registry.Url, req.Artifact.Repository, "", req.Artifact.Digest)
+		jp[ParamRegistry] = req.Registry.Url
+	}
+
+	// Keep the artifact as JSON string to survive the job parameters serialization.
+	bytes, err := json.Marshal(req.Artifact)
+	if err != nil {
+		return nil, errorf.Wrap("marshal artifact error", err)
+	}
+	jp[ParamArtifact] = string(bytes)
+
+	return jp, nil
+}
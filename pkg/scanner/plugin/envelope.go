@@ -0,0 +1,83 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"encoding/json"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// ExecProtocolVersion is the version of the exec plugin protocol.
+const ExecProtocolVersion = "v1"
+
+// Exit codes of the exec plugin.
+const (
+	// ExitOK means the reports are written to the output path.
+	ExitOK = 0
+	// ExitFailed means the scan is failed, the error should be written to the output path.
+	ExitFailed = 1
+	// ExitUnsupported means the artifact is not supported by the plugin.
+	ExitUnsupported = 3
+)
+
+// Envelope is the job sent to the exec plugin on stdin.
+type Envelope struct {
+	// Version of the protocol.
+	Version string `json:"version"`
+	// RequestID is the scan request ID.
+	RequestID string `json:"request_id"`
+	// Artifact to scan.
+	Artifact *spec.Artifact `json:"artifact"`
+	// Registry hosting the artifact.
+	Registry *EnvelopeRegistry `json:"registry"`
+	// ProducesMimeTypes are the report mime types expected.
+	ProducesMimeTypes []string `json:"produces_mime_types"`
+	// OutputPath is the file the plugin should write the Result to.
+	OutputPath string `json:"output_path"`
+	// Parameters declared in the plugin configuration.
+	Parameters map[string]interface{} `json:"parameters,omitempty"`
+}
+
+// EnvelopeRegistry is the registry info in the envelope.
+// The credentials are not sent inline but kept in a file only readable by the adapter user.
+type EnvelopeRegistry struct {
+	URL string `json:"url"`
+	// CredentialsFile is the path of the JSON encoded Credentials, empty if no authorization.
+	CredentialsFile string `json:"credentials_file,omitempty"`
+}
+
+// Credentials of the registry.
+type Credentials struct {
+	// Authorization is the authorization header sent by Harbor, e.g: Basic xxx or Bearer xxx.
+	Authorization string `json:"authorization"`
+	// Username and Password are decoded from the basic authorization.
+	Username string `json:"username,omitempty"`
+	Password string `json:"password,omitempty"`
+}
+
+// Result is written by the exec plugin to the output path.
+type Result struct {
+	// Reports of the produced mime types.
+	Reports []ResultReport `json:"reports,omitempty"`
+	// Error message if the scan is failed.
+	Error string `json:"error,omitempty"`
+}
+
+// ResultReport is the report of a mime type.
+type ResultReport struct {
+	MimeType string          `json:"mime_type"`
+	Report   json.RawMessage `json:"report"`
+}
@@ -0,0 +1,201 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/client"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// ParamPlugin is parameter key of the plugin name.
+	ParamPlugin = "plugin"
+	// ParamKeyImage is parameter key of image path.
+	ParamKeyImage = "image"
+	// ParamReqID is parameter key of request ID.
+	ParamReqID = "reqID"
+	// ParamJobID is parameter key of the job ID used to correlate logs.
+	ParamJobID = "jobID"
+	// ParamArtifact is parameter key of the JSON encoded artifact.
+	ParamArtifact = "artifact"
+	// ParamRegistry is parameter key of the registry URL.
+	ParamRegistry = "registry"
+	// ParamAuthorization is parameter key of the registry authorization.
+	ParamAuthorization = "authorization"
+
+	dataPrefix = "{plugin-result-store}"
+)
+
+// Exec is the plugin running a third-party scanner binary for each scan.
+//
+// The binary receives the JSON encoded Envelope on stdin and writes the JSON encoded Result
+// to the output path of the envelope. The exit code tells the scan status, see ExitOK,
+// ExitFailed and ExitUnsupported.
+type Exec struct {
+	spec   ExecSpec
+	store  store.Provider
+	dataNS string
+}
+
+func newExec(s ExecSpec) *Exec {
+	return &Exec{
+		spec:   s,
+		store:  store.Default(),
+		dataNS: dataPrefix,
+	}
+}
+
+// Name implements Plugin.
+func (e *Exec) Name() string {
+	return e.spec.Name
+}
+
+// MimeTypes implements Plugin.
+func (e *Exec) MimeTypes() []string {
+	return producedMimeTypes(e.spec.Capabilities)
+}
+
+// Metadata implements Plugin.
+func (e *Exec) Metadata() *spec.ScannerAdapterMetadata {
+	return metadataOf(e.spec.Metadata, e.spec.Capabilities)
+}
+
+// AcceptScanRequest implements Plugin.
+func (e *Exec) AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error) {
+	errorf := errs.WithPrefix("accept scan request error")
+
+	// Extract request ID first.
+	reqID := uuid.FromContext(ctx)
+	if reqID == "" {
+		return nil, errorf.Error("missing request ID in the context")
+	}
+
+	if req == nil || req.Registry == nil || req.Artifact == nil {
+		return nil, errorf.Error("registry and artifact are required in the scan request")
+	}
+
+	if !consumes(e.spec.Capabilities, req.Artifact.MimeType) {
+		return nil, errorf.Error("plugin %s does not support mimetype: %s", e.spec.Name, req.Artifact.MimeType)
+	}
+
+	// Convert job parameters.
+	jp, err := toJobParams(req)
+	if err != nil {
+		return nil, errorf.Wrap("parse job parameters error", err)
+	}
+
+	jobID := uuid.Random()
+	jp[ParamPlugin] = e.spec.Name
+	jp[ParamReqID] = reqID
+	jp[ParamJobID] = jobID
+
+	ctx = zlog.WithFields(ctx,
+		zlog.FieldProvider, e.spec.Name,
+		zlog.FieldJobID, jobID,
+		zlog.FieldArtifact, jp[ParamKeyImage],
+	)
+	lg := zlog.FromContext(ctx)
+
+	// Enqueue scan job.
+	enq, err := client.Enqueuer()
+	if err != nil {
+		return nil, errorf.Wrap("get job enqueuer error", err)
+	}
+
+	j, err := enq.EnqueueUnique(ExecJobName, jp)
+	if err != nil {
+		return nil, errorf.Wrap("enqueue exec plugin job error", err)
+	}
+
+	// Log the backend job info for potential debug.
+	lg.Infow("Exec plugin scan job is enqueued", "job", j.Name, "id", j.ID)
+
+	// Create result placeholders and set the status to pending.
+	for _, m := range e.MimeTypes() {
+		if err := e.store.SaveResult(dataKey(e.spec.Name, reqID, m), &data.Item{
+			Timestamp: time.Now().UTC().Unix(),
+			Status:    data.Pending,
+		}); err != nil {
+			// Not a panic case, just logged it.
+			// Once the scan job is started, it can be recovered again.
+			lg.Errorw("save result placeholder failed", "error", err, "mimetype", m)
+		}
+	}
+
+	return &spec.ScanResponse{
+		Id: reqID,
+	}, nil
+}
+
+// RetrieveScanResult implements Plugin.
+func (e *Exec) RetrieveScanResult(_ context.Context, reqID string, mimetype string) (scan.Result, error) {
+	errorf := errs.WithPrefix("retrieve scan request error")
+
+	dt, err := e.store.GetResult(dataKey(e.spec.Name, reqID, mimetype))
+	if err != nil {
+		if errors.Is(err, rds.NotFoundErr) {
+			return scan.ResultOf(mimetype, nil)
+		}
+		return nil, errorf.Wrap("store get result error", err)
+	}
+
+	return scan.ResultOf(mimetype, dt)
+}
+
+// dataKey returns the data key of the plugin report with the mimetype.
+func dataKey(name string, reqID string, mimetype string) *data.Key {
+	dk := &data.Key{
+		Provider: name,
+		ReqID:    reqID,
+		Mimetype: mimetype,
+	}
+	dk.AppendPrefix(dataPrefix)
+
+	return dk
+}
+
+func toJobParams(req *spec.ScanRequest) (job.Parameters, error) {
+	errorf := errs.WithPrefix("")
+
+	jp := make(job.Parameters)
+	// The authorization is passed to the plugin as it is, so both basic and bearer are supported.
+	jp[ParamAuthorization] = req.Registry.Authorization
+	jp[ParamKeyImage] = oci.ImageRef(req.Registry.Url, req.Artifact.Repository, req.Artifact.Tag, req.Artifact.Digest)
+	jp[ParamRegistry] = req.Registry.Url
+
+	// Keep the artifact as JSON string to survive the job parameters serialization.
+	bytes, err := json.Marshal(req.Artifact)
+	if err != nil {
+		return nil, errorf.Wrap("marshal artifact error", err)
+	}
+	jp[ParamArtifact] = string(bytes)
+
+	return jp, nil
+}
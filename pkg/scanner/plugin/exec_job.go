@@ -0,0 +1,329 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// ExecJobName is the name of the exec plugin scan job.
+	ExecJobName = "EXEC_PLUGIN_SCAN"
+	concurrency = 10
+
+	// outputTail is the max size of the plugin output kept for the error message.
+	outputTail = 4096
+)
+
+// ErrUnsupported is returned if the plugin exits with ExitUnsupported.
+var ErrUnsupported = errors.New("artifact is not supported by the plugin")
+
+// AddToKnownList adds plugin.ExecRunner to the known list.
+func AddToKnownList(l *job.KnownList) error {
+	return l.AddKnownJob(ExecJobName, &ExecRunner{}, job.Concurrency(concurrency))
+}
+
+// ExecRunner of the exec plugin scan job.
+type ExecRunner struct{}
+
+// Run implements job.Runnable.
+func (r *ExecRunner) Run(ctx context.Context, parameters job.Parameters) (err error) {
+	// Skip parameter validation as the parameter should be validated in the API layer.
+
+	errorf := errs.WithPrefix("exec plugin scan job error")
+	resStore := store.Default()
+
+	// Extract key parameters.
+	name := parameters[ParamPlugin].(string)
+	reqID := parameters[ParamReqID].(string)
+	imagePath := parameters[ParamKeyImage].(string)
+
+	// Correlate the job logs with the scan request.
+	ctx = uuid.WithRequestID(ctx, reqID)
+	ctx = zlog.WithFields(ctx,
+		zlog.FieldProvider, name,
+		zlog.FieldJobID, parameters[ParamJobID],
+		zlog.FieldArtifact, imagePath,
+	)
+	lg := zlog.FromContext(ctx)
+
+	e, ok := lookupExec(name)
+	if !ok {
+		// The placeholders can not be updated without the produced mimetypes.
+		return errorf.Error("exec plugin %s is not enabled", name)
+	}
+	mimetypes := e.MimeTypes()
+
+	artifact := &spec.Artifact{}
+	if err := json.Unmarshal([]byte(parameters[ParamArtifact].(string)), artifact); err != nil {
+		return errorf.Wrap("unmarshal artifact error", err)
+	}
+
+	uk := fmt.Sprintf("%s:%s:%s", dataPrefix, name, imagePath)
+	if err := resStore.Unique(uk); err != nil {
+		return errorf.Wrap("last scan is still not finished yet, skip job run", err)
+	}
+
+	defer func() {
+		// Defer to de-unique.
+		if e := resStore.DeUnique(uk); e != nil {
+			// Just log.
+			lg.Error(e)
+		}
+
+		// Check if error is occurred.
+		if err != nil {
+			for _, m := range mimetypes {
+				if e := resStore.SaveResult(dataKey(name, reqID, m), &data.Item{
+					Timestamp: time.Now().UTC().Unix(),
+					Status:    data.Error,
+					Error:     err.Error(),
+				}); e != nil {
+					lg.Error(e)
+				}
+			}
+		}
+	}()
+
+	// Mark status to start.
+	for _, m := range mimetypes {
+		if err := resStore.SaveResult(dataKey(name, reqID, m), &data.Item{
+			Timestamp: time.Now().UTC().Unix(),
+			Status:    data.Ongoing,
+		}); err != nil {
+			// Just need to log
+			lg.Error(err)
+		}
+	}
+
+	lg.Info("exec plugin scan is started")
+
+	authorization, _ := parameters[ParamAuthorization].(string)
+	res, err := e.run(ctx, &Envelope{
+		Version:           ExecProtocolVersion,
+		RequestID:         reqID,
+		Artifact:          artifact,
+		Registry:          &EnvelopeRegistry{URL: parameters[ParamRegistry].(string)},
+		ProducesMimeTypes: mimetypes,
+		Parameters:        e.spec.Parameters,
+	}, authorization)
+	if err != nil {
+		return errorf.Wrap("run plugin error", err)
+	}
+
+	reports := make(map[string]json.RawMessage, len(res.Reports))
+	for _, rp := range res.Reports {
+		reports[rp.MimeType] = rp.Report
+	}
+
+	username, password := basicCredential(authorization)
+	options := oci.RemoteOptions{Username: username, Password: password}
+
+	// Save data.
+	for _, m := range mimetypes {
+		item := &data.Item{
+			Status:    data.Success,
+			Timestamp: time.Now().UTC().Unix(),
+		}
+
+		if rp, ok := reports[m]; ok && json.Valid(rp) {
+			item.JSON = string(rp)
+		} else {
+			item.Status = data.Error
+			item.Error = fmt.Sprintf("plugin %s did not produce a valid report of mimetype %s", name, m)
+		}
+
+		if err := resStore.SaveResult(dataKey(name, reqID, m), item); err != nil {
+			lg.Error(err)
+		}
+
+		// Push the report back to the registry along with the image.
+		if item.Status == data.Success {
+			scan.PushReport(ctx, parameters[ParamRegistry].(string), artifact, m, reports[m], options)
+		}
+	}
+
+	lg.Infow("exec plugin job is completed", "reports", len(res.Reports))
+
+	return nil
+}
+
+// run the plugin binary with the envelope and returns the result.
+// The credentials file and the output path of the envelope are prepared in a private work dir.
+func (e *Exec) run(ctx context.Context, env *Envelope, authorization string) (*Result, error) {
+	dir, err := ioutil.TempDir("", "scanner-plugin-")
+	if err != nil {
+		return nil, fmt.Errorf("create work dir error: %w", err)
+	}
+	defer func() {
+		_ = os.RemoveAll(dir)
+	}()
+
+	if authorization != "" {
+		env.Registry.CredentialsFile = filepath.Join(dir, "credentials.json")
+		if err := writeCredentials(env.Registry.CredentialsFile, authorization); err != nil {
+			return nil, err
+		}
+	}
+	env.OutputPath = filepath.Join(dir, "result.json")
+
+	input, err := json.Marshal(env)
+	if err != nil {
+		return nil, fmt.Errorf("marshal envelope error: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.spec.Timeout)
+	defer cancel()
+
+	// Write the plugin output to a file instead of a pipe, so the run is not blocked
+	// by the children of a killed plugin still holding the pipe.
+	output, err := os.Create(filepath.Join(dir, "output.log"))
+	if err != nil {
+		return nil, fmt.Errorf("create plugin output file error: %w", err)
+	}
+	defer func() {
+		_ = output.Close()
+	}()
+
+	cmd := exec.CommandContext(ctx, e.spec.Command, e.spec.Args...)
+	cmd.Env = append(os.Environ(), e.spec.Env...)
+	cmd.Dir = dir
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = output
+	cmd.Stderr = output
+
+	code := ExitOK
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("plugin %s is not completed in %s", e.spec.Name, e.spec.Timeout)
+		}
+
+		var ee *exec.ExitError
+		if !errors.As(err, &ee) {
+			return nil, fmt.Errorf("start plugin %s error: %w", e.spec.Name, err)
+		}
+		code = ee.ExitCode()
+	}
+
+	res := &Result{}
+	content, err := ioutil.ReadFile(env.OutputPath)
+	if err == nil {
+		err = json.Unmarshal(content, res)
+	}
+
+	switch code {
+	case ExitOK:
+		if err != nil {
+			return nil, fmt.Errorf("read plugin %s result error: %w", e.spec.Name, err)
+		}
+
+		return res, nil
+	case ExitUnsupported:
+		if res.Error != "" {
+			return nil, fmt.Errorf("%w: %s", ErrUnsupported, res.Error)
+		}
+
+		return nil, ErrUnsupported
+	default:
+		msg := res.Error
+		if msg == "" {
+			msg = strings.TrimSpace(tail(output, outputTail))
+		}
+
+		return nil, fmt.Errorf("plugin %s exited with code %d: %s", e.spec.Name, code, msg)
+	}
+}
+
+// writeCredentials writes the registry credentials to the file only readable by the current user.
+func writeCredentials(path string, authorization string) error {
+	creds := &Credentials{
+		Authorization: authorization,
+	}
+
+	// Decode the basic authorization for the convenience of the plugins.
+	creds.Username, creds.Password = basicCredential(authorization)
+
+	content, err := json.Marshal(creds)
+	if err != nil {
+		return fmt.Errorf("marshal credentials error: %w", err)
+	}
+
+	if err := ioutil.WriteFile(path, content, 0600); err != nil {
+		return fmt.Errorf("write credentials file error: %w", err)
+	}
+
+	return nil
+}
+
+// basicCredential decodes the username and password of the basic authorization.
+// Empty values are returned if it's not a basic authorization.
+func basicCredential(authorization string) (string, string) {
+	segments := strings.SplitN(authorization, " ", 2)
+	if len(segments) != 2 || segments[0] != "Basic" {
+		return "", ""
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(segments[1])
+	if err != nil {
+		return "", ""
+	}
+
+	up := strings.SplitN(string(decoded), ":", 2)
+	if len(up) != 2 {
+		return "", ""
+	}
+
+	return up[0], up[1]
+}
+
+// tail returns the last bytes of the file at most max.
+func tail(f *os.File, max int64) string {
+	fi, err := f.Stat()
+	if err != nil {
+		return ""
+	}
+
+	offset := fi.Size() - max
+	if offset < 0 {
+		offset = 0
+	}
+
+	buf := make([]byte, fi.Size()-offset)
+	n, _ := f.ReadAt(buf, offset)
+
+	return string(buf[:n])
+}
@@ -0,0 +1,170 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// defaultTimeout of a plugin run if not configured.
+	defaultTimeout = 10 * time.Minute
+)
+
+// Plugin is a scanner provider backed by a third-party scanner engine.
+// It implements scanner.Provider.
+type Plugin interface {
+	// Name of the plugin.
+	Name() string
+	// MimeTypes of the reports produced by the plugin.
+	MimeTypes() []string
+	// Metadata of the plugin scanner.
+	Metadata() *spec.ScannerAdapterMetadata
+	// AcceptScanRequest accepts the scan request.
+	AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error)
+	// RetrieveScanResult retrieves the scan result of the mimetype.
+	RetrieveScanResult(ctx context.Context, reqID string, mimetype string) (scan.Result, error)
+}
+
+var (
+	// loaded are the enabled plugins in order.
+	loaded []Plugin
+	// execs are the enabled exec plugins by name.
+	execs = make(map[string]*Exec)
+	// residents are the enabled resident plugins by name.
+	residents = make(map[string]*Resident)
+	lock      sync.RWMutex
+	// supervisors of the resident plugins.
+	supervisors sync.WaitGroup
+)
+
+// Load the enabled plugins declared under `scanner.plugins`.
+// The resident plugins are launched and supervised until the context is done.
+func Load(ctx context.Context) error {
+	errorf := errs.WithPrefix("load plugins error")
+
+	var specs []ExecSpec
+	if err := viper.UnmarshalKey("scanner.plugins.exec", &specs); err != nil {
+		return errorf.Wrap("parse exec plugins error", err)
+	}
+
+	var residentSpecs []ResidentSpec
+	if err := viper.UnmarshalKey("scanner.plugins.resident", &residentSpecs); err != nil {
+		return errorf.Wrap("parse resident plugins error", err)
+	}
+
+	var plugins []Plugin
+	execPlugins := make(map[string]*Exec)
+	residentPlugins := make(map[string]*Resident)
+	for _, s := range specs {
+		if !s.Enabled {
+			continue
+		}
+
+		if err := validate(s.Name, s.Metadata, s.Capabilities); err != nil {
+			return errorf.Wrap("invalid exec plugin", err)
+		}
+
+		if s.Command == "" {
+			return errorf.Error("invalid exec plugin: plugin %s: command is required", s.Name)
+		}
+
+		if _, ok := execPlugins[s.Name]; ok {
+			return errorf.Error("duplicated plugin: %s", s.Name)
+		}
+
+		if s.Timeout <= 0 {
+			s.Timeout = defaultTimeout
+		}
+
+		e := newExec(s)
+		execPlugins[s.Name] = e
+		plugins = append(plugins, e)
+	}
+
+	for _, s := range residentSpecs {
+		if !s.Enabled {
+			continue
+		}
+
+		if s.Name == "" || s.Command == "" {
+			return errorf.Error("invalid resident plugin: name and command are required")
+		}
+
+		if _, ok := execPlugins[s.Name]; ok {
+			return errorf.Error("duplicated plugin: %s", s.Name)
+		}
+		if _, ok := residentPlugins[s.Name]; ok {
+			return errorf.Error("duplicated plugin: %s", s.Name)
+		}
+
+		r := newResident(s)
+		// Launch the plugin and wait until it's ready to get the metadata.
+		if err := r.Start(ctx); err != nil {
+			return errorf.Wrap("start resident plugin error", err)
+		}
+
+		residentPlugins[s.Name] = r
+		plugins = append(plugins, r)
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	loaded = plugins
+	execs = execPlugins
+	residents = residentPlugins
+
+	return nil
+}
+
+// Loaded returns the enabled plugins.
+func Loaded() []Plugin {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	return append([]Plugin(nil), loaded...)
+}
+
+// Wait until the resident plugins are stopped after the context passed to Load is done.
+func Wait() {
+	supervisors.Wait()
+}
+
+// lookupExec returns the enabled exec plugin with the name.
+func lookupExec(name string) (*Exec, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	e, ok := execs[name]
+	return e, ok
+}
+
+// lookupResident returns the enabled resident plugin with the name.
+func lookupResident(name string) (*Resident, bool) {
+	lock.RLock()
+	defer lock.RUnlock()
+
+	r, ok := residents[name]
+	return r, ok
+}
@@ -0,0 +1,500 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/plugin/pluginpb"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	defaultConcurrency    = 4
+	defaultStartTimeout   = 30 * time.Second
+	defaultHealthInterval = 30 * time.Second
+	defaultBackoffInitial = time.Second
+	defaultBackoffMax     = time.Minute
+
+	// maxHealthFailures is the number of the successive failed health checks to restart the plugin.
+	maxHealthFailures = 3
+	// stopGrace is the duration to wait for the plugin to exit after interrupted.
+	stopGrace = 5 * time.Second
+)
+
+// Resident is the plugin process launched and supervised by the adapter.
+//
+// The adapter talks to the plugin with gRPC over a unix socket, see pluginpb/plugin.proto for the protocol. The plugin is
+// restarted with an exponential backoff once it exits or fails the health checks, and the scans
+// sent to the plugin at the same time are limited by the concurrency.
+type Resident struct {
+	spec  ResidentSpec
+	store store.Provider
+	// sem limits the concurrent scans.
+	sem chan struct{}
+	lg  *zap.SugaredLogger
+
+	lock     sync.Mutex
+	metadata *spec.ScannerAdapterMetadata
+	// proc is the running plugin process, nil if the plugin is restarting.
+	proc *process
+	// ready is closed once the proc is available.
+	ready chan struct{}
+}
+
+// process of the plugin.
+type process struct {
+	cmd     *exec.Cmd
+	conn    *grpc.ClientConn
+	client  pluginpb.PluginClient
+	dir     string
+	started time.Time
+	// done is closed once the process exits with the err.
+	done chan struct{}
+	err  error
+}
+
+func newResident(s ResidentSpec) *Resident {
+	if s.Concurrency <= 0 {
+		s.Concurrency = defaultConcurrency
+	}
+	if s.Timeout <= 0 {
+		s.Timeout = defaultTimeout
+	}
+	if s.StartTimeout <= 0 {
+		s.StartTimeout = defaultStartTimeout
+	}
+	if s.HealthInterval <= 0 {
+		s.HealthInterval = defaultHealthInterval
+	}
+	if s.Backoff.Initial <= 0 {
+		s.Backoff.Initial = defaultBackoffInitial
+	}
+	if s.Backoff.Max < s.Backoff.Initial {
+		s.Backoff.Max = defaultBackoffMax
+		if s.Backoff.Max < s.Backoff.Initial {
+			s.Backoff.Max = s.Backoff.Initial
+		}
+	}
+
+	return &Resident{
+		spec:  s,
+		store: store.Default(),
+		sem:   make(chan struct{}, s.Concurrency),
+		lg:    zlog.Logger().With(zlog.FieldProvider, s.Name),
+		ready: make(chan struct{}),
+	}
+}
+
+// Metadata implements Plugin.
+// It's the metadata reported by the plugin in the last handshake.
+func (r *Resident) Metadata() *spec.ScannerAdapterMetadata {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	md := *r.metadata
+	props := make(map[string]string)
+	if md.Properties != nil {
+		for k, v := range *md.Properties {
+			props[k] = v
+		}
+	}
+	md.Properties = &props
+
+	return &md
+}
+
+// capabilities of the plugin from the metadata.
+func (r *Resident) capabilities() []Capability {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	var res []Capability
+	for _, c := range r.metadata.Capabilities {
+		res = append(res, Capability{
+			Type:              c.Type,
+			ConsumesMimeTypes: c.ConsumesMimeTypes,
+			ProducesMimeTypes: c.ProducesMimeTypes,
+		})
+	}
+
+	return res
+}
+
+// Start launches the plugin and supervises it in background until the context is done.
+// An error is returned if the plugin is not ready in the start timeout.
+func (r *Resident) Start(ctx context.Context) error {
+	p, err := r.launch(ctx)
+	if err != nil {
+		return err
+	}
+
+	supervisors.Add(1)
+	go func() {
+		defer supervisors.Done()
+		r.supervise(ctx, p)
+	}()
+
+	return nil
+}
+
+// supervise the running process and restarts it when it's down.
+func (r *Resident) supervise(ctx context.Context, p *process) {
+	backoff := r.spec.Backoff.Initial
+	for {
+		r.setProcess(p)
+		err := r.watch(ctx, p)
+		r.setProcess(nil)
+		r.stop(p)
+
+		if ctx.Err() != nil {
+			r.lg.Info("resident plugin is stopped")
+			return
+		}
+
+		// Reset the backoff if the plugin has run long enough.
+		if time.Since(p.started) > r.spec.Backoff.Max {
+			backoff = r.spec.Backoff.Initial
+		}
+
+		r.lg.Warnw("resident plugin is down", "error", err)
+
+		for {
+			r.lg.Infow("restarting resident plugin", "backoff", backoff)
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+
+			if backoff *= 2; backoff > r.spec.Backoff.Max {
+				backoff = r.spec.Backoff.Max
+			}
+
+			if p, err = r.launch(ctx); err == nil {
+				break
+			}
+
+			r.lg.Errorw("restart resident plugin error", "error", err)
+		}
+	}
+}
+
+// watch the process until it exits, fails the health checks or the context is done.
+func (r *Resident) watch(ctx context.Context, p *process) error {
+	ticker := time.NewTicker(r.spec.HealthInterval)
+	defer ticker.Stop()
+
+	failures := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-p.done:
+			return fmt.Errorf("plugin process exited: %v", p.err)
+		case <-ticker.C:
+			if err := r.health(ctx, p); err != nil {
+				failures++
+				r.lg.Warnw("resident plugin health check failed", "error", err, "failures", failures)
+
+				if failures >= maxHealthFailures {
+					return fmt.Errorf("plugin is unhealthy: %w", err)
+				}
+				continue
+			}
+
+			failures = 0
+		}
+	}
+}
+
+// health checks the plugin health.
+func (r *Resident) health(ctx context.Context, p *process) error {
+	ctx, cancel := context.WithTimeout(ctx, r.spec.HealthInterval)
+	defer cancel()
+
+	res, err := p.client.Health(ctx, &pluginpb.HealthRequest{})
+	if err != nil {
+		return err
+	}
+
+	if !res.GetHealthy() {
+		return fmt.Errorf("unhealthy: %s", res.GetMessage())
+	}
+
+	return nil
+}
+
+// launch the plugin process, connects to it and completes the handshake.
+func (r *Resident) launch(ctx context.Context) (*process, error) {
+	dir, err := ioutil.TempDir("", "scanner-plugin-")
+	if err != nil {
+		return nil, fmt.Errorf("create work dir error: %w", err)
+	}
+	socket := filepath.Join(dir, "plugin.sock")
+
+	// Forward the plugin output to the logs.
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("create output pipe error: %w", err)
+	}
+
+	cmd := exec.Command(r.spec.Command, r.spec.Args...)
+	cmd.Env = append(os.Environ(), r.spec.Env...)
+	cmd.Env = append(cmd.Env,
+		fmt.Sprintf("%s=%s", EnvSocket, socket),
+		fmt.Sprintf("%s=%s", EnvProtocolVersion, ResidentProtocolVersion),
+	)
+	cmd.Dir = dir
+	cmd.Stdout = pw
+	cmd.Stderr = pw
+
+	err = cmd.Start()
+	// The write end is held by the plugin now.
+	_ = pw.Close()
+	if err != nil {
+		_ = pr.Close()
+		_ = os.RemoveAll(dir)
+		return nil, fmt.Errorf("start plugin error: %w", err)
+	}
+
+	go func() {
+		defer func() {
+			_ = pr.Close()
+		}()
+
+		scanner := bufio.NewScanner(pr)
+		for scanner.Scan() {
+			r.lg.Infow("resident plugin output", "line", scanner.Text())
+		}
+	}()
+
+	p := &process{
+		cmd:     cmd,
+		dir:     dir,
+		started: time.Now(),
+		done:    make(chan struct{}),
+	}
+	go func() {
+		p.err = cmd.Wait()
+		close(p.done)
+	}()
+
+	ctx, cancel := context.WithTimeout(ctx, r.spec.StartTimeout)
+	defer cancel()
+
+	if err := r.connect(ctx, p, socket); err != nil {
+		r.stop(p)
+		return nil, err
+	}
+
+	r.lg.Infow("resident plugin is started", "pid", cmd.Process.Pid)
+
+	return p, nil
+}
+
+// connect to the plugin socket, then completes the handshake and refreshes the metadata.
+func (r *Resident) connect(ctx context.Context, p *process, socket string) error {
+	conn, err := grpc.DialContext(ctx, "unix:"+socket, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return fmt.Errorf("connect plugin error: %w", err)
+	}
+	p.conn = conn
+	p.client = pluginpb.NewPluginClient(conn)
+
+	// Give up waiting for the socket once the process exits.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-p.done:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	// Wait for the plugin listening on the socket.
+	hs, err := p.client.Handshake(ctx, &pluginpb.HandshakeRequest{ProtocolVersion: ResidentProtocolVersion}, grpc.WaitForReady(true))
+	if err != nil {
+		select {
+		case <-p.done:
+			return fmt.Errorf("plugin process exited during start: %v", p.err)
+		default:
+		}
+
+		return fmt.Errorf("handshake error: %w", err)
+	}
+
+	if hs.GetProtocolVersion() != ResidentProtocolVersion {
+		return fmt.Errorf("handshake error: unsupported protocol version %q, expect %q", hs.GetProtocolVersion(), ResidentProtocolVersion)
+	}
+
+	res, err := p.client.Metadata(ctx, &pluginpb.MetadataRequest{})
+	if err != nil {
+		return fmt.Errorf("get plugin metadata error: %w", err)
+	}
+	md := metadataFromProto(res)
+
+	if md.Scanner == nil {
+		return fmt.Errorf("invalid plugin metadata: missing scanner")
+	}
+
+	var capabilities []Capability
+	for _, c := range md.Capabilities {
+		capabilities = append(capabilities, Capability{
+			Type:              c.Type,
+			ConsumesMimeTypes: c.ConsumesMimeTypes,
+			ProducesMimeTypes: c.ProducesMimeTypes,
+		})
+	}
+
+	if err := validate(r.spec.Name, Metadata{Name: md.Scanner.Name, Version: md.Scanner.Version}, capabilities); err != nil {
+		return fmt.Errorf("invalid plugin metadata: %w", err)
+	}
+
+	r.lock.Lock()
+	r.metadata = md
+	r.lock.Unlock()
+
+	return nil
+}
+
+// stop the plugin process gracefully and cleans up.
+func (r *Resident) stop(p *process) {
+	if p.conn != nil {
+		_ = p.conn.Close()
+	}
+
+	select {
+	case <-p.done:
+	default:
+		_ = p.cmd.Process.Signal(os.Interrupt)
+
+		select {
+		case <-p.done:
+		case <-time.After(stopGrace):
+			_ = p.cmd.Process.Kill()
+			<-p.done
+		}
+	}
+
+	_ = os.RemoveAll(p.dir)
+}
+
+// setProcess sets the running process, nil means the plugin is down.
+func (r *Resident) setProcess(p *process) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.proc = p
+	if p != nil {
+		close(r.ready)
+	} else {
+		r.ready = make(chan struct{})
+	}
+}
+
+// client returns the gRPC client of the running process, it waits if the plugin is restarting.
+func (r *Resident) client(ctx context.Context) (pluginpb.PluginClient, error) {
+	for {
+		r.lock.Lock()
+		p, ready := r.proc, r.ready
+		r.lock.Unlock()
+
+		if p != nil {
+			return p.client, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("plugin %s is not ready: %w", r.spec.Name, ctx.Err())
+		case <-ready:
+		}
+	}
+}
+
+// scan runs the scan task on the plugin.
+func (r *Resident) scan(ctx context.Context, t *task) (*Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, r.spec.Timeout)
+	defer cancel()
+
+	// Limit the concurrent scans.
+	select {
+	case r.sem <- struct{}{}:
+		defer func() {
+			<-r.sem
+		}()
+	case <-ctx.Done():
+		return nil, fmt.Errorf("wait for plugin %s concurrency slot error: %w", r.spec.Name, ctx.Err())
+	}
+
+	client, err := r.client(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	params, err := structpb.NewStruct(r.spec.Parameters)
+	if err != nil {
+		return nil, fmt.Errorf("convert plugin %s parameters error: %w", r.spec.Name, err)
+	}
+
+	res, err := client.Scan(ctx, &pluginpb.ScanRequest{
+		RequestId: t.reqID,
+		Artifact: &pluginpb.Artifact{
+			Repository: t.artifact.Repository,
+			Digest:     t.artifact.Digest,
+			Tag:        t.artifact.Tag,
+			MimeType:   t.artifact.MimeType,
+		},
+		Registry: &pluginpb.Registry{
+			Url:           t.registry,
+			Authorization: t.authorization,
+		},
+		ProducesMimeTypes: t.mimetypes,
+		Parameters:        params,
+	})
+	if err != nil {
+		if st, ok := status.FromError(err); ok && st.Code() == codes.FailedPrecondition {
+			if st.Message() != "" {
+				return nil, fmt.Errorf("%w: %s", ErrUnsupported, st.Message())
+			}
+
+			return nil, ErrUnsupported
+		}
+
+		return nil, fmt.Errorf("plugin %s scan error: %w", r.spec.Name, err)
+	}
+
+	return &Result{Reports: reportsFromProto(res)}, nil
+}
@@ -0,0 +1,176 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// ResidentJobName is the name of the resident plugin scan job.
+	ResidentJobName = "RESIDENT_PLUGIN_SCAN"
+)
+
+// task is the scan task run by the resident plugin.
+type task struct {
+	reqID         string
+	artifact      *spec.Artifact
+	registry      string
+	authorization string
+	mimetypes     []string
+}
+
+// AddResidentToKnownList adds plugin.ResidentRunner to the known list.
+func AddResidentToKnownList(l *job.KnownList) error {
+	return l.AddKnownJob(ResidentJobName, &ResidentRunner{}, job.Concurrency(concurrency))
+}
+
+// ResidentRunner of the resident plugin scan job.
+type ResidentRunner struct{}
+
+// Run implements job.Runnable.
+func (r *ResidentRunner) Run(ctx context.Context, parameters job.Parameters) (err error) {
+	// Skip parameter validation as the parameter should be validated in the API layer.
+
+	errorf := errs.WithPrefix("resident plugin scan job error")
+	resStore := store.Default()
+
+	// Extract key parameters.
+	name := parameters[ParamPlugin].(string)
+	reqID := parameters[ParamReqID].(string)
+	imagePath := parameters[ParamKeyImage].(string)
+
+	// Correlate the job logs with the scan request.
+	ctx = uuid.WithRequestID(ctx, reqID)
+	ctx = zlog.WithFields(ctx,
+		zlog.FieldProvider, name,
+		zlog.FieldJobID, parameters[ParamJobID],
+		zlog.FieldArtifact, imagePath,
+	)
+	lg := zlog.FromContext(ctx)
+
+	p, ok := lookupResident(name)
+	if !ok {
+		// The placeholders can not be updated without the produced mimetypes.
+		return errorf.Error("resident plugin %s is not enabled", name)
+	}
+	mimetypes := p.MimeTypes()
+
+	artifact := &spec.Artifact{}
+	if err := json.Unmarshal([]byte(parameters[ParamArtifact].(string)), artifact); err != nil {
+		return errorf.Wrap("unmarshal artifact error", err)
+	}
+
+	uk := fmt.Sprintf("%s:%s:%s", dataPrefix, name, imagePath)
+	if err := resStore.Unique(uk); err != nil {
+		return errorf.Wrap("last scan is still not finished yet, skip job run", err)
+	}
+
+	defer func() {
+		// Defer to de-unique.
+		if e := resStore.DeUnique(uk); e != nil {
+			// Just log.
+			lg.Error(e)
+		}
+
+		// Check if error is occurred.
+		if err != nil {
+			for _, m := range mimetypes {
+				if e := resStore.SaveResult(dataKey(name, reqID, m), &data.Item{
+					Timestamp: time.Now().UTC().Unix(),
+					Status:    data.Error,
+					Error:     err.Error(),
+				}); e != nil {
+					lg.Error(e)
+				}
+			}
+		}
+	}()
+
+	// Mark status to start.
+	for _, m := range mimetypes {
+		if err := resStore.SaveResult(dataKey(name, reqID, m), &data.Item{
+			Timestamp: time.Now().UTC().Unix(),
+			Status:    data.Ongoing,
+		}); err != nil {
+			// Just need to log
+			lg.Error(err)
+		}
+	}
+
+	lg.Info("resident plugin scan is started")
+
+	authorization, _ := parameters[ParamAuthorization].(string)
+	res, err := p.scan(ctx, &task{
+		reqID:         reqID,
+		artifact:      artifact,
+		registry:      parameters[ParamRegistry].(string),
+		authorization: authorization,
+		mimetypes:     mimetypes,
+	})
+	if err != nil {
+		return errorf.Wrap("run plugin error", err)
+	}
+
+	reports := make(map[string]json.RawMessage, len(res.Reports))
+	for _, rp := range res.Reports {
+		reports[rp.MimeType] = rp.Report
+	}
+
+	username, password := basicCredential(authorization)
+	options := oci.RemoteOptions{Username: username, Password: password}
+
+	// Save data.
+	for _, m := range mimetypes {
+		item := &data.Item{
+			Status:    data.Success,
+			Timestamp: time.Now().UTC().Unix(),
+		}
+
+		if rp, ok := reports[m]; ok && json.Valid(rp) {
+			item.JSON = string(rp)
+		} else {
+			item.Status = data.Error
+			item.Error = fmt.Sprintf("plugin %s did not produce a valid report of mimetype %s", name, m)
+		}
+
+		if err := resStore.SaveResult(dataKey(name, reqID, m), item); err != nil {
+			lg.Error(err)
+		}
+
+		// Push the report back to the registry along with the image.
+		if item.Status == data.Success {
+			scan.PushReport(ctx, parameters[ParamRegistry].(string), artifact, m, reports[m], options)
+		}
+	}
+
+	lg.Infow("resident plugin scan job is completed", "reports", len(res.Reports))
+
+	return nil
+}
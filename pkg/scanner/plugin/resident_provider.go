@@ -0,0 +1,123 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/szlabs/goworker/pkg/errs"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/client"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// Name implements Plugin.
+func (r *Resident) Name() string {
+	return r.spec.Name
+}
+
+// MimeTypes implements Plugin.
+func (r *Resident) MimeTypes() []string {
+	return producedMimeTypes(r.capabilities())
+}
+
+// AcceptScanRequest implements Plugin.
+func (r *Resident) AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error) {
+	errorf := errs.WithPrefix("accept scan request error")
+
+	// Extract request ID first.
+	reqID := uuid.FromContext(ctx)
+	if reqID == "" {
+		return nil, errorf.Error("missing request ID in the context")
+	}
+
+	if req == nil || req.Registry == nil || req.Artifact == nil {
+		return nil, errorf.Error("registry and artifact are required in the scan request")
+	}
+
+	if !consumes(r.capabilities(), req.Artifact.MimeType) {
+		return nil, errorf.Error("plugin %s does not support mimetype: %s", r.spec.Name, req.Artifact.MimeType)
+	}
+
+	// Convert job parameters.
+	jp, err := toJobParams(req)
+	if err != nil {
+		return nil, errorf.Wrap("parse job parameters error", err)
+	}
+
+	jobID := uuid.Random()
+	jp[ParamPlugin] = r.spec.Name
+	jp[ParamReqID] = reqID
+	jp[ParamJobID] = jobID
+
+	ctx = zlog.WithFields(ctx,
+		zlog.FieldProvider, r.spec.Name,
+		zlog.FieldJobID, jobID,
+		zlog.FieldArtifact, jp[ParamKeyImage],
+	)
+	lg := zlog.FromContext(ctx)
+
+	// Enqueue scan job.
+	enq, err := client.Enqueuer()
+	if err != nil {
+		return nil, errorf.Wrap("get job enqueuer error", err)
+	}
+
+	j, err := enq.EnqueueUnique(ResidentJobName, jp)
+	if err != nil {
+		return nil, errorf.Wrap("enqueue resident plugin scan job error", err)
+	}
+
+	// Log the backend job info for potential debug.
+	lg.Infow("Resident plugin scan job is enqueued", "job", j.Name, "id", j.ID)
+
+	// Create result placeholders and set the status to pending.
+	for _, m := range r.MimeTypes() {
+		if err := r.store.SaveResult(dataKey(r.spec.Name, reqID, m), &data.Item{
+			Timestamp: time.Now().UTC().Unix(),
+			Status:    data.Pending,
+		}); err != nil {
+			// Not a panic case, just logged it.
+			// Once the scan job is started, it can be recovered again.
+			lg.Errorw("save result placeholder failed", "error", err, "mimetype", m)
+		}
+	}
+
+	return &spec.ScanResponse{
+		Id: reqID,
+	}, nil
+}
+
+// RetrieveScanResult implements Plugin.
+func (r *Resident) RetrieveScanResult(_ context.Context, reqID string, mimetype string) (scan.Result, error) {
+	errorf := errs.WithPrefix("retrieve scan request error")
+
+	dt, err := r.store.GetResult(dataKey(r.spec.Name, reqID, mimetype))
+	if err != nil {
+		if errors.Is(err, rds.NotFoundErr) {
+			return scan.ResultOf(mimetype, nil)
+		}
+		return nil, errorf.Wrap("store get result error", err)
+	}
+
+	return scan.ResultOf(mimetype, dt)
+}
@@ -0,0 +1,277 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/plugin/pluginpb"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// The resident plugins serve the gRPC service harbor.scanner.plugin.v1.Plugin defined in
+// pluginpb/plugin.proto on the unix socket passed by the adapter in the EnvSocket environment
+// variable. The plugin can be written in any language with the stubs generated from the proto,
+// the methods mirror scanner.Provider:
+//
+//	Handshake(HandshakeRequest) HandshakeResponse
+//	Metadata(MetadataRequest) MetadataResponse
+//	Scan(ScanRequest) ScanResponse
+//	Health(HealthRequest) HealthResponse
+//
+// The plugins written in Go can implement Server and call Serve.
+
+const (
+	// ResidentProtocolVersion is the version of the resident plugin protocol.
+	// It's the version of the proto package as well.
+	ResidentProtocolVersion = "v1"
+	// EnvSocket is the environment variable of the unix socket path the plugin should listen on.
+	EnvSocket = "SCANNER_PLUGIN_SOCKET"
+	// EnvProtocolVersion is the environment variable of the protocol version used by the adapter.
+	EnvProtocolVersion = "SCANNER_PLUGIN_PROTOCOL_VERSION"
+)
+
+// ScanRequest of the resident plugin.
+type ScanRequest struct {
+	// RequestID is the scan request ID.
+	RequestID string
+	// Artifact to scan.
+	Artifact *spec.Artifact
+	// Registry hosting the artifact. The authorization is sent inline as the socket is private.
+	Registry *spec.Registry
+	// ProducesMimeTypes are the report mime types expected.
+	ProducesMimeTypes []string
+	// Parameters declared in the plugin configuration.
+	Parameters map[string]interface{}
+}
+
+// ScanResponse of the resident plugin.
+type ScanResponse struct {
+	// Reports of the produced mime types.
+	Reports []ResultReport
+	// Unsupported is set if the artifact is not supported by the plugin.
+	Unsupported bool
+	// Error message if the scan is failed.
+	Error string
+}
+
+// Server is implemented by the resident plugins written in Go.
+type Server interface {
+	// Metadata of the plugin scanner.
+	Metadata(ctx context.Context) (*spec.ScannerAdapterMetadata, error)
+	// Scan the artifact and returns the reports.
+	Scan(ctx context.Context, req *ScanRequest) (*ScanResponse, error)
+	// Health checks whether the plugin is able to scan.
+	Health(ctx context.Context) error
+}
+
+// Serve the plugin on the unix socket passed by the adapter.
+// It blocks until the listener is failed.
+func Serve(server Server) error {
+	socket := os.Getenv(EnvSocket)
+	if socket == "" {
+		return fmt.Errorf("missing environment variable %s, the plugin should be launched by the adapter", EnvSocket)
+	}
+
+	if v := os.Getenv(EnvProtocolVersion); v != ResidentProtocolVersion {
+		return fmt.Errorf("unsupported protocol version %q, expect %q", v, ResidentProtocolVersion)
+	}
+
+	l, err := net.Listen("unix", socket)
+	if err != nil {
+		return fmt.Errorf("listen on %s error: %w", socket, err)
+	}
+
+	gs := grpc.NewServer()
+	pluginpb.RegisterPluginServer(gs, &service{server: server})
+
+	if err := gs.Serve(l); err != nil {
+		return fmt.Errorf("serve plugin error: %w", err)
+	}
+
+	return nil
+}
+
+// service adapts Server to the gRPC service.
+type service struct {
+	pluginpb.UnimplementedPluginServer
+	server Server
+}
+
+// Handshake checks the protocol version.
+func (s *service) Handshake(_ context.Context, req *pluginpb.HandshakeRequest) (*pluginpb.HandshakeResponse, error) {
+	if req.GetProtocolVersion() != ResidentProtocolVersion {
+		return nil, status.Errorf(codes.FailedPrecondition, "unsupported protocol version %q, expect %q", req.GetProtocolVersion(), ResidentProtocolVersion)
+	}
+
+	return &pluginpb.HandshakeResponse{ProtocolVersion: ResidentProtocolVersion}, nil
+}
+
+// Metadata returns the plugin metadata.
+func (s *service) Metadata(ctx context.Context, _ *pluginpb.MetadataRequest) (*pluginpb.MetadataResponse, error) {
+	md, err := s.server.Metadata(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if md == nil {
+		return nil, errors.New("nil metadata")
+	}
+
+	return metadataToProto(md), nil
+}
+
+// Scan scans the artifact.
+// The unsupported artifact is reported with the status FAILED_PRECONDITION.
+func (s *service) Scan(ctx context.Context, req *pluginpb.ScanRequest) (*pluginpb.ScanResponse, error) {
+	sr, err := s.server.Scan(ctx, scanRequestFromProto(req))
+	if err != nil {
+		return nil, err
+	}
+
+	res := &pluginpb.ScanResponse{}
+	if sr == nil {
+		return res, nil
+	}
+
+	if sr.Unsupported {
+		return nil, status.Error(codes.FailedPrecondition, sr.Error)
+	}
+
+	if sr.Error != "" {
+		return nil, status.Error(codes.Unknown, sr.Error)
+	}
+
+	for _, rp := range sr.Reports {
+		res.Reports = append(res.Reports, &pluginpb.Report{
+			MimeType: rp.MimeType,
+			Report:   rp.Report,
+		})
+	}
+
+	return res, nil
+}
+
+// Health checks the plugin health.
+func (s *service) Health(ctx context.Context, _ *pluginpb.HealthRequest) (*pluginpb.HealthResponse, error) {
+	if err := s.server.Health(ctx); err != nil {
+		return &pluginpb.HealthResponse{Message: err.Error()}, nil
+	}
+
+	return &pluginpb.HealthResponse{Healthy: true}, nil
+}
+
+// metadataToProto converts the scanner metadata to the message.
+func metadataToProto(md *spec.ScannerAdapterMetadata) *pluginpb.MetadataResponse {
+	res := &pluginpb.MetadataResponse{}
+	if md.Scanner != nil {
+		res.Scanner = &pluginpb.Scanner{
+			Name:    md.Scanner.Name,
+			Vendor:  md.Scanner.Vendor,
+			Version: md.Scanner.Version,
+		}
+	}
+
+	for _, c := range md.Capabilities {
+		res.Capabilities = append(res.Capabilities, &pluginpb.Capability{
+			Type:              c.Type,
+			ConsumesMimeTypes: c.ConsumesMimeTypes,
+			ProducesMimeTypes: c.ProducesMimeTypes,
+		})
+	}
+
+	if md.Properties != nil {
+		res.Properties = *md.Properties
+	}
+
+	return res
+}
+
+// metadataFromProto converts the message to the scanner metadata.
+func metadataFromProto(res *pluginpb.MetadataResponse) *spec.ScannerAdapterMetadata {
+	md := &spec.ScannerAdapterMetadata{}
+	if s := res.GetScanner(); s != nil {
+		md.Scanner = &spec.Scanner{
+			Name:    s.GetName(),
+			Vendor:  s.GetVendor(),
+			Version: s.GetVersion(),
+		}
+	}
+
+	for _, c := range res.GetCapabilities() {
+		md.Capabilities = append(md.Capabilities, spec.ScannerCapability{
+			Type:              c.GetType(),
+			ConsumesMimeTypes: c.GetConsumesMimeTypes(),
+			ProducesMimeTypes: c.GetProducesMimeTypes(),
+		})
+	}
+
+	props := make(map[string]string, len(res.GetProperties()))
+	for k, v := range res.GetProperties() {
+		props[k] = v
+	}
+	md.Properties = &props
+
+	return md
+}
+
+// scanRequestFromProto converts the message to the scan request.
+func scanRequestFromProto(req *pluginpb.ScanRequest) *ScanRequest {
+	sr := &ScanRequest{
+		RequestID:         req.GetRequestId(),
+		ProducesMimeTypes: req.GetProducesMimeTypes(),
+		Parameters:        req.GetParameters().AsMap(),
+	}
+
+	if a := req.GetArtifact(); a != nil {
+		sr.Artifact = &spec.Artifact{
+			Repository: a.GetRepository(),
+			Digest:     a.GetDigest(),
+			Tag:        a.GetTag(),
+			MimeType:   a.GetMimeType(),
+		}
+	}
+
+	if r := req.GetRegistry(); r != nil {
+		sr.Registry = &spec.Registry{
+			Url:           r.GetUrl(),
+			Authorization: r.GetAuthorization(),
+		}
+	}
+
+	return sr
+}
+
+// reportsFromProto converts the reports of the message.
+func reportsFromProto(res *pluginpb.ScanResponse) []ResultReport {
+	var reports []ResultReport
+	for _, rp := range res.GetReports() {
+		reports = append(reports, ResultReport{
+			MimeType: rp.GetMimeType(),
+			Report:   json.RawMessage(rp.GetReport()),
+		})
+	}
+
+	return reports
+}
@@ -0,0 +1,167 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// Metadata of the plugin scanner declared in the configuration.
+type Metadata struct {
+	Name       string            `mapstructure:"name"`
+	Vendor     string            `mapstructure:"vendor"`
+	Version    string            `mapstructure:"version"`
+	Properties map[string]string `mapstructure:"properties"`
+}
+
+// Capability of the plugin declared in the configuration.
+type Capability struct {
+	// Type of the capability, e.g: vulnerability or sbom.
+	Type              string   `mapstructure:"type"`
+	ConsumesMimeTypes []string `mapstructure:"consumesMimeTypes"`
+	ProducesMimeTypes []string `mapstructure:"producesMimeTypes"`
+}
+
+// ExecSpec declares an exec plugin under `scanner.plugins.exec`.
+type ExecSpec struct {
+	// Name of the plugin, it's also the provider name.
+	Name    string `mapstructure:"name"`
+	Enabled bool   `mapstructure:"enabled"`
+	// Command is the path of the plugin binary.
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	// Env are the extra environment variables in the KEY=VALUE format.
+	Env []string `mapstructure:"env"`
+	// Timeout of each plugin run.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// Parameters are passed to the plugin in the job envelope as they are.
+	Parameters   map[string]interface{} `mapstructure:"parameters"`
+	Metadata     Metadata               `mapstructure:"metadata"`
+	Capabilities []Capability           `mapstructure:"capabilities"`
+}
+
+// validate the plugin declaration.
+func validate(name string, md Metadata, capabilities []Capability) error {
+	if name == "" {
+		return fmt.Errorf("plugin name is required")
+	}
+
+	if md.Name == "" || md.Version == "" {
+		return fmt.Errorf("plugin %s: metadata name and version are required", name)
+	}
+
+	if len(capabilities) == 0 {
+		return fmt.Errorf("plugin %s: at least one capability is required", name)
+	}
+
+	for _, c := range capabilities {
+		if len(c.ConsumesMimeTypes) == 0 || len(c.ProducesMimeTypes) == 0 {
+			return fmt.Errorf("plugin %s: consumed and produced mime types are required in the capability", name)
+		}
+	}
+
+	return nil
+}
+
+// metadataOf converts the declaration to the scanner adapter metadata.
+func metadataOf(md Metadata, capabilities []Capability) *spec.ScannerAdapterMetadata {
+	props := make(map[string]string, len(md.Properties))
+	for k, v := range md.Properties {
+		props[k] = v
+	}
+
+	res := &spec.ScannerAdapterMetadata{
+		Scanner: &spec.Scanner{
+			Name:    md.Name,
+			Vendor:  md.Vendor,
+			Version: md.Version,
+		},
+		Properties: &props,
+	}
+
+	for _, c := range capabilities {
+		res.Capabilities = append(res.Capabilities, spec.ScannerCapability{
+			Type:              c.Type,
+			ConsumesMimeTypes: c.ConsumesMimeTypes,
+			ProducesMimeTypes: c.ProducesMimeTypes,
+		})
+	}
+
+	return res
+}
+
+// producedMimeTypes returns the distinct produced mime types of the capabilities.
+func producedMimeTypes(capabilities []Capability) []string {
+	seen := make(map[string]bool)
+	var res []string
+	for _, c := range capabilities {
+		for _, m := range c.ProducesMimeTypes {
+			if !seen[m] {
+				seen[m] = true
+				res = append(res, m)
+			}
+		}
+	}
+
+	return res
+}
+
+// consumes checks whether the capabilities consume the artifact mime type.
+func consumes(capabilities []Capability, mimetype string) bool {
+	for _, c := range capabilities {
+		for _, m := range c.ConsumesMimeTypes {
+			if m == mimetype {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Backoff of restarting the resident plugin.
+type Backoff struct {
+	// Initial delay of the first restart, doubled on each failure.
+	Initial time.Duration `mapstructure:"initial"`
+	// Max delay of the restarts.
+	Max time.Duration `mapstructure:"max"`
+}
+
+// ResidentSpec declares a resident plugin under `scanner.plugins.resident`.
+// The metadata and capabilities are provided by the plugin itself.
+type ResidentSpec struct {
+	// Name of the plugin, it's also the provider name.
+	Name    string `mapstructure:"name"`
+	Enabled bool   `mapstructure:"enabled"`
+	// Command is the path of the plugin binary.
+	Command string   `mapstructure:"command"`
+	Args    []string `mapstructure:"args"`
+	// Env are the extra environment variables in the KEY=VALUE format.
+	Env []string `mapstructure:"env"`
+	// Concurrency is the max number of the scans run by the plugin at the same time.
+	Concurrency int `mapstructure:"concurrency"`
+	// Timeout of each scan.
+	Timeout time.Duration `mapstructure:"timeout"`
+	// StartTimeout is the max duration from launching the plugin to the handshake completed.
+	StartTimeout time.Duration `mapstructure:"startTimeout"`
+	// HealthInterval is the interval of the health checks.
+	HealthInterval time.Duration `mapstructure:"healthInterval"`
+	Backoff        Backoff       `mapstructure:"backoff"`
+	// Parameters are passed to the plugin in the scan request as they are.
+	Parameters map[string]interface{} `mapstructure:"parameters"`
+}
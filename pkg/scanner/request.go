@@ -0,0 +1,93 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// keepRequest keeps the scan request for the wrappers processing the reports when they're retrieved.
+// The failure is only logged as the artifact in the report is used instead.
+func keepRequest(ctx context.Context, req *spec.ScanRequest) {
+	bytes, err := json.Marshal(req)
+	if err == nil {
+		err = store.Default().SaveResult(requestKey(uuid.FromContext(ctx)), &data.Item{
+			Status:    data.Success,
+			JSON:      string(bytes),
+			Timestamp: time.Now().UTC().Unix(),
+		})
+	}
+
+	if err != nil {
+		zlog.FromContext(ctx).Warnw("keep scan request error", "error", err)
+	}
+}
+
+// keptRequest returns the scan request kept by keepRequest.
+// An empty request is returned if it's not found.
+func keptRequest(reqID string) (*spec.ScanRequest, error) {
+	req := &spec.ScanRequest{}
+
+	item, err := store.Default().GetResult(requestKey(reqID))
+	if err != nil || item.JSON == "" {
+		return req, nil
+	}
+
+	if err := json.Unmarshal([]byte(item.JSON), req); err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// requestKey is the data key of the kept scan request.
+func requestKey(reqID string) *data.Key {
+	return (&data.Key{
+		Provider: "adapter",
+		ReqID:    reqID,
+		Mimetype: "scan_request",
+	}).AppendPrefix("{request-store}")
+}
+
+// artifactOf merges the artifact of the scan request with the artifact in the report,
+// the scan request is preferred.
+func artifactOf(requested *spec.Artifact, reported *spec.Artifact) spec.Artifact {
+	res := spec.Artifact{}
+	for _, a := range []*spec.Artifact{reported, requested} {
+		if a == nil {
+			continue
+		}
+
+		if a.Repository != "" {
+			res.Repository = a.Repository
+		}
+		if a.Tag != "" {
+			res.Tag = a.Tag
+		}
+		if a.Digest != "" {
+			res.Digest = a.Digest
+		}
+	}
+
+	return res
+}
@@ -0,0 +1,144 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/auth"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+)
+
+// engine returns the configured SBOM engine.
+func engine() string {
+	if e := viper.GetString("scanner.backends.sbom.engine"); e != "" {
+		return e
+	}
+
+	return EngineSyft
+}
+
+// buildCommand builds the command of the engine to generate the SBOM document
+// with the media type for the image into the output file.
+func buildCommand(ctx context.Context, params job.Parameters, mediaType string, output string) (*exec.Cmd, error) {
+	image := params[ParamKeyImage].(string)
+	username, _ := params[auth.ParamKeyUsername].(string)
+	password, _ := params[auth.ParamKeyPassword].(string)
+	insecure := viper.GetBool("scanner.backends.sbom.insecure")
+	// The registry settings replace the engine settings.
+	if c, ok := oci.LookupRegistry(registryHost(image)); ok {
+		insecure = c.Insecure
+	}
+
+	var local *oci.LocalReference
+	if ref, _ := params[ParamLocal].(string); ref != "" {
+		var err error
+		if local, err = oci.ParseLocal(ref); err != nil {
+			return nil, err
+		}
+	}
+
+	var (
+		name string
+		args []string
+		envs []string
+	)
+
+	switch e := engine(); e {
+	case EngineSyft:
+		format := "spdx-json"
+		if mediaType == MediaTypeCycloneDX {
+			format = "cyclonedx-json"
+		}
+
+		// The engine pulls the image itself, the blob cache is not used.
+		source := fmt.Sprintf("registry:%s", image)
+		if local != nil {
+			source = fmt.Sprintf("docker-archive:%s", local.Path)
+			if local.Transport == oci.TransportOCILayout {
+				source = fmt.Sprintf("oci-dir:%s", local.Path)
+			}
+		}
+
+		name = EngineSyft
+		args = []string{
+			source,
+			"-o", fmt.Sprintf("%s=%s", format, output),
+			"-q",
+		}
+		if username != "" {
+			envs = append(envs,
+				fmt.Sprintf("SYFT_REGISTRY_AUTH_AUTHORITY=%s", registryHost(image)),
+				fmt.Sprintf("SYFT_REGISTRY_AUTH_USERNAME=%s", username),
+				fmt.Sprintf("SYFT_REGISTRY_AUTH_PASSWORD=%s", password),
+			)
+		}
+		if insecure {
+			envs = append(envs, "SYFT_REGISTRY_INSECURE_SKIP_TLS_VERIFY=true")
+		}
+	case EngineTrivy:
+		format := "spdx-json"
+		if mediaType == MediaTypeCycloneDX {
+			format = "cyclonedx"
+		}
+
+		name = EngineTrivy
+		args = []string{
+			"image",
+			"--format", format,
+			"--output", output,
+			"--quiet",
+		}
+		if timeout := viper.GetString("scanner.backends.sbom.timeout"); timeout != "" {
+			args = append(args, "--timeout", timeout)
+		}
+		if insecure {
+			args = append(args, "--insecure")
+		}
+		if local != nil {
+			args = append(args, "--input", local.Path)
+		} else {
+			// The engine pulls the image itself, the blob cache is not used.
+			args = append(args, image)
+		}
+
+		if username != "" {
+			envs = append(envs,
+				fmt.Sprintf("TRIVY_USERNAME=%s", username),
+				fmt.Sprintf("TRIVY_PASSWORD=%s", password),
+			)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported SBOM engine: %s", e)
+	}
+
+	cmd := exec.CommandContext(ctx, name, args...)
+	// Credentials are passed through the environment variables to avoid leaking in the process list.
+	cmd.Env = append(os.Environ(), envs...)
+
+	return cmd, nil
+}
+
+// registryHost extracts the registry host from the image reference.
+func registryHost(image string) string {
+	return strings.SplitN(oci.RegistryHost(image), "/", 2)[0]
+}
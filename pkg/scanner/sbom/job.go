@@ -0,0 +1,219 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/auth"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// JobName is the name of the SBOM generation job.
+	JobName     = "SBOM_GEN"
+	concurrency = 10
+)
+
+// AddToKnownList adds sbom.Runner to the known list.
+func AddToKnownList(l *job.KnownList) error {
+	return l.AddKnownJob(JobName, &Runner{}, job.Concurrency(concurrency))
+}
+
+// Runner of the SBOM generation job.
+type Runner struct{}
+
+// Run implements job.Runnable.
+func (r *Runner) Run(ctx context.Context, parameters job.Parameters) (err error) {
+	// Skip parameter validation as the parameter should be validated in the API layer.
+
+	errorf := errs.WithPrefix("sbom job error")
+	resStore := store.Default()
+
+	// Extract key parameters.
+	reqID := parameters[ParamReqID].(string)
+	imagePath := parameters[ParamKeyImage].(string)
+	mediaTypes := strings.Split(parameters[ParamMediaTypes].(string), ",")
+
+	// Correlate the job logs with the scan request.
+	ctx = uuid.WithRequestID(ctx, reqID)
+	ctx = zlog.WithFields(ctx,
+		zlog.FieldProvider, Name,
+		zlog.FieldJobID, parameters[ParamJobID],
+		zlog.FieldArtifact, imagePath,
+	)
+	lg := zlog.FromContext(ctx)
+
+	artifact := &spec.Artifact{}
+	if err := json.Unmarshal([]byte(parameters[ParamArtifact].(string)), artifact); err != nil {
+		return errorf.Wrap("unmarshal artifact error", err)
+	}
+
+	uk := fmt.Sprintf("%s:%s:%s:%s", dataPrefix, Name, imagePath, parameters[ParamMediaTypes])
+	if err := resStore.Unique(uk); err != nil {
+		return errorf.Wrap("last job is still not finished yet, skip job run", err)
+	}
+
+	// Track the media types not completed for marking errors.
+	pending := make(map[string]bool)
+	for _, mt := range mediaTypes {
+		pending[mt] = true
+	}
+
+	defer func() {
+		// Defer to de-unique.
+		if e := resStore.DeUnique(uk); e != nil {
+			// Just log.
+			lg.Error(e)
+		}
+
+		// Check if error is occurred.
+		if err != nil {
+			for mt := range pending {
+				if e := resStore.SaveResult(dataKey(reqID, mt), &data.Item{
+					Timestamp: time.Now().UTC().Unix(),
+					Status:    data.Error,
+					Error:     err.Error(),
+				}); e != nil {
+					lg.Error(e)
+				}
+			}
+		}
+	}()
+
+	// Mark status to start.
+	for _, mt := range mediaTypes {
+		if err := resStore.SaveResult(dataKey(reqID, mt), &data.Item{
+			Timestamp: time.Now().UTC().Unix(),
+			Status:    data.Ongoing,
+		}); err != nil {
+			// Just need to log
+			lg.Error(err)
+		}
+	}
+
+	if timeout := viper.GetDuration("scanner.backends.sbom.timeout"); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	// The jobs enqueued before the registry parameter was added have no registry, the reports are not pushed.
+	registry, _ := parameters[ParamRegistry].(string)
+	username, _ := parameters[auth.ParamKeyUsername].(string)
+	password, _ := parameters[auth.ParamKeyPassword].(string)
+	options := oci.RemoteOptions{
+		Username: username,
+		Password: password,
+		Insecure: viper.GetBool("scanner.backends.sbom.insecure"),
+	}
+
+	for _, mt := range mediaTypes {
+		report, err := generate(ctx, parameters, artifact, mt)
+		if err != nil {
+			return errorf.Wrap("generate %s SBOM error", err, mt)
+		}
+
+		// Save data.
+		if err := resStore.SaveResult(dataKey(reqID, mt), &data.Item{
+			Status:    data.Success,
+			JSON:      string(report),
+			Timestamp: time.Now().UTC().Unix(),
+		}); err != nil {
+			lg.Error(err)
+		}
+		delete(pending, mt)
+
+		lg.Infow("SBOM is generated", "media_type", mt)
+
+		// Push the report back to the registry along with the image.
+		scan.PushReport(ctx, registry, artifact, reportMimeType(mt), report, options)
+	}
+
+	lg.Infow("sbom.job is completed")
+
+	return nil
+}
+
+// generate runs the engine to generate the SBOM document with the media type
+// and wraps it in the report envelope.
+func generate(ctx context.Context, parameters job.Parameters, artifact *spec.Artifact, mediaType string) ([]byte, error) {
+	errorf := errs.WithPrefix("")
+
+	// Specify a temp file for keeping the engine output.
+	f, err := ioutil.TempFile("", "sbom-*.json")
+	if err != nil {
+		return nil, errorf.Wrap("create temp SBOM file error", err)
+	}
+	if err := f.Close(); err != nil {
+		return nil, errorf.Wrap("close temp SBOM file error", err)
+	}
+	defer func() {
+		if err := os.Remove(f.Name()); err != nil {
+			zlog.FromContext(ctx).Error(err)
+		}
+	}()
+
+	cmd, err := buildCommand(ctx, parameters, mediaType, f.Name())
+	if err != nil {
+		return nil, err
+	}
+
+	if dt, err := cmd.CombinedOutput(); err != nil {
+		return nil, errorf.Wrap("run backend engine command error: details=%s", err, dt)
+	}
+
+	doc, err := ioutil.ReadFile(f.Name())
+	if err != nil {
+		return nil, errorf.Wrap("read SBOM file error", err)
+	}
+
+	if !json.Valid(doc) {
+		return nil, errorf.Error("invalid SBOM document generated by %s", engine())
+	}
+
+	report := &spec.HarborSBOMReport{
+		GeneratedAt: time.Now().UTC(),
+		Artifact:    artifact,
+		Scanner: &spec.Scanner{
+			Name:    Name,
+			Vendor:  Vendor,
+			Version: Version,
+		},
+		MediaType: mediaType,
+		Sbom:      doc,
+		VendorAttributes: spec.ModelMap{
+			"engine": engine(),
+		},
+	}
+
+	return json.Marshal(report)
+}
@@ -0,0 +1,50 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+const (
+	// Name of SBOM provider.
+	Name = "SBOM"
+	// Vendor of SBOM provider.
+	Vendor = "Harbor"
+	// Version of SBOM provider.
+	Version = "0.1.0"
+	// ReportMimeType is mimetype of SBOM report.
+	ReportMimeType = "application/vnd.security.sbom.report+json; version=1.0"
+	// CapabilityType is the capability type of generating SBOM.
+	CapabilityType = "sbom"
+
+	// MediaTypeSPDX is the media type of the SPDX JSON document.
+	MediaTypeSPDX = "application/spdx+json"
+	// MediaTypeCycloneDX is the media type of the CycloneDX JSON document.
+	MediaTypeCycloneDX = "application/vnd.cyclonedx+json"
+
+	// EngineSyft uses syft to generate SBOM.
+	EngineSyft = "syft"
+	// EngineTrivy uses trivy to generate SBOM.
+	EngineTrivy = "trivy"
+)
+
+// SupportedMediaTypes are the media types of the SBOM documents can be generated.
+var SupportedMediaTypes = []string{
+	MediaTypeSPDX,
+	MediaTypeCycloneDX,
+}
+
+// ExtraMeta is the SBOM extra properties.
+var ExtraMeta = map[string]string{
+	"maintainer": "steven-zou",
+	"license":    "Apache-2.0",
+}
@@ -0,0 +1,337 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package sbom
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/auth"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/client"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// ParamKeyImage is parameter key of image path.
+	ParamKeyImage = "image"
+	// ParamReqID is parameter key of request ID.
+	ParamReqID = "reqID"
+	// ParamJobID is parameter key of the job ID used to correlate logs.
+	ParamJobID = "jobID"
+	// ParamArtifact is parameter key of the JSON encoded artifact.
+	ParamArtifact = "artifact"
+	// ParamMediaTypes is parameter key of the comma separated SBOM media types to generate.
+	ParamMediaTypes = "mediaTypes"
+	// ParamRegistry is parameter key of the registry URL, empty for the local artifacts.
+	ParamRegistry = "registry"
+	// ParamLocal is parameter key of the local artifact reference, e.g: oci-layout:/path.
+	ParamLocal = "local"
+
+	// paramSBOMMediaTypes is the parameter of the sbom capability in the scan request.
+	paramSBOMMediaTypes = "sbom_media_types"
+
+	dataPrefix = "{sbom-result-store}"
+)
+
+// Use singleton provider.
+var provider *Provider
+var once sync.Once
+
+// Provider to support SBOM generation.
+type Provider struct {
+	store  store.Provider
+	name   string
+	dataNS string
+}
+
+// New a SBOM provider.
+func New() *Provider {
+	once.Do(func() {
+		provider = &Provider{
+			store:  store.Default(),
+			name:   Name,
+			dataNS: dataPrefix,
+		}
+	})
+
+	return provider
+}
+
+// Metadata implements scanner.Provider.
+func (p *Provider) Metadata() *spec.ScannerAdapterMetadata {
+	props := map[string]string{
+		"engine": engine(),
+	}
+	for k, v := range ExtraMeta {
+		props[k] = v
+	}
+
+	return &spec.ScannerAdapterMetadata{
+		Scanner: &spec.Scanner{
+			Name:    Name,
+			Version: Version,
+			Vendor:  Vendor,
+		},
+		Capabilities: []spec.ScannerCapability{
+			{
+				Type: CapabilityType,
+				ConsumesMimeTypes: []string{
+					oci.OCIImage,
+					oci.DockerV2Image,
+				},
+				ProducesMimeTypes: []string{
+					ReportMimeType,
+				},
+				AdditionalAttributes: map[string]interface{}{
+					paramSBOMMediaTypes: SupportedMediaTypes,
+				},
+			},
+		},
+		Properties: &props,
+	}
+}
+
+// AcceptScanRequest implements scanner.Provider.
+func (p *Provider) AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error) {
+	errorf := errs.WithPrefix("accept scan request error")
+
+	// Extract request ID first.
+	reqID := uuid.FromContext(ctx)
+	if reqID == "" {
+		return nil, errorf.Error("missing request ID in the context")
+	}
+
+	if req == nil || req.Artifact == nil || (req.Registry == nil && req.Artifact.Reference == "") {
+		return nil, errorf.Error("registry or local reference and artifact are required in the scan request")
+	}
+
+	// Validate image mimetype.
+	if req.Artifact.MimeType != oci.OCIImage && req.Artifact.MimeType != oci.DockerV2Image {
+		return nil, errorf.Error("only support mimetypes: %s,%s", oci.OCIImage, oci.DockerV2Image)
+	}
+
+	mediaTypes, err := requestedMediaTypes(req)
+	if err != nil {
+		return nil, errorf.Wrap("parse SBOM media types error", err)
+	}
+
+	// Pin the artifact to the digest, so the job scans the requested image even if the tag is moved.
+	req, err = native.PinDigest(ctx, req, oci.RemoteOptions{
+		Insecure: viper.GetBool("scanner.backends.sbom.insecure"),
+	})
+	if err != nil {
+		return nil, errorf.Wrap("resolve artifact digest error", err)
+	}
+
+	// Convert job parameters.
+	jp, err := toJobParams(req)
+	if err != nil {
+		return nil, errorf.Wrap("parse job parameters error", err)
+	}
+
+	jobID := uuid.Random()
+	jp[ParamReqID] = reqID
+	jp[ParamJobID] = jobID
+	jp[ParamMediaTypes] = strings.Join(mediaTypes, ",")
+
+	ctx = zlog.WithFields(ctx,
+		zlog.FieldProvider, p.name,
+		zlog.FieldJobID, jobID,
+		zlog.FieldArtifact, jp[ParamKeyImage],
+	)
+	lg := zlog.FromContext(ctx)
+
+	// Enqueue scan job.
+	enq, err := client.Enqueuer()
+	if err != nil {
+		return nil, errorf.Wrap("get job enqueuer error", err)
+	}
+
+	j, err := enq.EnqueueUnique(JobName, jp)
+	if err != nil {
+		return nil, errorf.Wrap("enqueue sbom job error", err)
+	}
+
+	// Log the backend job info for potential debug.
+	lg.Infow("SBOM backend job is enqueued", "job", j.Name, "id", j.ID, "media_types", mediaTypes)
+
+	// Create result placeholders and set the status to pending.
+	for _, mt := range mediaTypes {
+		if err := p.store.SaveResult(dataKey(reqID, mt), &data.Item{
+			Timestamp: time.Now().UTC().Unix(),
+			Status:    data.Pending,
+		}); err != nil {
+			// Not a panic case, just logged it.
+			// Once the scan job is started, it can be recovered again.
+			lg.Errorw("save result placeholder failed", "error", err, "media_type", mt)
+		}
+	}
+
+	return &spec.ScanResponse{
+		Id: reqID,
+	}, nil
+}
+
+// RetrieveScanResult implements scanner.Provider.
+// The SBOM media type is extracted from the context, the first default media type is used if it's not set.
+func (p *Provider) RetrieveScanResult(ctx context.Context, reqID string, mimetype string) (scan.Result, error) {
+	errorf := errs.WithPrefix("retrieve scan request error")
+
+	mediaType := scan.ReportMediaTypeFromContext(ctx)
+	if mediaType == "" {
+		mediaType = defaultMediaTypes()[0]
+	}
+
+	if !supported(mediaType) {
+		// Not generated at all.
+		return scan.ResultOf(mimetype, nil)
+	}
+
+	dt, err := p.store.GetResult(dataKey(reqID, mediaType))
+	if err != nil {
+		if errors.Is(err, rds.NotFoundErr) {
+			return scan.ResultOf(mimetype, nil)
+		}
+		return nil, errorf.Wrap("store get result error", err)
+	}
+
+	return scan.ResultOf(mimetype, dt)
+}
+
+// dataKey returns the data key of the SBOM report with the media type.
+func dataKey(reqID string, mediaType string) *data.Key {
+	dk := &data.Key{
+		Provider: Name,
+		ReqID:    reqID,
+		Mimetype: reportMimeType(mediaType),
+	}
+	dk.AppendPrefix(dataPrefix)
+
+	return dk
+}
+
+// reportMimeType returns the report mimetype of the SBOM media type.
+func reportMimeType(mediaType string) string {
+	return fmt.Sprintf("%s; media_type=%s", ReportMimeType, mediaType)
+}
+
+// requestedMediaTypes returns the SBOM media types requested through the sbom capability of the request.
+// The configured default media types are returned if nothing is requested.
+func requestedMediaTypes(req *spec.ScanRequest) ([]string, error) {
+	var mediaTypes []string
+	for _, c := range req.EnabledCapabilities {
+		if c.Type != CapabilityType || c.Parameters == nil {
+			continue
+		}
+
+		raw, ok := c.Parameters[paramSBOMMediaTypes].([]interface{})
+		if !ok {
+			continue
+		}
+
+		for _, r := range raw {
+			mt, ok := r.(string)
+			if !ok || !supported(mt) {
+				return nil, fmt.Errorf("unsupported SBOM media type: %v", r)
+			}
+			mediaTypes = append(mediaTypes, mt)
+		}
+	}
+
+	if len(mediaTypes) == 0 {
+		return defaultMediaTypes(), nil
+	}
+
+	return mediaTypes, nil
+}
+
+// defaultMediaTypes returns the configured default media types.
+func defaultMediaTypes() []string {
+	var mediaTypes []string
+	for _, mt := range viper.GetStringSlice("scanner.backends.sbom.mediaTypes") {
+		if supported(mt) {
+			mediaTypes = append(mediaTypes, mt)
+		}
+	}
+
+	if len(mediaTypes) == 0 {
+		return []string{MediaTypeSPDX}
+	}
+
+	return mediaTypes
+}
+
+func supported(mediaType string) bool {
+	for _, mt := range SupportedMediaTypes {
+		if mt == mediaType {
+			return true
+		}
+	}
+
+	return false
+}
+
+func toJobParams(req *spec.ScanRequest) (job.Parameters, error) {
+	errorf := errs.WithPrefix("")
+
+	jp := make(job.Parameters)
+
+	// Always scan the image by digest, the digest is pinned at accepting.
+	if req.Artifact.Reference != "" {
+		// The local artifact is read from the filesystem.
+		jp[ParamKeyImage] = oci.LocalImageRef(req.Artifact.Reference, "", req.Artifact.Digest)
+		jp[ParamLocal] = req.Artifact.Reference
+		jp[ParamRegistry] = ""
+	} else {
+		// Parse authorization credential.
+		ap, err := auth.Parse(req.Registry.Authorization)
+		if err != nil {
+			return nil, errorf.Wrap("parse registry authorization error", err)
+		}
+
+		if err := ap.Inject(jp); err != nil {
+			return nil, errorf.Wrap("inject auth params error", err)
+		}
+
+		jp[ParamKeyImage] = oci.ImageRef(req.Registry.Url, req.Artifact.Repository, "", req.Artifact.Digest)
+		jp[ParamRegistry] = req.Registry.Url
+	}
+
+	// Keep the artifact as JSON string to survive the job parameters serialization.
+	bytes, err := json.Marshal(req.Artifact)
+	if err != nil {
+		return nil, errorf.Wrap("marshal artifact error", err)
+	}
+	jp[ParamArtifact] = string(bytes)
+
+	return jp, nil
+}
@@ -0,0 +1,197 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"archive/tar"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// defaultMaxFileSize is the default size limit of the files to scan.
+	defaultMaxFileSize = 1 << 20
+	// binarySniffLen is the length of the content sniffed to detect binary files.
+	binarySniffLen = 8000
+)
+
+// Detector detects secrets in the image layers.
+type Detector struct {
+	rules       []Rule
+	allowlist   []Allow
+	maxFileSize int64
+	// fingerprint of the rules and settings, the per-layer findings are reused by it.
+	fingerprint string
+}
+
+// NewDetector creates a detector with the configured rules and allowlist.
+func NewDetector() (*Detector, error) {
+	rules, err := loadRules()
+	if err != nil {
+		return nil, err
+	}
+
+	allowlist, err := loadAllowlist()
+	if err != nil {
+		return nil, err
+	}
+
+	maxFileSize := viper.GetInt64("scanner.backends.secret.maxFileSize")
+	if maxFileSize <= 0 {
+		maxFileSize = defaultMaxFileSize
+	}
+
+	fingerprint, err := scan.Fingerprint(Version, []interface{}{rules, allowlist, maxFileSize})
+	if err != nil {
+		return nil, err
+	}
+
+	return &Detector{
+		rules:       rules,
+		allowlist:   allowlist,
+		maxFileSize: maxFileSize,
+		fingerprint: fingerprint,
+	}, nil
+}
+
+// Scan streams the layers of the image and detects the secrets in the regular files.
+// Files larger than the size limit and binary files are skipped. Layers with unsupported
+// compressions are skipped with a warning. The findings of the layers analyzed by the
+// previous scans are reused.
+func (d *Detector) Scan(ctx context.Context, img *oci.Image) ([]spec.SecretFinding, error) {
+	layers := scan.NewLayers(ctx, img, Name, d.fingerprint)
+
+	findings := []spec.SecretFinding{}
+	for _, l := range img.Manifest.Layers {
+		l := l
+
+		var fs []spec.SecretFinding
+		err := layers.Analyze(ctx, l, &fs, func() (err error) {
+			fs, err = d.scanLayer(ctx, img, l)
+			return err
+		})
+		if err != nil {
+			if errors.Is(err, oci.ErrUnsupportedCompression) {
+				zlog.FromContext(ctx).Warnw("layer is skipped", "layer", l.Digest, "error", err)
+				continue
+			}
+			return nil, fmt.Errorf("scan layer %s error: %w", l.Digest, err)
+		}
+
+		// The reused findings may come from the layer with the same content but different compression.
+		for i := range fs {
+			fs[i].Layer = l.Digest
+		}
+		findings = append(findings, fs...)
+	}
+
+	return findings, nil
+}
+
+func (d *Detector) scanLayer(ctx context.Context, img *oci.Image, desc oci.Descriptor) ([]spec.SecretFinding, error) {
+	rc, err := img.Layer(ctx, desc)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var findings []spec.SecretFinding
+
+	tr := tar.NewReader(rc)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if hdr.Typeflag != tar.TypeReg || hdr.Size == 0 || hdr.Size > d.maxFileSize {
+			continue
+		}
+
+		content, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+
+		findings = append(findings, d.Detect(path.Clean("/"+hdr.Name), desc.Digest, content)...)
+	}
+
+	// Drain the stream to verify the layer digest.
+	if _, err := io.Copy(ioutil.Discard, rc); err != nil {
+		return nil, err
+	}
+
+	return findings, nil
+}
+
+// Detect runs the rules over the file content.
+func (d *Detector) Detect(file string, layer string, content []byte) []spec.SecretFinding {
+	sniff := content
+	if len(sniff) > binarySniffLen {
+		sniff = sniff[:binarySniffLen]
+	}
+	if bytes.IndexByte(sniff, 0) >= 0 {
+		// Skip binary files.
+		return nil
+	}
+
+	var findings []spec.SecretFinding
+	for _, r := range d.rules {
+		if allowed(d.allowlist, r.ID, file) {
+			continue
+		}
+
+		for _, loc := range r.re.FindAllSubmatchIndex(content, -1) {
+			// Use the first submatch as the secret if it exists.
+			start, end := loc[0], loc[1]
+			if len(loc) >= 4 && loc[2] >= 0 {
+				start, end = loc[2], loc[3]
+			}
+
+			secret := string(content[start:end])
+			if r.Entropy > 0 && entropy(secret) < r.Entropy {
+				continue
+			}
+
+			severity := spec.Severity(r.Severity)
+			findings = append(findings, spec.SecretFinding{
+				RuleID:   r.ID,
+				Title:    r.Title,
+				Severity: &severity,
+				Path:     file,
+				Layer:    layer,
+				Line:     bytes.Count(content[:start], []byte("\n")) + 1,
+				Match:    mask(secret),
+			})
+		}
+	}
+
+	return findings
+}
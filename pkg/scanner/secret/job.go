@@ -0,0 +1,106 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// JobName is the name of the secret scan job.
+	JobName = "SECRET_SCAN"
+)
+
+// severityOrder ranks the severities from low to high.
+var severityOrder = map[spec.Severity]int{
+	spec.UNKNOWN:    0,
+	spec.NEGLIGIBLE: 1,
+	spec.LOW:        2,
+	spec.MEDIUM:     3,
+	spec.HIGH:       4,
+	spec.CRITICAL:   5,
+}
+
+// AddToKnownList adds the secret scan job to the known list.
+func AddToKnownList(l *job.KnownList) error {
+	return secretEngine.AddToKnownList(l)
+}
+
+// scanSecrets detects the secrets in the layers of the image.
+func scanSecrets(ctx context.Context, t *native.Target) ([]byte, error) {
+	errorf := errs.WithPrefix("")
+
+	detector, err := NewDetector()
+	if err != nil {
+		return nil, errorf.Wrap("create secret detector error", err)
+	}
+
+	img, err := t.Image(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	findings, err := detector.Scan(ctx, img)
+	if err != nil {
+		return nil, errorf.Wrap("detect secrets error", err)
+	}
+
+	zlog.FromContext(ctx).Infow("secrets are detected", "findings", len(findings))
+
+	result, err := json.Marshal(NewReport(t.Artifact, findings))
+	if err != nil {
+		return nil, errorf.Wrap("marshal secret report error", err)
+	}
+
+	return result, nil
+}
+
+// NewReport builds the secret report with the summary of the findings.
+func NewReport(artifact *spec.Artifact, findings []spec.SecretFinding) *spec.HarborSecretReport {
+	report := &spec.HarborSecretReport{
+		GeneratedAt: time.Now().UTC(),
+		Artifact:    artifact,
+		Scanner: &spec.Scanner{
+			Name:    Name,
+			Vendor:  Vendor,
+			Version: Version,
+		},
+		Summary: &spec.SecretSummary{
+			Total:   uint(len(findings)),
+			Summary: make(map[spec.Severity]uint),
+		},
+		Findings: findings,
+	}
+
+	for _, f := range findings {
+		report.Summary.Summary[*f.Severity]++
+
+		if report.Severity == nil || severityOrder[*f.Severity] > severityOrder[*report.Severity] {
+			sev := *f.Severity
+			report.Severity = &sev
+		}
+	}
+
+	return report
+}
@@ -0,0 +1,258 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package secret
+
+import (
+	"fmt"
+	"math"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// Rule detects a kind of secrets.
+type Rule struct {
+	// ID of the rule, e.g: aws-access-key-id.
+	ID string `mapstructure:"id"`
+	// Title of the rule.
+	Title string `mapstructure:"title"`
+	// Severity of the secrets detected by the rule.
+	Severity string `mapstructure:"severity"`
+	// Regex matches the secret. The first submatch is treated as the secret if it exists.
+	Regex string `mapstructure:"regex"`
+	// Entropy is the minimal Shannon entropy of the secret, 0 means no entropy check.
+	Entropy float64 `mapstructure:"entropy"`
+
+	re *regexp.Regexp
+}
+
+// Allow is an allowlist entry of the paths.
+type Allow struct {
+	// Path pattern of the files, e.g: /usr/share/doc/**, *.md.
+	Path string `mapstructure:"path"`
+	// Rules are the IDs of the allowed rules, empty means all the rules.
+	Rules []string `mapstructure:"rules"`
+}
+
+// builtinRules are the default rules.
+var builtinRules = []Rule{
+	{
+		ID:       "aws-access-key-id",
+		Title:    "AWS Access Key ID",
+		Severity: string(spec.CRITICAL),
+		Regex:    `\b((?:AKIA|ASIA)[0-9A-Z]{16})\b`,
+	},
+	{
+		ID:       "aws-secret-access-key",
+		Title:    "AWS Secret Access Key",
+		Severity: string(spec.CRITICAL),
+		Regex:    `(?i)aws_?secret_?(?:access_?)?key\W{0,5}([A-Za-z0-9/+=]{40})\b`,
+		Entropy:  4,
+	},
+	{
+		ID:       "github-token",
+		Title:    "GitHub Token",
+		Severity: string(spec.CRITICAL),
+		Regex:    `\b(gh[pousr]_[A-Za-z0-9]{36,255})\b`,
+	},
+	{
+		ID:       "gitlab-token",
+		Title:    "GitLab Personal Access Token",
+		Severity: string(spec.CRITICAL),
+		Regex:    `\b(glpat-[A-Za-z0-9_\-]{20})\b`,
+	},
+	{
+		ID:       "slack-token",
+		Title:    "Slack Token",
+		Severity: string(spec.HIGH),
+		Regex:    `\b(xox[baprs]-[0-9A-Za-z\-]{10,72})\b`,
+	},
+	{
+		ID:       "stripe-secret-key",
+		Title:    "Stripe Secret Key",
+		Severity: string(spec.CRITICAL),
+		Regex:    `\b((?:sk|rk)_live_[0-9A-Za-z]{24,99})\b`,
+	},
+	{
+		ID:       "gcp-api-key",
+		Title:    "Google API Key",
+		Severity: string(spec.HIGH),
+		Regex:    `\b(AIza[0-9A-Za-z_\-]{35})\b`,
+	},
+	{
+		ID:       "private-key",
+		Title:    "Private Key",
+		Severity: string(spec.HIGH),
+		Regex:    `-----BEGIN[ A-Z0-9_-]{0,100}PRIVATE KEY(?: BLOCK)?-----`,
+	},
+	{
+		ID:       "generic-secret",
+		Title:    "Generic Secret",
+		Severity: string(spec.MEDIUM),
+		Regex:    `(?i)(?:password|passwd|secret|token|api_?key|access_?key)["']?\s*[:=]\s*["']?([A-Za-z0-9/+_.=\-]{16,128})`,
+		Entropy:  3.5,
+	},
+}
+
+// loadRules loads the builtin rules and the rules configured by `scanner.backends.secret.rules`.
+// The configured rule overrides the builtin rule with the same ID.
+// The rules listed in `scanner.backends.secret.disabledRules` are excluded.
+func loadRules() ([]Rule, error) {
+	var custom []Rule
+	if err := viper.UnmarshalKey("scanner.backends.secret.rules", &custom); err != nil {
+		return nil, fmt.Errorf("parse secret rules error: %w", err)
+	}
+
+	disabled := make(map[string]bool)
+	for _, id := range viper.GetStringSlice("scanner.backends.secret.disabledRules") {
+		disabled[id] = true
+	}
+
+	all := append([]Rule{}, custom...)
+	for _, b := range builtinRules {
+		// Skip the builtin rule overridden by the custom one.
+		overridden := false
+		for _, c := range custom {
+			if c.ID == b.ID {
+				overridden = true
+				break
+			}
+		}
+
+		if !overridden {
+			all = append(all, b)
+		}
+	}
+
+	var rules []Rule
+	for _, r := range all {
+		if disabled[r.ID] {
+			continue
+		}
+
+		if r.ID == "" || r.Regex == "" {
+			return nil, fmt.Errorf("invalid secret rule %q: id and regex are required", r.ID)
+		}
+
+		if r.Severity == "" {
+			r.Severity = string(spec.UNKNOWN)
+		}
+
+		re, err := regexp.Compile(r.Regex)
+		if err != nil {
+			return nil, fmt.Errorf("compile regex of secret rule %q error: %w", r.ID, err)
+		}
+		r.re = re
+
+		rules = append(rules, r)
+	}
+
+	return rules, nil
+}
+
+// loadAllowlist loads the allowlist configured by `scanner.backends.secret.allowlist`.
+func loadAllowlist() ([]Allow, error) {
+	var allowlist []Allow
+	if err := viper.UnmarshalKey("scanner.backends.secret.allowlist", &allowlist); err != nil {
+		return nil, fmt.Errorf("parse secret allowlist error: %w", err)
+	}
+
+	for _, a := range allowlist {
+		if _, err := path.Match(strings.TrimSuffix(a.Path, "/**"), "/"); err != nil {
+			return nil, fmt.Errorf("invalid allowlist path pattern %q: %w", a.Path, err)
+		}
+	}
+
+	return allowlist, nil
+}
+
+// allowed checks whether the rule is allowed for the file path.
+func allowed(allowlist []Allow, rule string, file string) bool {
+	for _, a := range allowlist {
+		if !matchPath(a.Path, file) {
+			continue
+		}
+
+		if len(a.Rules) == 0 {
+			return true
+		}
+
+		for _, r := range a.Rules {
+			if r == rule {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchPath matches the absolute file path with the pattern.
+// The pattern ending with /** matches all the files under the directory and
+// the pattern without slash matches the file name.
+func matchPath(pattern string, file string) bool {
+	if strings.HasSuffix(pattern, "/**") {
+		dir := strings.TrimSuffix(pattern, "/**")
+		for d := path.Dir(file); d != "/" && d != "."; d = path.Dir(d) {
+			if ok, _ := path.Match(dir, d); ok {
+				return true
+			}
+		}
+		return false
+	}
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(file))
+		return ok
+	}
+
+	ok, _ := path.Match(pattern, file)
+	return ok
+}
+
+// entropy computes the Shannon entropy of the string.
+func entropy(s string) float64 {
+	if s == "" {
+		return 0
+	}
+
+	freq := make(map[rune]float64)
+	for _, c := range s {
+		freq[c]++
+	}
+
+	var e float64
+	n := float64(len([]rune(s)))
+	for _, f := range freq {
+		p := f / n
+		e -= p * math.Log2(p)
+	}
+
+	return e
+}
+
+// mask the secret and keep the first 4 characters for identification.
+func mask(s string) string {
+	const keep = 4
+	if len(s) <= keep*2 {
+		return strings.Repeat("*", len(s))
+	}
+
+	return s[:keep] + strings.Repeat("*", len(s)-keep)
+}
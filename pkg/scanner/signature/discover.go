@@ -0,0 +1,579 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/attest"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// TypeSignature is the verified type of the signatures.
+	TypeSignature = "signature"
+	// TypeProvenance is the verified type of the SLSA provenance attestations.
+	TypeProvenance = "provenance"
+	// TypeAttestation is the verified type of the other in-toto attestations.
+	TypeAttestation = "attestation"
+
+	// FormatCosign is the cosign simple signing format.
+	FormatCosign = "cosign"
+	// FormatNotation is the notation signature format.
+	FormatNotation = "notation"
+	// FormatInToto is the in-toto statement in the DSSE envelope.
+	FormatInToto = "in-toto"
+
+	// SourceReferrers means the signature is discovered by the referrers API or the referrers tag schema.
+	SourceReferrers = "referrers"
+	// SourceTag means the signature is discovered by the cosign tag convention, e.g: sha256-<hex>.sig.
+	SourceTag = "tag"
+
+	artifactTypeNotation   = "application/vnd.cncf.notary.signature"
+	artifactTypeCosign     = "application/vnd.dev.cosign.artifact.sig.v1+json"
+	mediaTypeSimpleSigning = "application/vnd.dev.cosign.simplesigning.v1+json"
+	mediaTypeJWS           = "application/jose+json"
+	mediaTypeCOSE          = "application/cose"
+
+	annotationCosignSignature   = "dev.cosignproject.cosign/signature"
+	annotationCosignCertificate = "dev.sigstore.cosign/certificate"
+	annotationCosignChain       = "dev.sigstore.cosign/chain"
+
+	// maxReferrers limits the referrers checked of an artifact.
+	maxReferrers = 64
+	// maxEnvelopeSize limits the size of the signature payloads and envelopes.
+	maxEnvelopeSize = 4 << 20
+)
+
+// provenanceTypes are the SLSA provenance predicate types.
+var provenanceTypes = []string{
+	"https://slsa.dev/provenance/v0.1",
+	"https://slsa.dev/provenance/v0.2",
+	"https://slsa.dev/provenance/v1",
+}
+
+// checker discovers and verifies the signatures and attestations of the artifact.
+type checker struct {
+	remote   *oci.Remote
+	verifier *Verifier
+	// digest of the artifact manifest.
+	digest string
+
+	results  []spec.SignatureVerification
+	warnings []string
+}
+
+// Check discovers the signatures and attestations of the artifact with the digest by the referrers
+// and the cosign tag convention, and verifies them with the verifier.
+func Check(ctx context.Context, remote *oci.Remote, verifier *Verifier, digest string) ([]spec.SignatureVerification, []string, error) {
+	c := &checker{
+		remote:   remote,
+		verifier: verifier,
+		digest:   digest,
+	}
+
+	idx, err := remote.Referrers(ctx, digest, "")
+	if err != nil {
+		if ctx.Err() != nil {
+			return nil, nil, err
+		}
+		c.warnf("list referrers error: %s", err)
+	} else {
+		for i, desc := range idx.Manifests {
+			if i >= maxReferrers {
+				c.warnf("only the first %d referrers are checked", maxReferrers)
+				break
+			}
+
+			c.referrer(ctx, desc)
+		}
+	}
+
+	tag := strings.Replace(digest, ":", "-", 1)
+	c.cosignTag(ctx, fmt.Sprintf("%s.sig", tag))
+	c.cosignTag(ctx, fmt.Sprintf("%s.att", tag))
+
+	if ctx.Err() != nil {
+		return nil, nil, ctx.Err()
+	}
+
+	return c.results, c.warnings, nil
+}
+
+// referrer checks the referrer if it's a known signature or attestation.
+func (c *checker) referrer(ctx context.Context, desc oci.Descriptor) {
+	switch desc.ArtifactType {
+	case "", artifactTypeNotation, artifactTypeCosign, attest.MimeType:
+	default:
+		// Other artifacts, e.g: the SBOMs or the scan reports.
+		return
+	}
+
+	m, err := c.manifest(ctx, desc.Digest)
+	if err != nil {
+		c.warnf("fetch referrer %s error: %s", desc.Digest, err)
+		return
+	}
+
+	artifactType := desc.ArtifactType
+	if artifactType == "" {
+		artifactType = m.ArtifactType
+	}
+	if artifactType == "" {
+		artifactType = m.Config.MediaType
+	}
+
+	switch artifactType {
+	case artifactTypeNotation:
+		c.notation(ctx, desc.Digest, m)
+	case artifactTypeCosign:
+		c.layers(ctx, SourceReferrers, desc.Digest, m)
+	case attest.MimeType:
+		c.layers(ctx, SourceReferrers, desc.Digest, m)
+	}
+}
+
+// cosignTag checks the signatures or attestations of the cosign tag if it exists.
+func (c *checker) cosignTag(ctx context.Context, tag string) {
+	content, _, err := c.remote.Manifest(ctx, tag)
+	if err != nil {
+		if !errors.Is(err, oci.ErrNotFound) {
+			c.warnf("fetch cosign tag %s error: %s", tag, err)
+		}
+		return
+	}
+
+	m := &oci.Manifest{}
+	if err := json.Unmarshal(content, m); err != nil {
+		c.warnf("unmarshal cosign tag %s error: %s", tag, err)
+		return
+	}
+
+	c.layers(ctx, SourceTag, oci.Digest(content), m)
+}
+
+// layers checks the cosign signatures or the DSSE envelopes in the layers of the manifest.
+func (c *checker) layers(ctx context.Context, source string, digest string, m *oci.Manifest) {
+	for _, l := range m.Layers {
+		switch l.MediaType {
+		case mediaTypeSimpleSigning:
+			c.add(c.cosign(ctx, source, digest, l))
+		case attest.MimeType:
+			c.add(c.envelope(ctx, source, digest, l))
+		}
+	}
+}
+
+// cosign verifies the cosign simple signing payload.
+func (c *checker) cosign(ctx context.Context, source string, digest string, layer oci.Descriptor) spec.SignatureVerification {
+	res := spec.SignatureVerification{
+		Type:   TypeSignature,
+		Format: FormatCosign,
+		Source: source,
+		Digest: digest,
+	}
+
+	payload, err := c.blob(ctx, layer)
+	if err != nil {
+		return failed(res, err)
+	}
+
+	sp := &simpleSigning{}
+	if err := json.Unmarshal(payload, sp); err != nil {
+		return failed(res, fmt.Errorf("unmarshal simple signing payload error: %w", err))
+	}
+
+	if sp.Critical.Image.DockerManifestDigest != c.digest {
+		return failed(res, fmt.Errorf("signed digest %s does not match the artifact", sp.Critical.Image.DockerManifestDigest))
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(layer.Annotations[annotationCosignSignature])
+	if err != nil || len(sig) == 0 {
+		return failed(res, fmt.Errorf("missing or invalid signature annotation"))
+	}
+
+	cert, chain, err := cosignCertificate(layer.Annotations)
+	if err != nil {
+		return failed(res, err)
+	}
+
+	s, err := c.verifier.verify(payload, sig, cert, chain)
+	if err != nil {
+		return failed(res, err)
+	}
+
+	return verified(res, s)
+}
+
+// envelope verifies the DSSE envelope of the in-toto statement.
+func (c *checker) envelope(ctx context.Context, source string, digest string, layer oci.Descriptor) spec.SignatureVerification {
+	res := spec.SignatureVerification{
+		Type:   TypeAttestation,
+		Format: FormatInToto,
+		Source: source,
+		Digest: digest,
+	}
+
+	content, err := c.blob(ctx, layer)
+	if err != nil {
+		return failed(res, err)
+	}
+
+	env, err := attest.ParseEnvelope(content)
+	if err != nil {
+		return failed(res, err)
+	}
+
+	payload, err := env.DecodePayload()
+	if err != nil {
+		return failed(res, fmt.Errorf("decode payload error: %w", err))
+	}
+
+	st := &statement{}
+	if err := json.Unmarshal(payload, st); err != nil {
+		return failed(res, fmt.Errorf("unmarshal in-toto statement error: %w", err))
+	}
+	res.PredicateType = st.PredicateType
+
+	if contains(provenanceTypes, st.PredicateType) {
+		res.Type = TypeProvenance
+		res.Builder, res.BuildType = st.builder()
+	}
+
+	if !st.refers(c.digest) {
+		return failed(res, fmt.Errorf("statement subject does not match the artifact"))
+	}
+
+	cert, chain, err := cosignCertificate(layer.Annotations)
+	if err != nil {
+		return failed(res, err)
+	}
+
+	pae := attest.PAE(env.PayloadType, payload)
+	err = fmt.Errorf("envelope has no signature")
+	for _, sig := range env.Signatures {
+		raw, e := base64.StdEncoding.DecodeString(sig.Sig)
+		if e != nil {
+			err = fmt.Errorf("decode signature error: %w", e)
+			continue
+		}
+
+		var s *signer
+		if s, err = c.verifier.verify(pae, raw, cert, chain); err == nil {
+			return verified(res, s)
+		}
+	}
+
+	return failed(res, err)
+}
+
+// notation verifies the notation signature envelope of the manifest.
+// Only the JWS envelopes are supported.
+func (c *checker) notation(ctx context.Context, digest string, m *oci.Manifest) {
+	res := spec.SignatureVerification{
+		Type:   TypeSignature,
+		Format: FormatNotation,
+		Source: SourceReferrers,
+		Digest: digest,
+	}
+
+	if len(m.Layers) == 0 {
+		c.add(failed(res, fmt.Errorf("no signature envelope in the manifest")))
+		return
+	}
+
+	layer := m.Layers[0]
+	switch layer.MediaType {
+	case mediaTypeJWS:
+	case mediaTypeCOSE:
+		c.add(failed(res, fmt.Errorf("COSE signature envelope is not supported")))
+		return
+	default:
+		c.add(failed(res, fmt.Errorf("unknown signature envelope %s", layer.MediaType)))
+		return
+	}
+
+	content, err := c.blob(ctx, layer)
+	if err != nil {
+		c.add(failed(res, err))
+		return
+	}
+
+	c.add(c.jws(res, content))
+}
+
+// jws verifies the notation JWS envelope.
+func (c *checker) jws(res spec.SignatureVerification, content []byte) spec.SignatureVerification {
+	env := &jwsEnvelope{}
+	if err := json.Unmarshal(content, env); err != nil {
+		return failed(res, fmt.Errorf("unmarshal JWS envelope error: %w", err))
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(env.Protected)
+	if err != nil {
+		return failed(res, fmt.Errorf("decode JWS protected header error: %w", err))
+	}
+
+	header := &jwsHeader{}
+	if err := json.Unmarshal(protected, header); err != nil {
+		return failed(res, fmt.Errorf("unmarshal JWS protected header error: %w", err))
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(env.Payload)
+	if err != nil {
+		return failed(res, fmt.Errorf("decode JWS payload error: %w", err))
+	}
+
+	np := &notationPayload{}
+	if err := json.Unmarshal(payload, np); err != nil {
+		return failed(res, fmt.Errorf("unmarshal notation payload error: %w", err))
+	}
+
+	if np.TargetArtifact.Digest != c.digest {
+		return failed(res, fmt.Errorf("signed digest %s does not match the artifact", np.TargetArtifact.Digest))
+	}
+
+	sig, err := base64.RawURLEncoding.DecodeString(env.Signature)
+	if err != nil {
+		return failed(res, fmt.Errorf("decode JWS signature error: %w", err))
+	}
+
+	var chain []*x509.Certificate
+	for _, raw := range env.Header.X5C {
+		der, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return failed(res, fmt.Errorf("decode certificate chain error: %w", err))
+		}
+
+		cert, err := x509.ParseCertificate(der)
+		if err != nil {
+			return failed(res, fmt.Errorf("parse certificate chain error: %w", err))
+		}
+		chain = append(chain, cert)
+	}
+
+	if len(chain) == 0 {
+		return failed(res, fmt.Errorf("no certificate chain in the JWS envelope"))
+	}
+
+	if err := c.verifier.trust(chain[0], chain[1:]); err != nil {
+		return failed(res, err)
+	}
+
+	if err := verifyJWS(chain[0].PublicKey, header.Algorithm, []byte(env.Protected+"."+env.Payload), sig); err != nil {
+		return failed(res, err)
+	}
+
+	return verified(res, certSigner(chain[0]))
+}
+
+// manifest fetches the image manifest of the signature or attestation.
+func (c *checker) manifest(ctx context.Context, digest string) (*oci.Manifest, error) {
+	content, _, err := c.remote.Manifest(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	if oci.Digest(content) != digest {
+		return nil, fmt.Errorf("manifest digest mismatch")
+	}
+
+	m := &oci.Manifest{}
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("unmarshal manifest error: %w", err)
+	}
+
+	return m, nil
+}
+
+// blob fetches and verifies the content of the layer within the size limit.
+func (c *checker) blob(ctx context.Context, layer oci.Descriptor) ([]byte, error) {
+	if layer.Size > maxEnvelopeSize {
+		return nil, fmt.Errorf("layer %s is too large: %d bytes", layer.Digest, layer.Size)
+	}
+
+	rc, err := c.remote.Blob(ctx, layer.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("fetch layer %s error: %w", layer.Digest, err)
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(rc, maxEnvelopeSize))
+	if err != nil {
+		return nil, fmt.Errorf("read layer %s error: %w", layer.Digest, err)
+	}
+
+	if oci.Digest(content) != layer.Digest {
+		return nil, fmt.Errorf("layer %s digest mismatch", layer.Digest)
+	}
+
+	return content, nil
+}
+
+func (c *checker) add(res spec.SignatureVerification) {
+	c.results = append(c.results, res)
+}
+
+func (c *checker) warnf(format string, args ...interface{}) {
+	c.warnings = append(c.warnings, fmt.Sprintf(format, args...))
+}
+
+// cosignCertificate parses the signing certificate and chain of the cosign keyless signature.
+// Nil is returned if the signature has no certificate.
+func cosignCertificate(annotations map[string]string) (*x509.Certificate, []*x509.Certificate, error) {
+	pem := annotations[annotationCosignCertificate]
+	if pem == "" {
+		return nil, nil, nil
+	}
+
+	certs, err := parseCertificates(pem)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("no certificate in the certificate annotation")
+	}
+
+	chain, err := parseCertificates(annotations[annotationCosignChain])
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return certs[0], chain, nil
+}
+
+func verified(res spec.SignatureVerification, s *signer) spec.SignatureVerification {
+	res.Verified = true
+	res.Signer = s.identity
+	res.Issuer = s.issuer
+	res.KeyID = s.keyID
+
+	return res
+}
+
+func failed(res spec.SignatureVerification, err error) spec.SignatureVerification {
+	res.Verified = false
+	res.Error = err.Error()
+
+	return res
+}
+
+// simpleSigning is the cosign simple signing payload.
+type simpleSigning struct {
+	Critical struct {
+		Image struct {
+			DockerManifestDigest string `json:"docker-manifest-digest"`
+		} `json:"image"`
+	} `json:"critical"`
+}
+
+// statement is the in-toto statement with the predicate kept raw.
+type statement struct {
+	Type          string           `json:"_type"`
+	Subject       []attest.Subject `json:"subject"`
+	PredicateType string           `json:"predicateType"`
+	Predicate     json.RawMessage  `json:"predicate"`
+}
+
+// refers checks whether the subjects of the statement contain the digest.
+func (st *statement) refers(digest string) bool {
+	parts := strings.SplitN(digest, ":", 2)
+	if len(parts) != 2 {
+		return false
+	}
+
+	for _, s := range st.Subject {
+		if s.Digest[parts[0]] == parts[1] {
+			return true
+		}
+	}
+
+	return false
+}
+
+// builder returns the builder ID and build type of the SLSA provenance predicate.
+func (st *statement) builder() (string, string) {
+	p := &struct {
+		// v0.1 and v0.2.
+		Builder struct {
+			ID string `json:"id"`
+		} `json:"builder"`
+		BuildType string `json:"buildType"`
+		Recipe    struct {
+			Type string `json:"type"`
+		} `json:"recipe"`
+		// v1.
+		BuildDefinition struct {
+			BuildType string `json:"buildType"`
+		} `json:"buildDefinition"`
+		RunDetails struct {
+			Builder struct {
+				ID string `json:"id"`
+			} `json:"builder"`
+		} `json:"runDetails"`
+	}{}
+	if err := json.Unmarshal(st.Predicate, p); err != nil {
+		return "", ""
+	}
+
+	if p.RunDetails.Builder.ID != "" {
+		return p.RunDetails.Builder.ID, p.BuildDefinition.BuildType
+	}
+
+	if p.BuildType != "" {
+		return p.Builder.ID, p.BuildType
+	}
+
+	return p.Builder.ID, p.Recipe.Type
+}
+
+// jwsEnvelope is the JWS JSON serialization of the notation signature.
+type jwsEnvelope struct {
+	Payload   string `json:"payload"`
+	Protected string `json:"protected"`
+	Header    struct {
+		X5C []string `json:"x5c"`
+	} `json:"header"`
+	Signature string `json:"signature"`
+}
+
+// jwsHeader is the protected header of the notation JWS envelope.
+type jwsHeader struct {
+	Algorithm string `json:"alg"`
+}
+
+// notationPayload is the payload of the notation signature.
+type notationPayload struct {
+	TargetArtifact oci.Descriptor `json:"targetArtifact"`
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
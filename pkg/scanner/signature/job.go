@@ -0,0 +1,118 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// JobName is the name of the signature verification job.
+	JobName = "SIGNATURE_VERIFY"
+)
+
+// AddToKnownList adds the signature verification job to the known list.
+func AddToKnownList(l *job.KnownList) error {
+	return signatureEngine.AddToKnownList(l)
+}
+
+// verifySignatures discovers the signatures and attestations referring to the artifact digest and
+// verifies them with the configured trust.
+func verifySignatures(ctx context.Context, t *native.Target) ([]byte, error) {
+	errorf := errs.WithPrefix("")
+
+	verifier, err := LoadVerifier()
+	if err != nil {
+		return nil, errorf.Wrap("load signature verifier error", err)
+	}
+
+	remote, err := oci.NewRemote(t.Registry, t.Artifact.Repository, t.Options)
+	if err != nil {
+		return nil, errorf.Wrap("create registry client error", err)
+	}
+
+	// The signatures refer to the digest of the artifact.
+	if t.Artifact.Digest == "" {
+		desc, err := remote.Resolve(ctx, t.Artifact.Tag)
+		if err != nil {
+			return nil, errorf.Wrap("resolve artifact digest error", err)
+		}
+		t.Artifact.Digest = desc.Digest
+	}
+
+	results, warnings, err := Check(ctx, remote, verifier, t.Artifact.Digest)
+	if err != nil {
+		return nil, errorf.Wrap("check signatures error", err)
+	}
+
+	report := NewReport(t.Artifact, results, warnings)
+	zlog.FromContext(ctx).Infow("signatures are verified", "total", report.Summary.Total, "verified", report.Summary.Verified)
+
+	result, err := json.Marshal(report)
+	if err != nil {
+		return nil, errorf.Wrap("marshal signature report error", err)
+	}
+
+	return result, nil
+}
+
+// NewReport builds the signature report with the summary of the verification results.
+func NewReport(artifact *spec.Artifact, results []spec.SignatureVerification, warnings []string) *spec.HarborSignatureReport {
+	summary := &spec.SignatureSummary{
+		Total: uint(len(results)),
+	}
+
+	builders := make(map[string]bool)
+	for _, r := range results {
+		if !r.Verified {
+			continue
+		}
+		summary.Verified++
+
+		switch r.Type {
+		case TypeSignature:
+			summary.Signed = true
+		case TypeProvenance:
+			summary.Provenance = true
+			if r.Builder != "" && !builders[r.Builder] {
+				builders[r.Builder] = true
+				summary.Builders = append(summary.Builders, r.Builder)
+			}
+		}
+	}
+
+	return &spec.HarborSignatureReport{
+		GeneratedAt: time.Now().UTC(),
+		Artifact:    artifact,
+		Scanner: &spec.Scanner{
+			Name:    Name,
+			Vendor:  Vendor,
+			Version: Version,
+		},
+		Summary:       summary,
+		Verifications: results,
+		Warnings:      warnings,
+	}
+}
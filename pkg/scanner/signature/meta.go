@@ -0,0 +1,36 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+const (
+	// Name of signature provider.
+	Name = "Signature"
+	// Vendor of signature provider.
+	Vendor = "Harbor"
+	// Version of signature provider.
+	Version = "0.1.0"
+	// ReportMimeType is mimetype of signature report.
+	ReportMimeType = "application/vnd.security.signature.report; version=1.0"
+	// CapabilityType is the capability type of verifying signatures and provenance.
+	CapabilityType = "signature"
+)
+
+// ExtraMeta is the signature extra properties.
+var ExtraMeta = map[string]string{
+	"maintainer": "steven-zou",
+	"engine":     "native",
+	"formats":    "cosign,notation,in-toto",
+	"license":    "Apache-2.0",
+}
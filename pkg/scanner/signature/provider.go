@@ -0,0 +1,56 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"sync"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner/native"
+)
+
+// Use singleton provider.
+var provider *native.Provider
+var once sync.Once
+
+// signatureEngine verifies the signatures and the provenance attestations of the artifact.
+var signatureEngine = &native.Engine{
+	Name:           Name,
+	Vendor:         Vendor,
+	Version:        Version,
+	CapabilityType: CapabilityType,
+	ReportMimeType: ReportMimeType,
+	ExtraMeta:      ExtraMeta,
+	JobName:        JobName,
+	// The signatures are discovered in the registry, the local artifacts are not supported.
+	// The signatures of the image index are discovered by the digest of the index.
+	MimeTypes: []string{
+		oci.OCIImage,
+		oci.DockerV2Image,
+		oci.OCIImageIndex,
+		oci.DockerManifestList,
+	},
+	Backend: "signature",
+	Scan:    verifySignatures,
+}
+
+// New a signature provider.
+func New() *native.Provider {
+	once.Do(func() {
+		provider = native.NewProvider(signatureEngine)
+	})
+
+	return provider
+}
@@ -0,0 +1,310 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"math/big"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/attest"
+)
+
+// oidIssuer and oidIssuerV2 are the Fulcio certificate extensions of the OIDC issuer.
+var (
+	oidIssuer   = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 1}
+	oidIssuerV2 = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 57264, 1, 8}
+)
+
+// Verifier verifies the signatures with the trusted public keys and certificate roots.
+type Verifier struct {
+	keys  []crypto.PublicKey
+	roots *x509.CertPool
+}
+
+// signer is the identity of the verified signature.
+type signer struct {
+	identity string
+	issuer   string
+	keyID    string
+}
+
+// LoadVerifier loads the trusted public keys of `scanner.backends.signature.publicKeys`
+// and the certificate roots of `scanner.backends.signature.roots`.
+func LoadVerifier() (*Verifier, error) {
+	return NewVerifier(
+		viper.GetStringSlice("scanner.backends.signature.publicKeys"),
+		viper.GetStringSlice("scanner.backends.signature.roots"),
+	)
+}
+
+// NewVerifier creates the verifier with the PEM files of the public keys and the certificate roots.
+func NewVerifier(keyPaths []string, rootPaths []string) (*Verifier, error) {
+	v := &Verifier{}
+
+	for _, p := range keyPaths {
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return nil, fmt.Errorf("read public key error: %w", err)
+		}
+
+		for {
+			var block *pem.Block
+			if block, content = pem.Decode(content); block == nil {
+				break
+			}
+
+			if block.Type != "PUBLIC KEY" {
+				continue
+			}
+
+			key, err := x509.ParsePKIXPublicKey(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("parse public key %s error: %w", p, err)
+			}
+			v.keys = append(v.keys, key)
+		}
+	}
+
+	if len(rootPaths) > 0 {
+		v.roots = x509.NewCertPool()
+		for _, p := range rootPaths {
+			content, err := ioutil.ReadFile(p)
+			if err != nil {
+				return nil, fmt.Errorf("read certificate roots error: %w", err)
+			}
+
+			if !v.roots.AppendCertsFromPEM(content) {
+				return nil, fmt.Errorf("no certificate found in %s", p)
+			}
+		}
+	}
+
+	if len(v.keys) == 0 && v.roots == nil {
+		return nil, fmt.Errorf("neither public keys nor certificate roots are configured")
+	}
+
+	return v, nil
+}
+
+// verify the signature of the content by the signing certificate if it's attached,
+// otherwise by the trusted keys. The certificate is trusted if it's issued by the roots
+// or its key is trusted.
+func (v *Verifier) verify(content, sig []byte, cert *x509.Certificate, chain []*x509.Certificate) (*signer, error) {
+	if cert != nil {
+		if err := v.trust(cert, chain); err != nil {
+			return nil, err
+		}
+
+		if err := verifySignature(cert.PublicKey, content, sig); err != nil {
+			return nil, err
+		}
+
+		return certSigner(cert), nil
+	}
+
+	for _, key := range v.keys {
+		if verifySignature(key, content, sig) == nil {
+			id, _ := attest.KeyID(key)
+			return &signer{identity: fmt.Sprintf("key:%s", id), keyID: id}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("signature is not verified by the trusted keys")
+}
+
+// trust checks whether the certificate is issued by the roots and valid now,
+// or the key of the certificate is trusted.
+// The signing time asserted by the signer is not trusted, and neither the transparency log
+// entries nor the RFC 3161 timestamps are verified, so the expired certificates, e.g. the
+// short-lived certificates of the keyless signatures, are not trusted.
+func (v *Verifier) trust(cert *x509.Certificate, chain []*x509.Certificate) error {
+	if id, err := attest.KeyID(cert.PublicKey); err == nil {
+		for _, key := range v.keys {
+			if kid, _ := attest.KeyID(key); kid == id {
+				return nil
+			}
+		}
+	}
+
+	if v.roots == nil {
+		return fmt.Errorf("signing certificate %q is not trusted, no certificate roots are configured", cert.Subject)
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, c := range chain {
+		if !bytes.Equal(c.Raw, cert.Raw) {
+			intermediates.AddCert(c)
+		}
+	}
+
+	if _, err := cert.Verify(x509.VerifyOptions{
+		Roots:         v.roots,
+		Intermediates: intermediates,
+		CurrentTime:   time.Now(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}); err != nil {
+		return fmt.Errorf("verify signing certificate %q error: %w", cert.Subject, err)
+	}
+
+	return nil
+}
+
+// certSigner returns the identity of the signing certificate.
+// The email or URI of the SAN is preferred, e.g: the identity of the keyless signatures.
+func certSigner(cert *x509.Certificate) *signer {
+	s := &signer{issuer: cert.Issuer.CommonName}
+	switch {
+	case len(cert.EmailAddresses) > 0:
+		s.identity = cert.EmailAddresses[0]
+	case len(cert.URIs) > 0:
+		s.identity = cert.URIs[0].String()
+	default:
+		s.identity = cert.Subject.String()
+	}
+
+	for _, ext := range cert.Extensions {
+		switch {
+		case ext.Id.Equal(oidIssuer):
+			s.issuer = string(ext.Value)
+		case ext.Id.Equal(oidIssuerV2):
+			var issuer string
+			if _, err := asn1.Unmarshal(ext.Value, &issuer); err == nil {
+				s.issuer = issuer
+			}
+		}
+	}
+
+	if id, err := attest.KeyID(cert.PublicKey); err == nil {
+		s.keyID = id
+	}
+
+	return s
+}
+
+// verifySignature verifies the signature of cosign and DSSE: ASN.1 encoded ECDSA, Ed25519,
+// or RSA PKCS #1 v1.5 and PSS with sha256.
+func verifySignature(key crypto.PublicKey, content, sig []byte) error {
+	if k, ok := key.(*rsa.PublicKey); ok {
+		digest := sha256.Sum256(content)
+		if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+			return nil
+		}
+
+		return rsa.VerifyPSS(k, crypto.SHA256, digest[:], sig, nil)
+	}
+
+	kv, err := attest.NewKeyVerifier(key)
+	if err != nil {
+		return err
+	}
+
+	return kv.Verify(content, sig)
+}
+
+// verifyJWS verifies the JWS signature with the algorithm of the protected header.
+// The ECDSA signature of JWS is the concatenated r and s instead of ASN.1.
+func verifyJWS(key crypto.PublicKey, alg string, content, sig []byte) error {
+	var h crypto.Hash
+	switch alg {
+	case "PS256", "ES256":
+		h = crypto.SHA256
+	case "PS384", "ES384":
+		h = crypto.SHA384
+	case "PS512", "ES512":
+		h = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported JWS algorithm %q", alg)
+	}
+
+	digest := hashOf(h, content)
+
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if alg[0] != 'P' {
+			return fmt.Errorf("JWS algorithm %s does not match the RSA key", alg)
+		}
+
+		return rsa.VerifyPSS(k, h, digest, sig, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+	case *ecdsa.PublicKey:
+		if alg[0] != 'E' || len(sig)%2 != 0 {
+			return fmt.Errorf("JWS algorithm %s does not match the ECDSA key", alg)
+		}
+
+		r := new(big.Int).SetBytes(sig[:len(sig)/2])
+		s := new(big.Int).SetBytes(sig[len(sig)/2:])
+		if !ecdsa.Verify(k, digest, r, s) {
+			return fmt.Errorf("invalid ECDSA signature")
+		}
+
+		return nil
+	case ed25519.PublicKey:
+		return fmt.Errorf("JWS algorithm %s does not match the Ed25519 key", alg)
+	default:
+		return fmt.Errorf("unsupported public key type %T", key)
+	}
+}
+
+// hashOf hashes the content with the hash.
+func hashOf(h crypto.Hash, content []byte) []byte {
+	switch h {
+	case crypto.SHA384:
+		sum := sha512.Sum384(content)
+		return sum[:]
+	case crypto.SHA512:
+		sum := sha512.Sum512(content)
+		return sum[:]
+	default:
+		sum := sha256.Sum256(content)
+		return sum[:]
+	}
+}
+
+// parseCertificates parses the PEM encoded certificates.
+func parseCertificates(content string) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	rest := []byte(content)
+	for {
+		var block *pem.Block
+		if block, rest = pem.Decode(rest); block == nil {
+			break
+		}
+
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parse certificate error: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+
+	return certs, nil
+}
@@ -0,0 +1,206 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package signature
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/attest"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// newCert issues a code signing certificate by the parent, it's self-signed if the parent is nil.
+func newCert(t *testing.T, parent *x509.Certificate, parentKey *ecdsa.PrivateKey, notBefore, notAfter time.Time) (*x509.Certificate, *ecdsa.PrivateKey) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber:   big.NewInt(time.Now().UnixNano()),
+		Subject:        pkix.Name{CommonName: "signer"},
+		EmailAddresses: []string{"dev@example.com"},
+		NotBefore:      notBefore,
+		NotAfter:       notAfter,
+		KeyUsage:       x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:    []x509.ExtKeyUsage{x509.ExtKeyUsageCodeSigning},
+	}
+
+	if parent == nil {
+		tmpl.Subject.CommonName = "root"
+		tmpl.EmailAddresses = nil
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage = x509.KeyUsageCertSign
+		parent, parentKey = tmpl, key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func sign(t *testing.T, key *ecdsa.PrivateKey, content []byte) []byte {
+	t.Helper()
+
+	digest := sha256.Sum256(content)
+	sig, err := ecdsa.SignASN1(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return sig
+}
+
+func TestVerify(t *testing.T) {
+	now := time.Now()
+	root, rootKey := newCert(t, nil, nil, now.Add(-time.Hour), now.Add(time.Hour))
+	other, otherKey := newCert(t, nil, nil, now.Add(-time.Hour), now.Add(time.Hour))
+
+	valid, validKey := newCert(t, root, rootKey, now.Add(-time.Minute), now.Add(time.Minute))
+	// The short-lived certificate of a keyless signature signed in the past.
+	expired, expiredKey := newCert(t, root, rootKey, now.Add(-time.Hour), now.Add(-50*time.Minute))
+	future, futureKey := newCert(t, root, rootKey, now.Add(time.Minute), now.Add(time.Hour))
+	untrusted, untrustedKey := newCert(t, other, otherKey, now.Add(-time.Minute), now.Add(time.Minute))
+	pinned, pinnedKey := newCert(t, root, rootKey, now.Add(-time.Hour), now.Add(-50*time.Minute))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+	v := &Verifier{
+		keys:  []crypto.PublicKey{pinned.PublicKey},
+		roots: roots,
+	}
+
+	pinnedID, err := attest.KeyID(pinned.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	content := []byte("payload")
+	cases := []struct {
+		name     string
+		cert     *x509.Certificate
+		sig      []byte
+		identity string
+		wantErr  string
+	}{
+		{name: "valid certificate", cert: valid, sig: sign(t, validKey, content), identity: "dev@example.com"},
+		{name: "expired certificate", cert: expired, sig: sign(t, expiredKey, content), wantErr: "expired"},
+		{name: "not yet valid certificate", cert: future, sig: sign(t, futureKey, content), wantErr: "expired or is not yet valid"},
+		{name: "untrusted root", cert: untrusted, sig: sign(t, untrustedKey, content), wantErr: "unknown authority"},
+		{name: "trusted key of expired certificate", cert: pinned, sig: sign(t, pinnedKey, content), identity: "dev@example.com"},
+		{name: "wrong signature", cert: valid, sig: sign(t, expiredKey, content), wantErr: "signature"},
+		{name: "trusted key", sig: sign(t, pinnedKey, content), identity: "key:" + pinnedID},
+		{name: "unknown key", sig: sign(t, validKey, content), wantErr: "not verified by the trusted keys"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			s, err := v.verify(content, tc.sig, tc.cert, nil)
+			if tc.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+					t.Fatalf("expect error %q, got %v", tc.wantErr, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("verify error: %v", err)
+			}
+
+			if s.identity != tc.identity {
+				t.Errorf("expect identity %s, got %s", tc.identity, s.identity)
+			}
+		})
+	}
+}
+
+func TestTrustWithoutRoots(t *testing.T) {
+	now := time.Now()
+	root, rootKey := newCert(t, nil, nil, now.Add(-time.Hour), now.Add(time.Hour))
+	cert, _ := newCert(t, root, rootKey, now.Add(-time.Minute), now.Add(time.Minute))
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	v := &Verifier{keys: []crypto.PublicKey{key.Public()}}
+	if err := v.trust(cert, []*x509.Certificate{cert, root}); err == nil || !strings.Contains(err.Error(), "no certificate roots") {
+		t.Errorf("expect untrusted certificate, got %v", err)
+	}
+}
+
+func TestNotationSigningTimeIsNotTrusted(t *testing.T) {
+	now := time.Now()
+	root, rootKey := newCert(t, nil, nil, now.Add(-time.Hour), now.Add(time.Hour))
+	expired, expiredKey := newCert(t, root, rootKey, now.Add(-time.Hour), now.Add(-50*time.Minute))
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root)
+
+	digest := "sha256:" + strings.Repeat("a", 64)
+	c := &checker{verifier: &Verifier{roots: roots}, digest: digest}
+
+	// The signing time asserted in the protected header is within the validity of the certificate.
+	protected := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","io.cncf.notary.signingTime":"` +
+		now.Add(-55*time.Minute).UTC().Format(time.RFC3339) + `"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"targetArtifact":{"digest":"` + digest + `"}}`))
+
+	hashed := sha256.Sum256([]byte(protected + "." + payload))
+	r, ss, err := ecdsa.Sign(rand.Reader, expiredKey, hashed[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := append(r.FillBytes(make([]byte, 32)), ss.FillBytes(make([]byte, 32))...)
+
+	env := map[string]interface{}{
+		"payload":   payload,
+		"protected": protected,
+		"header":    map[string]interface{}{"x5c": []string{base64.StdEncoding.EncodeToString(expired.Raw)}},
+		"signature": base64.RawURLEncoding.EncodeToString(sig),
+	}
+	content, err := json.Marshal(env)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	res := c.jws(spec.SignatureVerification{Type: TypeSignature, Format: FormatNotation}, content)
+	if res.Verified || !strings.Contains(res.Error, "expired") {
+		t.Errorf("expect the expired certificate not verified, got verified=%v error=%q", res.Verified, res.Error)
+	}
+}
@@ -0,0 +1,136 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/szlabs/goworker/pkg/errs"
+	"github.com/szlabs/goworker/pkg/job"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/auth"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/vex"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// suppressed wraps the vulnerability provider to apply the VEX statements to its reports.
+// The statements are applied when the report is retrieved, so the documents added after the scan
+// take effect too.
+type suppressed struct {
+	Provider
+}
+
+// withVEX wraps the providers producing the vulnerability reports.
+func withVEX(regs []registration) []registration {
+	res := make([]registration, 0, len(regs))
+	for _, r := range regs {
+		applies := false
+		for _, m := range r.mimetypes {
+			if vex.Applies(m) {
+				applies = true
+				break
+			}
+		}
+
+		if !applies {
+			res = append(res, r)
+			continue
+		}
+
+		inner := r.new
+		res = append(res, registration{
+			key:       r.key,
+			mimetypes: r.mimetypes,
+			new:       func() Provider { return &suppressed{Provider: inner()} },
+		})
+	}
+
+	return res
+}
+
+// AcceptScanRequest implements Provider.
+// The scan request is kept to identify the artifact and access its VEX referrers later.
+func (s *suppressed) AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error) {
+	res, err := s.Provider.AcceptScanRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	keepRequest(ctx, req)
+
+	return res, nil
+}
+
+// RetrieveScanResult implements Provider.
+// The VEX statements are applied to the ready vulnerability reports.
+func (s *suppressed) RetrieveScanResult(ctx context.Context, reqID string, mimetype string) (scan.Result, error) {
+	res, err := s.Provider.RetrieveScanResult(ctx, reqID, mimetype)
+	if err != nil || !vex.Applies(mimetype) || res.Phase() != scan.ResultPhaseReady {
+		return res, err
+	}
+
+	errorf := errs.WithPrefix("apply VEX statements error")
+
+	report, err := vex.ParseReport([]byte(res.JSON()))
+	if err != nil {
+		return nil, errorf.Wrap("parse report error", err)
+	}
+
+	req, err := keptRequest(reqID)
+	if err != nil {
+		return nil, errorf.Wrap("get scan request error", err)
+	}
+
+	a := artifactOf(req.Artifact, report.Artifact)
+	t := vex.Target{Repository: a.Repository, Tag: a.Tag, Digest: a.Digest}
+	docs := vex.Load(ctx, vexRemote(ctx, req, t), t.Digest)
+	if sups := vex.Apply(report, docs, t, vex.Mode()); len(sups) == 0 {
+		return res, nil
+	}
+
+	bytes, err := json.Marshal(report)
+	if err != nil {
+		return nil, errorf.Wrap("marshal report error", err)
+	}
+
+	suppressedRes := scan.NewJSONResult(mimetype)
+	return suppressedRes, suppressedRes.Write(string(bytes), scan.Phase(scan.ResultPhaseReady), scan.NextTry(0))
+}
+
+// vexRemote returns the registry client to load the VEX referrers if `scanner.vex.referrers` is on.
+// Nil is returned if the artifact is not in a registry.
+func vexRemote(ctx context.Context, req *spec.ScanRequest, t vex.Target) *oci.Remote {
+	if !vex.Referrers() || req.Registry == nil || req.Registry.Url == "" || t.Repository == "" {
+		return nil
+	}
+
+	params := make(job.Parameters)
+	if ap, err := auth.Parse(req.Registry.Authorization); err == nil {
+		_ = ap.Inject(params)
+	}
+	username, _ := params[auth.ParamKeyUsername].(string)
+	password, _ := params[auth.ParamKeyPassword].(string)
+
+	remote, err := oci.NewRemote(req.Registry.Url, t.Repository, oci.RemoteOptions{Username: username, Password: password})
+	if err != nil {
+		zlog.FromContext(ctx).Warnw("create registry client for VEX referrers error", "error", err)
+		return nil
+	}
+
+	return remote
+}
@@ -0,0 +1,113 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package scanner
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/szlabs/goworker/pkg/errs"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/waiver"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// waived wraps the vulnerability and CIS providers to apply the waivers to their reports.
+// The waivers are applied when the report is retrieved, so the expired waivers no longer apply
+// to the reports retrieved after the expiry.
+type waived struct {
+	Provider
+}
+
+// withWaivers wraps the providers producing the vulnerability or CIS reports.
+func withWaivers(regs []registration) []registration {
+	res := make([]registration, 0, len(regs))
+	for _, r := range regs {
+		applies := false
+		for _, m := range r.mimetypes {
+			if waiver.Applies(m) {
+				applies = true
+				break
+			}
+		}
+
+		if !applies {
+			res = append(res, r)
+			continue
+		}
+
+		inner := r.new
+		res = append(res, registration{
+			key:       r.key,
+			mimetypes: r.mimetypes,
+			new:       func() Provider { return &waived{Provider: inner()} },
+		})
+	}
+
+	return res
+}
+
+// AcceptScanRequest implements Provider.
+// The scan request is kept to match the repository of the artifact later.
+func (w *waived) AcceptScanRequest(ctx context.Context, req *spec.ScanRequest) (*spec.ScanResponse, error) {
+	res, err := w.Provider.AcceptScanRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	keepRequest(ctx, req)
+
+	return res, nil
+}
+
+// RetrieveScanResult implements Provider.
+// The active waivers are applied to the ready reports.
+func (w *waived) RetrieveScanResult(ctx context.Context, reqID string, mimetype string) (scan.Result, error) {
+	res, err := w.Provider.RetrieveScanResult(ctx, reqID, mimetype)
+	if err != nil || !waiver.Applies(mimetype) || res.Phase() != scan.ResultPhaseReady {
+		return res, err
+	}
+
+	errorf := errs.WithPrefix("apply waivers error")
+
+	waivers, err := waiver.Active(ctx)
+	if err != nil {
+		return nil, errorf.Wrap("list waivers error", err)
+	}
+	if len(waivers) == 0 {
+		return res, nil
+	}
+
+	req, err := keptRequest(reqID)
+	if err != nil {
+		return nil, errorf.Wrap("get scan request error", err)
+	}
+
+	reported := &struct {
+		Artifact *spec.Artifact `json:"artifact,omitempty"`
+	}{}
+	if err := json.Unmarshal([]byte(res.JSON()), reported); err != nil {
+		return nil, errorf.Wrap("parse report error", err)
+	}
+
+	a := artifactOf(req.Artifact, reported.Artifact)
+	content, err := waiver.Apply(mimetype, []byte(res.JSON()), a.Repository, waivers)
+	if err != nil {
+		return nil, errorf.Wrap("waive findings error", err)
+	}
+
+	waivedRes := scan.NewJSONResult(mimetype)
+	return waivedRes, waivedRes.Write(string(content), scan.Phase(scan.ResultPhaseReady), scan.NextTry(0))
+}
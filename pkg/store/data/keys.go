@@ -77,3 +77,60 @@ func (k *Key) Validate() error {
 
 	return nil
 }
+
+// LayerKey for the per-layer analysis result.
+type LayerKey struct {
+	// Provider name of scanner.
+	Provider string
+	// Fingerprint of the engine version and the settings affecting the result.
+	Fingerprint string
+	// DiffID is the digest of the uncompressed layer.
+	DiffID string
+	prefix []string
+}
+
+// AppendPrefix appends prefix as part of the key.
+func (k *LayerKey) AppendPrefix(prefix string) *LayerKey {
+	// Duplicated prefix is not allowed.
+	for _, p := range k.prefix {
+		if p == prefix {
+			return k
+		}
+	}
+
+	k.prefix = append(k.prefix, prefix)
+	return k
+}
+
+// String format of the key.
+func (k *LayerKey) String() string {
+	segments := make([]string, 0)
+	if start := strings.Join(k.prefix, ":"); len(start) > 0 {
+		segments = append(segments, start)
+	}
+
+	segments = append(segments, "layer", k.Provider, k.Fingerprint, k.DiffID)
+
+	return strings.ReplaceAll(strings.Join(segments, ":"), " ", "_")
+}
+
+// Validate the LayerKey.
+func (k *LayerKey) Validate() error {
+	if k == nil {
+		return fmt.Errorf("nil layer key")
+	}
+
+	if k.Provider == "" {
+		return fmt.Errorf("empty provider of layer key")
+	}
+
+	if k.Fingerprint == "" {
+		return fmt.Errorf("empty fingerprint of layer key")
+	}
+
+	if k.DiffID == "" {
+		return fmt.Errorf("empty diff ID of layer key")
+	}
+
+	return nil
+}
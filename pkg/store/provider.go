@@ -17,6 +17,8 @@ package store
 import (
 	"sync"
 
+	"github.com/spf13/viper"
+
 	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
 	"github.com/szlabs/harbor-scanner-adapter/pkg/store/rds"
 )
@@ -42,9 +44,60 @@ type Provider interface {
 	GetResult(key *data.Key) (*data.Item, error)
 }
 
+// LayerProvider to provide the storage of the per-layer analysis results.
+type LayerProvider interface {
+	// GetLayer retrieves the analysis result of the layer.
+	// If the result is not found, then NOT_FOUND error should be returned.
+	GetLayer(key *data.LayerKey) ([]byte, error)
+	// SaveLayer saves the analysis result of the layer.
+	// The results exceeding the size limit of the store are skipped.
+	SaveLayer(key *data.LayerKey, content []byte) error
+}
+
+// VEXProvider to provide the storage of the VEX documents uploaded via the API.
+type VEXProvider interface {
+	// ListVEX returns the contents of all the VEX documents by the IDs.
+	ListVEX() (map[string][]byte, error)
+	// GetVEX retrieves the content of the VEX document.
+	// If the document is not found, then NOT_FOUND error should be returned.
+	GetVEX(id string) ([]byte, error)
+	// SaveVEX creates or replaces the VEX document.
+	SaveVEX(id string, content []byte) error
+	// DeleteVEX removes the VEX document.
+	// If the document is not found, then NOT_FOUND error should be returned.
+	DeleteVEX(id string) error
+}
+
+// WaiverProvider to provide the storage of the vulnerability waivers and the audit log of their changes.
+type WaiverProvider interface {
+	// ListWaivers returns the contents of all the waivers by the IDs.
+	ListWaivers() (map[string][]byte, error)
+	// GetWaiver retrieves the content of the waiver.
+	// If the waiver is not found, then NOT_FOUND error should be returned.
+	GetWaiver(id string) ([]byte, error)
+	// SaveWaiver creates or replaces the waiver.
+	SaveWaiver(id string, content []byte) error
+	// DeleteWaiver removes the waiver.
+	// If the waiver is not found, then NOT_FOUND error should be returned.
+	DeleteWaiver(id string) error
+	// AppendAudit appends the entry to the audit log, the oldest entries exceeding the size limit are dropped.
+	AppendAudit(entry []byte) error
+	// ListAudit returns the latest entries of the audit log, the latest first.
+	ListAudit(limit int) ([][]byte, error)
+}
+
 var defaultProvider Provider
 var once sync.Once
 
+var layerProvider LayerProvider
+var onceLayer sync.Once
+
+var vexProvider VEXProvider
+var onceVEX sync.Once
+
+var waiverProvider WaiverProvider
+var onceWaiver sync.Once
+
 // Default returns the default store provider.
 // The default provider is redis.Provider.
 func Default() Provider {
@@ -54,3 +107,36 @@ func Default() Provider {
 
 	return defaultProvider
 }
+
+// Layers returns the store provider of the per-layer analysis results.
+// The layer provider is redis.LayerProvider.
+func Layers() LayerProvider {
+	onceLayer.Do(func() {
+		layerProvider = rds.NewLayerProvider(
+			viper.GetDuration("scanner.layerCache.ttl"),
+			viper.GetInt("scanner.layerCache.maxEntrySize"),
+		)
+	})
+
+	return layerProvider
+}
+
+// VEX returns the store provider of the VEX documents.
+// The VEX provider is redis.VEXProvider.
+func VEX() VEXProvider {
+	onceVEX.Do(func() {
+		vexProvider = rds.NewVEXProvider()
+	})
+
+	return vexProvider
+}
+
+// Waivers returns the store provider of the vulnerability waivers.
+// The waiver provider is redis.WaiverProvider.
+func Waivers() WaiverProvider {
+	onceWaiver.Do(func() {
+		waiverProvider = rds.NewWaiverProvider(viper.GetInt("scanner.waivers.auditSize"))
+	})
+
+	return waiverProvider
+}
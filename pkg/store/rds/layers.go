@@ -0,0 +1,114 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rds
+
+import (
+	"errors"
+	"time"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/szlabs/goworker/pkg/errs"
+	rd "github.com/szlabs/harbor-scanner-adapter/pkg/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/data"
+)
+
+const (
+	defaultLayerLife    = 7 * 24 * time.Hour
+	defaultMaxLayerSize = 1 << 20 // bytes
+)
+
+// LayerProvider stores the per-layer analysis results in redis.
+type LayerProvider struct {
+	pool    *redis.Pool
+	ttl     time.Duration
+	maxSize int
+}
+
+// NewLayerProvider creates a redis layer provider.
+// The results expire after the ttl, and the results larger than maxSize bytes are not stored.
+func NewLayerProvider(ttl time.Duration, maxSize int) *LayerProvider {
+	pool, err := rd.RedisPool()
+	if err != nil {
+		panic(err)
+	}
+
+	if ttl <= 0 {
+		ttl = defaultLayerLife
+	}
+
+	if maxSize <= 0 {
+		maxSize = defaultMaxLayerSize
+	}
+
+	return &LayerProvider{
+		pool:    pool,
+		ttl:     ttl,
+		maxSize: maxSize,
+	}
+}
+
+// GetLayer implements store.LayerProvider.
+func (p *LayerProvider) GetLayer(key *data.LayerKey) ([]byte, error) {
+	errorf := errs.WithPrefix("get layer result error")
+
+	if err := key.Validate(); err != nil {
+		return nil, errorf.Wrap("validate layer key error", err)
+	}
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	k := key.String()
+
+	content, err := redis.Bytes(conn.Do("GET", k))
+	if err != nil {
+		if errors.Is(err, redis.ErrNil) {
+			return nil, NotFoundErr
+		}
+
+		return nil, errorf.Wrap("retrieve layer result error", err, "key", k)
+	}
+
+	// Extend the life of the used results.
+	if _, err := conn.Do("EXPIRE", k, int64(p.ttl/time.Second)); err != nil {
+		return nil, errorf.Wrap("extend layer result error", err, "key", k)
+	}
+
+	return content, nil
+}
+
+// SaveLayer implements store.LayerProvider.
+func (p *LayerProvider) SaveLayer(key *data.LayerKey, content []byte) error {
+	errorf := errs.WithPrefix("save layer result error")
+
+	if err := key.Validate(); err != nil {
+		return errorf.Wrap("validate layer key error", err)
+	}
+
+	if len(content) > p.maxSize {
+		return nil
+	}
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	k := key.String()
+
+	if _, err := conn.Do("SET", k, content, "EX", int64(p.ttl/time.Second)); err != nil {
+		return errorf.Wrap("store layer result error", err, "key", k)
+	}
+
+	return nil
+}
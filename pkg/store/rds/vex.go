@@ -0,0 +1,116 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rds
+
+import (
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/szlabs/goworker/pkg/errs"
+	rd "github.com/szlabs/harbor-scanner-adapter/pkg/rds"
+)
+
+// vexKey is the hash of the VEX documents, the fields are the document IDs.
+// The documents are kept until they're deleted.
+const vexKey = "{vex-store}:documents"
+
+// VEXProvider stores the VEX documents in redis.
+type VEXProvider struct {
+	pool *redis.Pool
+}
+
+// NewVEXProvider creates a redis VEX provider.
+func NewVEXProvider() *VEXProvider {
+	pool, err := rd.RedisPool()
+	if err != nil {
+		panic(err)
+	}
+
+	return &VEXProvider{
+		pool: pool,
+	}
+}
+
+// ListVEX implements store.VEXProvider.
+func (p *VEXProvider) ListVEX() (map[string][]byte, error) {
+	errorf := errs.WithPrefix("list VEX documents error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	bytes, err := redis.ByteSlices(conn.Do("HGETALL", vexKey))
+	if err != nil {
+		return nil, errorf.Wrap("retrieve VEX documents error", err, "key", vexKey)
+	}
+
+	docs := make(map[string][]byte, len(bytes)/2)
+	for i := 0; i+1 < len(bytes); i = i + 2 {
+		docs[string(bytes[i])] = bytes[i+1]
+	}
+
+	return docs, nil
+}
+
+// GetVEX implements store.VEXProvider.
+func (p *VEXProvider) GetVEX(id string) ([]byte, error) {
+	errorf := errs.WithPrefix("get VEX document error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	content, err := redis.Bytes(conn.Do("HGET", vexKey, id))
+	if err != nil {
+		if errors.Is(err, redis.ErrNil) {
+			return nil, NotFoundErr
+		}
+
+		return nil, errorf.Wrap("retrieve VEX document error", err, "id", id)
+	}
+
+	return content, nil
+}
+
+// SaveVEX implements store.VEXProvider.
+func (p *VEXProvider) SaveVEX(id string, content []byte) error {
+	errorf := errs.WithPrefix("save VEX document error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	if _, err := conn.Do("HSET", vexKey, id, content); err != nil {
+		return errorf.Wrap("store VEX document error", err, "id", id)
+	}
+
+	return nil
+}
+
+// DeleteVEX implements store.VEXProvider.
+func (p *VEXProvider) DeleteVEX(id string) error {
+	errorf := errs.WithPrefix("delete VEX document error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	n, err := redis.Int(conn.Do("HDEL", vexKey, id))
+	if err != nil {
+		return errorf.Wrap("remove VEX document error", err, "id", id)
+	}
+
+	if n == 0 {
+		return NotFoundErr
+	}
+
+	return nil
+}
@@ -0,0 +1,168 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rds
+
+import (
+	"errors"
+
+	"github.com/gomodule/redigo/redis"
+	"github.com/szlabs/goworker/pkg/errs"
+	rd "github.com/szlabs/harbor-scanner-adapter/pkg/rds"
+)
+
+const (
+	// waiversKey is the hash of the waivers, the fields are the waiver IDs.
+	// The waivers are kept until they're deleted, the expired ones included.
+	waiversKey = "{waiver-store}:waivers"
+	// auditKey is the list of the audit log entries, the latest first.
+	auditKey = "{waiver-store}:audit"
+
+	defaultAuditSize = 10000
+)
+
+// WaiverProvider stores the waivers and the audit log in redis.
+type WaiverProvider struct {
+	pool      *redis.Pool
+	auditSize int
+}
+
+// NewWaiverProvider creates a redis waiver provider.
+// The audit log keeps the latest auditSize entries.
+func NewWaiverProvider(auditSize int) *WaiverProvider {
+	pool, err := rd.RedisPool()
+	if err != nil {
+		panic(err)
+	}
+
+	if auditSize <= 0 {
+		auditSize = defaultAuditSize
+	}
+
+	return &WaiverProvider{
+		pool:      pool,
+		auditSize: auditSize,
+	}
+}
+
+// ListWaivers implements store.WaiverProvider.
+func (p *WaiverProvider) ListWaivers() (map[string][]byte, error) {
+	errorf := errs.WithPrefix("list waivers error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	bytes, err := redis.ByteSlices(conn.Do("HGETALL", waiversKey))
+	if err != nil {
+		return nil, errorf.Wrap("retrieve waivers error", err, "key", waiversKey)
+	}
+
+	waivers := make(map[string][]byte, len(bytes)/2)
+	for i := 0; i+1 < len(bytes); i = i + 2 {
+		waivers[string(bytes[i])] = bytes[i+1]
+	}
+
+	return waivers, nil
+}
+
+// GetWaiver implements store.WaiverProvider.
+func (p *WaiverProvider) GetWaiver(id string) ([]byte, error) {
+	errorf := errs.WithPrefix("get waiver error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	content, err := redis.Bytes(conn.Do("HGET", waiversKey, id))
+	if err != nil {
+		if errors.Is(err, redis.ErrNil) {
+			return nil, NotFoundErr
+		}
+
+		return nil, errorf.Wrap("retrieve waiver error", err, "id", id)
+	}
+
+	return content, nil
+}
+
+// SaveWaiver implements store.WaiverProvider.
+func (p *WaiverProvider) SaveWaiver(id string, content []byte) error {
+	errorf := errs.WithPrefix("save waiver error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	if _, err := conn.Do("HSET", waiversKey, id, content); err != nil {
+		return errorf.Wrap("store waiver error", err, "id", id)
+	}
+
+	return nil
+}
+
+// DeleteWaiver implements store.WaiverProvider.
+func (p *WaiverProvider) DeleteWaiver(id string) error {
+	errorf := errs.WithPrefix("delete waiver error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	n, err := redis.Int(conn.Do("HDEL", waiversKey, id))
+	if err != nil {
+		return errorf.Wrap("remove waiver error", err, "id", id)
+	}
+
+	if n == 0 {
+		return NotFoundErr
+	}
+
+	return nil
+}
+
+// AppendAudit implements store.WaiverProvider.
+func (p *WaiverProvider) AppendAudit(entry []byte) error {
+	errorf := errs.WithPrefix("append waiver audit error")
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	if err := conn.Send("LPUSH", auditKey, entry); err != nil {
+		return errorf.Wrap("send command error", err, "key", auditKey, "command", "LPUSH")
+	}
+	if err := conn.Send("LTRIM", auditKey, 0, p.auditSize-1); err != nil {
+		return errorf.Wrap("send command error", err, "key", auditKey, "command", "LTRIM")
+	}
+	if err := conn.Flush(); err != nil {
+		return errorf.Wrap("store audit entry error", err, "key", auditKey)
+	}
+
+	return nil
+}
+
+// ListAudit implements store.WaiverProvider.
+func (p *WaiverProvider) ListAudit(limit int) ([][]byte, error) {
+	errorf := errs.WithPrefix("list waiver audit error")
+
+	if limit <= 0 || limit > p.auditSize {
+		limit = p.auditSize
+	}
+
+	conn := p.pool.Get()
+	defer rd.CloseConn(conn)
+
+	entries, err := redis.ByteSlices(conn.Do("LRANGE", auditKey, 0, limit-1))
+	if err != nil {
+		return nil, errorf.Wrap("retrieve audit entries error", err, "key", auditKey)
+	}
+
+	return entries, nil
+}
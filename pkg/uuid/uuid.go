@@ -23,6 +23,8 @@ import (
 const (
 	// requestIDCtxKey is the key injected into the context.Context.
 	requestIDCtxKey ctxKeyType = "REQUEST_ID"
+	// correlationIDCtxKey is the key of the correlation ID injected into the context.Context.
+	correlationIDCtxKey ctxKeyType = "CORRELATION_ID"
 )
 
 // ctxKeyType is the key type for the request ID.
@@ -36,7 +38,13 @@ func Random() string {
 // WithContext injects request ID into the context.
 func WithContext(ctx context.Context) (context.Context, string) {
 	reqID := Random()
-	return context.WithValue(ctx, requestIDCtxKey, reqID), reqID
+	return WithRequestID(ctx, reqID), reqID
+}
+
+// WithRequestID injects the specified request ID into the context.
+// It's used to restore the request ID of the accepted scan request, e.g. in the scan job.
+func WithRequestID(ctx context.Context, reqID string) context.Context {
+	return context.WithValue(ctx, requestIDCtxKey, reqID)
 }
 
 // FromContext extracts the request ID from the context.
@@ -49,3 +57,20 @@ func FromContext(ctx context.Context) string {
 
 	return ""
 }
+
+// WithCorrelationID injects the correlation ID passed from the upstream, e.g. the X-Request-Id header.
+// The correlation ID is only used to correlate the logs, it never identifies the scan request.
+func WithCorrelationID(ctx context.Context, corrID string) context.Context {
+	return context.WithValue(ctx, correlationIDCtxKey, corrID)
+}
+
+// CorrelationIDFromContext extracts the correlation ID from the context.
+func CorrelationIDFromContext(ctx context.Context) string {
+	if v := ctx.Value(correlationIDCtxKey); v != nil {
+		if s, ok := v.(string); ok {
+			return s
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,371 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vex
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+const (
+	// StatusNotAffected means the product is not affected by the vulnerability.
+	StatusNotAffected = "not_affected"
+	// StatusAffected means the product is affected by the vulnerability.
+	StatusAffected = "affected"
+	// StatusFixed means the product contains the fix of the vulnerability.
+	StatusFixed = "fixed"
+	// StatusUnderInvestigation means it's not known yet whether the product is affected.
+	StatusUnderInvestigation = "under_investigation"
+
+	// MediaType of the OpenVEX documents, also the artifact type of the VEX referrers.
+	MediaType = "application/openvex+json"
+	// contextPrefix is the prefix of the JSON-LD context of all the OpenVEX versions,
+	// also the prefix of the in-toto predicate types of the VEX attestations.
+	contextPrefix = "https://openvex.dev/ns"
+)
+
+// Document is an OpenVEX document.
+type Document struct {
+	Context    string      `json:"@context"`
+	ID         string      `json:"@id"`
+	Author     string      `json:"author,omitempty"`
+	Timestamp  *time.Time  `json:"timestamp,omitempty"`
+	Version    int         `json:"version,omitempty"`
+	Statements []Statement `json:"statements"`
+
+	// source of the document, e.g: the file path or the referrer digest.
+	source string
+	// attached documents are the referrers of the artifact, whose statements apply to it
+	// no matter how the products are identified.
+	attached bool
+}
+
+// Statement of the status of a vulnerability in the products.
+type Statement struct {
+	Vulnerability   Vulnerability `json:"vulnerability"`
+	Products        []Product     `json:"products,omitempty"`
+	Status          string        `json:"status"`
+	Justification   string        `json:"justification,omitempty"`
+	ImpactStatement string        `json:"impact_statement,omitempty"`
+	ActionStatement string        `json:"action_statement,omitempty"`
+	Timestamp       *time.Time    `json:"timestamp,omitempty"`
+}
+
+// Vulnerability referred by the statement.
+type Vulnerability struct {
+	ID      string   `json:"@id,omitempty"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+// The vulnerability is a plain string in the OpenVEX versions before v0.2.0.
+func (v *Vulnerability) UnmarshalJSON(data []byte) error {
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		v.Name = name
+		return nil
+	}
+
+	type vulnerability Vulnerability
+	return json.Unmarshal(data, (*vulnerability)(v))
+}
+
+// Product of the statement with the optional subcomponents, i.e. the affected packages.
+type Product struct {
+	Component
+	Subcomponents []Component `json:"subcomponents,omitempty"`
+}
+
+// Component is identified by the IRI, e.g: a purl, or the identifiers and hashes.
+type Component struct {
+	ID          string            `json:"@id,omitempty"`
+	Identifiers map[string]string `json:"identifiers,omitempty"`
+	Hashes      map[string]string `json:"hashes,omitempty"`
+}
+
+// Target is the scanned artifact matched with the products of the statements.
+type Target struct {
+	// Repository of the artifact, e.g: library/nginx.
+	Repository string
+	// Tag of the artifact, optional.
+	Tag string
+	// Digest of the artifact manifest.
+	Digest string
+}
+
+// Parse the OpenVEX document.
+func Parse(content []byte) (*Document, error) {
+	doc := &Document{}
+	if err := json.Unmarshal(content, doc); err != nil {
+		return nil, fmt.Errorf("unmarshal OpenVEX document error: %w", err)
+	}
+
+	if !strings.HasPrefix(doc.Context, contextPrefix) {
+		return nil, fmt.Errorf("unsupported OpenVEX context: %q", doc.Context)
+	}
+
+	for i, st := range doc.Statements {
+		if st.Vulnerability.Name == "" {
+			return nil, fmt.Errorf("statement %d: vulnerability name is required", i)
+		}
+
+		switch st.Status {
+		case StatusNotAffected, StatusAffected, StatusFixed, StatusUnderInvestigation:
+		default:
+			return nil, fmt.Errorf("statement %d: unknown status %q", i, st.Status)
+		}
+	}
+
+	return doc, nil
+}
+
+// timestamp of the statement, inherited from the document if it's not set.
+func (d *Document) timestamp(st *Statement) time.Time {
+	switch {
+	case st.Timestamp != nil:
+		return *st.Timestamp
+	case d.Timestamp != nil:
+		return *d.Timestamp
+	default:
+		return time.Time{}
+	}
+}
+
+// refers checks whether the statement is about the vulnerability, by the name or the aliases.
+func (st *Statement) refers(id string) bool {
+	if strings.EqualFold(st.Vulnerability.Name, id) {
+		return true
+	}
+
+	for _, a := range st.Vulnerability.Aliases {
+		if strings.EqualFold(a, id) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// applies checks whether the statement applies to the package of the target.
+// The statement applies to all the packages of the product if the product has no subcomponents.
+func (st *Statement) applies(t Target, attached bool, pkg string, version string) bool {
+	for _, p := range st.Products {
+		if !attached && !p.matches(t) {
+			continue
+		}
+
+		if len(p.Subcomponents) == 0 {
+			return true
+		}
+
+		for _, s := range p.Subcomponents {
+			if s.matchesPackage(pkg, version) {
+				return true
+			}
+		}
+	}
+
+	// The statements of the attached documents may omit the products.
+	return attached && len(st.Products) == 0
+}
+
+// matches checks whether the component identifies the target.
+func (c *Component) matches(t Target) bool {
+	if t.Digest != "" {
+		hex := strings.TrimPrefix(t.Digest, "sha256:")
+		if h, ok := c.Hashes["sha-256"]; ok && strings.EqualFold(h, hex) {
+			return true
+		}
+	}
+
+	for _, id := range c.ids() {
+		if matchesImage(id, t) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// matchesPackage checks whether the component identifies the package, the version is checked
+// only if the component is a purl with the version.
+func (c *Component) matchesPackage(pkg string, version string) bool {
+	for _, id := range c.ids() {
+		p, ok := parsePURL(id)
+		if !ok {
+			if id == pkg {
+				return true
+			}
+			continue
+		}
+
+		if p.version != "" && p.version != version {
+			continue
+		}
+
+		for _, name := range p.names() {
+			if name == pkg {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// ids of the component, the IRI and the purl identifier.
+func (c *Component) ids() []string {
+	var ids []string
+	if c.ID != "" {
+		ids = append(ids, c.ID)
+	}
+
+	if p := c.Identifiers["purl"]; p != "" && p != c.ID {
+		ids = append(ids, p)
+	}
+
+	return ids
+}
+
+// matchesImage checks whether the ID identifies the target, it's one of:
+//   - the digest, e.g: sha256:<hex>
+//   - the OCI purl, e.g: pkg:oci/nginx@sha256%3A<hex>?repository_url=harbor.example.com/library/nginx
+//   - the image reference, e.g: harbor.example.com/library/nginx@sha256:<hex> or library/nginx:1.25
+func matchesImage(id string, t Target) bool {
+	if strings.HasPrefix(id, "sha256:") {
+		return t.Digest != "" && id == t.Digest
+	}
+
+	if p, ok := parsePURL(id); ok {
+		if p.typ != "oci" {
+			return false
+		}
+
+		if p.version != "" && p.version != t.Digest {
+			return false
+		}
+
+		if ru := p.qualifiers.Get("repository_url"); ru != "" {
+			return sameRepository(ru, t.Repository)
+		}
+
+		// The purl name is the last path segment of the repository.
+		return p.version != "" || p.name == path.Base(t.Repository)
+	}
+
+	name := id
+	if i := strings.Index(name, "@"); i >= 0 {
+		if t.Digest == "" || name[i+1:] != t.Digest {
+			return false
+		}
+		name = name[:i]
+	} else if i := strings.LastIndex(name, ":"); i > strings.LastIndex(name, "/") {
+		if name[i+1:] != t.Tag {
+			return false
+		}
+		name = name[:i]
+	}
+
+	return sameRepository(name, t.Repository)
+}
+
+// sameRepository checks whether the repository name, optionally with the registry host, is the repository.
+func sameRepository(name string, repository string) bool {
+	if repository == "" {
+		return false
+	}
+
+	if i := strings.Index(name, "://"); i >= 0 {
+		name = name[i+3:]
+	}
+
+	return name == repository || strings.HasSuffix(name, "/"+repository)
+}
+
+// purl is the package URL, see https://github.com/package-url/purl-spec.
+type purl struct {
+	typ        string
+	namespace  string
+	name       string
+	version    string
+	qualifiers url.Values
+}
+
+// parsePURL parses the package URL.
+func parsePURL(s string) (*purl, bool) {
+	if !strings.HasPrefix(s, "pkg:") {
+		return nil, false
+	}
+	s = strings.TrimPrefix(s, "pkg:")
+
+	if i := strings.Index(s, "#"); i >= 0 {
+		s = s[:i]
+	}
+
+	p := &purl{}
+	if i := strings.Index(s, "?"); i >= 0 {
+		q, err := url.ParseQuery(s[i+1:])
+		if err != nil {
+			return nil, false
+		}
+		p.qualifiers, s = q, s[:i]
+	}
+
+	if i := strings.LastIndex(s, "@"); i >= 0 {
+		v, err := url.PathUnescape(s[i+1:])
+		if err != nil {
+			return nil, false
+		}
+		p.version, s = v, s[:i]
+	}
+
+	segments := strings.Split(strings.Trim(s, "/"), "/")
+	if len(segments) < 2 {
+		return nil, false
+	}
+
+	for i, seg := range segments {
+		v, err := url.PathUnescape(seg)
+		if err != nil {
+			return nil, false
+		}
+		segments[i] = v
+	}
+
+	p.typ = strings.ToLower(segments[0])
+	p.name = segments[len(segments)-1]
+	p.namespace = strings.Join(segments[1:len(segments)-1], "/")
+
+	return p, true
+}
+
+// names of the package as reported by the scanners, e.g: openssl for the OS packages,
+// github.com/foo/bar for the Go modules, @scope/name for npm and group:artifact for maven.
+func (p *purl) names() []string {
+	names := []string{p.name}
+	if p.namespace != "" {
+		names = append(names, p.namespace+"/"+p.name)
+		if p.typ == "maven" {
+			names = append(names, p.namespace+":"+p.name)
+		}
+	}
+
+	return names
+}
@@ -0,0 +1,125 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vex
+
+import (
+	"testing"
+)
+
+const digest = "sha256:4c0fdaa8b6341bfdeca5f18f7837462c80cff90527ee35ef185571e1c327beac"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		name    string
+		content string
+		valid   bool
+	}{
+		{
+			name:    "valid",
+			content: `{"@context":"https://openvex.dev/ns/v0.2.0","@id":"doc","statements":[{"vulnerability":{"name":"CVE-2023-0001"},"status":"not_affected"}]}`,
+			valid:   true,
+		},
+		{
+			name:    "legacy vulnerability string",
+			content: `{"@context":"https://openvex.dev/ns","@id":"doc","statements":[{"vulnerability":"CVE-2023-0001","status":"fixed"}]}`,
+			valid:   true,
+		},
+		{
+			name:    "unsupported context",
+			content: `{"@context":"https://example.com/ns","statements":[]}`,
+		},
+		{
+			name:    "missing vulnerability",
+			content: `{"@context":"https://openvex.dev/ns/v0.2.0","statements":[{"status":"fixed"}]}`,
+		},
+		{
+			name:    "unknown status",
+			content: `{"@context":"https://openvex.dev/ns/v0.2.0","statements":[{"vulnerability":{"name":"CVE-2023-0001"},"status":"wontfix"}]}`,
+		},
+		{
+			name:    "invalid JSON",
+			content: `{`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc, err := Parse([]byte(c.content))
+			if c.valid {
+				if err != nil {
+					t.Fatalf("expect the document to be valid, got %v", err)
+				}
+				if doc.Statements[0].Vulnerability.Name != "CVE-2023-0001" {
+					t.Errorf("expect the vulnerability CVE-2023-0001, got %q", doc.Statements[0].Vulnerability.Name)
+				}
+			} else if err == nil {
+				t.Error("expect the document to be invalid")
+			}
+		})
+	}
+}
+
+func TestMatchesImage(t *testing.T) {
+	target := Target{Repository: "library/nginx", Tag: "1.25", Digest: digest}
+
+	cases := []struct {
+		id    string
+		match bool
+	}{
+		{id: digest, match: true},
+		{id: "sha256:0000", match: false},
+		{id: "pkg:oci/nginx@sha256%3A" + digest[7:] + "?repository_url=harbor.example.com/library/nginx", match: true},
+		{id: "pkg:oci/nginx@sha256%3A" + digest[7:] + "?repository_url=harbor.example.com/team/nginx", match: false},
+		{id: "pkg:oci/nginx", match: true},
+		{id: "pkg:oci/redis", match: false},
+		{id: "pkg:deb/debian/nginx", match: false},
+		{id: "harbor.example.com/library/nginx@" + digest, match: true},
+		{id: "library/nginx:1.25", match: true},
+		{id: "library/nginx:1.24", match: false},
+		{id: "harbor.example.com/library/nginx", match: true},
+		{id: "harbor.example.com/team/nginx", match: false},
+	}
+
+	for _, c := range cases {
+		if m := matchesImage(c.id, target); m != c.match {
+			t.Errorf("expect %s to match %v, got %v", c.id, c.match, m)
+		}
+	}
+}
+
+func TestMatchesPackage(t *testing.T) {
+	cases := []struct {
+		id      string
+		pkg     string
+		version string
+		match   bool
+	}{
+		{id: "pkg:deb/debian/openssl@3.0.11", pkg: "openssl", version: "3.0.11", match: true},
+		{id: "pkg:deb/debian/openssl@3.0.11", pkg: "openssl", version: "3.0.13", match: false},
+		{id: "pkg:deb/debian/openssl", pkg: "openssl", version: "3.0.13", match: true},
+		{id: "pkg:golang/golang.org/x/net", pkg: "golang.org/x/net", match: true},
+		{id: "pkg:npm/%40babel/core", pkg: "@babel/core", match: true},
+		{id: "pkg:maven/org.apache.logging.log4j/log4j-core", pkg: "org.apache.logging.log4j:log4j-core", match: true},
+		{id: "openssl", pkg: "openssl", match: true},
+		{id: "openssl", pkg: "libssl3", match: false},
+	}
+
+	for _, c := range cases {
+		comp := &Component{ID: c.id}
+		if m := comp.matchesPackage(c.pkg, c.version); m != c.match {
+			t.Errorf("expect %s to match %s@%s %v, got %v", c.id, c.pkg, c.version, c.match, m)
+		}
+	}
+}
@@ -0,0 +1,316 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vex
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"io"
+	"io/fs"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/attest"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// maxDocumentSize is the size limit of the VEX documents.
+	maxDocumentSize = 4 << 20
+	// maxReferrers is the limit of the VEX referrers fetched for an artifact.
+	maxReferrers = 16
+)
+
+// metrics counts the loaded documents, the suppressed vulnerabilities and the invalid documents,
+// served by the /metrics API.
+var metrics = expvar.NewMap("vex")
+
+// Enabled checks whether the VEX statements are applied by `scanner.vex.enabled`.
+func Enabled() bool {
+	return viper.GetBool("scanner.vex.enabled")
+}
+
+// Mode of applying the statements, ModeDrop by default.
+func Mode() string {
+	if viper.GetString("scanner.vex.mode") == ModeAnnotate {
+		return ModeAnnotate
+	}
+
+	return ModeDrop
+}
+
+// Referrers checks whether the VEX documents attached to the artifacts are loaded by `scanner.vex.referrers`.
+func Referrers() bool {
+	return viper.GetBool("scanner.vex.referrers")
+}
+
+// Key of the document uploaded via the API, derived from the @id of the document
+// so that the new versions of the document replace the old ones.
+func (d *Document) Key() string {
+	sum := sha256.Sum256([]byte(d.ID))
+	return hex.EncodeToString(sum[:])
+}
+
+// Load the documents applying to the artifact in order: the documents under `scanner.vex.dir`,
+// the documents uploaded via the API and the referrers of the artifact if the remote is set.
+// The invalid documents and the unavailable sources are logged and skipped.
+func Load(ctx context.Context, remote *oci.Remote, digest string) []*Document {
+	lg := zlog.FromContext(ctx)
+
+	var docs []*Document
+	if dir := viper.GetString("scanner.vex.dir"); dir != "" {
+		found, err := loadDir(ctx, dir)
+		if err != nil {
+			lg.Warnw("load VEX documents from directory error", "dir", dir, "error", err)
+		}
+		docs = append(docs, found...)
+	}
+
+	found, err := loadStore(ctx)
+	if err != nil {
+		lg.Warnw("load uploaded VEX documents error", "error", err)
+	}
+	docs = append(docs, found...)
+
+	if remote != nil && digest != "" {
+		found, err := loadReferrers(ctx, remote, digest)
+		if err != nil {
+			lg.Warnw("load VEX referrers error", "digest", digest, "error", err)
+		}
+		docs = append(docs, found...)
+	}
+
+	metrics.Add("documents", int64(len(docs)))
+
+	return docs
+}
+
+// loadDir loads the JSON files under the directory recursively.
+func loadDir(ctx context.Context, dir string) ([]*Document, error) {
+	var docs []*Document
+	err := filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || !strings.HasSuffix(d.Name(), ".json") {
+			return nil
+		}
+
+		content, err := ioutil.ReadFile(p)
+		if err != nil {
+			return err
+		}
+
+		if doc := parse(ctx, content, p); doc != nil {
+			docs = append(docs, doc)
+		}
+
+		return nil
+	})
+
+	return docs, err
+}
+
+// loadStore loads the documents uploaded via the API.
+func loadStore(ctx context.Context) ([]*Document, error) {
+	contents, err := store.VEX().ListVEX()
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []*Document
+	for id, content := range contents {
+		if doc := parse(ctx, content, fmt.Sprintf("api:%s", id)); doc != nil {
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, nil
+}
+
+// loadReferrers loads the OpenVEX documents and the in-toto attestations of them attached to the artifact.
+func loadReferrers(ctx context.Context, remote *oci.Remote, digest string) ([]*Document, error) {
+	idx, err := remote.Referrers(ctx, digest, "")
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []*Document
+	fetched := 0
+	for _, desc := range idx.Manifests {
+		if desc.ArtifactType != MediaType && desc.ArtifactType != attest.MimeType {
+			continue
+		}
+
+		if fetched >= maxReferrers {
+			zlog.FromContext(ctx).Warnw("too many VEX referrers, the rest are skipped", "digest", digest, "limit", maxReferrers)
+			break
+		}
+		fetched++
+
+		found, err := loadReferrer(ctx, remote, digest, desc.Digest)
+		if err != nil {
+			metrics.Add("failures", 1)
+			zlog.FromContext(ctx).Warnw("load VEX referrer error", "referrer", desc.Digest, "error", err)
+			continue
+		}
+		docs = append(docs, found...)
+	}
+
+	return docs, nil
+}
+
+// loadReferrer loads the documents in the layers of the referrer manifest.
+func loadReferrer(ctx context.Context, remote *oci.Remote, subject string, digest string) ([]*Document, error) {
+	content, _, err := remote.Manifest(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &oci.Manifest{}
+	if err := json.Unmarshal(content, m); err != nil {
+		return nil, fmt.Errorf("unmarshal manifest error: %w", err)
+	}
+
+	var docs []*Document
+	for _, layer := range m.Layers {
+		if layer.MediaType != MediaType && layer.MediaType != attest.MimeType {
+			continue
+		}
+
+		content, err := blob(ctx, remote, layer)
+		if err != nil {
+			return nil, err
+		}
+
+		if layer.MediaType == attest.MimeType {
+			if content, err = predicate(content, subject); err != nil {
+				return nil, err
+			}
+			if content == nil {
+				// Not a VEX attestation.
+				continue
+			}
+		}
+
+		if doc := parse(ctx, content, fmt.Sprintf("referrer:%s", digest)); doc != nil {
+			doc.attached = true
+			docs = append(docs, doc)
+		}
+	}
+
+	return docs, nil
+}
+
+// predicate extracts the OpenVEX document from the in-toto attestation of the subject.
+// Nil is returned if it's not a VEX attestation. The signature is not verified, as the referrers
+// are pushed with the same permission as the artifact.
+func predicate(content []byte, subject string) ([]byte, error) {
+	env, err := attest.ParseEnvelope(content)
+	if err != nil {
+		return nil, err
+	}
+
+	if env.PayloadType != attest.PayloadType {
+		return nil, nil
+	}
+
+	payload, err := env.DecodePayload()
+	if err != nil {
+		return nil, err
+	}
+
+	st := &struct {
+		PredicateType string           `json:"predicateType"`
+		Subject       []attest.Subject `json:"subject"`
+		Predicate     json.RawMessage  `json:"predicate"`
+	}{}
+	if err := json.Unmarshal(payload, st); err != nil {
+		return nil, fmt.Errorf("unmarshal in-toto statement error: %w", err)
+	}
+
+	if !strings.HasPrefix(st.PredicateType, contextPrefix) {
+		return nil, nil
+	}
+
+	parts := strings.SplitN(subject, ":", 2)
+	for _, s := range st.Subject {
+		if len(parts) == 2 && s.Digest[parts[0]] == parts[1] {
+			return st.Predicate, nil
+		}
+	}
+
+	return nil, fmt.Errorf("statement subject does not match the artifact")
+}
+
+// blob fetches and verifies the content of the layer within the size limit.
+func blob(ctx context.Context, remote *oci.Remote, layer oci.Descriptor) ([]byte, error) {
+	if layer.Size > maxDocumentSize {
+		return nil, fmt.Errorf("layer %s is too large: %d bytes", layer.Digest, layer.Size)
+	}
+
+	rc, err := remote.Blob(ctx, layer.Digest)
+	if err != nil {
+		return nil, fmt.Errorf("fetch layer %s error: %w", layer.Digest, err)
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(io.LimitReader(rc, maxDocumentSize))
+	if err != nil {
+		return nil, fmt.Errorf("read layer %s error: %w", layer.Digest, err)
+	}
+
+	if oci.Digest(content) != layer.Digest {
+		return nil, fmt.Errorf("layer %s digest mismatch", layer.Digest)
+	}
+
+	return content, nil
+}
+
+// parse the document of the source, nil is returned if it's invalid.
+func parse(ctx context.Context, content []byte, source string) *Document {
+	doc, err := Parse(content)
+	if err != nil {
+		metrics.Add("failures", 1)
+		zlog.FromContext(ctx).Warnw("invalid VEX document", "source", source, "error", err)
+		return nil
+	}
+	doc.source = source
+
+	return doc
+}
+
+// Info of the document uploaded via the API.
+func (d *Document) Info() *spec.VexDocumentInfo {
+	return &spec.VexDocumentInfo{
+		Id:         d.Key(),
+		DocumentId: d.ID,
+		Author:     d.Author,
+		Timestamp:  d.Timestamp,
+		Version:    int32(d.Version),
+		Statements: int32(len(d.Statements)),
+	}
+}
@@ -0,0 +1,207 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vex
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// ModeDrop removes the suppressed vulnerabilities from the report.
+	ModeDrop = "drop"
+	// ModeAnnotate keeps the suppressed vulnerabilities with the VEX status in their vendor attributes.
+	ModeAnnotate = "annotate"
+
+	// AttrSuppressed is the vendor attribute of the report listing the suppressed vulnerabilities.
+	AttrSuppressed = "vex_suppressed"
+	// AttrStatus is the vendor attribute of the VEX status of the annotated vulnerability.
+	AttrStatus = "vex_status"
+	// AttrJustification is the vendor attribute of the justification of the annotated vulnerability.
+	AttrJustification = "vex_justification"
+	// AttrImpactStatement is the vendor attribute of the impact statement of the annotated vulnerability.
+	AttrImpactStatement = "vex_impact_statement"
+	// AttrDocument is the vendor attribute of the VEX document suppressing the annotated vulnerability.
+	AttrDocument = "vex_document"
+)
+
+// reports are the report MIME types, without the parameters, the VEX statements are applied to.
+var reports = []string{
+	"application/vnd.scanner.adapter.vuln.report.harbor+json",
+	"application/vnd.security.vulnerability.report",
+}
+
+// severityOrder ranks the severities from low to high.
+var severityOrder = map[spec.Severity]int{
+	spec.UNKNOWN:    0,
+	spec.NEGLIGIBLE: 1,
+	spec.LOW:        2,
+	spec.MEDIUM:     3,
+	spec.HIGH:       4,
+	spec.CRITICAL:   5,
+}
+
+// Finding mirrors spec.VulnerabilityItem but keeps the vendor attributes of any types.
+type Finding struct {
+	spec.VulnerabilityItem
+	VendorAttributes map[string]interface{} `json:"vendor_attributes,omitempty"`
+}
+
+// Report mirrors spec.HarborVulnerabilityReport with the finding above.
+type Report struct {
+	spec.HarborVulnerabilityReport
+	Vulnerabilities []Finding `json:"vulnerabilities,omitempty"`
+}
+
+// Suppression is a vulnerability suppressed by the VEX statement, listed in the report for auditing.
+type Suppression struct {
+	ID              string `json:"id"`
+	Package         string `json:"package,omitempty"`
+	Version         string `json:"version,omitempty"`
+	Status          string `json:"status"`
+	Justification   string `json:"justification,omitempty"`
+	ImpactStatement string `json:"impact_statement,omitempty"`
+	// Document is the @id of the VEX document.
+	Document string `json:"document,omitempty"`
+	// Source of the VEX document, e.g: the file path or the referrer digest.
+	Source string `json:"source,omitempty"`
+}
+
+// Applies checks whether the VEX statements are applied to the report of the MIME type.
+func Applies(mimeType string) bool {
+	mt, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+
+	for _, r := range reports {
+		if r == mt {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseReport parses the Harbor vulnerability report.
+func ParseReport(content []byte) (*Report, error) {
+	r := &Report{}
+	if err := json.Unmarshal(content, r); err != nil {
+		return nil, fmt.Errorf("unmarshal vulnerability report error: %w", err)
+	}
+
+	return r, nil
+}
+
+// Apply the statements of the documents to the report of the target.
+//
+// The latest statement about the vulnerability and package wins, the vulnerabilities with the status
+// not_affected or fixed are suppressed: removed from the report in the drop mode, or annotated with
+// the VEX status in the annotate mode. Either way, the severity of the report is re-evaluated without
+// them and they are listed in the vendor attribute vex_suppressed of the report.
+func Apply(r *Report, docs []*Document, t Target, mode string) []Suppression {
+	if len(docs) == 0 {
+		return nil
+	}
+
+	var (
+		suppressed []Suppression
+		kept       []Finding
+		severity   *spec.Severity
+	)
+	for _, v := range r.Vulnerabilities {
+		doc, st := latest(docs, t, v.Id, v.Package_, v.Version)
+		if st == nil || (st.Status != StatusNotAffected && st.Status != StatusFixed) {
+			kept = append(kept, v)
+			if v.Severity != nil && (severity == nil || severityOrder[*v.Severity] > severityOrder[*severity]) {
+				sev := *v.Severity
+				severity = &sev
+			}
+			continue
+		}
+
+		suppressed = append(suppressed, Suppression{
+			ID:              v.Id,
+			Package:         v.Package_,
+			Version:         v.Version,
+			Status:          st.Status,
+			Justification:   st.Justification,
+			ImpactStatement: st.ImpactStatement,
+			Document:        doc.ID,
+			Source:          doc.source,
+		})
+
+		if mode == ModeAnnotate {
+			attrs := make(map[string]interface{}, len(v.VendorAttributes)+4)
+			for k, a := range v.VendorAttributes {
+				attrs[k] = a
+			}
+			attrs[AttrStatus] = st.Status
+			for k, a := range map[string]string{
+				AttrJustification:   st.Justification,
+				AttrImpactStatement: st.ImpactStatement,
+				AttrDocument:        doc.ID,
+			} {
+				if a != "" {
+					attrs[k] = a
+				}
+			}
+			v.VendorAttributes = attrs
+			kept = append(kept, v)
+		}
+	}
+
+	if len(suppressed) == 0 {
+		return nil
+	}
+
+	r.Vulnerabilities = kept
+	r.Severity = severity
+
+	if r.VendorAttributes == nil {
+		r.VendorAttributes = make(map[string]interface{}, 1)
+	}
+	r.VendorAttributes[AttrSuppressed] = suppressed
+
+	return suppressed
+}
+
+// latest returns the latest statement about the vulnerability of the package in the target.
+// The statements of the later documents win if they have the same timestamps.
+func latest(docs []*Document, t Target, id string, pkg string, version string) (*Document, *Statement) {
+	var (
+		doc *Document
+		st  *Statement
+		ts  time.Time
+	)
+	for _, d := range docs {
+		for i := range d.Statements {
+			s := &d.Statements[i]
+			if !s.refers(id) || !s.applies(t, d.attached, pkg, version) {
+				continue
+			}
+
+			if st == nil || !d.timestamp(s).Before(ts) {
+				doc, st, ts = d, s, d.timestamp(s)
+			}
+		}
+	}
+
+	return doc, st
+}
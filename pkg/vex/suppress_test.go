@@ -0,0 +1,270 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vex
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+// report returns the vulnerability report with the critical openssl, high curl and medium zlib vulnerabilities.
+func report(t *testing.T) *Report {
+	critical, high, medium := spec.CRITICAL, spec.HIGH, spec.MEDIUM
+	content, err := json.Marshal(map[string]interface{}{
+		"severity": critical,
+		"vulnerabilities": []map[string]interface{}{
+			{"id": "CVE-2023-0001", "package": "openssl", "version": "3.0.11", "severity": critical},
+			{"id": "CVE-2023-0002", "package": "curl", "version": "7.88.1", "severity": high,
+				"vendor_attributes": map[string]interface{}{"cvss": map[string]interface{}{"score": 7.5}}},
+			{"id": "CVE-2023-0003", "package": "zlib", "version": "1.2.13", "severity": medium},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := ParseReport(content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return r
+}
+
+// document returns the VEX document about library/nginx with the statements.
+func document(t *testing.T, statements string) *Document {
+	doc, err := Parse([]byte(`{"@context":"https://openvex.dev/ns/v0.2.0","@id":"https://vex.example.com/doc","statements":` + statements + `}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	doc.source = "vex.json"
+
+	return doc
+}
+
+var target = Target{Repository: "library/nginx", Tag: "1.25", Digest: digest}
+
+func TestApply(t *testing.T) {
+	doc := document(t, `[
+		{"vulnerability":{"name":"CVE-2023-0001"},"products":[{"@id":"library/nginx:1.25","subcomponents":[{"@id":"pkg:deb/debian/openssl@3.0.11"}]}],
+		 "status":"not_affected","justification":"vulnerable_code_not_in_execute_path","impact_statement":"TLS is terminated by the proxy"},
+		{"vulnerability":{"name":"CVE-2023-0002"},"products":[{"@id":"library/nginx:1.25"}],"status":"fixed"},
+		{"vulnerability":{"name":"CVE-2023-0003"},"products":[{"@id":"library/nginx:1.25"}],"status":"under_investigation"}
+	]`)
+
+	cases := []struct {
+		name string
+		mode string
+		kept []string
+	}{
+		{name: "drop", mode: ModeDrop, kept: []string{"CVE-2023-0003"}},
+		{name: "annotate", mode: ModeAnnotate, kept: []string{"CVE-2023-0001", "CVE-2023-0002", "CVE-2023-0003"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			r := report(t)
+
+			suppressed := Apply(r, []*Document{doc}, target, c.mode)
+			if len(suppressed) != 2 {
+				t.Fatalf("expect 2 suppressed vulnerabilities, got %+v", suppressed)
+			}
+
+			expected := Suppression{
+				ID:              "CVE-2023-0001",
+				Package:         "openssl",
+				Version:         "3.0.11",
+				Status:          StatusNotAffected,
+				Justification:   "vulnerable_code_not_in_execute_path",
+				ImpactStatement: "TLS is terminated by the proxy",
+				Document:        "https://vex.example.com/doc",
+				Source:          "vex.json",
+			}
+			if suppressed[0] != expected {
+				t.Errorf("expect the suppression %+v, got %+v", expected, suppressed[0])
+			}
+			if suppressed[1].ID != "CVE-2023-0002" || suppressed[1].Status != StatusFixed {
+				t.Errorf("expect the fixed vulnerability to be suppressed, got %+v", suppressed[1])
+			}
+
+			// The suppressed vulnerabilities are excluded from the severity.
+			if r.Severity == nil || *r.Severity != spec.MEDIUM {
+				t.Errorf("expect the severity %s, got %v", spec.MEDIUM, r.Severity)
+			}
+
+			var kept []string
+			for _, v := range r.Vulnerabilities {
+				kept = append(kept, v.Id)
+			}
+			if len(kept) != len(c.kept) {
+				t.Fatalf("expect the vulnerabilities %v, got %v", c.kept, kept)
+			}
+			for i := range kept {
+				if kept[i] != c.kept[i] {
+					t.Errorf("expect the vulnerabilities %v, got %v", c.kept, kept)
+				}
+			}
+
+			// The suppressions are listed in the report for auditing.
+			content, err := json.Marshal(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var listed struct {
+				VendorAttributes struct {
+					Suppressed []Suppression `json:"vex_suppressed"`
+				} `json:"vendor_attributes"`
+			}
+			if err := json.Unmarshal(content, &listed); err != nil {
+				t.Fatal(err)
+			}
+			if s := listed.VendorAttributes.Suppressed; len(s) != 2 || s[0].Justification != expected.Justification {
+				t.Errorf("expect the suppressions with the justification in the report, got %+v", s)
+			}
+		})
+	}
+}
+
+func TestApplyAnnotate(t *testing.T) {
+	doc := document(t, `[
+		{"vulnerability":{"name":"CVE-2023-0001"},"products":[{"@id":"library/nginx:1.25"}],
+		 "status":"not_affected","justification":"component_not_present"},
+		{"vulnerability":{"name":"CVE-2023-0002"},"products":[{"@id":"library/nginx:1.25"}],"status":"fixed"}
+	]`)
+
+	r := report(t)
+	Apply(r, []*Document{doc}, target, ModeAnnotate)
+
+	cases := []struct {
+		attrs    map[string]interface{}
+		expected map[string]interface{}
+	}{
+		{
+			attrs: r.Vulnerabilities[0].VendorAttributes,
+			expected: map[string]interface{}{
+				AttrStatus:        StatusNotAffected,
+				AttrJustification: "component_not_present",
+				AttrDocument:      "https://vex.example.com/doc",
+			},
+		},
+		{
+			attrs: r.Vulnerabilities[1].VendorAttributes,
+			expected: map[string]interface{}{
+				AttrStatus:   StatusFixed,
+				AttrDocument: "https://vex.example.com/doc",
+			},
+		},
+	}
+
+	for i, c := range cases {
+		for k, v := range c.expected {
+			if c.attrs[k] != v {
+				t.Errorf("vulnerability %d: expect the vendor attribute %s to be %v, got %v", i, k, v, c.attrs[k])
+			}
+		}
+	}
+
+	// The empty statement fields are not annotated, the vendor attributes of the finding are kept.
+	if _, ok := cases[1].attrs[AttrJustification]; ok {
+		t.Error("expect no justification of the fixed vulnerability")
+	}
+	if _, ok := cases[1].attrs["cvss"]; !ok {
+		t.Error("expect the vendor attributes of the finding to be kept")
+	}
+	if _, ok := r.Vulnerabilities[2].VendorAttributes[AttrStatus]; ok {
+		t.Error("expect the vulnerability without statements not to be annotated")
+	}
+}
+
+func TestApplyLatest(t *testing.T) {
+	earlier := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	first := document(t, `[{"vulnerability":{"name":"CVE-2023-0001"},"products":[{"@id":"library/nginx:1.25"}],"status":"not_affected","justification":"component_not_present"}]`)
+	first.Timestamp = &later
+	second := document(t, `[{"vulnerability":{"name":"CVE-2023-0001"},"products":[{"@id":"library/nginx:1.25"}],"status":"affected"}]`)
+	second.Timestamp = &earlier
+
+	// The latest statement wins even if its document comes first.
+	r := report(t)
+	if s := Apply(r, []*Document{first, second}, target, ModeDrop); len(s) != 1 {
+		t.Errorf("expect the latest not_affected statement to win, got %+v", s)
+	}
+
+	second.Timestamp = &later
+	r = report(t)
+	if s := Apply(r, []*Document{first, second}, target, ModeDrop); s != nil {
+		t.Errorf("expect the later document to win at the same timestamp, got %+v", s)
+	}
+	if len(r.Vulnerabilities) != 3 || r.VendorAttributes[AttrSuppressed] != nil {
+		t.Error("expect the report to be unchanged")
+	}
+}
+
+func TestApplyProducts(t *testing.T) {
+	cases := []struct {
+		name       string
+		statements string
+		attached   bool
+		suppressed int
+	}{
+		{
+			name:       "other image",
+			statements: `[{"vulnerability":{"name":"CVE-2023-0001"},"products":[{"@id":"library/redis:7"}],"status":"not_affected"}]`,
+		},
+		{
+			name:       "other package",
+			statements: `[{"vulnerability":{"name":"CVE-2023-0001"},"products":[{"@id":"library/nginx:1.25","subcomponents":[{"@id":"pkg:deb/debian/libssl3"}]}],"status":"not_affected"}]`,
+		},
+		{
+			name:       "other package version",
+			statements: `[{"vulnerability":{"name":"CVE-2023-0001"},"products":[{"@id":"library/nginx:1.25","subcomponents":[{"@id":"pkg:deb/debian/openssl@3.0.13"}]}],"status":"not_affected"}]`,
+		},
+		{
+			name:       "alias",
+			statements: `[{"vulnerability":{"name":"GHSA-xxxx","aliases":["CVE-2023-0001"]},"products":[{"@id":"library/nginx:1.25"}],"status":"not_affected"}]`,
+			suppressed: 1,
+		},
+		{
+			name:       "image hash",
+			statements: `[{"vulnerability":{"name":"CVE-2023-0001"},"products":[{"hashes":{"sha-256":"` + digest[7:] + `"}}],"status":"not_affected"}]`,
+			suppressed: 1,
+		},
+		{
+			name:       "attached without products",
+			statements: `[{"vulnerability":{"name":"CVE-2023-0001"},"status":"not_affected"}]`,
+			attached:   true,
+			suppressed: 1,
+		},
+		{
+			name:       "unattached without products",
+			statements: `[{"vulnerability":{"name":"CVE-2023-0001"},"status":"not_affected"}]`,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			doc := document(t, c.statements)
+			doc.attached = c.attached
+
+			if s := Apply(report(t), []*Document{doc}, target, ModeDrop); len(s) != c.suppressed {
+				t.Errorf("expect %d suppressed vulnerabilities, got %+v", c.suppressed, s)
+			}
+		})
+	}
+}
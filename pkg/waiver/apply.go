@@ -0,0 +1,274 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package waiver
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"mime"
+	"strings"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/vex"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// AttrWaived is the vendor attribute marking the waived finding, also the vendor attribute
+	// of the vulnerability report listing the waived vulnerabilities.
+	AttrWaived = "waived"
+	// AttrWaiverID is the vendor attribute of the ID of the waiver applied to the finding.
+	AttrWaiverID = "waiver_id"
+	// AttrWaiverOwner is the vendor attribute of the owner of the waiver applied to the finding.
+	AttrWaiverOwner = "waiver_owner"
+	// AttrWaiverReason is the vendor attribute of the reason of the waiver applied to the finding.
+	AttrWaiverReason = "waiver_reason"
+	// AttrWaiverExpiresAt is the vendor attribute of the expiry of the waiver applied to the finding.
+	AttrWaiverExpiresAt = "waiver_expires_at"
+
+	// SummaryWaived is the key of the CIS summary counting the waived items.
+	SummaryWaived = "waived"
+
+	// cisReport is the CIS report MIME type without the parameters.
+	cisReport = "application/vnd.security.cis.report"
+)
+
+// vulnerabilityReports are the report MIME types, without the parameters, of the vulnerability reports.
+var vulnerabilityReports = []string{
+	"application/vnd.scanner.adapter.vuln.report.harbor+json",
+	"application/vnd.security.vulnerability.report",
+}
+
+// severityOrder ranks the severities from low to high.
+var severityOrder = map[spec.Severity]int{
+	spec.UNKNOWN:    0,
+	spec.NEGLIGIBLE: 1,
+	spec.LOW:        2,
+	spec.MEDIUM:     3,
+	spec.HIGH:       4,
+	spec.CRITICAL:   5,
+}
+
+// metrics counts the waived findings, served by the /metrics API.
+var metrics = expvar.NewMap("waivers")
+
+// finding mirrors spec.VulnerabilityItem but keeps the vendor attributes of any types.
+type finding struct {
+	spec.VulnerabilityItem
+	VendorAttributes map[string]interface{} `json:"vendor_attributes,omitempty"`
+}
+
+// report mirrors spec.HarborVulnerabilityReport with the finding above.
+type report struct {
+	spec.HarborVulnerabilityReport
+	Vulnerabilities []finding `json:"vulnerabilities,omitempty"`
+}
+
+// Waived is a vulnerability waived, listed in the vulnerability report.
+type Waived struct {
+	ID        string    `json:"id"`
+	Package   string    `json:"package,omitempty"`
+	Version   string    `json:"version,omitempty"`
+	WaiverID  string    `json:"waiver_id"`
+	Owner     string    `json:"owner"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// Applies checks whether the waivers are applied to the report of the MIME type,
+// i.e. the vulnerability and CIS reports.
+func Applies(mimeType string) bool {
+	mt, _, err := mime.ParseMediaType(mimeType)
+	if err != nil {
+		return false
+	}
+
+	return mt == cisReport || contains(vulnerabilityReports, mt)
+}
+
+// Apply the active waivers to the report of the MIME type of the artifact in the repository.
+// The report is returned as it is if no finding is waived.
+func Apply(mimeType string, content []byte, repository string, waivers []*spec.Waiver) ([]byte, error) {
+	mt, _, err := mime.ParseMediaType(mimeType)
+	if err != nil || len(waivers) == 0 {
+		return content, nil
+	}
+
+	switch {
+	case mt == cisReport:
+		return applyCIS(content, repository, waivers)
+	case contains(vulnerabilityReports, mt):
+		return applyVulnerabilities(content, repository, waivers)
+	default:
+		return content, nil
+	}
+}
+
+// applyVulnerabilities marks the waived vulnerabilities and lists them in the vendor attribute
+// waived of the report. The severity of the report is re-evaluated without them.
+func applyVulnerabilities(content []byte, repository string, waivers []*spec.Waiver) ([]byte, error) {
+	r := &report{}
+	if err := json.Unmarshal(content, r); err != nil {
+		return nil, fmt.Errorf("unmarshal vulnerability report error: %w", err)
+	}
+
+	var (
+		waived   []Waived
+		severity *spec.Severity
+	)
+	for i := range r.Vulnerabilities {
+		v := &r.Vulnerabilities[i]
+
+		w := match(waivers, repository, v.Id, v.Package_)
+		if w == nil {
+			if v.Severity != nil && !suppressed(v) && (severity == nil || severityOrder[*v.Severity] > severityOrder[*severity]) {
+				sev := *v.Severity
+				severity = &sev
+			}
+			continue
+		}
+
+		attrs := make(map[string]interface{}, len(v.VendorAttributes)+5)
+		for k, a := range v.VendorAttributes {
+			attrs[k] = a
+		}
+		for k, a := range attributes(w) {
+			attrs[k] = a
+		}
+		v.VendorAttributes = attrs
+
+		waived = append(waived, Waived{
+			ID:        v.Id,
+			Package:   v.Package_,
+			Version:   v.Version,
+			WaiverID:  w.Id,
+			Owner:     w.Owner,
+			ExpiresAt: w.ExpiresAt,
+		})
+	}
+
+	if len(waived) == 0 {
+		return content, nil
+	}
+	metrics.Add("waived", int64(len(waived)))
+
+	r.Severity = severity
+	if r.VendorAttributes == nil {
+		r.VendorAttributes = make(map[string]interface{}, 1)
+	}
+	r.VendorAttributes[AttrWaived] = waived
+
+	return json.Marshal(r)
+}
+
+// applyCIS marks the waived CIS benchmark items, which are counted as waived in the summaries
+// instead of their levels. The passed and skipped items are not waived.
+func applyCIS(content []byte, repository string, waivers []*spec.Waiver) ([]byte, error) {
+	r := &spec.HarborCISReport{}
+	if err := json.Unmarshal(content, r); err != nil {
+		return nil, fmt.Errorf("unmarshal CIS report error: %w", err)
+	}
+
+	bodies := []*spec.CISReportBody{r.Benchmarks}
+	for i := range r.Platforms {
+		bodies = append(bodies, r.Platforms[i].Benchmarks)
+	}
+
+	waived := 0
+	for _, body := range bodies {
+		if body == nil {
+			continue
+		}
+
+		for i := range body.Details {
+			d := &body.Details[i]
+			if d.Level == nil || *d.Level == spec.PASS || *d.Level == spec.SKIP {
+				continue
+			}
+
+			w := match(waivers, repository, d.Code, "")
+			if w == nil {
+				continue
+			}
+
+			attrs := make(spec.ModelMap, len(d.VendorAttributes)+5)
+			for k, a := range d.VendorAttributes {
+				attrs[k] = a
+			}
+			for k, a := range attributes(w) {
+				attrs[k] = a
+			}
+			d.VendorAttributes = attrs
+
+			if body.Summary == nil {
+				body.Summary = make(map[string]uint)
+			}
+			if level := strings.ToLower(string(*d.Level)); body.Summary[level] > 1 {
+				body.Summary[level]--
+			} else {
+				delete(body.Summary, level)
+			}
+			body.Summary[SummaryWaived]++
+			waived++
+		}
+	}
+
+	if waived == 0 {
+		return content, nil
+	}
+	metrics.Add("waived", int64(waived))
+
+	return json.Marshal(r)
+}
+
+// match returns the waiver of the finding expiring last, nil is returned if no waiver matches.
+func match(waivers []*spec.Waiver, repository string, id string, pkg string) *spec.Waiver {
+	var res *spec.Waiver
+	for _, w := range waivers {
+		if Matches(w, repository, id, pkg) && (res == nil || w.ExpiresAt.After(res.ExpiresAt)) {
+			res = w
+		}
+	}
+
+	return res
+}
+
+// attributes of the finding waived by the waiver.
+func attributes(w *spec.Waiver) map[string]string {
+	return map[string]string{
+		AttrWaived:          "true",
+		AttrWaiverID:        w.Id,
+		AttrWaiverOwner:     w.Owner,
+		AttrWaiverReason:    w.Reason,
+		AttrWaiverExpiresAt: w.ExpiresAt.UTC().Format(time.RFC3339),
+	}
+}
+
+// suppressed checks whether the vulnerability is annotated as suppressed by the VEX statements,
+// which is excluded from the severity too.
+func suppressed(v *finding) bool {
+	status, _ := v.VendorAttributes[vex.AttrStatus].(string)
+	return status == vex.StatusNotAffected || status == vex.StatusFixed
+}
+
+func contains(list []string, s string) bool {
+	for _, l := range list {
+		if l == s {
+			return true
+		}
+	}
+
+	return false
+}
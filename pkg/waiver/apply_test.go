@@ -0,0 +1,199 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package waiver
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/vex"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const vulnerabilityReport = "application/vnd.security.vulnerability.report; version=1.1"
+
+func TestApplies(t *testing.T) {
+	cases := map[string]bool{
+		vulnerabilityReport: true,
+		"application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0": true,
+		"application/vnd.security.cis.report; version=1.0":                     true,
+		"application/vnd.security.secret.report; version=1.0":                  false,
+		"invalid;;": false,
+	}
+
+	for mt, applies := range cases {
+		if a := Applies(mt); a != applies {
+			t.Errorf("expect applies of %s to be %v, got %v", mt, applies, a)
+		}
+	}
+}
+
+func TestApplyVulnerabilities(t *testing.T) {
+	critical, high, medium := spec.CRITICAL, spec.HIGH, spec.MEDIUM
+	content, err := json.Marshal(map[string]interface{}{
+		"severity": critical,
+		"vulnerabilities": []map[string]interface{}{
+			{"id": "CVE-2023-0001", "package": "openssl", "version": "1.1.1", "severity": critical},
+			{"id": "CVE-2023-0002", "package": "curl", "version": "7.0", "severity": high,
+				"vendor_attributes": map[string]interface{}{vex.AttrStatus: vex.StatusNotAffected}},
+			{"id": "CVE-2023-0003", "package": "zlib", "version": "1.2", "severity": medium,
+				"vendor_attributes": map[string]interface{}{"cvss": map[string]interface{}{"score": 5.3}}},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expiresAt := now.Add(24 * time.Hour)
+	waivers := []*spec.Waiver{
+		{Id: "w1", Cve: "CVE-2023-0001", Repository: "library/*", Owner: "alice", Reason: "mitigated", ExpiresAt: now.Add(time.Hour)},
+		{Id: "w2", Cve: "CVE-2023-0001", Project: "library", Owner: "bob", Reason: "accepted", ExpiresAt: expiresAt},
+		{Id: "w3", Cve: "CVE-2023-0003", Project: "team", Owner: "carol", Reason: "other project", ExpiresAt: expiresAt},
+	}
+
+	res, err := Apply(vulnerabilityReport, content, "library/nginx", waivers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &report{}
+	if err := json.Unmarshal(res, r); err != nil {
+		t.Fatal(err)
+	}
+
+	// The VEX suppressed vulnerability is excluded from the severity too.
+	if r.Severity == nil || *r.Severity != spec.MEDIUM {
+		t.Errorf("expect the severity %s without the waived and suppressed vulnerabilities, got %v", spec.MEDIUM, r.Severity)
+	}
+
+	// The waiver expiring last is applied.
+	attrs := r.Vulnerabilities[0].VendorAttributes
+	expected := map[string]interface{}{
+		AttrWaived:          "true",
+		AttrWaiverID:        "w2",
+		AttrWaiverOwner:     "bob",
+		AttrWaiverReason:    "accepted",
+		AttrWaiverExpiresAt: expiresAt.Format(time.RFC3339),
+	}
+	for k, v := range expected {
+		if attrs[k] != v {
+			t.Errorf("expect the vendor attribute %s to be %v, got %v", k, v, attrs[k])
+		}
+	}
+
+	for _, v := range r.Vulnerabilities[1:] {
+		if _, ok := v.VendorAttributes[AttrWaived]; ok {
+			t.Errorf("expect %s not to be waived", v.Id)
+		}
+	}
+	if _, ok := r.Vulnerabilities[2].VendorAttributes["cvss"]; !ok {
+		t.Error("expect the vendor attributes of the finding to be kept")
+	}
+
+	var waived []Waived
+	raw, _ := json.Marshal(r.VendorAttributes[AttrWaived])
+	if err := json.Unmarshal(raw, &waived); err != nil {
+		t.Fatal(err)
+	}
+	if len(waived) != 1 || waived[0].ID != "CVE-2023-0001" || waived[0].Package != "openssl" || waived[0].WaiverID != "w2" {
+		t.Errorf("expect the waived vulnerability to be listed, got %+v", waived)
+	}
+}
+
+func TestApplyUnchanged(t *testing.T) {
+	content := []byte(`{"vulnerabilities":[{"id":"CVE-2023-0001","package":"openssl"}]}`)
+	waivers := []*spec.Waiver{{Id: "w1", Cve: "CVE-2023-0001", Project: "team", ExpiresAt: now}}
+
+	cases := []struct {
+		name       string
+		mimeType   string
+		repository string
+		waivers    []*spec.Waiver
+	}{
+		{name: "no waivers", mimeType: vulnerabilityReport, repository: "team/nginx"},
+		{name: "no matches", mimeType: vulnerabilityReport, repository: "library/nginx", waivers: waivers},
+		{name: "other report", mimeType: "application/vnd.security.secret.report; version=1.0", repository: "team/nginx", waivers: waivers},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			res, err := Apply(c.mimeType, content, c.repository, c.waivers)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(res) != string(content) {
+				t.Errorf("expect the report to be returned as it is, got %s", res)
+			}
+		})
+	}
+}
+
+func TestApplyCIS(t *testing.T) {
+	fatal, warn, pass := spec.FATAL, spec.WARN, spec.PASS
+	body := func() *spec.CISReportBody {
+		return &spec.CISReportBody{
+			Summary: map[string]uint{"fatal": 1, "warn": 2, "pass": 1},
+			Details: []spec.CISBenchmarkItem{
+				{Code: "CIS-DI-0001", Level: &warn},
+				{Code: "CIS-DI-0005", Level: &warn},
+				{Code: "CIS-DI-0010", Level: &fatal},
+				{Code: "CIS-DI-0006", Level: &pass},
+			},
+		}
+	}
+	content, err := json.Marshal(&spec.HarborCISReport{
+		Benchmarks: body(),
+		Platforms:  []spec.CISPlatformReport{{Platform: "linux/arm64", Benchmarks: body()}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	waivers := []*spec.Waiver{
+		{Id: "w1", Cve: "CIS-DI-0001", Owner: "alice", Reason: "root is required", ExpiresAt: now},
+		{Id: "w2", Cve: "CIS-DI-0010", Owner: "alice", Reason: "test credentials", ExpiresAt: now},
+		{Id: "w3", Cve: "CIS-DI-0006", Owner: "alice", Reason: "passed", ExpiresAt: now},
+	}
+
+	res, err := Apply("application/vnd.security.cis.report; version=1.0", content, "library/nginx", waivers)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &spec.HarborCISReport{}
+	if err := json.Unmarshal(res, r); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, b := range []*spec.CISReportBody{r.Benchmarks, r.Platforms[0].Benchmarks} {
+		expected := map[string]uint{"warn": 1, "pass": 1, SummaryWaived: 2}
+		if len(b.Summary) != len(expected) {
+			t.Errorf("expect the summary %v, got %v", expected, b.Summary)
+		}
+		for k, v := range expected {
+			if b.Summary[k] != v {
+				t.Errorf("expect the summary %s to be %d, got %d", k, v, b.Summary[k])
+			}
+		}
+
+		for _, d := range b.Details {
+			_, waived := d.VendorAttributes[AttrWaived]
+			if expect := d.Code == "CIS-DI-0001" || d.Code == "CIS-DI-0010"; waived != expect {
+				t.Errorf("expect %s waived %v, got %v", d.Code, expect, waived)
+			}
+		}
+	}
+}
@@ -0,0 +1,300 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package waiver
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	// ActionCreate is the audit action of creating a waiver.
+	ActionCreate = "create"
+	// ActionUpdate is the audit action of updating a waiver.
+	ActionUpdate = "update"
+	// ActionDelete is the audit action of deleting a waiver.
+	ActionDelete = "delete"
+)
+
+// ErrInvalid is returned if the waiver violates the rules of Validate.
+var ErrInvalid = errors.New("invalid waiver")
+
+// Enabled checks whether the waivers are applied to the reports by `scanner.waivers.enabled`.
+func Enabled() bool {
+	return viper.GetBool("scanner.waivers.enabled")
+}
+
+// Validate the waiver before it's saved.
+// The vulnerability ID, owner, reason and a future expiry are required.
+func Validate(w *spec.Waiver, now time.Time) error {
+	switch {
+	case strings.TrimSpace(w.Cve) == "":
+		return fmt.Errorf("%w: cve is required", ErrInvalid)
+	case strings.TrimSpace(w.Owner) == "":
+		return fmt.Errorf("%w: owner is required", ErrInvalid)
+	case strings.TrimSpace(w.Reason) == "":
+		return fmt.Errorf("%w: reason is required", ErrInvalid)
+	case w.ExpiresAt.IsZero():
+		return fmt.Errorf("%w: expires_at is required", ErrInvalid)
+	case !w.ExpiresAt.After(now):
+		return fmt.Errorf("%w: expires_at must be in the future", ErrInvalid)
+	}
+
+	if w.Repository != "" {
+		if _, err := path.Match(w.Repository, ""); err != nil {
+			return fmt.Errorf("%w: invalid repository pattern %q", ErrInvalid, w.Repository)
+		}
+	}
+
+	if strings.Contains(w.Project, "/") {
+		return fmt.Errorf("%w: invalid project %q", ErrInvalid, w.Project)
+	}
+
+	return nil
+}
+
+// Expired checks whether the waiver is expired at the time.
+func Expired(w *spec.Waiver, now time.Time) bool {
+	return !now.Before(w.ExpiresAt)
+}
+
+// Matches checks whether the waiver applies to the vulnerability, or the CIS benchmark item
+// without the package, in the repository.
+func Matches(w *spec.Waiver, repository string, id string, pkg string) bool {
+	if !strings.EqualFold(w.Cve, id) {
+		return false
+	}
+
+	if w.Package_ != "" && w.Package_ != pkg {
+		return false
+	}
+
+	if w.Project != "" && w.Project != ProjectOf(repository) {
+		return false
+	}
+
+	if w.Repository != "" {
+		if ok, _ := path.Match(w.Repository, repository); !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ProjectOf returns the Harbor project of the repository, i.e. the first path segment.
+func ProjectOf(repository string) string {
+	if i := strings.Index(repository, "/"); i >= 0 {
+		return repository[:i]
+	}
+
+	return ""
+}
+
+// List the waivers, ordered by the expiry.
+func List(ctx context.Context) ([]*spec.Waiver, error) {
+	contents, err := store.Waivers().ListWaivers()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	waivers := make([]*spec.Waiver, 0, len(contents))
+	for id, content := range contents {
+		w := &spec.Waiver{}
+		if err := json.Unmarshal(content, w); err != nil {
+			zlog.FromContext(ctx).Warnw("invalid waiver in the store", "id", id, "error", err)
+			continue
+		}
+		w.Expired = Expired(w, now)
+		waivers = append(waivers, w)
+	}
+
+	sort.Slice(waivers, func(i, j int) bool {
+		if !waivers[i].ExpiresAt.Equal(waivers[j].ExpiresAt) {
+			return waivers[i].ExpiresAt.Before(waivers[j].ExpiresAt)
+		}
+		return waivers[i].Id < waivers[j].Id
+	})
+
+	return waivers, nil
+}
+
+// Active returns the waivers not expired yet.
+func Active(ctx context.Context) ([]*spec.Waiver, error) {
+	waivers, err := List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var active []*spec.Waiver
+	for _, w := range waivers {
+		if !w.Expired {
+			active = append(active, w)
+		}
+	}
+
+	return active, nil
+}
+
+// Get the waiver by the ID.
+func Get(id string) (*spec.Waiver, error) {
+	content, err := store.Waivers().GetWaiver(id)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &spec.Waiver{}
+	if err := json.Unmarshal(content, w); err != nil {
+		return nil, fmt.Errorf("unmarshal waiver error: %w", err)
+	}
+	w.Expired = Expired(w, time.Now().UTC())
+
+	return w, nil
+}
+
+// Create the waiver with a new ID, the change is audited with the actor.
+func Create(ctx context.Context, w *spec.Waiver, actor string) (*spec.Waiver, error) {
+	now := time.Now().UTC()
+	if err := Validate(w, now); err != nil {
+		return nil, err
+	}
+
+	w.Id = uuid.Random()
+	w.CreatedAt, w.UpdatedAt = &now, &now
+
+	if err := save(ctx, w, ActionCreate, actor, now); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Update the waiver, the creation time is kept and the change is audited with the actor.
+func Update(ctx context.Context, id string, w *spec.Waiver, actor string) (*spec.Waiver, error) {
+	old, err := Get(id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	if err := Validate(w, now); err != nil {
+		return nil, err
+	}
+
+	w.Id = id
+	w.CreatedAt, w.UpdatedAt = old.CreatedAt, &now
+
+	if err := save(ctx, w, ActionUpdate, actor, now); err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+// Delete the waiver, the change is audited with the actor.
+func Delete(ctx context.Context, id string, actor string) error {
+	old, err := Get(id)
+	if err != nil {
+		return err
+	}
+
+	if err := store.Waivers().DeleteWaiver(id); err != nil {
+		return err
+	}
+
+	audit(ctx, &spec.WaiverAuditEntry{
+		Time:     time.Now().UTC(),
+		Action:   ActionDelete,
+		WaiverId: id,
+		Actor:    actor,
+		Waiver:   old,
+	})
+
+	return nil
+}
+
+// Audit returns the latest entries of the audit log.
+func Audit(ctx context.Context, limit int) ([]*spec.WaiverAuditEntry, error) {
+	contents, err := store.Waivers().ListAudit(limit)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]*spec.WaiverAuditEntry, 0, len(contents))
+	for _, content := range contents {
+		e := &spec.WaiverAuditEntry{}
+		if err := json.Unmarshal(content, e); err != nil {
+			zlog.FromContext(ctx).Warnw("invalid waiver audit entry in the store", "error", err)
+			continue
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+// save the waiver and audit the change.
+func save(ctx context.Context, w *spec.Waiver, action string, actor string, now time.Time) error {
+	w.Expired = false
+
+	content, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("marshal waiver error: %w", err)
+	}
+
+	if err := store.Waivers().SaveWaiver(w.Id, content); err != nil {
+		return err
+	}
+
+	audit(ctx, &spec.WaiverAuditEntry{
+		Time:     now,
+		Action:   action,
+		WaiverId: w.Id,
+		Actor:    actor,
+		Waiver:   w,
+	})
+
+	return nil
+}
+
+// audit logs the change and appends it to the audit log in the store.
+// The failure of the store is logged as the change is already made.
+func audit(ctx context.Context, e *spec.WaiverAuditEntry) {
+	lg := zlog.FromContext(ctx)
+	lg.Infow("waiver is changed", "action", e.Action, "waiver_id", e.WaiverId, "actor", e.Actor,
+		"cve", e.Waiver.Cve, "owner", e.Waiver.Owner, "expires_at", e.Waiver.ExpiresAt)
+
+	content, err := json.Marshal(e)
+	if err == nil {
+		err = store.Waivers().AppendAudit(content)
+	}
+	if err != nil {
+		lg.Errorw("append waiver audit entry error", "waiver_id", e.WaiverId, "error", err)
+	}
+}
@@ -0,0 +1,190 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package waiver
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+var now = time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestValidate(t *testing.T) {
+	valid := func() *spec.Waiver {
+		return &spec.Waiver{
+			Cve:       "CVE-2023-44487",
+			Owner:     "alice",
+			Reason:    "not reachable",
+			ExpiresAt: now.Add(time.Hour),
+		}
+	}
+
+	cases := []struct {
+		name   string
+		modify func(w *spec.Waiver)
+		valid  bool
+	}{
+		{name: "valid", modify: func(w *spec.Waiver) {}, valid: true},
+		{name: "repository glob", modify: func(w *spec.Waiver) { w.Repository = "library/*" }, valid: true},
+		{name: "missing cve", modify: func(w *spec.Waiver) { w.Cve = " " }},
+		{name: "missing owner", modify: func(w *spec.Waiver) { w.Owner = "" }},
+		{name: "missing reason", modify: func(w *spec.Waiver) { w.Reason = "" }},
+		{name: "missing expiry", modify: func(w *spec.Waiver) { w.ExpiresAt = time.Time{} }},
+		{name: "past expiry", modify: func(w *spec.Waiver) { w.ExpiresAt = now }},
+		{name: "invalid repository pattern", modify: func(w *spec.Waiver) { w.Repository = "library/[" }},
+		{name: "invalid project", modify: func(w *spec.Waiver) { w.Project = "library/nginx" }},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			w := valid()
+			c.modify(w)
+
+			err := Validate(w, now)
+			if c.valid && err != nil {
+				t.Errorf("expect the waiver to be valid, got %v", err)
+			}
+			if !c.valid && !errors.Is(err, ErrInvalid) {
+				t.Errorf("expect ErrInvalid, got %v", err)
+			}
+		})
+	}
+}
+
+func TestExpired(t *testing.T) {
+	w := &spec.Waiver{ExpiresAt: now}
+
+	if Expired(w, now.Add(-time.Second)) {
+		t.Error("expect the waiver to be active before the expiry")
+	}
+	if !Expired(w, now) {
+		t.Error("expect the waiver to be expired at the expiry")
+	}
+	if !Expired(w, now.Add(time.Second)) {
+		t.Error("expect the waiver to be expired after the expiry")
+	}
+}
+
+func TestMatches(t *testing.T) {
+	cases := []struct {
+		name       string
+		waiver     spec.Waiver
+		repository string
+		id         string
+		pkg        string
+		match      bool
+	}{
+		{
+			name:       "any repository",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487"},
+			repository: "library/nginx",
+			id:         "CVE-2023-44487",
+			pkg:        "golang.org/x/net",
+			match:      true,
+		},
+		{
+			name:       "case insensitive id",
+			waiver:     spec.Waiver{Cve: "cve-2023-44487"},
+			repository: "library/nginx",
+			id:         "CVE-2023-44487",
+			match:      true,
+		},
+		{
+			name:       "other id",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487"},
+			repository: "library/nginx",
+			id:         "CVE-2024-0001",
+		},
+		{
+			name:       "package",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487", Package_: "golang.org/x/net"},
+			repository: "library/nginx",
+			id:         "CVE-2023-44487",
+			pkg:        "golang.org/x/net",
+			match:      true,
+		},
+		{
+			name:       "other package",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487", Package_: "golang.org/x/net"},
+			repository: "library/nginx",
+			id:         "CVE-2023-44487",
+			pkg:        "google.golang.org/grpc",
+		},
+		{
+			name:       "project",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487", Project: "library"},
+			repository: "library/nginx",
+			id:         "CVE-2023-44487",
+			match:      true,
+		},
+		{
+			name:       "other project",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487", Project: "library"},
+			repository: "team/nginx",
+			id:         "CVE-2023-44487",
+		},
+		{
+			name:       "repository glob",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487", Repository: "library/*"},
+			repository: "library/nginx",
+			id:         "CVE-2023-44487",
+			match:      true,
+		},
+		{
+			name:       "repository glob of other project",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487", Repository: "library/*"},
+			repository: "team/nginx",
+			id:         "CVE-2023-44487",
+		},
+		{
+			name:       "repository glob not crossing the path segments",
+			waiver:     spec.Waiver{Cve: "CVE-2023-44487", Repository: "library/*"},
+			repository: "library/base/nginx",
+			id:         "CVE-2023-44487",
+		},
+		{
+			name:       "CIS benchmark item",
+			waiver:     spec.Waiver{Cve: "CIS-DI-0001"},
+			repository: "library/nginx",
+			id:         "CIS-DI-0001",
+			match:      true,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			if m := Matches(&c.waiver, c.repository, c.id, c.pkg); m != c.match {
+				t.Errorf("expect match %v, got %v", c.match, m)
+			}
+		})
+	}
+}
+
+func TestProjectOf(t *testing.T) {
+	cases := map[string]string{
+		"library/nginx":      "library",
+		"library/base/nginx": "library",
+		"nginx":              "",
+	}
+
+	for repository, project := range cases {
+		if p := ProjectOf(repository); p != project {
+			t.Errorf("expect the project of %s to be %q, got %q", repository, project, p)
+		}
+	}
+}
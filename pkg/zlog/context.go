@@ -0,0 +1,86 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package zlog
+
+import (
+	"context"
+
+	"go.uber.org/zap"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+)
+
+const (
+	// FieldRequestID is the log field of the request ID.
+	FieldRequestID = "request_id"
+	// FieldCorrelationID is the log field of the correlation ID passed from the client.
+	FieldCorrelationID = "correlation_id"
+	// FieldProvider is the log field of the scanner provider name.
+	FieldProvider = "provider"
+	// FieldJobID is the log field of the scan job ID.
+	FieldJobID = "job_id"
+	// FieldArtifact is the log field of the scanned artifact.
+	FieldArtifact = "artifact"
+)
+
+// fieldsCtxKey is the key of the log fields injected into the context.Context.
+type fieldsCtxKey struct{}
+
+// WithFields attaches the key-value pairs to the context.
+// The pairs are appended to the ones already attached, and they are
+// added to every logger returned by FromContext.
+func WithFields(ctx context.Context, keysAndValues ...interface{}) context.Context {
+	if len(keysAndValues) == 0 {
+		return ctx
+	}
+
+	existing := fieldsFromContext(ctx)
+	fields := make([]interface{}, 0, len(existing)+len(keysAndValues))
+	fields = append(fields, existing...)
+	fields = append(fields, keysAndValues...)
+
+	return context.WithValue(ctx, fieldsCtxKey{}, fields)
+}
+
+// FromContext returns a logger with the request ID, the correlation ID and the fields attached to the context.
+func FromContext(ctx context.Context) *zap.SugaredLogger {
+	lg := Logger()
+	if ctx == nil {
+		return lg
+	}
+
+	reqID := uuid.FromContext(ctx)
+	if reqID != "" {
+		lg = lg.With(FieldRequestID, reqID)
+	}
+
+	if corrID := uuid.CorrelationIDFromContext(ctx); corrID != "" && corrID != reqID {
+		lg = lg.With(FieldCorrelationID, corrID)
+	}
+
+	if fields := fieldsFromContext(ctx); len(fields) > 0 {
+		lg = lg.With(fields...)
+	}
+
+	return lg
+}
+
+func fieldsFromContext(ctx context.Context) []interface{} {
+	if v, ok := ctx.Value(fieldsCtxKey{}).([]interface{}); ok {
+		return v
+	}
+
+	return nil
+}
@@ -15,7 +15,9 @@
 package zlog
 
 import (
+	"fmt"
 	"log"
+	"strings"
 	"sync"
 	"time"
 
@@ -23,23 +25,82 @@ import (
 	"go.uber.org/zap/zapcore"
 )
 
+const (
+	// FormatJSON outputs logs with JSON encoding.
+	FormatJSON = "json"
+	// FormatConsole outputs logs with human-friendly console encoding.
+	FormatConsole = "console"
+)
+
 var once sync.Once
+var lock sync.RWMutex
 var zlogger *zap.SugaredLogger
 
 // Logger returns an initialized zap logger.
 func Logger() *zap.SugaredLogger {
 	once.Do(func() {
-		loggerConfig := zap.NewProductionConfig()
-		loggerConfig.EncoderConfig.TimeKey = "timestamp"
-		loggerConfig.EncoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
-
-		logger, err := loggerConfig.Build()
+		logger, err := build("info", FormatJSON)
 		if err != nil {
 			log.Fatal(err)
 		}
 
-		zlogger = logger.Sugar()
+		zlogger = logger
 	})
 
+	lock.RLock()
+	defer lock.RUnlock()
+
 	return zlogger
 }
+
+// Configure rebuilds the global logger with the specified level and format.
+// Level can be one of debug, info, warn, error; format can be json or console.
+// Empty values fall back to info and json.
+func Configure(level string, format string) error {
+	// Make sure the default logger is initialized first.
+	_ = Logger()
+
+	logger, err := build(level, format)
+	if err != nil {
+		return err
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	zlogger = logger
+
+	return nil
+}
+
+func build(level string, format string) (*zap.SugaredLogger, error) {
+	lvl := zap.NewAtomicLevel()
+	if len(level) > 0 {
+		if err := lvl.UnmarshalText([]byte(strings.ToLower(level))); err != nil {
+			return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+		}
+	}
+
+	var loggerConfig zap.Config
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		loggerConfig = zap.NewProductionConfig()
+	case FormatConsole:
+		loggerConfig = zap.NewProductionConfig()
+		loggerConfig.Encoding = FormatConsole
+		loggerConfig.EncoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder
+	default:
+		return nil, fmt.Errorf("invalid log format %q: only %s and %s are supported", format, FormatJSON, FormatConsole)
+	}
+
+	loggerConfig.Level = lvl
+	loggerConfig.EncoderConfig.TimeKey = "timestamp"
+	loggerConfig.EncoderConfig.EncodeTime = zapcore.TimeEncoderOfLayout(time.RFC3339)
+
+	logger, err := loggerConfig.Build()
+	if err != nil {
+		return nil, err
+	}
+
+	return logger.Sugar(), nil
+}
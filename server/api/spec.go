@@ -0,0 +1,187 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	_ "embed" // embed the OpenAPI document
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	schemaRefPrefix = "#/components/schemas/"
+)
+
+// SwaggerYAML is the OpenAPI document of the Harbor scanner adapter API.
+//
+//go:embed swagger.yaml
+var SwaggerYAML []byte
+
+var once sync.Once
+var document *Document
+var loadErr error
+
+// Document is the parsed OpenAPI document.
+// Only the parts used for validating requests and responses are parsed.
+type Document struct {
+	Info struct {
+		Version string `yaml:"version"`
+	} `yaml:"info"`
+	Paths      map[string]map[string]*Operation `yaml:"paths"`
+	Components struct {
+		Schemas map[string]*Schema `yaml:"schemas"`
+	} `yaml:"components"`
+
+	json []byte
+}
+
+// Operation of an API path.
+type Operation struct {
+	OperationID string `yaml:"operationId"`
+	RequestBody *struct {
+		Content map[string]*MediaType `yaml:"content"`
+	} `yaml:"requestBody"`
+	Responses map[string]*struct {
+		Content map[string]*MediaType `yaml:"content"`
+	} `yaml:"responses"`
+}
+
+// MediaType of a request body or a response.
+type MediaType struct {
+	Schema *Schema `yaml:"schema"`
+}
+
+// Schema is the subset of the OpenAPI schema object supported by the validator.
+type Schema struct {
+	Ref                  string             `yaml:"$ref"`
+	Type                 string             `yaml:"type"`
+	Format               string             `yaml:"format"`
+	Nullable             bool               `yaml:"nullable"`
+	Required             []string           `yaml:"required"`
+	Properties           map[string]*Schema `yaml:"properties"`
+	AdditionalProperties yaml.Node          `yaml:"additionalProperties"`
+	Items                *Schema            `yaml:"items"`
+	Enum                 []interface{}      `yaml:"enum"`
+}
+
+// Load returns the embedded OpenAPI document.
+// The document is parsed only once.
+func Load() (*Document, error) {
+	once.Do(func() {
+		doc := &Document{}
+		if err := yaml.Unmarshal(SwaggerYAML, doc); err != nil {
+			loadErr = fmt.Errorf("parse OpenAPI document error: %w", err)
+			return
+		}
+
+		raw := make(map[string]interface{})
+		if err := yaml.Unmarshal(SwaggerYAML, &raw); err != nil {
+			loadErr = fmt.Errorf("parse OpenAPI document error: %w", err)
+			return
+		}
+
+		doc.json, loadErr = json.Marshal(raw)
+		if loadErr != nil {
+			return
+		}
+
+		document = doc
+	})
+
+	return document, loadErr
+}
+
+// JSON returns the OpenAPI document with JSON format.
+func (d *Document) JSON() []byte {
+	return d.json
+}
+
+// Operation finds the operation with the specified ID.
+func (d *Document) Operation(operationID string) (*Operation, bool) {
+	for _, methods := range d.Paths {
+		for _, op := range methods {
+			if op != nil && op.OperationID == operationID {
+				return op, true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+// RequestSchema returns the request body schema of the operation.
+// The schema of the first content is returned as all the contents share the same schema.
+func (d *Document) RequestSchema(operationID string) (*Schema, bool) {
+	op, ok := d.Operation(operationID)
+	if !ok || op.RequestBody == nil {
+		return nil, false
+	}
+
+	for _, mt := range op.RequestBody.Content {
+		if mt != nil && mt.Schema != nil {
+			return mt.Schema, true
+		}
+	}
+
+	return nil, false
+}
+
+// ResponseSchema returns the response schema of the operation with the specified status and media type.
+func (d *Document) ResponseSchema(operationID string, status int, mediaType string) (*Schema, bool) {
+	op, ok := d.Operation(operationID)
+	if !ok {
+		return nil, false
+	}
+
+	resp, ok := op.Responses[strconv.Itoa(status)]
+	if !ok || resp == nil {
+		return nil, false
+	}
+
+	for mt, content := range resp.Content {
+		if normalizeMediaType(mt) == normalizeMediaType(mediaType) && content != nil && content.Schema != nil {
+			return content.Schema, true
+		}
+	}
+
+	return nil, false
+}
+
+// resolve follows the reference of the schema.
+func (d *Document) resolve(s *Schema) (*Schema, error) {
+	// Avoid endless loop of the circular references.
+	for i := 0; s != nil && s.Ref != "" && i < 16; i++ {
+		if !strings.HasPrefix(s.Ref, schemaRefPrefix) {
+			return nil, fmt.Errorf("unsupported schema reference: %s", s.Ref)
+		}
+
+		target, ok := d.Components.Schemas[strings.TrimPrefix(s.Ref, schemaRefPrefix)]
+		if !ok {
+			return nil, fmt.Errorf("schema reference not found: %s", s.Ref)
+		}
+		s = target
+	}
+
+	return s, nil
+}
+
+func normalizeMediaType(mt string) string {
+	return strings.ToLower(strings.ReplaceAll(mt, " ", ""))
+}
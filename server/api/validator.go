@@ -0,0 +1,317 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// FieldError describes the violation of a field.
+type FieldError struct {
+	// Field is the JSON path of the field, e.g. artifact.repository.
+	Field string
+	// Message describes the violation.
+	Message string
+	// Malformed indicates the field has a wrong JSON type.
+	Malformed bool
+}
+
+// String format of the field error.
+func (fe FieldError) String() string {
+	if fe.Field == "" {
+		return fe.Message
+	}
+
+	return fmt.Sprintf("%s: %s", fe.Field, fe.Message)
+}
+
+// ValidationError contains all the field errors found in the validation.
+type ValidationError struct {
+	Fields []FieldError
+}
+
+// Error implements error.
+func (ve *ValidationError) Error() string {
+	messages := make([]string, 0, len(ve.Fields))
+	for _, f := range ve.Fields {
+		messages = append(messages, f.String())
+	}
+
+	return strings.Join(messages, "; ")
+}
+
+// Status returns the HTTP status code for the validation error.
+// 400 is for the malformed JSON or the wrong type of JSON values,
+// 422 is for the invalid fields.
+func (ve *ValidationError) Status() int {
+	for _, f := range ve.Fields {
+		if f.Malformed {
+			return http.StatusBadRequest
+		}
+	}
+
+	return http.StatusUnprocessableEntity
+}
+
+// Append field errors.
+func (ve *ValidationError) Append(fields ...FieldError) {
+	ve.Fields = append(ve.Fields, fields...)
+}
+
+// Validate the JSON data against the schema.
+// A *ValidationError is returned if any violation is found.
+func (d *Document) Validate(s *Schema, data []byte) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+
+	var v interface{}
+	if err := decoder.Decode(&v); err != nil {
+		return &ValidationError{
+			Fields: []FieldError{{Message: fmt.Sprintf("invalid JSON: %s", err.Error()), Malformed: true}},
+		}
+	}
+
+	ve := &ValidationError{}
+
+	// The null values are treated as absent by validate, the root must be present though.
+	if v == nil {
+		rs, err := d.resolve(s)
+		if err != nil {
+			return err
+		}
+
+		if rs != nil {
+			ve.Append(typeError("", schemaType(rs), v))
+			return ve
+		}
+	}
+
+	if err := d.validate(s, v, "", ve); err != nil {
+		return err
+	}
+
+	if len(ve.Fields) > 0 {
+		return ve
+	}
+
+	return nil
+}
+
+func (d *Document) validate(s *Schema, v interface{}, path string, ve *ValidationError) error {
+	s, err := d.resolve(s)
+	if err != nil {
+		return err
+	}
+	if s == nil {
+		return nil
+	}
+
+	if v == nil {
+		// Null values are treated as absent, the required ones are checked by the parent.
+		return nil
+	}
+
+	typ := schemaType(s)
+	switch typ {
+	case "object":
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			ve.Append(typeError(path, typ, v))
+			return nil
+		}
+
+		for _, r := range s.Required {
+			fv, ok := obj[r]
+			if !ok || fv == nil {
+				ve.Append(FieldError{Field: join(path, r), Message: "is required"})
+				continue
+			}
+
+			// The required strings must not be empty either, e.g. registry.url: "".
+			if str, ok := fv.(string); ok && strings.TrimSpace(str) == "" {
+				ps, err := d.resolve(s.Properties[r])
+				if err != nil {
+					return err
+				}
+				if ps != nil && ps.Type == "string" {
+					ve.Append(FieldError{Field: join(path, r), Message: "must not be empty"})
+				}
+			}
+		}
+
+		// Sort keys to get stable error messages.
+		keys := make([]string, 0, len(obj))
+		for k := range obj {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+
+		for _, k := range keys {
+			if ps, ok := s.Properties[k]; ok {
+				if err := d.validate(ps, obj[k], join(path, k), ve); err != nil {
+					return err
+				}
+				continue
+			}
+
+			if err := d.validateAdditional(s, obj[k], join(path, k), ve); err != nil {
+				return err
+			}
+		}
+	case "array":
+		arr, ok := v.([]interface{})
+		if !ok {
+			ve.Append(typeError(path, typ, v))
+			return nil
+		}
+
+		for i, item := range arr {
+			if err := d.validate(s.Items, item, fmt.Sprintf("%s[%d]", path, i), ve); err != nil {
+				return err
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			ve.Append(typeError(path, typ, v))
+			return nil
+		}
+
+		if msg := checkFormat(s.Format, str); msg != "" {
+			ve.Append(FieldError{Field: path, Message: msg})
+		}
+	case "number", "integer":
+		n, ok := v.(json.Number)
+		if !ok {
+			ve.Append(typeError(path, typ, v))
+			return nil
+		}
+
+		if typ == "integer" {
+			if _, err := n.Int64(); err != nil {
+				ve.Append(typeError(path, typ, v))
+				return nil
+			}
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			ve.Append(typeError(path, typ, v))
+			return nil
+		}
+	}
+
+	if len(s.Enum) > 0 && !inEnum(s.Enum, v) {
+		ve.Append(FieldError{Field: path, Message: fmt.Sprintf("must be one of %v", s.Enum)})
+	}
+
+	return nil
+}
+
+func (d *Document) validateAdditional(s *Schema, v interface{}, path string, ve *ValidationError) error {
+	node := s.AdditionalProperties
+	if node.Kind == 0 {
+		// Additional properties are allowed by default.
+		return nil
+	}
+
+	var allowed bool
+	if err := node.Decode(&allowed); err == nil {
+		if !allowed {
+			ve.Append(FieldError{Field: path, Message: "unknown field"})
+		}
+		return nil
+	}
+
+	as := &Schema{}
+	if err := node.Decode(as); err != nil {
+		return fmt.Errorf("invalid additionalProperties schema: %w", err)
+	}
+
+	return d.validate(as, v, path, ve)
+}
+
+// schemaType returns the JSON type of the schema, the schemas with properties are objects.
+func schemaType(s *Schema) string {
+	if s.Type == "" && len(s.Properties) > 0 {
+		return "object"
+	}
+
+	return s.Type
+}
+
+func checkFormat(format string, v string) string {
+	switch format {
+	case "date-time":
+		if _, err := time.Parse(time.RFC3339, v); err != nil {
+			return "must be a RFC3339 date-time"
+		}
+	case "url", "uri":
+		if _, err := url.Parse(v); err != nil {
+			return "must be a valid URL"
+		}
+	}
+
+	return ""
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	for _, e := range enum {
+		if fmt.Sprint(e) == fmt.Sprint(v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func typeError(path string, expected string, v interface{}) FieldError {
+	return FieldError{
+		Field:     path,
+		Message:   fmt.Sprintf("must be %s but got %s", expected, jsonType(v)),
+		Malformed: true,
+	}
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case map[string]interface{}:
+		return "object"
+	case []interface{}:
+		return "array"
+	case string:
+		return "string"
+	case json.Number:
+		return "number"
+	case bool:
+		return "boolean"
+	default:
+		return "null"
+	}
+}
+
+func join(path string, field string) string {
+	if path == "" {
+		return field
+	}
+
+	return fmt.Sprintf("%s.%s", path, field)
+}
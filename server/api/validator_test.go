@@ -0,0 +1,81 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestValidateScanRequest(t *testing.T) {
+	doc, err := Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s, ok := doc.RequestSchema("AcceptScanRequest")
+	if !ok {
+		t.Fatal("missing request schema of AcceptScanRequest")
+	}
+
+	cases := []struct {
+		name string
+		body string
+		// status of the validation error, 0 if the body is valid.
+		status int
+		// field expected in the error message.
+		field string
+	}{
+		{name: "valid", body: `{"registry":{"url":"https://core.harbor.domain"},"artifact":{"repository":"library/nginx","tag":"latest"}}`},
+		{name: "extra fields", body: `{"artifact":{"repository":"library/nginx","extra":1},"extra":true}`},
+		{name: "malformed JSON", body: `{"artifact":`, status: http.StatusBadRequest, field: "invalid JSON"},
+		{name: "null", body: `null`, status: http.StatusBadRequest, field: "must be object but got null"},
+		{name: "array", body: `[]`, status: http.StatusBadRequest, field: "must be object but got array"},
+		{name: "string", body: `"x"`, status: http.StatusBadRequest, field: "must be object but got string"},
+		{name: "missing artifact", body: `{}`, status: http.StatusUnprocessableEntity, field: "artifact: is required"},
+		{name: "null artifact", body: `{"artifact":null}`, status: http.StatusUnprocessableEntity, field: "artifact: is required"},
+		{name: "empty repository", body: `{"artifact":{"repository":" "}}`, status: http.StatusUnprocessableEntity, field: "artifact.repository: must not be empty"},
+		{name: "wrong type", body: `{"artifact":{"repository":1}}`, status: http.StatusBadRequest, field: "artifact.repository: must be string but got number"},
+		{name: "missing registry url", body: `{"registry":{},"artifact":{"repository":"library/nginx"}}`, status: http.StatusUnprocessableEntity, field: "registry.url: is required"},
+		{name: "wrong item type", body: `{"artifact":{"repository":"library/nginx"},"enabled_capabilities":[{"type":1}]}`, status: http.StatusBadRequest, field: "enabled_capabilities[0].type"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := doc.Validate(s, []byte(tc.body))
+			if tc.status == 0 {
+				if err != nil {
+					t.Fatalf("expect valid, got %v", err)
+				}
+				return
+			}
+
+			var ve *ValidationError
+			if !errors.As(err, &ve) {
+				t.Fatalf("expect validation error, got %v", err)
+			}
+
+			if ve.Status() != tc.status {
+				t.Errorf("expect status %d, got %d", tc.status, ve.Status())
+			}
+
+			if !strings.Contains(ve.Error(), tc.field) {
+				t.Errorf("expect %q in the error, got %q", tc.field, ve.Error())
+			}
+		})
+	}
+}
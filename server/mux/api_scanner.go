@@ -11,44 +11,230 @@ package mux
 
 import (
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
 	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
 
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/oci"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scan"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/scanner"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/api"
 	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
 
-	"github.com/gorilla/mux"
+const (
+	opAcceptScanRequest = "AcceptScanRequest"
+	opGetMetadata       = "GetMetadata"
+	opGetScanReport     = "GetScanReport"
+
+	querySBOMMediaType = "sbom_media_type"
 )
 
+// AcceptScanRequest validates the scan request and dispatches it to the providers
+// which can scan the artifact.
 func AcceptScanRequest(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
 	bytes, err := ioutil.ReadAll(r.Body)
 	if err != nil {
-		InternalServerError("read request error", err).Write(w)
+		SpecError(http.StatusInternalServerError, "read request error: %s", err).Write(w)
 		return
 	}
 
-	req := &spec.ScanRequest{}
-	if err := json.Unmarshal(bytes, req); err != nil {
-		BadRequestError("marshal request json data error", err).Write(w)
+	req, providers, err := decodeScanRequest(bytes)
+	if err != nil {
+		writeValidationError(w, "invalid scan request", err)
 		return
 	}
 
-	dt, _ := json.Marshal(&spec.ScanResponse{
-		Id: "0001-111-11",
+	// Apply the rewrite rules and mirrors of the registry before the scan jobs are enqueued.
+	// The registry is not set for the local artifacts.
+	if req.Registry != nil {
+		if u := oci.ResolveRegistry(ctx, req.Registry.Url); u != req.Registry.Url {
+			zlog.FromContext(ctx).Infow("registry URL is rewritten", "from", req.Registry.Url, "to", u)
+
+			registry := *req.Registry
+			registry.Url = u
+			req.Registry = &registry
+		}
+	}
+
+	accepted := 0
+	for _, p := range providers {
+		if _, err = p.AcceptScanRequest(ctx, req); err != nil {
+			zlog.FromContext(ctx).Errorw("provider accept scan request error",
+				zlog.FieldProvider, p.Metadata().Scanner.Name, "error", err)
+			continue
+		}
+		accepted++
+	}
+
+	if accepted == 0 {
+		SpecError(http.StatusInternalServerError, "scan request is not accepted: %s", err).Write(w)
+		return
+	}
+
+	writeSpecJSON(w, r, opAcceptScanRequest, http.StatusAccepted, mimeScanResponse, &spec.ScanResponse{
+		Id: uuid.FromContext(ctx),
 	})
-	JSON(dt).Write(w)
 }
 
+// GetMetadata returns the merged metadata of the enabled providers.
 func GetMetadata(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte("{}"))
+	writeSpecJSON(w, r, opGetMetadata, http.StatusOK, mimeMetadata, scanner.Metadata())
 }
 
+// GetScanReport returns the scan report with the mimetype specified in the Accept header.
 func GetScanReport(w http.ResponseWriter, r *http.Request) {
-	vars := mux.Vars(r)
-	reqID := vars["scan_request_id"]
+	ctx := r.Context()
+	reqID := mux.Vars(r)["scan_request_id"]
+	mimetype := strings.TrimSpace(r.Header.Get(headerAccept))
+
+	p := scanner.Get(mimetype)
+	if p == nil {
+		SpecError(http.StatusBadRequest, "unsupported report mime type: %q", mimetype).Write(w)
+		return
+	}
+
+	// Pass the requested media type of the report content, e.g. the SBOM format.
+	if mt := r.URL.Query().Get(querySBOMMediaType); mt != "" {
+		ctx = scan.WithReportMediaType(ctx, mt)
+	}
+
+	res, err := p.RetrieveScanResult(ctx, reqID, mimetype)
+	if err != nil {
+		SpecError(http.StatusInternalServerError, "retrieve scan report error: %s", err).Write(w)
+		return
+	}
+
+	switch res.Phase() {
+	case scan.ResultPhaseNotFound:
+		SpecError(http.StatusNotFound, "scan report of request %s is not found", reqID).Write(w)
+	case scan.ResultPhaseNotReady:
+		next := res.NextTry()
+		if next <= 0 {
+			next = scan.DefaultRetry
+		}
+		w.Header().Set(headerRefreshAfter, strconv.FormatInt(next, 10))
+		w.WriteHeader(http.StatusFound)
+	default:
+		data := []byte(res.JSON())
+		validateResponse(r, opGetScanReport, http.StatusOK, res.MimeType(), data)
+		JSON(data).WithContentType(res.MimeType()).Write(w)
+	}
+}
+
+// GetSpec returns the embedded OpenAPI document.
+// The YAML format is returned if it's accepted, otherwise JSON is returned.
+func GetSpec(w http.ResponseWriter, r *http.Request) {
+	doc, err := api.Load()
+	if err != nil {
+		SpecError(http.StatusInternalServerError, "load OpenAPI document error: %s", err).Write(w)
+		return
+	}
+
+	if strings.Contains(r.Header.Get(headerAccept), "yaml") {
+		JSON(api.SwaggerYAML).WithContentType(applicationYAML).Write(w)
+		return
+	}
+
+	JSON(doc.JSON()).Write(w)
+}
+
+// decodeScanRequest validates the scan request against the OpenAPI document
+// and returns the providers which can scan the requested artifact.
+func decodeScanRequest(data []byte) (*spec.ScanRequest, []scanner.Provider, error) {
+	if err := validateRequest(opAcceptScanRequest, data); err != nil {
+		return nil, nil, err
+	}
+
+	req := &spec.ScanRequest{}
+	if err := json.Unmarshal(data, req); err != nil {
+		return nil, nil, &api.ValidationError{
+			Fields: []api.FieldError{{Message: err.Error(), Malformed: true}},
+		}
+	}
+
+	// Checks not covered by the OpenAPI document.
+	ve := &api.ValidationError{}
+	if req.Artifact == nil {
+		ve.Append(api.FieldError{Field: "artifact", Message: "is required"})
+		return nil, nil, ve
+	}
+
+	if req.Artifact.Reference != "" {
+		// The registry is not accessed for the local artifacts.
+		req.Registry = nil
+
+		if err := allowLocal(req.Artifact.Reference); err != nil {
+			ve.Append(api.FieldError{Field: "artifact.reference", Message: err.Error()})
+		}
+	} else {
+		if req.Registry == nil {
+			ve.Append(api.FieldError{Field: "registry", Message: "registry is required"})
+		}
+
+		if req.Artifact.Tag == "" && req.Artifact.Digest == "" {
+			ve.Append(api.FieldError{Field: "artifact", Message: "either tag or digest is required"})
+		}
+	}
+
+	providers := scanner.Accepts(req)
+	if len(providers) == 0 {
+		ve.Append(api.FieldError{Field: "artifact.mime_type", Message: "unsupported artifact mime type or capabilities"})
+	}
+
+	if len(ve.Fields) > 0 {
+		return nil, nil, ve
+	}
+
+	return req, providers, nil
+}
+
+// allowLocal checks whether the local artifact reference can be scanned.
+// The local artifacts are scanned only if `scanner.local.enabled` is on, and the paths must be
+// under the directories of `scanner.local.paths` after the symlinks are resolved. Nothing is
+// allowed if no directories are set, the adapter must not read arbitrary paths of the host.
+func allowLocal(reference string) error {
+	if !viper.GetBool("scanner.local.enabled") {
+		return fmt.Errorf("local artifacts are not enabled")
+	}
+
+	r, err := oci.ParseLocal(reference)
+	if err != nil {
+		return err
+	}
+
+	dirs := viper.GetStringSlice("scanner.local.paths")
+	if len(dirs) == 0 {
+		return fmt.Errorf("no directories of the local artifacts are allowed, set scanner.local.paths")
+	}
+
+	// Resolve the symlinks, otherwise a link under the allowed directories can point to anywhere.
+	target, err := filepath.EvalSymlinks(r.Path)
+	if err != nil {
+		return fmt.Errorf("resolve path %s error: %w", r.Path, err)
+	}
+
+	for _, dir := range dirs {
+		resolved, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			// Nothing is under the missing directory.
+			continue
+		}
+
+		if rel, err := filepath.Rel(resolved, target); err == nil && rel != ".." && !strings.HasPrefix(rel, "../") {
+			return nil
+		}
+	}
 
-	w.Header().Set("Content-Type", "application/json; charset=UTF-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(reqID))
+	return fmt.Errorf("path %s is not allowed", r.Path)
 }
@@ -0,0 +1,95 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func TestAcceptScanRequestMalformedBody(t *testing.T) {
+	for _, body := range []string{`null`, `[]`, `"x"`, `1`, ``} {
+		t.Run(body, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/scan", strings.NewReader(body))
+
+			AcceptScanRequest(rec, req)
+
+			if rec.Code != http.StatusBadRequest {
+				t.Fatalf("expect status %d, got %d: %s", http.StatusBadRequest, rec.Code, rec.Body.String())
+			}
+
+			var res map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &res); err != nil {
+				t.Errorf("invalid error response: %v", err)
+			}
+		})
+	}
+}
+
+func TestAllowLocal(t *testing.T) {
+	root := t.TempDir()
+	allowed := filepath.Join(root, "allowed")
+	outside := filepath.Join(root, "outside")
+	for _, dir := range []string{allowed, outside, filepath.Join(allowed, "image")} {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.Symlink(outside, filepath.Join(allowed, "escape")); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		name      string
+		disabled  bool
+		paths     []string
+		reference string
+		allowed   bool
+	}{
+		{"disabled", true, []string{allowed}, "oci-layout:" + filepath.Join(allowed, "image"), false},
+		{"no allowed paths", false, nil, "oci-layout:" + filepath.Join(allowed, "image"), false},
+		{"under allowed path", false, []string{allowed}, "oci-layout:" + filepath.Join(allowed, "image"), true},
+		{"allowed path itself", false, []string{allowed}, "oci-layout:" + allowed, true},
+		{"outside allowed path", false, []string{allowed}, "oci-layout:" + outside, false},
+		{"sibling with common prefix", false, []string{allowed}, "oci-layout:" + allowed + "-other", false},
+		{"symlink escaping allowed path", false, []string{allowed}, "oci-layout:" + filepath.Join(allowed, "escape"), false},
+		{"missing path", false, []string{allowed}, "oci-layout:" + filepath.Join(allowed, "missing"), false},
+		{"invalid reference", false, []string{allowed}, "registry:" + allowed, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			viper.Set("scanner.local.enabled", !c.disabled)
+			viper.Set("scanner.local.paths", c.paths)
+
+			err := allowLocal(c.reference)
+			if c.allowed && err != nil {
+				t.Errorf("expect %s to be allowed, got %v", c.reference, err)
+			}
+			if !c.allowed && err == nil {
+				t.Errorf("expect %s to be rejected", c.reference)
+			}
+		})
+	}
+}
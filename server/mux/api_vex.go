@@ -0,0 +1,138 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sort"
+
+	"github.com/gorilla/mux"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/vex"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	opListVEX   = "ListVEX"
+	opCreateVEX = "CreateVEX"
+
+	// maxVEXSize is the size limit of the uploaded VEX documents.
+	maxVEXSize = 4 << 20
+)
+
+// ListVEX returns the info of the VEX documents uploaded via the API.
+func ListVEX(w http.ResponseWriter, r *http.Request) {
+	contents, err := store.VEX().ListVEX()
+	if err != nil {
+		SpecError(http.StatusInternalServerError, "list VEX documents error: %s", err).Write(w)
+		return
+	}
+
+	infos := make([]*spec.VexDocumentInfo, 0, len(contents))
+	for id, content := range contents {
+		doc, err := vex.Parse(content)
+		if err != nil {
+			zlog.FromContext(r.Context()).Warnw("invalid VEX document in the store", "id", id, "error", err)
+			continue
+		}
+		infos = append(infos, doc.Info())
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].DocumentId < infos[j].DocumentId })
+
+	writeSpecJSON(w, r, opListVEX, http.StatusOK, applicationJSON, infos)
+}
+
+// CreateVEX uploads the OpenVEX document, the document with the same @id is replaced.
+func CreateVEX(w http.ResponseWriter, r *http.Request) {
+	content, err := ioutil.ReadAll(io.LimitReader(r.Body, maxVEXSize+1))
+	if err != nil {
+		SpecError(http.StatusInternalServerError, "read request error: %s", err).Write(w)
+		return
+	}
+
+	if len(content) > maxVEXSize {
+		SpecError(http.StatusRequestEntityTooLarge, "VEX document exceeds %d bytes", maxVEXSize).Write(w)
+		return
+	}
+
+	if !json.Valid(content) {
+		SpecError(http.StatusBadRequest, "invalid JSON").Write(w)
+		return
+	}
+
+	doc, err := vex.Parse(content)
+	if err != nil {
+		SpecError(http.StatusUnprocessableEntity, "invalid VEX document: %s", err).Write(w)
+		return
+	}
+
+	if doc.ID == "" {
+		SpecError(http.StatusUnprocessableEntity, "invalid VEX document: @id is required").Write(w)
+		return
+	}
+
+	if err := store.VEX().SaveVEX(doc.Key(), content); err != nil {
+		SpecError(http.StatusInternalServerError, "save VEX document error: %s", err).Write(w)
+		return
+	}
+
+	zlog.FromContext(r.Context()).Infow("VEX document is uploaded", "id", doc.Key(), "document_id", doc.ID)
+
+	writeSpecJSON(w, r, opCreateVEX, http.StatusCreated, applicationJSON, doc.Info())
+}
+
+// GetVEX returns the uploaded VEX document as it is.
+func GetVEX(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["vex_id"]
+
+	content, err := store.VEX().GetVEX(id)
+	if err != nil {
+		if errors.Is(err, rds.NotFoundErr) {
+			SpecError(http.StatusNotFound, "VEX document %s is not found", id).Write(w)
+			return
+		}
+
+		SpecError(http.StatusInternalServerError, "get VEX document error: %s", err).Write(w)
+		return
+	}
+
+	JSON(content).WithContentType(vex.MediaType).Write(w)
+}
+
+// DeleteVEX removes the uploaded VEX document.
+func DeleteVEX(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["vex_id"]
+
+	if err := store.VEX().DeleteVEX(id); err != nil {
+		if errors.Is(err, rds.NotFoundErr) {
+			SpecError(http.StatusNotFound, "VEX document %s is not found", id).Write(w)
+			return
+		}
+
+		SpecError(http.StatusInternalServerError, "delete VEX document error: %s", err).Write(w)
+		return
+	}
+
+	zlog.FromContext(r.Context()).Infow("VEX document is deleted", "id", id)
+
+	w.WriteHeader(http.StatusNoContent)
+}
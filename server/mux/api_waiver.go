@@ -0,0 +1,188 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/store/rds"
+	"github.com/szlabs/harbor-scanner-adapter/pkg/waiver"
+	"github.com/szlabs/harbor-scanner-adapter/server/api"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
+)
+
+const (
+	opListWaivers     = "ListWaivers"
+	opCreateWaiver    = "CreateWaiver"
+	opGetWaiver       = "GetWaiver"
+	opUpdateWaiver    = "UpdateWaiver"
+	opListWaiverAudit = "ListWaiverAudit"
+
+	queryActive = "active"
+	queryLimit  = "limit"
+
+	defaultAuditLimit = 100
+)
+
+// ListWaivers returns the waivers ordered by the expiry, only the active ones if `active=true` is queried.
+func ListWaivers(w http.ResponseWriter, r *http.Request) {
+	list := waiver.List
+	if active, _ := strconv.ParseBool(r.URL.Query().Get(queryActive)); active {
+		list = waiver.Active
+	}
+
+	waivers, err := list(r.Context())
+	if err != nil {
+		SpecError(http.StatusInternalServerError, "list waivers error: %s", err).Write(w)
+		return
+	}
+
+	if waivers == nil {
+		waivers = []*spec.Waiver{}
+	}
+
+	writeSpecJSON(w, r, opListWaivers, http.StatusOK, applicationJSON, waivers)
+}
+
+// CreateWaiver creates the waiver, the change is audited.
+func CreateWaiver(w http.ResponseWriter, r *http.Request) {
+	in, err := decodeWaiver(r, opCreateWaiver)
+	if err != nil {
+		writeValidationError(w, "invalid waiver", err)
+		return
+	}
+
+	created, err := waiver.Create(r.Context(), in, actor(r))
+	if err != nil {
+		writeWaiverError(w, "", err)
+		return
+	}
+
+	writeSpecJSON(w, r, opCreateWaiver, http.StatusCreated, applicationJSON, created)
+}
+
+// GetWaiver returns the waiver.
+func GetWaiver(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["waiver_id"]
+
+	found, err := waiver.Get(id)
+	if err != nil {
+		writeWaiverError(w, id, err)
+		return
+	}
+
+	writeSpecJSON(w, r, opGetWaiver, http.StatusOK, applicationJSON, found)
+}
+
+// UpdateWaiver replaces the waiver, the change is audited.
+func UpdateWaiver(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["waiver_id"]
+
+	in, err := decodeWaiver(r, opUpdateWaiver)
+	if err != nil {
+		writeValidationError(w, "invalid waiver", err)
+		return
+	}
+
+	updated, err := waiver.Update(r.Context(), id, in, actor(r))
+	if err != nil {
+		writeWaiverError(w, id, err)
+		return
+	}
+
+	writeSpecJSON(w, r, opUpdateWaiver, http.StatusOK, applicationJSON, updated)
+}
+
+// DeleteWaiver removes the waiver, the change is audited.
+func DeleteWaiver(w http.ResponseWriter, r *http.Request) {
+	id := mux.Vars(r)["waiver_id"]
+
+	if err := waiver.Delete(r.Context(), id, actor(r)); err != nil {
+		writeWaiverError(w, id, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListWaiverAudit returns the latest entries of the waiver audit log, 100 by default.
+func ListWaiverAudit(w http.ResponseWriter, r *http.Request) {
+	limit := defaultAuditLimit
+	if l := r.URL.Query().Get(queryLimit); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil || n <= 0 {
+			SpecError(http.StatusBadRequest, "invalid limit: %q", l).Write(w)
+			return
+		}
+		limit = n
+	}
+
+	entries, err := waiver.Audit(r.Context(), limit)
+	if err != nil {
+		SpecError(http.StatusInternalServerError, "list waiver audit error: %s", err).Write(w)
+		return
+	}
+
+	writeSpecJSON(w, r, opListWaiverAudit, http.StatusOK, applicationJSON, entries)
+}
+
+// decodeWaiver validates the waiver in the request body against the OpenAPI document.
+func decodeWaiver(r *http.Request, operationID string) (*spec.Waiver, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := validateRequest(operationID, data); err != nil {
+		return nil, err
+	}
+
+	in := &spec.Waiver{}
+	if err := json.Unmarshal(data, in); err != nil {
+		return nil, &api.ValidationError{
+			Fields: []api.FieldError{{Message: err.Error(), Malformed: true}},
+		}
+	}
+
+	return in, nil
+}
+
+// writeWaiverError writes the error of the waiver operation with the status code of its kind.
+func writeWaiverError(w http.ResponseWriter, id string, err error) {
+	switch {
+	case errors.Is(err, waiver.ErrInvalid):
+		SpecError(http.StatusUnprocessableEntity, "%s", err).Write(w)
+	case errors.Is(err, rds.NotFoundErr):
+		SpecError(http.StatusNotFound, "waiver %s is not found", id).Write(w)
+	default:
+		SpecError(http.StatusInternalServerError, "waiver operation error: %s", err).Write(w)
+	}
+}
+
+// actor of the change in the audit log: the basic auth user, or the remote address
+// if the user is not known, e.g. the bearer tokens and the API keys.
+func actor(r *http.Request) string {
+	if user, _, ok := r.BasicAuth(); ok && user != "" {
+		return user
+	}
+
+	return r.RemoteAddr
+}
@@ -0,0 +1,246 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/szlabs/goworker/pkg/errs"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+)
+
+const (
+	headerAuthorization   = "Authorization"
+	headerWWWAuthenticate = "WWW-Authenticate"
+	defaultAPIKeyHeader   = "X-API-Key"
+	realm                 = "harbor-scanner-adapter"
+)
+
+// authFailures counts the failed authentication attempts by reason.
+var authFailures = expvar.NewMap("auth_failures")
+
+var (
+	errMissingCredential = errors.New("missing credential")
+	errInvalidCredential = errors.New("invalid credential")
+	errUnsupportedScheme = errors.New("unsupported authorization scheme")
+)
+
+// Authenticator verifies the credentials carried by the API requests.
+// Static bearer tokens, basic auth users and API keys are supported and can be enabled together.
+type Authenticator struct {
+	bearerTokens []string
+	basicUsers   map[string]string
+	apiKeys      []string
+	apiKeyHeader string
+}
+
+// NewAuthenticator creates an Authenticator with the credentials loaded from the configurations
+// and the credential files under the `server.auth` section.
+func NewAuthenticator() (*Authenticator, error) {
+	errorf := errs.WithPrefix("create API authenticator error")
+
+	a := &Authenticator{
+		basicUsers:   map[string]string{},
+		apiKeyHeader: viper.GetString("server.auth.apiKeyHeader"),
+	}
+	if a.apiKeyHeader == "" {
+		a.apiKeyHeader = defaultAPIKeyHeader
+	}
+
+	tokens, err := loadCredentials("server.auth.bearerTokens", "server.auth.bearerTokensFile")
+	if err != nil {
+		return nil, errorf.Wrap("load bearer tokens error", err)
+	}
+	a.bearerTokens = tokens
+
+	keys, err := loadCredentials("server.auth.apiKeys", "server.auth.apiKeysFile")
+	if err != nil {
+		return nil, errorf.Wrap("load API keys error", err)
+	}
+	a.apiKeys = keys
+
+	users, err := loadCredentials("server.auth.basicUsers", "server.auth.basicUsersFile")
+	if err != nil {
+		return nil, errorf.Wrap("load basic auth users error", err)
+	}
+	for _, u := range users {
+		segments := strings.SplitN(u, ":", 2)
+		if len(segments) != 2 || segments[0] == "" || segments[1] == "" {
+			return nil, errorf.Error("invalid basic auth user: expected format is 'username:password'")
+		}
+		a.basicUsers[segments[0]] = segments[1]
+	}
+
+	return a, nil
+}
+
+// Enabled returns true if any credential is configured.
+func (a *Authenticator) Enabled() bool {
+	return a != nil && (len(a.bearerTokens) > 0 || len(a.basicUsers) > 0 || len(a.apiKeys) > 0)
+}
+
+// Verify the credential carried by the request.
+func (a *Authenticator) Verify(r *http.Request) error {
+	if key := r.Header.Get(a.apiKeyHeader); key != "" && len(a.apiKeys) > 0 {
+		if matchAny(key, a.apiKeys) {
+			return nil
+		}
+
+		return errInvalidCredential
+	}
+
+	authorization := r.Header.Get(headerAuthorization)
+	if authorization == "" {
+		return errMissingCredential
+	}
+
+	segments := strings.SplitN(authorization, " ", 2)
+	if len(segments) != 2 {
+		return errInvalidCredential
+	}
+
+	switch {
+	case strings.EqualFold(segments[0], "Bearer") && len(a.bearerTokens) > 0:
+		if matchAny(strings.TrimSpace(segments[1]), a.bearerTokens) {
+			return nil
+		}
+	case strings.EqualFold(segments[0], "Basic") && len(a.basicUsers) > 0:
+		data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(segments[1]))
+		if err != nil {
+			return errInvalidCredential
+		}
+
+		tokens := strings.SplitN(string(data), ":", 2)
+		if len(tokens) == 2 {
+			// Compare the password of the unknown user too, so the users can't be told by the time.
+			password, ok := a.basicUsers[tokens[0]]
+			if match(tokens[1], password) && ok {
+				return nil
+			}
+		}
+	default:
+		return errUnsupportedScheme
+	}
+
+	return errInvalidCredential
+}
+
+// challenge returns the WWW-Authenticate header values for the enabled schemes.
+func (a *Authenticator) challenge() []string {
+	var values []string
+	if len(a.basicUsers) > 0 {
+		values = append(values, fmt.Sprintf("Basic realm=%q", realm))
+	}
+	if len(a.bearerTokens) > 0 {
+		values = append(values, fmt.Sprintf("Bearer realm=%q", realm))
+	}
+
+	return values
+}
+
+// Authenticate rejects the requests failing the verification of the authenticator.
+// The failures are logged and counted by reason.
+func Authenticate(inner http.Handler, a *Authenticator, name string) http.Handler {
+	if !a.Enabled() {
+		return inner
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := a.Verify(r); err != nil {
+			authFailures.Add(err.Error(), 1)
+			zlog.FromContext(r.Context()).Warnw(
+				"API authentication failed",
+				"name", name,
+				"remote_addr", r.RemoteAddr,
+				"reason", err.Error(),
+			)
+
+			for _, v := range a.challenge() {
+				w.Header().Add(headerWWWAuthenticate, v)
+			}
+			UnauthorizedError("authentication is required", err).Write(w)
+			return
+		}
+
+		inner.ServeHTTP(w, r)
+	})
+}
+
+func match(actual, expected string) bool {
+	return subtle.ConstantTimeCompare([]byte(actual), []byte(expected)) == 1
+}
+
+func matchAny(actual string, expected []string) bool {
+	matched := false
+	for _, e := range expected {
+		// Do not break to keep the comparison time stable.
+		if match(actual, e) {
+			matched = true
+		}
+	}
+
+	return matched
+}
+
+// loadCredentials loads the credentials from the configuration list and the credential file.
+// The file contains one credential per line, empty lines and lines starting with '#' are ignored.
+func loadCredentials(listKey string, fileKey string) ([]string, error) {
+	var credentials []string
+	for _, c := range viper.GetStringSlice(listKey) {
+		if c = strings.TrimSpace(c); c != "" {
+			credentials = append(credentials, c)
+		}
+	}
+
+	file := viper.GetString(fileKey)
+	if file == "" {
+		return credentials, nil
+	}
+
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, fmt.Errorf("open credential file %s error: %w", file, err)
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			zlog.Logger().Error(err)
+		}
+	}()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		credentials = append(credentials, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("read credential file %s error: %w", file, err)
+	}
+
+	return credentials, nil
+}
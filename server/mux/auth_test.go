@@ -0,0 +1,225 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"encoding/base64"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+// newTestAuthenticator creates the authenticator with the credentials configured.
+func newTestAuthenticator(t *testing.T, settings map[string]interface{}) *Authenticator {
+	t.Helper()
+	t.Cleanup(viper.Reset)
+
+	for k, v := range settings {
+		viper.Set(k, v)
+	}
+
+	a, err := NewAuthenticator()
+	if err != nil {
+		t.Fatalf("create authenticator error: %v", err)
+	}
+
+	return a
+}
+
+func basic(username, password string) string {
+	return "Basic " + base64.StdEncoding.EncodeToString([]byte(username+":"+password))
+}
+
+func TestAuthenticate(t *testing.T) {
+	a := newTestAuthenticator(t, map[string]interface{}{
+		"server.auth.bearerTokens": []string{"token-1", "token-2"},
+		"server.auth.basicUsers":   []string{"harbor:s3cret"},
+		"server.auth.apiKeys":      []string{"key-1"},
+	})
+
+	cases := []struct {
+		name   string
+		header map[string]string
+		code   int
+		reason string
+	}{
+		{"bearer", map[string]string{"Authorization": "Bearer token-2"}, http.StatusOK, ""},
+		{"bearer case insensitive scheme", map[string]string{"Authorization": "bearer token-1"}, http.StatusOK, ""},
+		{"invalid bearer", map[string]string{"Authorization": "Bearer token-3"}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"bearer prefix of token", map[string]string{"Authorization": "Bearer token-"}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"basic", map[string]string{"Authorization": basic("harbor", "s3cret")}, http.StatusOK, ""},
+		{"wrong password", map[string]string{"Authorization": basic("harbor", "secret")}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"unknown user", map[string]string{"Authorization": basic("admin", "s3cret")}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"unknown user with empty password", map[string]string{"Authorization": basic("admin", "")}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"basic without colon", map[string]string{"Authorization": "Basic " + base64.StdEncoding.EncodeToString([]byte("harbor"))}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"basic not encoded", map[string]string{"Authorization": "Basic harbor:s3cret"}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"api key", map[string]string{"X-API-Key": "key-1"}, http.StatusOK, ""},
+		{"invalid api key", map[string]string{"X-API-Key": "key-2", "Authorization": "Bearer token-1"}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"missing credential", nil, http.StatusUnauthorized, errMissingCredential.Error()},
+		{"malformed authorization", map[string]string{"Authorization": "Bearer"}, http.StatusUnauthorized, errInvalidCredential.Error()},
+		{"unsupported scheme", map[string]string{"Authorization": "Digest username=harbor"}, http.StatusUnauthorized, errUnsupportedScheme.Error()},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			handler := Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}), a, "Test")
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+			for k, v := range c.header {
+				req.Header.Set(k, v)
+			}
+
+			before := authFailureCount(c.reason)
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != c.code {
+				t.Fatalf("expect status %d, got %d: %s", c.code, rec.Code, rec.Body.String())
+			}
+			if c.code == http.StatusOK {
+				return
+			}
+
+			want := []string{`Basic realm="harbor-scanner-adapter"`, `Bearer realm="harbor-scanner-adapter"`}
+			if got := rec.Header().Values(headerWWWAuthenticate); !reflect.DeepEqual(got, want) {
+				t.Errorf("expect challenges %v, got %v", want, got)
+			}
+			if n := authFailureCount(c.reason); n != before+1 {
+				t.Errorf("expect the failure %q to be counted", c.reason)
+			}
+		})
+	}
+}
+
+// authFailureCount returns the count of the authentication failures of the reason.
+func authFailureCount(reason string) int64 {
+	if v, ok := authFailures.Get(reason).(interface{ Value() int64 }); ok {
+		return v.Value()
+	}
+
+	return 0
+}
+
+func TestAuthenticateDisabled(t *testing.T) {
+	a := newTestAuthenticator(t, nil)
+	if a.Enabled() {
+		t.Fatal("expect the authentication to be disabled without credentials")
+	}
+
+	handler := Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}), a, "Test")
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil))
+	if rec.Code != http.StatusNoContent {
+		t.Errorf("expect the request to pass, got %d", rec.Code)
+	}
+}
+
+func TestAuthenticateSchemeNotEnabled(t *testing.T) {
+	a := newTestAuthenticator(t, map[string]interface{}{
+		"server.auth.bearerTokens": []string{"token-1"},
+	})
+
+	cases := []struct {
+		name   string
+		header string
+		value  string
+	}{
+		// The basic scheme is not accepted if no users are configured.
+		{"basic", "Authorization", basic("harbor", "token-1")},
+		// The API key header is ignored if no keys are configured.
+		{"api key", "X-API-Key", "token-1"},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+			req.Header.Set(c.header, c.value)
+
+			if err := a.Verify(req); err == nil {
+				t.Error("expect the request to be rejected")
+			}
+		})
+	}
+
+	if got := a.challenge(); !reflect.DeepEqual(got, []string{`Bearer realm="harbor-scanner-adapter"`}) {
+		t.Errorf("expect only the bearer challenge, got %v", got)
+	}
+}
+
+func TestAuthenticatorCredentialFiles(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		file := filepath.Join(dir, name)
+		if err := ioutil.WriteFile(file, []byte(content), 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return file
+	}
+
+	a := newTestAuthenticator(t, map[string]interface{}{
+		"server.auth.apiKeyHeader":     "X-Scanner-Key",
+		"server.auth.bearerTokens":     []string{" token-1 ", ""},
+		"server.auth.bearerTokensFile": write("tokens", "# rotated monthly\n\ntoken-2\n  token-3  \n"),
+		"server.auth.basicUsersFile":   write("users", "harbor:pass:word\n"),
+		"server.auth.apiKeysFile":      write("keys", "key-1\n"),
+	})
+
+	if want := []string{"token-1", "token-2", "token-3"}; !reflect.DeepEqual(a.bearerTokens, want) {
+		t.Errorf("expect bearer tokens %v, got %v", want, a.bearerTokens)
+	}
+	if want := map[string]string{"harbor": "pass:word"}; !reflect.DeepEqual(a.basicUsers, want) {
+		t.Errorf("expect basic users %v, got %v", want, a.basicUsers)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/metadata", nil)
+	req.Header.Set("X-Scanner-Key", "key-1")
+	if err := a.Verify(req); err != nil {
+		t.Errorf("expect the API key in the custom header to pass, got %v", err)
+	}
+}
+
+func TestNewAuthenticatorInvalid(t *testing.T) {
+	cases := []struct {
+		name     string
+		settings map[string]interface{}
+	}{
+		{"basic user without password", map[string]interface{}{"server.auth.basicUsers": []string{"harbor:"}}},
+		{"basic user without colon", map[string]interface{}{"server.auth.basicUsers": []string{"harbor"}}},
+		{"missing credential file", map[string]interface{}{"server.auth.apiKeysFile": "/nonexistent/keys"}},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			for k, v := range c.settings {
+				viper.Set(k, v)
+			}
+
+			if _, err := NewAuthenticator(); err == nil {
+				t.Error("expect error")
+			}
+		})
+	}
+}
@@ -20,11 +20,19 @@ import (
 	"net/http"
 
 	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/spec"
 )
 
 const (
-	headerContentType = "Content-Type"
-	applicationJSON   = "application/json; charset=UTF-8"
+	headerContentType  = "Content-Type"
+	headerAccept       = "Accept"
+	headerRefreshAfter = "Refresh-After"
+	applicationJSON    = "application/json; charset=UTF-8"
+	applicationYAML    = "application/yaml; charset=UTF-8"
+
+	mimeMetadata     = "application/vnd.scanner.adapter.metadata+json; version=1.0"
+	mimeScanResponse = "application/vnd.scanner.adapter.scan.response+json; version=1.0"
+	mimeError        = "application/vnd.scanner.adapter.error+json; version=1.0"
 )
 
 // HTTPError defines an HTTP error.
@@ -67,6 +75,15 @@ func BadRequestError(message string, err error) *HTTPError {
 	}
 }
 
+// UnauthorizedError represents an unauthorized error.
+func UnauthorizedError(message string, err error) *HTTPError {
+	return &HTTPError{
+		Code:    http.StatusUnauthorized,
+		Message: message,
+		Error:   fmt.Sprintf("unauthorized: %s", err.Error()),
+	}
+}
+
 // NotFoundError represents a not found error.
 func NotFoundError(message string, err error) *HTTPError {
 	return &HTTPError{
@@ -76,23 +93,69 @@ func NotFoundError(message string, err error) *HTTPError {
 	}
 }
 
+// ErrorResponse represents an error response defined in the scanner adapter spec.
+type ErrorResponse struct {
+	code int
+	body *spec.ErrorResponse
+}
+
+// SpecError builds an error response defined in the scanner adapter spec.
+func SpecError(code int, format string, args ...interface{}) *ErrorResponse {
+	return &ErrorResponse{
+		code: code,
+		body: &spec.ErrorResponse{
+			Error_: &spec.ModelError{
+				Message: fmt.Sprintf(format, args...),
+			},
+		},
+	}
+}
+
+// Write error stream.
+func (er *ErrorResponse) Write(w http.ResponseWriter) {
+	bytes, _ := json.Marshal(er.body)
+
+	w.Header().Set(headerContentType, mimeError)
+	w.WriteHeader(er.code)
+	if n, err := w.Write(bytes); err != nil {
+		zlog.Logger().Errorw("write response error", "error", err, "wrote_bytes", n)
+	}
+}
+
 // JSONResponse represents a JSON response.
 type JSONResponse struct {
-	json []byte
+	json        []byte
+	code        int
+	contentType string
+}
+
+// WithStatus sets the status code of the response.
+func (jr *JSONResponse) WithStatus(code int) *JSONResponse {
+	jr.code = code
+	return jr
+}
+
+// WithContentType sets the content type of the response.
+func (jr *JSONResponse) WithContentType(contentType string) *JSONResponse {
+	jr.contentType = contentType
+	return jr
 }
 
 // Write writes JSON data to the network stream.
 func (jr *JSONResponse) Write(w http.ResponseWriter) {
-	w.Header().Set(headerContentType, applicationJSON)
-	w.WriteHeader(http.StatusOK)
+	w.Header().Set(headerContentType, jr.contentType)
+	w.WriteHeader(jr.code)
 	if n, err := w.Write(jr.json); err != nil {
 		zlog.Logger().Errorw("write response error", "error", err, "wrote_bytes", n)
 	}
 }
 
 // JSON wraps the data as a JSON response.
+// The status code is 200 and the content type is application/json by default.
 func JSON(data []byte) *JSONResponse {
 	return &JSONResponse{
-		json: data,
+		json:        data,
+		code:        http.StatusOK,
+		contentType: applicationJSON,
 	}
 }
@@ -16,22 +16,43 @@ import (
 	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
 )
 
+// statusRecorder records the status code written by the inner handler.
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (sr *statusRecorder) WriteHeader(code int) {
+	sr.status = code
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+// Write implements http.ResponseWriter.
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return n, err
+}
+
+// Logger logs the served request with the fields attached to the request context.
 func Logger(inner http.Handler, name string) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
+		sr := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
 
-		inner.ServeHTTP(w, r)
+		inner.ServeHTTP(sr, r)
 
-		zlog.Logger().Infow(
+		zlog.FromContext(r.Context()).Infow(
 			"serve request",
-			"method",
-			r.Method,
-			"request_uri",
-			r.RequestURI,
-			"name",
-			name,
-			"duration",
-			time.Since(start).String(),
+			"method", r.Method,
+			"request_uri", r.RequestURI,
+			"name", name,
+			"status", sr.status,
+			"bytes", sr.bytes,
+			"remote_addr", r.RemoteAddr,
+			"duration", time.Since(start).String(),
 		)
 	})
 }
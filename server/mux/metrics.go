@@ -0,0 +1,50 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"bytes"
+	"expvar"
+	"fmt"
+	"net/http"
+)
+
+// hiddenMetrics are the expvar variables published by the standard library, which are not served:
+// the command line may carry the credentials and the memory stats leak the process internals.
+var hiddenMetrics = map[string]bool{
+	"cmdline":  true,
+	"memstats": true,
+}
+
+// GetMetrics serves the expvar metrics of the adapter, e.g: the authentication failures and the
+// blob cache hit rates. It's authenticated like the other APIs.
+func GetMetrics(w http.ResponseWriter, r *http.Request) {
+	buf := &bytes.Buffer{}
+	buf.WriteString("{")
+	first := true
+	expvar.Do(func(kv expvar.KeyValue) {
+		if hiddenMetrics[kv.Key] {
+			return
+		}
+		if !first {
+			buf.WriteString(",")
+		}
+		first = false
+		fmt.Fprintf(buf, "%q:%s", kv.Key, kv.Value)
+	})
+	buf.WriteString("}")
+
+	JSON(buf.Bytes()).Write(w)
+}
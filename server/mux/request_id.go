@@ -0,0 +1,50 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"net/http"
+	"regexp"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/uuid"
+)
+
+const (
+	// HeaderRequestID is the header carrying the request ID.
+	HeaderRequestID = "X-Request-Id"
+)
+
+// validRequestID restricts the incoming request ID to keep the logs clean.
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// RequestID injects a request ID generated by the adapter into the request context, it identifies
+// the scan request accepted by the handler. The X-Request-Id header passed from the client is only
+// kept as the correlation ID of the logs and echoed in the response header, the generated request ID
+// is echoed if it's absent.
+func RequestID(inner http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx, reqID := uuid.WithContext(r.Context())
+
+		corrID := r.Header.Get(HeaderRequestID)
+		if !validRequestID.MatchString(corrID) {
+			corrID = reqID
+		}
+		ctx = uuid.WithCorrelationID(ctx, corrID)
+
+		w.Header().Set(HeaderRequestID, corrID)
+
+		inner.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
@@ -13,9 +13,11 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"path"
 	"strings"
 
 	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
 )
 
 // Route of the API handler.
@@ -29,39 +31,99 @@ type Route struct {
 // Routes of the scanner adapter API handlers.
 type Routes []Route
 
+const (
+	// APIVersionV1 is the version of the Harbor pluggable scanner spec v1.
+	APIVersionV1 = "v1"
+	// apiPrefix is the prefix of the versioned API paths.
+	apiPrefix = "/api"
+)
+
 // NewRouter registers API routes.
-func NewRouter() *mux.Router {
+// The versioned routes are mounted under /api/{version}. The v1 routes served before the
+// versioned paths are also served with the legacy bare paths if `server.legacyPaths` is enabled.
+func NewRouter() (*mux.Router, error) {
+	authenticator, err := NewAuthenticator()
+	if err != nil {
+		return nil, err
+	}
+
 	router := mux.NewRouter().StrictSlash(true)
-	for _, route := range routes {
+	var index []API
+
+	register := func(route Route, version string, pattern string, named bool) {
 		var handler http.Handler
 		handler = route.HandlerFunc
+		if !isPublic(route) {
+			handler = Authenticate(handler, authenticator, route.Name)
+		}
 		handler = Logger(handler, route.Name)
+		handler = RequestID(handler)
 
-		router.
+		r := router.
 			Methods(route.Method).
-			Path(route.Pattern).
-			Name(route.Name).
+			Path(pattern).
 			Handler(handler)
+		// Route names must be unique, so only the canonical route is named.
+		if named {
+			r.Name(route.Name)
+		}
+
+		index = append(index, API{
+			Name:    route.Name,
+			Method:  route.Method,
+			Version: version,
+			API:     pattern,
+		})
+	}
+
+	for _, route := range routes {
+		register(route, "", route.Pattern, true)
 	}
 
-	return router
+	for _, version := range apiVersions {
+		for _, route := range versionedRoutes[version] {
+			name := route.Name
+			if version != APIVersionV1 {
+				// Keep the v1 route names as they are for compatibility.
+				name = fmt.Sprintf("%s_%s", route.Name, version)
+			}
+			route.Name = name
+			register(route, version, path.Join(apiPrefix, version, route.Pattern), true)
+		}
+	}
+
+	if viper.GetBool("server.legacyPaths") {
+		for _, route := range versionedRoutes[APIVersionV1] {
+			if legacyRoutes[route.Name] {
+				register(route, APIVersionV1, route.Pattern, false)
+			}
+		}
+	}
+
+	apiIndex = index
+
+	return router, nil
+}
+
+// isPublic checks whether the route can be accessed without authentication.
+func isPublic(route Route) bool {
+	return route.Name == "GetMetadata" && viper.GetBool("server.auth.publicMetadata")
 }
 
 // Index of the API.
 func Index(w http.ResponseWriter, r *http.Request) {
-	// Append host info.
-	var res []*API
-	res = append(res, APIIndex...)
-	for _, a := range res {
+	// Append host info to the copies.
+	res := make([]API, 0, len(apiIndex))
+	for _, a := range apiIndex {
 		a.Host = r.Host
+		res = append(res, a)
 	}
 
 	data, _ := json.Marshal(res)
-
-	w.Header().Set(headerContentType, applicationJSON)
-	fmt.Fprintf(w, string(data))
+	JSON(data).Write(w)
 }
 
+// routes are the unversioned routes.
 var routes = Routes{
 	Route{
 		"Index",
@@ -71,44 +133,139 @@ var routes = Routes{
 	},
 
 	Route{
-		"AcceptScanRequest",
-		strings.ToUpper("Post"),
-		"/scan",
-		AcceptScanRequest,
-	},
-
-	Route{
-		"GetMetadata",
+		"GetMetrics",
 		strings.ToUpper("Get"),
-		"/metadata",
-		GetMetadata,
+		"/metrics",
+		GetMetrics,
 	},
+}
 
-	Route{
-		"GetScanReport",
-		strings.ToUpper("Get"),
-		"/scan/{scan_request_id}/report",
-		GetScanReport,
+// apiVersions are the supported API versions in order.
+var apiVersions = []string{
+	APIVersionV1,
+}
+
+// legacyRoutes are the names of the v1 routes served with the bare paths for compatibility.
+// The APIs added later are only served under /api/{version}.
+var legacyRoutes = map[string]bool{
+	"AcceptScanRequest": true,
+	"GetMetadata":       true,
+	"GetScanReport":     true,
+}
+
+// versionedRoutes are the routes of each API version.
+// The patterns are relative to /api/{version}.
+var versionedRoutes = map[string]Routes{
+	APIVersionV1: {
+		Route{
+			"AcceptScanRequest",
+			strings.ToUpper("Post"),
+			"/scan",
+			AcceptScanRequest,
+		},
+
+		Route{
+			"GetMetadata",
+			strings.ToUpper("Get"),
+			"/metadata",
+			GetMetadata,
+		},
+
+		Route{
+			"GetScanReport",
+			strings.ToUpper("Get"),
+			"/scan/{scan_request_id}/report",
+			GetScanReport,
+		},
+
+		Route{
+			"ListVEX",
+			strings.ToUpper("Get"),
+			"/vex",
+			ListVEX,
+		},
+
+		Route{
+			"CreateVEX",
+			strings.ToUpper("Post"),
+			"/vex",
+			CreateVEX,
+		},
+
+		Route{
+			"GetVEX",
+			strings.ToUpper("Get"),
+			"/vex/{vex_id}",
+			GetVEX,
+		},
+
+		Route{
+			"DeleteVEX",
+			strings.ToUpper("Delete"),
+			"/vex/{vex_id}",
+			DeleteVEX,
+		},
+
+		Route{
+			"ListWaivers",
+			strings.ToUpper("Get"),
+			"/waivers",
+			ListWaivers,
+		},
+
+		Route{
+			"CreateWaiver",
+			strings.ToUpper("Post"),
+			"/waivers",
+			CreateWaiver,
+		},
+
+		// The audit route is registered before the waiver routes to take precedence over the IDs.
+		Route{
+			"ListWaiverAudit",
+			strings.ToUpper("Get"),
+			"/waivers/audit",
+			ListWaiverAudit,
+		},
+
+		Route{
+			"GetWaiver",
+			strings.ToUpper("Get"),
+			"/waivers/{waiver_id}",
+			GetWaiver,
+		},
+
+		Route{
+			"UpdateWaiver",
+			strings.ToUpper("Put"),
+			"/waivers/{waiver_id}",
+			UpdateWaiver,
+		},
+
+		Route{
+			"DeleteWaiver",
+			strings.ToUpper("Delete"),
+			"/waivers/{waiver_id}",
+			DeleteWaiver,
+		},
+
+		Route{
+			"GetSpec",
+			strings.ToUpper("Get"),
+			"/spec",
+			GetSpec,
+		},
 	},
 }
 
 // API info.
 type API struct {
-	Name   string `json:"name"`
-	Method string `json:"method"`
-	Host   string `json:"host"'`
-	API    string `json:"API"`
+	Name    string `json:"name"`
+	Method  string `json:"method"`
+	Version string `json:"version,omitempty"`
+	Host    string `json:"host"`
+	API     string `json:"API"`
 }
 
-// APIIndex is index of all scanner adapter API.
-var APIIndex []*API
-
-func init() {
-	for _, r := range routes {
-		APIIndex = append(APIIndex, &API{
-			Name:   r.Name,
-			Method: r.Method,
-			API:    r.Pattern,
-		})
-	}
-}
+// apiIndex is index of all the registered scanner adapter API.
+var apiIndex []API
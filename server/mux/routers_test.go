@@ -0,0 +1,65 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/spf13/viper"
+)
+
+func TestNewRouterLegacyPaths(t *testing.T) {
+	cases := []struct {
+		method  string
+		path    string
+		legacy  bool
+		matched bool
+	}{
+		{http.MethodPost, "/api/v1/scan", true, true},
+		{http.MethodGet, "/api/v1/vex", true, true},
+		{http.MethodPost, "/scan", true, true},
+		{http.MethodGet, "/scan/abc/report", true, true},
+		{http.MethodGet, "/metadata", true, true},
+		{http.MethodGet, "/vex", true, false},
+		{http.MethodDelete, "/vex/abc", true, false},
+		{http.MethodGet, "/waivers", true, false},
+		{http.MethodGet, "/waivers/audit", true, false},
+		{http.MethodGet, "/spec", true, false},
+		{http.MethodPost, "/scan", false, false},
+		{http.MethodGet, "/metadata", false, false},
+		{http.MethodGet, "/api/v1/metadata", false, true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.method+" "+c.path, func(t *testing.T) {
+			t.Cleanup(viper.Reset)
+			viper.Set("server.legacyPaths", c.legacy)
+
+			router, err := NewRouter()
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var match mux.RouteMatch
+			matched := router.Match(httptest.NewRequest(c.method, c.path, nil), &match) && match.MatchErr == nil
+			if matched != c.matched {
+				t.Errorf("expect matched %v, got %v", c.matched, matched)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright Project Harbor Authors
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//    http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mux
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/spf13/viper"
+
+	"github.com/szlabs/harbor-scanner-adapter/pkg/zlog"
+	"github.com/szlabs/harbor-scanner-adapter/server/api"
+)
+
+// validateRequest validates the request body against the schema of the operation.
+func validateRequest(operationID string, data []byte) error {
+	doc, err := api.Load()
+	if err != nil {
+		return err
+	}
+
+	s, ok := doc.RequestSchema(operationID)
+	if !ok {
+		return nil
+	}
+
+	return doc.Validate(s, data)
+}
+
+// validateResponse validates the response body against the schema of the operation
+// if `server.validation.responses` is enabled.
+// The violations are only logged as the response is generated by the adapter itself.
+func validateResponse(r *http.Request, operationID string, status int, mediaType string, data []byte) {
+	if !viper.GetBool("server.validation.responses") {
+		return
+	}
+
+	lg := zlog.FromContext(r.Context())
+
+	doc, err := api.Load()
+	if err != nil {
+		lg.Errorw("load OpenAPI document error", "error", err)
+		return
+	}
+
+	s, ok := doc.ResponseSchema(operationID, status, mediaType)
+	if !ok {
+		return
+	}
+
+	if err := doc.Validate(s, data); err != nil {
+		lg.Warnw("response does not conform to the OpenAPI document",
+			"operation", operationID, "media_type", mediaType, "violations", err.Error())
+	}
+}
+
+// writeSpecJSON writes the object as a JSON response after validating it.
+func writeSpecJSON(w http.ResponseWriter, r *http.Request, operationID string, status int, contentType string, obj interface{}) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		SpecError(http.StatusInternalServerError, "marshal response error: %s", err).Write(w)
+		return
+	}
+
+	validateResponse(r, operationID, status, contentType, data)
+	JSON(data).WithStatus(status).WithContentType(contentType).Write(w)
+}
+
+// writeValidationError writes the validation error with the status code of its kind.
+func writeValidationError(w http.ResponseWriter, message string, err error) {
+	if ve, ok := err.(*api.ValidationError); ok {
+		SpecError(ve.Status(), "%s: %s", message, ve.Error()).Write(w)
+		return
+	}
+
+	SpecError(http.StatusInternalServerError, "%s: %s", message, err).Write(w)
+}
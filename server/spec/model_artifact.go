@@ -18,4 +18,6 @@ type Artifact struct {
 	Tag string `json:"tag,omitempty"`
 	// The MIME type of the artifact.
 	MimeType string `json:"mime_type,omitempty"`
+	// The reference of the artifact on the local filesystem of the adapter, e.g. oci-layout:/path or docker-archive:/path.tar. The registry is not accessed if it's set.
+	Reference string `json:"reference,omitempty"`
 }
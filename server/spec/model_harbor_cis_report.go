@@ -21,4 +21,6 @@ type HarborCISReport struct {
 	Scanner *Scanner `json:"scanner,omitempty"`
 
 	Benchmarks *CISReportBody `json:"benchmarks,omitempty"`
+	// The benchmarks of each platform if the artifact is an image index.
+	Platforms []CISPlatformReport `json:"platforms,omitempty"`
 }
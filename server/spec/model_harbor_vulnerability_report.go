@@ -23,4 +23,6 @@ type HarborVulnerabilityReport struct {
 	Severity *Severity `json:"severity,omitempty"`
 
 	Vulnerabilities []VulnerabilityItem `json:"vulnerabilities,omitempty"`
+	// The attributes of the report added by the adapter, e.g. the vulnerabilities suppressed by the VEX statements.
+	VendorAttributes map[string]interface{} `json:"vendor_attributes,omitempty"`
 }
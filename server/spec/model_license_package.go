@@ -0,0 +1,30 @@
+/*
+ * Harbor Scanner Adapter API
+ *
+ * ## Overview  This API must be implemented in order to register a new artifact scanner in [Harbor](https://goharbor.io) registry.  The [/scan](#operation/AcceptScanRequest) and [/scan/{scan_request_id}/report](#operation/GetScanReport) operations are responsible for the actual scanning and return a scan report that is visible in the Harbor web console.  The [/scan](#operation/AcceptScanRequest) operation is asynchronous. It should enqueue the job for processing a scan request and return the identifier. This allows Harbor to poll a corresponding scan report with the [/scan/{scan_request_id}/report](#operation/GetScanReport) operation. Harbor will call the [/scan/{scan_request_id}/report](#operation/GetScanReport) operation periodically periodically until it returns 200 or 500 status codes.  The [/metadata](#operation/GetMetadata) operation allows a Harbor admin to configure and register a scanner and discover its capabilities.  ## Supported consumed MIME types  - `application/vnd.oci.image.manifest.v1+json` - `application/vnd.docker.distribution.manifest.v2+json`  ## Supported produced MIME types  - `application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0` - `application/vnd.security.vulnerability.report; version=1.1` - `application/vnd.scanner.adapter.vuln.report.raw` - `application/vnd.security.cis.report; version=1.0`
+ *
+ * API version: 1.2
+ * Contact: cncf-harbor-maintainers@lists.cncf.io
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+package spec
+
+// LicensePackage is a package with the declared licenses.
+type LicensePackage struct {
+	// The name of the package.
+	Name string `json:"name"`
+	// The version of the package.
+	Version string `json:"version,omitempty"`
+	// The type of the package, e.g. deb, apk, rpm, npm or python.
+	Type string `json:"type"`
+	// The path of the file declaring the package.
+	Path string `json:"path,omitempty"`
+	// The digest of the layer containing the file.
+	Layer string `json:"layer,omitempty"`
+	// The license expression declared by the package.
+	Declared string `json:"declared,omitempty"`
+	// The resolved SPDX license identifiers.
+	Licenses []string `json:"licenses,omitempty"`
+	// The classification against the license policy: allowed, denied or unknown.
+	Classification string `json:"classification"`
+}
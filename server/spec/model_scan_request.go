@@ -13,4 +13,6 @@ type ScanRequest struct {
 	Registry *Registry `json:"registry"`
 
 	Artifact *Artifact `json:"artifact"`
+	// The capabilities enabled for this scan request. All the capabilities are enabled if it's empty.
+	EnabledCapabilities []ScanType `json:"enabled_capabilities,omitempty"`
 }
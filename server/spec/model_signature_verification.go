@@ -0,0 +1,38 @@
+/*
+ * Harbor Scanner Adapter API
+ *
+ * ## Overview  This API must be implemented in order to register a new artifact scanner in [Harbor](https://goharbor.io) registry.  The [/scan](#operation/AcceptScanRequest) and [/scan/{scan_request_id}/report](#operation/GetScanReport) operations are responsible for the actual scanning and return a scan report that is visible in the Harbor web console.  The [/scan](#operation/AcceptScanRequest) operation is asynchronous. It should enqueue the job for processing a scan request and return the identifier. This allows Harbor to poll a corresponding scan report with the [/scan/{scan_request_id}/report](#operation/GetScanReport) operation. Harbor will call the [/scan/{scan_request_id}/report](#operation/GetScanReport) operation periodically periodically until it returns 200 or 500 status codes.  The [/metadata](#operation/GetMetadata) operation allows a Harbor admin to configure and register a scanner and discover its capabilities.  ## Supported consumed MIME types  - `application/vnd.oci.image.manifest.v1+json` - `application/vnd.docker.distribution.manifest.v2+json`  ## Supported produced MIME types  - `application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0` - `application/vnd.security.vulnerability.report; version=1.1` - `application/vnd.scanner.adapter.vuln.report.raw` - `application/vnd.security.cis.report; version=1.0`
+ *
+ * API version: 1.2
+ * Contact: cncf-harbor-maintainers@lists.cncf.io
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+package spec
+
+// SignatureVerification is the verification result of a signature or an attestation of the artifact.
+type SignatureVerification struct {
+	// The type of the verified object: signature, provenance or attestation.
+	Type string `json:"type"`
+	// The format of the signature: cosign, notation or in-toto.
+	Format string `json:"format"`
+	// How the signature is discovered: referrers or tag.
+	Source string `json:"source"`
+	// The digest of the signature manifest.
+	Digest string `json:"digest"`
+	// Whether the signature is verified by the trusted keys or certificate roots.
+	Verified bool `json:"verified"`
+	// The identity of the signer, e.g. the subject of the signing certificate.
+	Signer string `json:"signer,omitempty"`
+	// The issuer of the signing certificate, e.g. the OIDC issuer of the keyless signatures.
+	Issuer string `json:"issuer,omitempty"`
+	// The ID of the trusted key verifying the signature.
+	KeyID string `json:"key_id,omitempty"`
+	// The predicate type of the attestation.
+	PredicateType string `json:"predicate_type,omitempty"`
+	// The builder ID of the provenance.
+	Builder string `json:"builder,omitempty"`
+	// The build type of the provenance.
+	BuildType string `json:"build_type,omitempty"`
+	// The reason why the signature is not verified.
+	Error string `json:"error,omitempty"`
+}
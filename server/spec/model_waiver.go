@@ -0,0 +1,39 @@
+/*
+ * Harbor Scanner Adapter API
+ *
+ * ## Overview  This API must be implemented in order to register a new artifact scanner in [Harbor](https://goharbor.io) registry.  The [/scan](#operation/AcceptScanRequest) and [/scan/{scan_request_id}/report](#operation/GetScanReport) operations are responsible for the actual scanning and return a scan report that is visible in the Harbor web console.  The [/scan](#operation/AcceptScanRequest) operation is asynchronous. It should enqueue the job for processing a scan request and return the identifier. This allows Harbor to poll a corresponding scan report with the [/scan/{scan_request_id}/report](#operation/GetScanReport) operation. Harbor will call the [/scan/{scan_request_id}/report](#operation/GetScanReport) operation periodically periodically until it returns 200 or 500 status codes.  The [/metadata](#operation/GetMetadata) operation allows a Harbor admin to configure and register a scanner and discover its capabilities.  ## Supported consumed MIME types  - `application/vnd.oci.image.manifest.v1+json` - `application/vnd.docker.distribution.manifest.v2+json`  ## Supported produced MIME types  - `application/vnd.scanner.adapter.vuln.report.harbor+json; version=1.0` - `application/vnd.security.vulnerability.report; version=1.1` - `application/vnd.scanner.adapter.vuln.report.raw` - `application/vnd.security.cis.report; version=1.0`
+ *
+ * API version: 1.2
+ * Contact: cncf-harbor-maintainers@lists.cncf.io
+ * Generated by: Swagger Codegen (https://github.com/swagger-api/swagger-codegen.git)
+ */
+package spec
+
+import (
+	"time"
+)
+
+type Waiver struct {
+	// The ID of the waiver, assigned by the adapter.
+	Id string `json:"id,omitempty"`
+	// The vulnerability ID, e.g. CVE-2023-44487, or the code of the CIS benchmark item waived.
+	Cve string `json:"cve"`
+	// The package of the vulnerability, all the packages if it's empty.
+	Package_ string `json:"package,omitempty"`
+	// The glob pattern of the repositories, e.g. library/*, all the repositories if it's empty.
+	Repository string `json:"repository,omitempty"`
+	// The project of the repositories, all the projects if it's empty.
+	Project string `json:"project,omitempty"`
+	// The owner accepting the risk.
+	Owner string `json:"owner"`
+	// The reason of accepting the risk.
+	Reason string `json:"reason"`
+	// The waiver does not apply after the expiry.
+	ExpiresAt time.Time `json:"expires_at"`
+
+	CreatedAt *time.Time `json:"created_at,omitempty"`
+
+	UpdatedAt *time.Time `json:"updated_at,omitempty"`
+	// Whether the waiver is expired, read only.
+	Expired bool `json:"expired"`
+}